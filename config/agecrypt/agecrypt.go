@@ -0,0 +1,39 @@
+// Package agecrypt decrypts config values encrypted with age, so the core
+// config package does not depend on it:
+//
+//	cfg := config.NewConfig(
+//		config.WithDecryptor("age", agecrypt.NewDecryptor(identity)),
+//	)
+package agecrypt
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+
+	"filippo.io/age"
+	"github.com/ducconit/gocore/config"
+)
+
+// NewDecryptor creates a decryptor for base64 encoded age ciphertexts
+func NewDecryptor(identities ...age.Identity) config.Decryptor {
+	return config.DecryptorFunc(func(ctx context.Context, ciphertext string) (string, error) {
+		blob, err := base64.StdEncoding.DecodeString(ciphertext)
+		if err != nil {
+			return "", fmt.Errorf("invalid age ciphertext: %w", err)
+		}
+
+		r, err := age.Decrypt(bytes.NewReader(blob), identities...)
+		if err != nil {
+			return "", err
+		}
+
+		plaintext, err := io.ReadAll(r)
+		if err != nil {
+			return "", err
+		}
+		return string(plaintext), nil
+	})
+}
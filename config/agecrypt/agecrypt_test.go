@@ -0,0 +1,32 @@
+package agecrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/ducconit/gocore/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDecryptor(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	assert.NoError(t, err)
+
+	var encrypted bytes.Buffer
+	w, err := age.Encrypt(&encrypted, identity.Recipient())
+	assert.NoError(t, err)
+	_, err = w.Write([]byte("s3cret"))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+
+	cfg := config.NewConfig(config.WithDecryptor("age", NewDecryptor(identity)))
+	cfg.Set("db.password", "ENC[age:"+base64.StdEncoding.EncodeToString(encrypted.Bytes())+"]")
+	assert.Equal(t, "s3cret", cfg.GetString("db.password"))
+
+	other, err := age.GenerateX25519Identity()
+	assert.NoError(t, err)
+	_, err = NewDecryptor(other).Decrypt(t.Context(), base64.StdEncoding.EncodeToString(encrypted.Bytes()))
+	assert.Error(t, err)
+}
@@ -0,0 +1,144 @@
+// Package awsconfig provides config sources reading AWS SSM Parameter Store
+// and Secrets Manager, so the core config package does not depend on the
+// AWS SDK:
+//
+//	awsCfg, _ := sdkconfig.LoadDefaultConfig(ctx)
+//	err := cfg.LoadFromSource(awsconfig.SSM(ssm.NewFromConfig(awsCfg), "/app/prod"),
+//		config.WithRefreshInterval(5*time.Minute),
+//	)
+package awsconfig
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/ducconit/gocore/config"
+)
+
+// SSMClient is the subset of *ssm.Client used by SSM
+type SSMClient interface {
+	GetParametersByPath(ctx context.Context, params *ssm.GetParametersByPathInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersByPathOutput, error)
+}
+
+// SecretsManagerClient is the subset of *secretsmanager.Client used by SecretsManager
+type SecretsManagerClient interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+// Option represents an SSM source option
+type Option func(*ssmSource)
+
+// WithDecryption sets whether SecureString parameters are decrypted, true by default
+func WithDecryption(enabled bool) Option {
+	return func(s *ssmSource) {
+		s.decrypt = enabled
+	}
+}
+
+// ssmSource reads the parameters stored under an SSM path
+type ssmSource struct {
+	client  SSMClient
+	path    string
+	decrypt bool
+}
+
+// SSM returns a source of every parameter under path (recursively) in AWS
+// SSM Parameter Store. The parameter /app/prod/db/host read from path
+// /app/prod becomes the key db.host.
+func SSM(client SSMClient, path string, opts ...Option) config.Source {
+	s := &ssmSource{
+		client:  client,
+		path:    "/" + strings.Trim(path, "/"),
+		decrypt: true,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+func (s *ssmSource) Name() string {
+	return "ssm"
+}
+
+func (s *ssmSource) Load(ctx context.Context) (map[string]any, error) {
+	values := make(map[string]any)
+	input := &ssm.GetParametersByPathInput{
+		Path:           aws.String(s.path),
+		Recursive:      aws.Bool(true),
+		WithDecryption: aws.Bool(s.decrypt),
+	}
+	for {
+		out, err := s.client.GetParametersByPath(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load ssm parameters: %w", err)
+		}
+
+		for _, param := range out.Parameters {
+			name := strings.Trim(strings.TrimPrefix(aws.ToString(param.Name), s.path), "/")
+			if name == "" {
+				continue
+			}
+			key := strings.ReplaceAll(name, "/", ".")
+
+			value := aws.ToString(param.Value)
+			if param.Type == ssmTypes.ParameterTypeStringList {
+				values[key] = strings.Split(value, ",")
+			} else {
+				values[key] = value
+			}
+		}
+
+		if out.NextToken == nil {
+			break
+		}
+		input.NextToken = out.NextToken
+	}
+	return values, nil
+}
+
+// secretSource reads a single secret from AWS Secrets Manager
+type secretSource struct {
+	client   SecretsManagerClient
+	secretID string
+}
+
+// SecretsManager returns a source of a secret from AWS Secrets Manager. A
+// JSON object secret is expanded into one key per field, any other secret
+// is stored under the secret ID.
+func SecretsManager(client SecretsManagerClient, secretID string) config.Source {
+	return &secretSource{client: client, secretID: secretID}
+}
+
+func (s *secretSource) Name() string {
+	return "secretsmanager"
+}
+
+func (s *secretSource) Load(ctx context.Context) (map[string]any, error) {
+	out, err := s.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(s.secretID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load secret %s: %w", s.secretID, err)
+	}
+
+	secret := aws.ToString(out.SecretString)
+	if out.SecretString == nil {
+		secret = string(out.SecretBinary)
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(secret), &fields); err == nil {
+		return fields, nil
+	}
+	return map[string]any{s.secretID: secret}, nil
+}
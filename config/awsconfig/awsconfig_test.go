@@ -0,0 +1,101 @@
+package awsconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/ducconit/gocore/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSSM struct {
+	params map[string]string
+	pages  int
+}
+
+func (f *fakeSSM) GetParametersByPath(ctx context.Context, in *ssm.GetParametersByPathInput, _ ...func(*ssm.Options)) (*ssm.GetParametersByPathOutput, error) {
+	f.pages++
+	out := &ssm.GetParametersByPathOutput{}
+	for name, value := range f.params {
+		paramType := ssmTypes.ParameterTypeString
+		if !aws.ToBool(in.WithDecryption) && strings.HasSuffix(name, "password") {
+			value = "ciphertext"
+			paramType = ssmTypes.ParameterTypeSecureString
+		}
+		out.Parameters = append(out.Parameters, ssmTypes.Parameter{
+			Name:  aws.String(name),
+			Value: aws.String(value),
+			Type:  paramType,
+		})
+	}
+	return out, nil
+}
+
+type fakeSecretsManager struct {
+	secret string
+}
+
+func (f *fakeSecretsManager) GetSecretValue(ctx context.Context, in *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(f.secret)}, nil
+}
+
+func TestLoadFromAWS(t *testing.T) {
+	t.Run("ssm", func(t *testing.T) {
+		client := &fakeSSM{params: map[string]string{
+			"/app/prod/db/host":     "db.internal",
+			"/app/prod/db/password": "hunter2",
+		}}
+
+		cfg := config.NewConfig()
+		assert.NoError(t, cfg.LoadFromSource(SSM(client, "/app/prod")))
+		assert.Equal(t, "db.internal", cfg.GetString("db.host"))
+		assert.Equal(t, "hunter2", cfg.GetString("db.password"))
+
+		client.params["/app/prod/db/host"] = "db2.internal"
+		delete(client.params, "/app/prod/db/password")
+		assert.NoError(t, cfg.Reload())
+		assert.Equal(t, "db2.internal", cfg.GetString("db.host"))
+		assert.False(t, cfg.IsSet("db.password"))
+
+		// SecureString values stay encrypted without decryption
+		client.params["/app/prod/db/password"] = "hunter2"
+		raw := config.NewConfig()
+		assert.NoError(t, raw.LoadFromSource(SSM(client, "/app/prod", WithDecryption(false))))
+		assert.Equal(t, "ciphertext", raw.GetString("db.password"))
+	})
+
+	t.Run("secrets_manager", func(t *testing.T) {
+		cfg := config.NewConfig()
+		client := &fakeSecretsManager{secret: `{"username": "admin", "password": "s3cret"}`}
+		assert.NoError(t, cfg.LoadFromSource(SecretsManager(client, "prod/db"), config.WithKeyPrefix("db.")))
+		assert.Equal(t, "admin", cfg.GetString("db.username"))
+		assert.Equal(t, "s3cret", cfg.GetString("db.password"))
+
+		plain := config.NewConfig()
+		assert.NoError(t, plain.LoadFromSource(SecretsManager(&fakeSecretsManager{secret: "token"}, "api-token")))
+		assert.Equal(t, "token", plain.GetString("api-token"))
+	})
+}
+
+type fakeKMS struct{}
+
+func (fakeKMS) Decrypt(ctx context.Context, in *kms.DecryptInput, _ ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+	return &kms.DecryptOutput{Plaintext: bytes.ToUpper(in.CiphertextBlob)}, nil
+}
+
+func TestNewKMSDecryptor(t *testing.T) {
+	cfg := config.NewConfig(config.WithDecryptor("kms", NewKMSDecryptor(fakeKMS{})))
+	cfg.Set("db.password", "ENC[kms:"+base64.StdEncoding.EncodeToString([]byte("s3cret"))+"]")
+	assert.Equal(t, "S3CRET", cfg.GetString("db.password"))
+
+	_, err := NewKMSDecryptor(fakeKMS{}).Decrypt(context.Background(), "not base64!")
+	assert.Error(t, err)
+}
@@ -0,0 +1,31 @@
+package awsconfig
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/ducconit/gocore/config"
+)
+
+// KMSClient is the subset of *kms.Client used by NewKMSDecryptor
+type KMSClient interface {
+	Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error)
+}
+
+// NewKMSDecryptor creates a decryptor for base64 encoded AWS KMS ciphertext blobs
+func NewKMSDecryptor(client KMSClient) config.Decryptor {
+	return config.DecryptorFunc(func(ctx context.Context, ciphertext string) (string, error) {
+		blob, err := base64.StdEncoding.DecodeString(ciphertext)
+		if err != nil {
+			return "", fmt.Errorf("invalid kms ciphertext: %w", err)
+		}
+
+		out, err := client.Decrypt(ctx, &kms.DecryptInput{CiphertextBlob: blob})
+		if err != nil {
+			return "", err
+		}
+		return string(out.Plaintext), nil
+	})
+}
@@ -1,18 +1,24 @@
 package config
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
-	"path/filepath"
 	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/fsnotify/fsnotify"
+	"github.com/ducconit/gocore/logger"
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
@@ -32,12 +38,26 @@ type Config interface {
 	AllKeys() []string
 
 	// Extended methods
-	Set(key string, value any)
-	SetDefault(key string, value any)
+	Set(key string, value any) error
+	SetDefault(key string, value any) error
+	SetDefaultsFromStruct(s any) error
+	Freeze()
+	IsFrozen() bool
 	LoadFromFile(path string, options ...Option) error
 	LoadFromDB(db any, tableName string) error
+	LoadFromURL(url string, options ...Option) error
+	LoadFromDir(dir string, options ...Option) error
+	LoadFromSource(src Source, options ...Option) error
+	BindFlags(fs *pflag.FlagSet) error
+	BindFlag(key string, flag *pflag.Flag) error
 	Reload() error
+	ReloadLayer(name string) error
+	Layers() []string
 	Watch(key string, callback func(any))
+	History() []Snapshot
+	Rollback(n int) error
+	Events() <-chan ChangeEvent
+	ValidateSchema(schema any) error
 
 	// Additional Viper Get methods
 	GetDuration(key string) time.Duration
@@ -61,116 +81,244 @@ type Option func(*viperConfig)
 // WithConfigType sets the config type (yaml, json, etc)
 func WithConfigType(configType string) Option {
 	return func(c *viperConfig) {
-		c.SetConfigType(configType)
+		c.configType = configType
 	}
 }
 
 // WithEnvPrefix sets the environment variables prefix
 func WithEnvPrefix(prefix string) Option {
 	return func(c *viperConfig) {
-		c.SetEnvPrefix(prefix)
+		c.state.envPrefix = prefix
+	}
+}
+
+// WithInterpolation enables ${...} expansion of values on read, off by default
+func WithInterpolation(enabled bool) Option {
+	return func(c *viperConfig) {
+		c.interpolation = enabled
+	}
+}
+
+// WithContext sets the context that bounds background refresh loops
+func WithContext(ctx context.Context) Option {
+	return func(c *viperConfig) {
+		c.ctx = ctx
+	}
+}
+
+// WithLogger sets the logger reporting the errors of background reloads, the
+// file and directory watchers and refresh intervals, which have no caller to
+// return them to. Defaults to the global logger.
+func WithLogger(l *logger.Logger) Option {
+	return func(c *viperConfig) {
+		c.logger = l
+	}
+}
+
+// WithRefreshInterval enables periodic refresh of remote sources
+func WithRefreshInterval(interval time.Duration) Option {
+	return func(c *viperConfig) {
+		c.refresh = interval
 	}
 }
 
 // WithEnvKeyReplacer sets the environment key replacer
 func WithEnvKeyReplacer(oldNew ...string) Option {
 	return func(c *viperConfig) {
-		c.SetEnvKeyReplacer(strings.NewReplacer(oldNew...))
+		c.state.envReplacer = strings.NewReplacer(oldNew...)
 	}
 }
 
 type viperConfig struct {
-	*viper.Viper
-	watchMu   sync.RWMutex
-	watches   map[string][]func(any)
-	lastState map[string]any
+	stateMu       sync.Mutex
+	state         *state
+	current       atomic.Pointer[viper.Viper]
+	watchMu       sync.RWMutex
+	watches       map[string][]func(any)
+	lastState     map[string]any
+	interpolation bool
+	configType    string
+	configFile    string
+	keyPrefix     string
+	decryptors    *decryptors
+	history       history
+	frozen        atomic.Bool
+	frozenPanic   bool
+	events        eventHub
+	validators    []Validator
+	decodeHooks   []mapstructure.DecodeHookFunc
+	debounce      time.Duration
+	reloadMu      sync.Mutex
+	watchOnce     sync.Once
+	watchErr      error
+	ctx           context.Context
+	http          httpOptions
+	logger        *logger.Logger
+	sources       []source
+	layers        []*layer
+	refresh       time.Duration
+}
+
+// source is a config origin that knows how to re-read itself
+type source interface {
+	refresh(ctx context.Context) error
 }
 
 // NewConfig creates a new configuration instance
-func NewConfig() Config {
-	v := viper.New()
-	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
-	v.AutomaticEnv()
+func NewConfig(options ...Option) Config {
+	return newViperConfig(&state{
+		envReplacer:  strings.NewReplacer(".", "_"),
+		automaticEnv: true,
+	}, options...)
+}
 
-	return &viperConfig{
-		Viper:     v,
-		watches:   make(map[string][]func(any)),
-		lastState: make(map[string]any),
+func newViperConfig(s *state, options ...Option) *viperConfig {
+	c := &viperConfig{
+		state:      s,
+		watches:    make(map[string][]func(any)),
+		lastState:  make(map[string]any),
+		decryptors: &decryptors{},
+		history:    history{size: DefaultHistorySize},
+		events:     eventHub{buffer: DefaultEventBuffer},
+		debounce:   DefaultReloadDebounce,
+		ctx:        context.Background(),
+		http: httpOptions{
+			client: http.DefaultClient,
+			header: make(http.Header),
+		},
 	}
-}
 
-func (c *viperConfig) LoadFromFile(path string, options ...Option) error {
 	// Apply options
+	c.apply(options)
+
+	v, _ := c.state.build()
+	c.current.Store(v)
+
+	return c
+}
+
+// apply applies options, they may change the state
+func (c *viperConfig) apply(options []Option) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
 	for _, opt := range options {
 		opt(c)
 	}
+}
 
-	// If config type not set, infer from extension
-	if c.ConfigFileUsed() == "" {
-		ext := filepath.Ext(path)
-		c.SetConfigType(strings.TrimPrefix(ext, "."))
+func (c *viperConfig) LoadFromFile(path string, options ...Option) error {
+	if err := c.checkFrozen("load " + path); err != nil {
+		return err
 	}
 
+	// Apply options
+	c.apply(options)
+
 	// Check if file exists
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		return fmt.Errorf("config file not found: %s", path)
 	}
 
-	// Read config file
-	c.SetConfigFile(path)
-	if err := c.ReadInConfig(); err != nil {
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+
+	// Read config file, the type is inferred from the extension if not set
+	c.configFile = path
+	if err := c.readFile(); err != nil {
 		return fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	// Watch for changes if fsnotify is enabled
-	c.WatchConfig()
-	c.OnConfigChange(func(e fsnotify.Event) {
-		if err := c.Reload(); err != nil {
-			// Log error but don't fail
-			fmt.Printf("failed to reload config: %v\n", err)
-		}
-	})
+	// Watch for changes
+	if err := c.watchFile(path); err != nil {
+		return err
+	}
 
-	// Update last state
-	c.updateLastState()
+	c.applied("file")
 
 	return nil
 }
 
 func (c *viperConfig) LoadFromDB(db any, tableName string) error {
-	var data map[string]any
-	var err error
-
-	switch v := db.(type) {
-	case *sql.DB:
-		data, err = loadFromSQL(v, tableName)
-	case *gorm.DB:
-		data, err = loadFromGorm(v, tableName)
-	default:
-		return fmt.Errorf("unsupported database type: %T", db)
+	if err := c.checkFrozen("load " + tableName); err != nil {
+		return err
 	}
 
+	data, err := loadFromDB(db, tableName)
 	if err != nil {
 		return err
 	}
 
 	// Set all values from database
-	for key, value := range data {
-		c.Set(key, value)
+	err = c.update(false, func(s *state) error {
+		for key, value := range data {
+			s.set(key, value)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	// Update last state
-	c.updateLastState()
+	c.applied("db")
 
 	return nil
 }
 
 func (c *viperConfig) Reload() error {
-	if err := c.ReadInConfig(); err != nil {
-		return fmt.Errorf("failed to reload config: %w", err)
+	if err := c.checkFrozen("reload"); err != nil {
+		return err
+	}
+	return c.reload()
+}
+
+// reload reloads every source, it is the Reload of the file watcher
+func (c *viperConfig) reload() error {
+	if err := c.frozenErr("reload"); err != nil {
+		return err
+	}
+
+	// Serialize explicit reloads with the ones triggered by the file watcher
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+
+	if len(c.layers) > 0 {
+		if err := c.reloadLayers(c.layers...); err != nil {
+			return fmt.Errorf("failed to reload config: %w", err)
+		}
+	}
+
+	if c.configFile != "" || (len(c.sources) == 0 && len(c.layers) == 0) {
+		if err := c.readFile(); err != nil {
+			return fmt.Errorf("failed to reload config: %w", err)
+		}
+		c.applied("file")
+	}
+
+	for _, src := range c.sources {
+		if err := src.refresh(c.ctx); err != nil {
+			return fmt.Errorf("failed to reload config: %w", err)
+		}
 	}
 
-	// Check for changes and notify watchers
+	return nil
+}
+
+// applied runs after a source has been (re)loaded: it notifies watchers of
+// changed keys and records the new state in the history
+func (c *viperConfig) applied(source string) {
+	c.notifyWatchers()
+
+	// Update last state
+	c.updateLastState()
+
+	c.history.record(source, c.viper().AllSettings())
+
+	c.emitChanges(source)
+}
+
+// notifyWatchers calls the callbacks of every watched key whose value changed
+func (c *viperConfig) notifyWatchers() {
 	c.watchMu.RLock()
 	defer c.watchMu.RUnlock()
 
@@ -183,11 +331,73 @@ func (c *viperConfig) Reload() error {
 			}
 		}
 	}
+}
 
-	// Update last state
-	c.updateLastState()
+// addSource registers a source for Reload and starts polling it if a refresh interval is set
+func (c *viperConfig) addSource(src source) {
+	c.registerSource(src)
 
-	return nil
+	if c.refresh > 0 {
+		go c.poll(c.ctx, src, c.refresh)
+	}
+}
+
+// logError reports an error that has no caller to return it to, such as
+// those of background reloads
+func (c *viperConfig) logError(msg string, err error, fields ...zap.Field) {
+	c.stateMu.Lock()
+	l := c.logger
+	c.stateMu.Unlock()
+
+	if l == nil {
+		l = logger.With()
+	}
+	l.Error(msg, append(fields, zap.Error(err))...)
+}
+
+// registerSource adds src to the sources refreshed by Reload. It waits for
+// a running reload, which iterates over the sources.
+func (c *viperConfig) registerSource(src source) {
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+	c.sources = append(c.sources, src)
+}
+
+func (c *viperConfig) poll(ctx context.Context, src source, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := src.refresh(ctx); err != nil && !errors.Is(err, ErrFrozen) {
+				c.logError("failed to refresh config", err)
+			}
+		}
+	}
+}
+
+// replaceKeys sets values and unsets the previously loaded keys that are no longer present
+func (c *viperConfig) replaceKeys(previous map[string]bool, values map[string]any) (map[string]bool, error) {
+	keys := make(map[string]bool, len(values))
+	err := c.update(false, func(s *state) error {
+		for key, value := range values {
+			keys[key] = true
+			s.set(key, value)
+		}
+		for key := range previous {
+			if !keys[key] {
+				s.set(key, nil)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return previous, err
+	}
+	return keys, nil
 }
 
 func (c *viperConfig) updateLastState() {
@@ -207,12 +417,47 @@ func (c *viperConfig) Watch(key string, callback func(any)) {
 	callback(c.Get(key))
 }
 
-func (c *viperConfig) Set(key string, value any) {
-	c.Viper.Set(key, value)
+func (c *viperConfig) Set(key string, value any) error {
+	if err := c.checkFrozen("set " + key); err != nil {
+		return err
+	}
+
+	if err := c.update(false, func(s *state) error {
+		s.set(key, value)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	c.emitChanges("set")
+	return nil
+}
+
+func (c *viperConfig) SetDefault(key string, value any) error {
+	if err := c.checkFrozen("set default " + key); err != nil {
+		return err
+	}
+
+	if err := c.update(false, func(s *state) error {
+		s.setDefault(key, value)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	c.emitChanges("default")
+	return nil
 }
 
-func (c *viperConfig) SetDefault(key string, value any) {
-	c.Viper.SetDefault(key, value)
+func loadFromDB(db any, tableName string) (map[string]any, error) {
+	switch v := db.(type) {
+	case *sql.DB:
+		return loadFromSQL(v, tableName)
+	case *gorm.DB:
+		return loadFromGorm(v, tableName)
+	default:
+		return nil, fmt.Errorf("unsupported database type: %T", db)
+	}
 }
 
 func loadFromSQL(db *sql.DB, tableName string) (map[string]any, error) {
@@ -1,13 +1,29 @@
 package config
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/ducconit/gocore/logger"
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -156,3 +172,898 @@ func TestExtendedMethods(t *testing.T) {
 		assert.True(t, watchCalled)
 	})
 }
+
+func TestInterpolation(t *testing.T) {
+	t.Setenv("GOCORE_TEST_TOKEN", "secret")
+
+	cfg := NewConfig(WithInterpolation(true))
+	cfg.Set("api.base", "https://api.example.com")
+	cfg.Set("api.users", "${api.base}/users")
+	cfg.Set("api.nested", "${api.users}/1")
+	cfg.Set("api.token", "${GOCORE_TEST_TOKEN}")
+	cfg.Set("api.fallback", "${GOCORE_TEST_MISSING:-none}")
+	cfg.Set("api.escaped", "$${api.base}")
+	cfg.Set("api.list", []string{"${api.base}", "plain"})
+	cfg.Set("loop.a", "${loop.b}")
+	cfg.Set("loop.b", "${loop.a}")
+
+	t.Run("references", func(t *testing.T) {
+		assert.Equal(t, "https://api.example.com/users", cfg.GetString("api.users"))
+		assert.Equal(t, "https://api.example.com/users/1", cfg.GetString("api.nested"))
+		assert.Equal(t, []string{"https://api.example.com", "plain"}, cfg.GetStringSlice("api.list"))
+	})
+
+	t.Run("environment_and_defaults", func(t *testing.T) {
+		assert.Equal(t, "secret", cfg.GetString("api.token"))
+		assert.Equal(t, "none", cfg.GetString("api.fallback"))
+	})
+
+	t.Run("escaping", func(t *testing.T) {
+		assert.Equal(t, "${api.base}", cfg.GetString("api.escaped"))
+
+		cfg.Set("api.doc", "see $${api.base} for ${api.base}")
+		assert.Equal(t, "see ${api.base} for https://api.example.com", cfg.GetString("api.doc"))
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		assert.Equal(t, "${loop.b}", cfg.GetString("loop.a"))
+	})
+
+	t.Run("unmarshal", func(t *testing.T) {
+		var api struct {
+			Users string `mapstructure:"users"`
+		}
+		assert.NoError(t, cfg.UnmarshalKey("api", &api))
+		assert.Equal(t, "https://api.example.com/users", api.Users)
+	})
+
+	t.Run("disabled_by_default", func(t *testing.T) {
+		raw := NewConfig()
+		raw.Set("a", "${b}")
+		raw.Set("b", "value")
+		raw.Set("c", "$${b}")
+		assert.Equal(t, "${b}", raw.GetString("a"))
+		assert.Equal(t, "$${b}", raw.GetString("c"))
+	})
+}
+
+func TestLoadFromURL(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		body     = `{"server": {"port": 8080}}`
+		etag     = `"v1"`
+		requests int
+		notMod   int
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		requests++
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		if r.Header.Get("If-None-Match") == etag {
+			notMod++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := NewConfig()
+	err := cfg.LoadFromURL(srv.URL+"/config",
+		WithBearerToken("token"),
+		WithRefreshInterval(20*time.Millisecond),
+		WithContext(ctx),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 8080, cfg.GetInt("server.port"))
+
+	changed := make(chan any, 1)
+	cfg.Watch("server.port", func(value any) {
+		select {
+		case changed <- value:
+		default:
+		}
+	})
+	<-changed
+
+	// Unchanged document is answered with 304
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	assert.Greater(t, notMod, 0)
+	body = `{"server": {"port": 9090}}`
+	etag = `"v2"`
+	mu.Unlock()
+
+	select {
+	case value := <-changed:
+		assert.EqualValues(t, 9090, value)
+	case <-time.After(time.Second):
+		t.Fatal("watcher not triggered after refresh")
+	}
+	assert.Equal(t, 9090, cfg.GetInt("server.port"))
+}
+
+func TestLoadFromURL_KeepsFile(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`{"server": {"port": 9090}, "remote": "yes"}`))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("server:\n  host: localhost\n  port: 8080\n"), 0644))
+
+	cfg := NewConfig()
+	assert.NoError(t, cfg.LoadFromFile(path))
+	assert.NoError(t, cfg.LoadFromURL(srv.URL+"/config.json"))
+
+	check := func() {
+		assert.Equal(t, "localhost", cfg.GetString("server.host"))
+		assert.Equal(t, 9090, cfg.GetInt("server.port"))
+		assert.Equal(t, "yes", cfg.GetString("remote"))
+	}
+	check()
+
+	// The file is read again and the URL answers 304, both are kept
+	assert.NoError(t, cfg.Reload())
+	check()
+}
+
+func TestLoadFromDir_KubernetesRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	// writeVersion mimics the kubelet's atomic writer: a timestamped data
+	// directory, a ..data symlink swapped by rename, and per-key links into it
+	writeVersion := func(version string, data map[string]string) {
+		versionDir := filepath.Join(dir, version)
+		assert.NoError(t, os.Mkdir(versionDir, 0755))
+		for key, value := range data {
+			assert.NoError(t, os.WriteFile(filepath.Join(versionDir, key), []byte(value), 0644))
+		}
+		tmpLink := filepath.Join(dir, "..data_tmp")
+		assert.NoError(t, os.Symlink(version, tmpLink))
+		assert.NoError(t, os.Rename(tmpLink, filepath.Join(dir, "..data")))
+		for key := range data {
+			link := filepath.Join(dir, key)
+			if _, err := os.Lstat(link); os.IsNotExist(err) {
+				assert.NoError(t, os.Symlink(filepath.Join("..data", key), link))
+			}
+		}
+	}
+
+	writeVersion("..2024_01_01", map[string]string{"password": "old\n"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := NewConfig()
+	err := cfg.LoadFromDir(dir, WithKeyPrefix("db."), WithContext(ctx))
+	assert.NoError(t, err)
+	assert.Equal(t, "old", cfg.GetString("db.password"))
+
+	changed := make(chan any, 2)
+	cfg.Watch("db.password", func(value any) {
+		changed <- value
+	})
+	<-changed
+
+	writeVersion("..2024_01_02", map[string]string{"password": "new\n"})
+
+	select {
+	case value := <-changed:
+		assert.Equal(t, "new", value)
+	case <-time.After(2 * time.Second):
+		t.Fatal("watcher not triggered after rotation")
+	}
+}
+
+type fakeSource struct {
+	values map[string]any
+}
+
+func (f *fakeSource) Name() string {
+	return "fake"
+}
+
+func (f *fakeSource) Load(ctx context.Context) (map[string]any, error) {
+	return maps.Clone(f.values), nil
+}
+
+func TestLoadFromSource(t *testing.T) {
+	src := &fakeSource{values: map[string]any{"host": "db.internal", "password": "hunter2"}}
+
+	cfg := NewConfig()
+	assert.NoError(t, cfg.LoadFromSource(src, WithKeyPrefix("db.")))
+	assert.Equal(t, "db.internal", cfg.GetString("db.host"))
+	assert.Equal(t, "hunter2", cfg.GetString("db.password"))
+
+	// Keys missing from a later load are unset
+	src.values = map[string]any{"host": "db2.internal"}
+	assert.NoError(t, cfg.Reload())
+	assert.Equal(t, "db2.internal", cfg.GetString("db.host"))
+	assert.False(t, cfg.IsSet("db.password"))
+}
+
+func TestLoadWhileReloading(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "password"), []byte("hunter2"), 0644))
+
+	cfg := NewConfig()
+	assert.NoError(t, cfg.LoadFromSource(&fakeSource{values: map[string]any{"host": "db.internal"}}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			_ = cfg.Reload()
+		}
+	}()
+
+	// Sources registered while a reload runs are seen by the next one
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, cfg.LoadFromSource(&fakeSource{values: map[string]any{"n": i}}, WithKeyPrefix(fmt.Sprintf("s%d.", i))))
+		assert.NoError(t, cfg.LoadFromDir(dir, WithKeyPrefix(fmt.Sprintf("d%d.", i))))
+	}
+	<-done
+
+	assert.NoError(t, cfg.Reload())
+	assert.Equal(t, 4, cfg.GetInt("s4.n"))
+	assert.Equal(t, "hunter2", cfg.GetString("d4.password"))
+}
+
+func TestEncryptedValues(t *testing.T) {
+	b64 := DecryptorFunc(func(ctx context.Context, ciphertext string) (string, error) {
+		plaintext, err := base64.StdEncoding.DecodeString(ciphertext)
+		return string(plaintext), err
+	})
+
+	var calls int
+	reverse := DecryptorFunc(func(ctx context.Context, ciphertext string) (string, error) {
+		calls++
+		runes := []rune(ciphertext)
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return string(runes), nil
+	})
+
+	cfg := NewConfig(
+		WithDecryptor("b64", b64),
+		WithDecryptor("", reverse),
+		WithInterpolation(true),
+	)
+	cfg.Set("db.password", "ENC[b64:"+base64.StdEncoding.EncodeToString([]byte("s3cret"))+"]")
+	cfg.Set("api.key", "ENC[olleh]")
+	cfg.Set("api.url", "https://example.com?key=${api.key}")
+	cfg.Set("broken", "ENC[unknown:abc]")
+
+	assert.Equal(t, "s3cret", cfg.GetString("db.password"))
+	assert.Equal(t, "hello", cfg.GetString("api.key"))
+	assert.Equal(t, "https://example.com?key=hello", cfg.GetString("api.url"))
+	assert.Equal(t, "ENC[unknown:abc]", cfg.GetString("broken"))
+
+	// Decrypted values are cached
+	assert.Equal(t, "hello", cfg.GetString("api.key"))
+	assert.Equal(t, 1, calls)
+}
+
+func TestDecryptionErrors(t *testing.T) {
+	failing := DecryptorFunc(func(ctx context.Context, ciphertext string) (string, error) {
+		return "", errors.New("key revoked")
+	})
+
+	var buf syncBuffer
+	cfg := NewConfig(WithDecryptor("kms", failing), WithLogger(logger.New(logger.WithOutput(&buf))))
+	cfg.Set("db.host", "db.internal")
+	cfg.Set("db.password", "ENC[kms:abc]")
+
+	// Plain reads keep the ciphertext and log the failure once per key
+	assert.Equal(t, "ENC[kms:abc]", cfg.GetString("db.password"))
+	assert.Equal(t, "ENC[kms:abc]", cfg.GetString("db.password"))
+	assert.Equal(t, 1, strings.Count(buf.String(), `"msg":"failed to decrypt config value"`))
+	assert.Contains(t, buf.String(), `"key":"db.password"`)
+
+	var db struct {
+		Host     string
+		Password string
+	}
+	assert.ErrorIs(t, cfg.Unmarshal(&db), ErrDecryption)
+	assert.ErrorIs(t, cfg.UnmarshalKey("db", &db), ErrDecryption)
+	assert.Empty(t, db.Password)
+
+	// Validators decoding the config reject values that cannot be decrypted
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(configFile, []byte("db:\n  password: ENC[kms:abc]\n"), 0644))
+	validated := NewConfig(
+		WithDecryptor("kms", failing),
+		WithLogger(logger.New(logger.WithOutput(&syncBuffer{}))),
+		WithValidator(func(cfg Config) error {
+			return cfg.UnmarshalKey("db", &db)
+		}),
+	)
+	assert.ErrorIs(t, validated.LoadFromFile(configFile), ErrDecryption)
+}
+
+func TestDecryptionCacheReset(t *testing.T) {
+	var calls int
+	identity := DecryptorFunc(func(ctx context.Context, ciphertext string) (string, error) {
+		calls++
+		return ciphertext, nil
+	})
+
+	cfg := NewConfig(WithDecryptor("", identity))
+	cfg.Set("a", "ENC[one]")
+	assert.Equal(t, "one", cfg.GetString("a"))
+	assert.Equal(t, "one", cfg.GetString("a"))
+	assert.Equal(t, 1, calls)
+
+	// Swapping in a new configuration drops the cached plaintexts
+	cfg.Set("b", "ENC[two]")
+	assert.Equal(t, "one", cfg.GetString("a"))
+	assert.Equal(t, 2, calls)
+}
+
+func TestHistoryAndRollback(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(configFile, []byte("server:\n  port: 8080\n"), 0644))
+
+	cfg := NewConfig(WithHistorySize(3))
+	assert.NoError(t, cfg.LoadFromFile(configFile))
+
+	var watched []any
+	cfg.Watch("server.port", func(value any) {
+		watched = append(watched, value)
+	})
+
+	assert.NoError(t, os.WriteFile(configFile, []byte("server:\n  port: 9090\n"), 0644))
+	assert.NoError(t, cfg.Reload())
+	assert.Equal(t, 9090, cfg.GetInt("server.port"))
+
+	history := cfg.History()
+	assert.Len(t, history, 2)
+	assert.Equal(t, "file", history[0].Source)
+	assert.Greater(t, history[0].Version, history[1].Version)
+
+	assert.NoError(t, cfg.Rollback(1))
+	assert.Equal(t, 8080, cfg.GetInt("server.port"))
+	assert.Equal(t, "rollback", cfg.History()[0].Source)
+	assert.Len(t, watched, 3)
+
+	assert.Error(t, cfg.Rollback(5))
+
+	// The file keeps its type after a rollback, so reloads still work
+	assert.NoError(t, cfg.Reload())
+	assert.Equal(t, 9090, cfg.GetInt("server.port"))
+	assert.Len(t, cfg.History(), 3)
+}
+
+func TestFreeze(t *testing.T) {
+	cfg := NewConfig()
+	assert.NoError(t, cfg.Set("app.name", "gocore"))
+	assert.False(t, cfg.IsFrozen())
+
+	cfg.Freeze()
+	assert.True(t, cfg.IsFrozen())
+	assert.ErrorIs(t, cfg.Set("app.name", "changed"), ErrFrozen)
+	assert.ErrorIs(t, cfg.SetDefault("app.port", 8080), ErrFrozen)
+	assert.ErrorIs(t, cfg.Reload(), ErrFrozen)
+	assert.ErrorIs(t, cfg.LoadFromDir(t.TempDir()), ErrFrozen)
+	assert.Equal(t, "gocore", cfg.GetString("app.name"))
+
+	assert.ErrorIs(t, cfg.LoadFromURL("http://127.0.0.1:1/config.json"), ErrFrozen)
+
+	strict := NewConfig(WithFrozenPanic(true))
+	strict.Freeze()
+	assert.Panics(t, func() {
+		_ = strict.Set("app.name", "changed")
+	})
+	assert.Panics(t, func() {
+		_ = strict.LoadFromDir(t.TempDir())
+	})
+	assert.Panics(t, func() {
+		_ = strict.LoadFromURL("http://127.0.0.1:1/config.json")
+	})
+}
+
+func TestFreeze_Background(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.json")
+	assert.NoError(t, os.WriteFile(configFile, []byte(`{"server": {"port": 8080}}`), 0644))
+	secrets := filepath.Join(dir, "secrets")
+	assert.NoError(t, os.Mkdir(secrets, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(secrets, "password"), []byte("old"), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := NewConfig(WithContext(ctx), WithReloadDebounce(10*time.Millisecond), WithFrozenPanic(true))
+	assert.NoError(t, cfg.LoadFromFile(configFile))
+	assert.NoError(t, cfg.LoadFromDir(secrets, WithKeyPrefix("db.")))
+	cfg.Freeze()
+
+	// Watchers skip the changes of a frozen config rather than panicking
+	assert.NoError(t, os.WriteFile(configFile, []byte(`{"server": {"port": 9090}}`), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(secrets, "password"), []byte("new"), 0644))
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, 8080, cfg.GetInt("server.port"))
+	assert.Equal(t, "old", cfg.GetString("db.password"))
+
+	assert.Panics(t, func() {
+		_ = cfg.Reload()
+	})
+}
+
+func TestEvents(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(configFile, []byte(`{"server": {"port": 8080, "host": "a"}}`), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cfg := NewConfig(WithContext(ctx))
+	assert.NoError(t, cfg.LoadFromFile(configFile))
+
+	events := cfg.Events()
+
+	assert.NoError(t, cfg.Set("app.name", "gocore"))
+	event := <-events
+	assert.Equal(t, "app.name", event.Key)
+	assert.Nil(t, event.OldValue)
+	assert.Equal(t, "gocore", event.NewValue)
+	assert.Equal(t, "set", event.Source)
+
+	assert.NoError(t, os.WriteFile(configFile, []byte(`{"server": {"port": 9090, "host": "a"}}`), 0644))
+	assert.NoError(t, cfg.Reload())
+	event = <-events
+	assert.Equal(t, "server.port", event.Key)
+	assert.EqualValues(t, 8080, event.OldValue)
+	assert.EqualValues(t, 9090, event.NewValue)
+	assert.Equal(t, "file", event.Source)
+
+	cancel()
+	for range events {
+		// drained until closed
+	}
+}
+
+func TestValidatedReload(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(configFile, []byte(`{"server": {"port": 8080}}`), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := NewConfig(
+		WithContext(ctx),
+		WithValidator(func(c Config) error {
+			if c.GetInt("server.port") <= 0 {
+				return errors.New("server.port must be positive")
+			}
+			return nil
+		}),
+	)
+	assert.NoError(t, cfg.LoadFromFile(configFile))
+
+	t.Run("rejected_by_validator", func(t *testing.T) {
+		assert.NoError(t, os.WriteFile(configFile, []byte(`{"server": {"port": 0}}`), 0644))
+		assert.ErrorContains(t, cfg.Reload(), "server.port must be positive")
+		assert.Equal(t, 8080, cfg.GetInt("server.port"))
+	})
+
+	t.Run("half_written", func(t *testing.T) {
+		assert.NoError(t, os.WriteFile(configFile, []byte(`{"server": {"po`), 0644))
+		assert.Error(t, cfg.Reload())
+		assert.Equal(t, 8080, cfg.GetInt("server.port"))
+
+		assert.NoError(t, os.WriteFile(configFile, nil, 0644))
+		assert.Error(t, cfg.Reload())
+		assert.Equal(t, 8080, cfg.GetInt("server.port"))
+	})
+}
+
+func TestDebouncedReload(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(configFile, []byte(`{"server": {"port": 8080}}`), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := NewConfig(WithContext(ctx), WithReloadDebounce(100*time.Millisecond))
+	assert.NoError(t, cfg.LoadFromFile(configFile))
+
+	var (
+		mu     sync.Mutex
+		values []any
+	)
+	cfg.Watch("server.port", func(value any) {
+		mu.Lock()
+		defer mu.Unlock()
+		values = append(values, value)
+	})
+
+	// A burst of writes, including a truncated one, results in a single reload
+	for _, content := range []string{`{"server": {"port": 1}}`, `{"ser`, `{"server": {"port": 9090}}`} {
+		assert.NoError(t, os.WriteFile(configFile, []byte(content), 0644))
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.Eventually(t, func() bool {
+		return cfg.GetInt("server.port") == 9090
+	}, 2*time.Second, 20*time.Millisecond)
+
+	time.Sleep(200 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []any{float64(8080), float64(9090)}, values)
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+type failingSource struct {
+	loads atomic.Int32
+}
+
+func (f *failingSource) Name() string {
+	return "failing"
+}
+
+func (f *failingSource) Load(ctx context.Context) (map[string]any, error) {
+	if f.loads.Add(1) > 1 {
+		return nil, errors.New("store unreachable")
+	}
+	return map[string]any{"host": "db.internal"}, nil
+}
+
+func TestBackgroundErrorsLogged(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var buf syncBuffer
+	cfg := NewConfig(WithContext(ctx), WithLogger(logger.New(logger.WithOutput(&buf))))
+	assert.NoError(t, cfg.LoadFromSource(&failingSource{}, WithRefreshInterval(10*time.Millisecond)))
+
+	assert.Eventually(t, func() bool {
+		return strings.Contains(buf.String(), `"msg":"failed to refresh config"`)
+	}, time.Second, 10*time.Millisecond)
+	assert.Contains(t, buf.String(), `"error":"store unreachable"`)
+	assert.Equal(t, "db.internal", cfg.GetString("host"))
+}
+
+func TestBindFlags(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(configFile, []byte(`{"host": "file-host", "port": 8080, "server": {"debug": false}}`), 0644))
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.String("host", "flag-default", "")
+	fs.Int("port", 1, "")
+	fs.String("region", "eu", "")
+	fs.Bool("debug", false, "")
+	assert.NoError(t, fs.Parse([]string{"--port=9090", "--debug"}))
+
+	cfg := NewConfig()
+	assert.NoError(t, cfg.LoadFromFile(configFile))
+	assert.NoError(t, cfg.BindFlags(fs))
+	assert.NoError(t, cfg.BindFlag("server.debug", fs.Lookup("debug")))
+
+	// flag > file > flag default
+	assert.Equal(t, 9090, cfg.GetInt("port"))
+	assert.Equal(t, "file-host", cfg.GetString("host"))
+	assert.Equal(t, "eu", cfg.GetString("region"))
+	assert.True(t, cfg.GetBool("server.debug"))
+
+	// env > file
+	t.Setenv("HOST", "env-host")
+	assert.Equal(t, "env-host", cfg.GetString("host"))
+}
+
+func TestValidateSchema(t *testing.T) {
+	cfg := NewConfig()
+	assert.NoError(t, cfg.Set("server.port", "not-a-number"))
+	assert.NoError(t, cfg.Set("server.mode", "staging"))
+	assert.NoError(t, cfg.Set("server.timeout", "5s"))
+	assert.NoError(t, cfg.Set("server.tags", []string{"a", "b"}))
+	assert.NoError(t, cfg.Set("database.pool", 500))
+
+	t.Run("struct", func(t *testing.T) {
+		type schema struct {
+			Server struct {
+				Host    string        `mapstructure:"host" validate:"required"`
+				Port    int           `mapstructure:"port" validate:"required"`
+				Mode    string        `mapstructure:"mode" validate:"oneof=debug release"`
+				Timeout time.Duration `mapstructure:"timeout" validate:"max=10s"`
+				Tags    []string      `mapstructure:"tags" validate:"min=1"`
+			} `mapstructure:"server"`
+			Database struct {
+				Pool int    `mapstructure:"pool" validate:"max=100"`
+				Name string `mapstructure:"name" validate:"required"`
+			} `mapstructure:"database"`
+		}
+
+		err := cfg.ValidateSchema(&schema{})
+		var schemaErr *SchemaError
+		assert.ErrorAs(t, err, &schemaErr)
+
+		keys := make([]string, 0, len(schemaErr.Violations))
+		for _, v := range schemaErr.Violations {
+			keys = append(keys, v.Key)
+		}
+		assert.Equal(t, []string{"server.host", "server.port", "server.mode", "database.pool", "database.name"}, keys)
+	})
+
+	t.Run("json_schema", func(t *testing.T) {
+		err := cfg.ValidateSchema(`{
+			"type": "object",
+			"required": ["server", "cache"],
+			"properties": {
+				"server": {
+					"type": "object",
+					"required": ["port", "host"],
+					"properties": {
+						"port": {"type": "integer"},
+						"mode": {"enum": ["debug", "release"]},
+						"tags": {"type": "array", "items": {"type": "string"}}
+					}
+				},
+				"database": {
+					"properties": {"pool": {"type": "integer", "maximum": 100}}
+				}
+			}
+		}`)
+		var schemaErr *SchemaError
+		assert.ErrorAs(t, err, &schemaErr)
+		assert.Len(t, schemaErr.Violations, 5)
+		assert.Contains(t, err.Error(), "cache: is required")
+		assert.Contains(t, err.Error(), "server.mode: must be one of")
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		valid := NewConfig()
+		assert.NoError(t, valid.Set("server.port", 8080))
+		assert.NoError(t, valid.ValidateSchema(map[string]any{
+			"required": []any{"server"},
+			"properties": map[string]any{
+				"server": map[string]any{"type": "object"},
+			},
+		}))
+	})
+}
+
+func TestGetOrDefault(t *testing.T) {
+	cfg := NewConfig()
+	assert.NoError(t, cfg.Set("server.port", "9090"))
+	assert.NoError(t, cfg.Set("server.host", "example.com"))
+	assert.NoError(t, cfg.Set("server.timeout", "3s"))
+	assert.NoError(t, cfg.Set("server.tags", []any{"a", "b"}))
+	assert.NoError(t, cfg.Set("server.debug", "not-a-bool"))
+
+	assert.Equal(t, 9090, GetOrDefault(cfg, "server.port", 8080))
+	assert.Equal(t, "example.com", GetOrDefault(cfg, "server.host", "localhost"))
+	assert.Equal(t, 3*time.Second, GetOrDefault(cfg, "server.timeout", time.Second))
+	assert.Equal(t, []string{"a", "b"}, GetOrDefault(cfg, "server.tags", []string{}))
+
+	// Missing keys and wrong types fall back to the default
+	assert.Equal(t, 10, GetOrDefault(cfg, "server.workers", 10))
+	assert.Equal(t, true, GetOrDefault(cfg, "server.debug", true))
+	assert.Equal(t, 8080, GetOrDefault(cfg, "server.host", 8080))
+
+	type server struct {
+		Host string
+		Port int
+	}
+	assert.Equal(t, server{Host: "example.com", Port: 9090}, GetOrDefault(cfg, "server", server{}))
+}
+
+func TestSetDefaultsFromStruct(t *testing.T) {
+	type Server struct {
+		Host    string        `mapstructure:"host"`
+		Port    int           `mapstructure:"port" default:"8080"`
+		Timeout time.Duration `mapstructure:"timeout" default:"30s"`
+		Tags    []string      `mapstructure:"tags"`
+	}
+	type Common struct {
+		Name string `mapstructure:"name"`
+	}
+	type App struct {
+		Common   `mapstructure:",squash"`
+		Server   Server  `mapstructure:"server"`
+		Cache    *Server `mapstructure:"cache"`
+		Internal string  `mapstructure:"-"`
+		secret   string
+	}
+
+	cfg := NewConfig()
+	assert.NoError(t, cfg.Set("server.port", 9090))
+	assert.NoError(t, cfg.SetDefaultsFromStruct(&App{
+		Common: Common{Name: "app"},
+		Server: Server{Host: "0.0.0.0", Tags: []string{"a"}},
+	}))
+
+	assert.Equal(t, "app", cfg.GetString("name"))
+	assert.Equal(t, "0.0.0.0", cfg.GetString("server.host"))
+	assert.Equal(t, 9090, cfg.GetInt("server.port"))
+	assert.Equal(t, 30*time.Second, cfg.GetDuration("server.timeout"))
+	assert.Equal(t, []string{"a"}, cfg.GetStringSlice("server.tags"))
+	assert.Equal(t, 8080, cfg.GetInt("cache.port"))
+	assert.True(t, cfg.IsSet("cache.host"))
+	assert.False(t, cfg.IsSet("internal"))
+
+	var app App
+	assert.NoError(t, cfg.Unmarshal(&app))
+	assert.Equal(t, 9090, app.Server.Port)
+	assert.Equal(t, 30*time.Second, app.Server.Timeout)
+
+	assert.Error(t, cfg.SetDefaultsFromStruct("not a struct"))
+
+	cfg.Freeze()
+	assert.ErrorIs(t, cfg.SetDefaultsFromStruct(App{}), ErrFrozen)
+}
+
+func TestLayeredConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(configFile, []byte("server:\n  host: localhost\n  port: 8080\ndb:\n  host: file-db\n  pool: 5\n"), 0644))
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE settings (key_name VARCHAR(255) PRIMARY KEY, value TEXT NOT NULL)")
+	assert.NoError(t, err)
+	_, err = db.Exec("INSERT INTO settings (key_name, value) VALUES ('db.host', '\"db-host\"'), ('server.port', '9000')")
+	assert.NoError(t, err)
+
+	t.Setenv("LAYERED_SERVER_PORT", "7000")
+	t.Setenv("LAYERED_DB_HOST", "env-host")
+
+	// Env below the database: the database wins for db.host
+	cfg, err := NewLayeredConfig(WithReloadDebounce(10*time.Millisecond)).
+		WithFile(configFile).
+		WithEnv("LAYERED").
+		WithDB(db, "settings").
+		Build()
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{configFile, "env", "db:settings"}, cfg.Layers())
+	assert.Equal(t, "localhost", cfg.GetString("server.host"))
+	assert.Equal(t, 9000, cfg.GetInt("server.port"))
+	assert.Equal(t, "db-host", cfg.GetString("db.host"))
+	assert.Equal(t, 5, cfg.GetInt("db.pool"))
+
+	// Reloading the database layer drops keys it no longer has
+	_, err = db.Exec("DELETE FROM settings WHERE key_name = 'server.port'")
+	assert.NoError(t, err)
+	assert.NoError(t, cfg.ReloadLayer("db:settings"))
+	assert.Equal(t, 7000, cfg.GetInt("server.port"))
+	assert.Equal(t, "db-host", cfg.GetString("db.host"))
+
+	// File changes reload only the file layer and keep lower precedence
+	assert.NoError(t, os.WriteFile(configFile, []byte("server:\n  host: example.com\n  port: 1\ndb:\n  pool: 10\n"), 0644))
+	assert.Eventually(t, func() bool {
+		return cfg.GetString("server.host") == "example.com"
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 7000, cfg.GetInt("server.port"))
+	assert.Equal(t, 10, cfg.GetInt("db.pool"))
+
+	assert.ErrorIs(t, cfg.ReloadLayer("missing"), ErrUnknownLayer)
+
+	_, err = NewLayeredConfig().WithFile(configFile).WithFile(configFile).Build()
+	assert.Error(t, err)
+}
+
+func TestConcurrentReload(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(configFile, []byte(`{"a": 0, "b": 0}`), 0644))
+
+	cfg := NewConfig()
+	assert.NoError(t, cfg.LoadFromFile(configFile))
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				// Readers never see a half-applied configuration
+				settings := cfg.AllSettings()
+				assert.Equal(t, settings["a"], settings["b"])
+				assert.NotZero(t, len(cfg.AllKeys()))
+			}
+		}()
+	}
+
+	for i := 1; i <= 50; i++ {
+		assert.NoError(t, os.WriteFile(configFile, []byte(fmt.Sprintf(`{"a": %d, "b": %d}`, i, i)), 0644))
+		assert.NoError(t, cfg.Reload())
+		assert.NoError(t, cfg.Set("c", i))
+	}
+	close(stop)
+	wg.Wait()
+
+	assert.Equal(t, 50, cfg.GetInt("a"))
+}
+
+type testLevel int
+
+func (l *testLevel) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return errors.New("unknown level")
+	}
+	return nil
+}
+
+func TestDecodeHooks(t *testing.T) {
+	type Settings struct {
+		MaxBody  ByteSize       `mapstructure:"max_body"`
+		Endpoint *url.URL       `mapstructure:"endpoint"`
+		Pattern  *regexp.Regexp `mapstructure:"pattern"`
+		Level    testLevel      `mapstructure:"level"`
+		Upper    string         `mapstructure:"upper"`
+	}
+
+	upper := func(from, to reflect.Type, data any) (any, error) {
+		if s, ok := data.(string); ok && to.Kind() == reflect.String && strings.HasPrefix(s, "up:") {
+			return strings.ToUpper(strings.TrimPrefix(s, "up:")), nil
+		}
+		return data, nil
+	}
+
+	cfg := NewConfig(WithDecodeHook(upper))
+	assert.NoError(t, cfg.Set("app", map[string]any{
+		"max_body": "512MB",
+		"endpoint": "https://api.example.com/v1",
+		"pattern":  "^user-[0-9]+$",
+		"level":    "high",
+		"upper":    "up:shout",
+	}))
+
+	var s Settings
+	assert.NoError(t, cfg.UnmarshalKey("app", &s))
+	assert.Equal(t, 512*Megabyte, s.MaxBody)
+	assert.Equal(t, "api.example.com", s.Endpoint.Host)
+	assert.True(t, s.Pattern.MatchString("user-42"))
+	assert.Equal(t, testLevel(2), s.Level)
+	assert.Equal(t, "SHOUT", s.Upper)
+
+	assert.NoError(t, cfg.Set("app.level", "unknown"))
+	assert.Error(t, cfg.UnmarshalKey("app", &s))
+
+	size, err := ParseByteSize("1.5GiB")
+	assert.NoError(t, err)
+	assert.Equal(t, ByteSize(1.5*float64(Gigabyte)), size)
+	_, err = ParseByteSize("lots")
+	assert.Error(t, err)
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+// ByteSize is a number of bytes decoded from strings like "512MB" or "1.5GiB"
+type ByteSize uint64
+
+// Byte size units
+const (
+	Byte     ByteSize = 1
+	Kilobyte ByteSize = 1 << (10 * iota)
+	Megabyte
+	Gigabyte
+	Terabyte
+)
+
+var decodeHooks struct {
+	mu    sync.RWMutex
+	hooks []mapstructure.DecodeHookFunc
+}
+
+// RegisterDecodeHook registers hooks applied by Unmarshal and UnmarshalKey of
+// every config, after the built-in ones and before those set with WithDecodeHook
+func RegisterDecodeHook(hooks ...mapstructure.DecodeHookFunc) {
+	decodeHooks.mu.Lock()
+	defer decodeHooks.mu.Unlock()
+
+	decodeHooks.hooks = append(decodeHooks.hooks, hooks...)
+}
+
+// WithDecodeHook adds hooks applied by Unmarshal and UnmarshalKey of this config
+func WithDecodeHook(hooks ...mapstructure.DecodeHookFunc) Option {
+	return func(c *viperConfig) {
+		c.decodeHooks = append(c.decodeHooks, hooks...)
+	}
+}
+
+// decode mirrors viper's default decoder configuration and adds the built-in,
+// registered and config hooks. Options passed at the call site are applied last.
+func (c *viperConfig) decode(input any, output any, opts ...viper.DecoderConfigOption) error {
+	hooks := []mapstructure.DecodeHookFunc{
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		StringToByteSizeHookFunc(),
+		StringToURLHookFunc(),
+		StringToRegexpHookFunc(),
+		mapstructure.TextUnmarshallerHookFunc(),
+	}
+
+	decodeHooks.mu.RLock()
+	hooks = append(hooks, decodeHooks.hooks...)
+	decodeHooks.mu.RUnlock()
+	hooks = append(hooks, c.decodeHooks...)
+
+	cfg := &mapstructure.DecoderConfig{
+		Result:           output,
+		WeaklyTypedInput: true,
+		DecodeHook:       mapstructure.ComposeDecodeHookFunc(hooks...),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	decoder, err := mapstructure.NewDecoder(cfg)
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(input)
+}
+
+// StringToByteSizeHookFunc decodes strings like "512MB" into ByteSize
+func StringToByteSizeHookFunc() mapstructure.DecodeHookFuncType {
+	return func(from reflect.Type, to reflect.Type, data any) (any, error) {
+		if from.Kind() != reflect.String || to != reflect.TypeOf(ByteSize(0)) {
+			return data, nil
+		}
+		return ParseByteSize(data.(string))
+	}
+}
+
+// StringToURLHookFunc decodes strings into url.URL and *url.URL
+func StringToURLHookFunc() mapstructure.DecodeHookFuncType {
+	return func(from reflect.Type, to reflect.Type, data any) (any, error) {
+		if from.Kind() != reflect.String {
+			return data, nil
+		}
+		switch to {
+		case reflect.TypeOf(url.URL{}):
+			u, err := url.Parse(data.(string))
+			if err != nil {
+				return nil, err
+			}
+			return *u, nil
+		case reflect.TypeOf(&url.URL{}):
+			return url.Parse(data.(string))
+		}
+		return data, nil
+	}
+}
+
+// StringToRegexpHookFunc compiles strings into regexp.Regexp and *regexp.Regexp
+func StringToRegexpHookFunc() mapstructure.DecodeHookFuncType {
+	return func(from reflect.Type, to reflect.Type, data any) (any, error) {
+		if from.Kind() != reflect.String {
+			return data, nil
+		}
+		switch to {
+		case reflect.TypeOf(regexp.Regexp{}):
+			re, err := regexp.Compile(data.(string))
+			if err != nil {
+				return nil, err
+			}
+			return *re, nil
+		case reflect.TypeOf(&regexp.Regexp{}):
+			return regexp.Compile(data.(string))
+		}
+		return data, nil
+	}
+}
+
+// ParseByteSize parses sizes like "512", "512B", "64KB", "1.5GB" or "2GiB".
+// Units are binary: 1KB is 1024 bytes.
+func ParseByteSize(s string) (ByteSize, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	str = strings.TrimSuffix(str, "IB")
+	str = strings.TrimSuffix(str, "B")
+
+	multiplier := Byte
+	if n := len(str); n > 0 {
+		switch str[n-1] {
+		case 'K':
+			multiplier = Kilobyte
+		case 'M':
+			multiplier = Megabyte
+		case 'G':
+			multiplier = Gigabyte
+		case 'T':
+			multiplier = Terabyte
+		}
+		if multiplier != Byte {
+			str = str[:n-1]
+		}
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid byte size: %q", s)
+	}
+	return ByteSize(value * float64(multiplier)), nil
+}
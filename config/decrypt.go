@@ -0,0 +1,135 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrDecryption is returned when an ENC[...] value cannot be decrypted
+var ErrDecryption = errors.New("failed to decrypt config value")
+
+// Decryptor decrypts config values written as ENC[scheme:ciphertext]. The
+// KMS and age decryptors are in config/awsconfig and config/agecrypt
+type Decryptor interface {
+	Decrypt(ctx context.Context, ciphertext string) (string, error)
+}
+
+// DecryptorFunc adapts a function to the Decryptor interface
+type DecryptorFunc func(ctx context.Context, ciphertext string) (string, error)
+
+// Decrypt implements Decryptor
+func (f DecryptorFunc) Decrypt(ctx context.Context, ciphertext string) (string, error) {
+	return f(ctx, ciphertext)
+}
+
+// WithDecryptor registers the decryptor used for ENC[scheme:...] values.
+// An empty scheme registers the decryptor for values without a scheme, ENC[...].
+func WithDecryptor(scheme string, d Decryptor) Option {
+	return func(c *viperConfig) {
+		c.decryptors.register(scheme, d)
+	}
+}
+
+// decryptors holds the registered decryptors and caches decrypted values,
+// so remote decryptors such as KMS are not called on every read. The cache
+// and the keys whose failure was reported are cleared with every new
+// configuration.
+type decryptors struct {
+	mu        sync.RWMutex
+	byScheme  map[string]Decryptor
+	plaintext map[string]string
+	reported  map[string]bool
+}
+
+func (d *decryptors) register(scheme string, decryptor Decryptor) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.byScheme == nil {
+		d.byScheme = make(map[string]Decryptor)
+	}
+	d.byScheme[scheme] = decryptor
+}
+
+// decrypt returns the plaintext of an ENC[...] value. Other values are returned unchanged.
+func (d *decryptors) decrypt(value string) (string, error) {
+	if !strings.HasPrefix(value, "ENC[") || !strings.HasSuffix(value, "]") {
+		return value, nil
+	}
+
+	d.mu.RLock()
+	plaintext, cached := d.plaintext[value]
+	registered := len(d.byScheme) > 0
+	d.mu.RUnlock()
+	if cached || !registered {
+		if cached {
+			return plaintext, nil
+		}
+		return value, nil
+	}
+
+	scheme, ciphertext := "", value[len("ENC["):len(value)-1]
+	if s, rest, ok := strings.Cut(ciphertext, ":"); ok && isScheme(s) {
+		scheme, ciphertext = s, rest
+	}
+
+	d.mu.RLock()
+	decryptor, ok := d.byScheme[scheme]
+	d.mu.RUnlock()
+	if !ok {
+		return value, fmt.Errorf("%w: no decryptor registered for scheme %q", ErrDecryption, scheme)
+	}
+
+	plaintext, err := decryptor.Decrypt(context.Background(), ciphertext)
+	if err != nil {
+		return value, fmt.Errorf("%w: %w", ErrDecryption, err)
+	}
+
+	d.mu.Lock()
+	if d.plaintext == nil {
+		d.plaintext = make(map[string]string)
+	}
+	d.plaintext[value] = plaintext
+	d.mu.Unlock()
+
+	return plaintext, nil
+}
+
+// report reports whether the decryption failure of key has not been reported yet
+func (d *decryptors) report(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.reported[key] {
+		return false
+	}
+	if d.reported == nil {
+		d.reported = make(map[string]bool)
+	}
+	d.reported[key] = true
+	return true
+}
+
+// reset forgets the decrypted values and reported failures
+func (d *decryptors) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.plaintext = nil
+	d.reported = nil
+}
+
+func isScheme(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '-' && r != '_' {
+			return false
+		}
+	}
+	return true
+}
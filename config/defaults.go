@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// SetDefaultsFromStruct registers a default for every field of s, so defaults
+// can live next to the typed config struct:
+//
+//	cfg.SetDefaultsFromStruct(AppConfig{
+//		Server: ServerConfig{Host: "0.0.0.0", Port: 8080},
+//	})
+//
+// Keys come from the mapstructure tag (or the lowercased field name). A zero
+// field takes its default from a default tag when present:
+//
+//	Timeout time.Duration `mapstructure:"timeout" default:"30s"`
+func (c *viperConfig) SetDefaultsFromStruct(s any) error {
+	if err := c.checkFrozen("set defaults"); err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(s)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return fmt.Errorf("unsupported defaults type: %T", s)
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("unsupported defaults type: %T", s)
+	}
+
+	if err := c.update(false, func(s *state) error {
+		setStructDefaults(s, "", v)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	c.emitChanges("default")
+	return nil
+}
+
+func setStructDefaults(s *state, prefix string, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, squash, ok := fieldKey(field)
+		if !ok {
+			continue
+		}
+
+		value := v.Field(i)
+		for value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				value = reflect.Zero(value.Type().Elem())
+				continue
+			}
+			value = value.Elem()
+		}
+
+		if value.Kind() == reflect.Struct && !isScalarStruct(value.Type()) {
+			if squash {
+				setStructDefaults(s, prefix, value)
+			} else {
+				setStructDefaults(s, joinKey(prefix, name), value)
+			}
+			continue
+		}
+
+		key := joinKey(prefix, name)
+		if def, ok := field.Tag.Lookup("default"); ok && value.IsZero() {
+			s.setDefault(key, def)
+			continue
+		}
+		s.setDefault(key, value.Interface())
+	}
+}
+
+// fieldKey returns the config key segment of a struct field following the
+// mapstructure tag, and whether the field is squashed into its parent.
+// ok is false for unexported and ignored fields.
+func fieldKey(field reflect.StructField) (name string, squash bool, ok bool) {
+	if !field.IsExported() {
+		return "", false, false
+	}
+
+	name = strings.ToLower(field.Name)
+	if tag, found := field.Tag.Lookup("mapstructure"); found {
+		parts := strings.Split(tag, ",")
+		if parts[0] == "-" {
+			return "", false, false
+		}
+		if parts[0] != "" {
+			name = strings.ToLower(parts[0])
+		}
+		for _, opt := range parts[1:] {
+			squash = squash || opt == "squash"
+		}
+	}
+	return name, squash, true
+}
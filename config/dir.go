@@ -0,0 +1,161 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// kubernetesDataDir is the symlink Kubernetes swaps atomically when a mounted
+// ConfigMap or Secret is updated
+const kubernetesDataDir = "..data"
+
+// WithKeyPrefix sets the prefix added to keys loaded by LoadFromDir and LoadFromSource
+func WithKeyPrefix(prefix string) Option {
+	return func(c *viperConfig) {
+		c.keyPrefix = prefix
+	}
+}
+
+// dirSource tracks a directory where every file is a config key, as produced
+// by mounting a Kubernetes ConfigMap or Secret as a volume
+type dirSource struct {
+	config *viperConfig
+	dir    string
+	prefix string
+	mu     sync.Mutex
+	keys   map[string]bool
+}
+
+// LoadFromDir loads every regular file in dir as a key holding the file content,
+// and reloads when the directory changes. Kubernetes updates mounted volumes by
+// swapping the ..data symlink; such rotations are detected and fire Watch callbacks.
+func (c *viperConfig) LoadFromDir(dir string, options ...Option) error {
+	if err := c.checkFrozen("load " + dir); err != nil {
+		return err
+	}
+
+	// Apply options
+	c.apply(options)
+
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("config directory not found: %s", dir)
+	}
+
+	src := &dirSource{
+		config: c,
+		dir:    dir,
+		prefix: c.keyPrefix,
+		keys:   make(map[string]bool),
+	}
+	if err := src.refresh(c.ctx); err != nil {
+		return err
+	}
+	c.registerSource(src)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch config directory: %w", err)
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch config directory: %w", err)
+	}
+	go src.watch(c.ctx, watcher)
+
+	return nil
+}
+
+func (s *dirSource) refresh(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.config.frozenErr("refresh"); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read config directory: %w", err)
+	}
+
+	c := s.config
+	values := make(map[string]any, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		// Skip Kubernetes bookkeeping entries (..data, ..2024_01_01_...) and hidden files
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		path := filepath.Join(s.dir, name)
+		// Follow symlinks, Kubernetes exposes every key as a link into ..data
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read config file %s: %w", name, err)
+		}
+		values[s.prefix+name] = strings.TrimRight(string(content), "\r\n")
+	}
+
+	// Keys whose file disappeared are unset
+	keys, err := c.replaceKeys(s.keys, values)
+	if err != nil {
+		return err
+	}
+	s.keys = keys
+
+	c.applied("dir")
+
+	return nil
+}
+
+func (s *dirSource) watch(ctx context.Context, watcher *fsnotify.Watcher) {
+	defer watcher.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if !s.relevant(event) {
+				continue
+			}
+			if err := s.refresh(ctx); err != nil && !errors.Is(err, ErrFrozen) {
+				s.config.logError("failed to reload config directory", err)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			s.config.logError("config directory watcher error", err)
+		}
+	}
+}
+
+// relevant reports whether an event changes the visible content of the directory.
+// A Kubernetes rotation only creates the new ..data symlink, the key links
+// themselves never change, which is why plain file watchers miss it.
+func (s *dirSource) relevant(event fsnotify.Event) bool {
+	name := filepath.Base(event.Name)
+	if name == kubernetesDataDir {
+		return event.Has(fsnotify.Create)
+	}
+	if strings.HasPrefix(name, ".") || event.Op == fsnotify.Chmod {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"reflect"
+	"sync"
+	"time"
+)
+
+// DefaultEventBuffer is the capacity of channels returned by Events
+var DefaultEventBuffer = 64
+
+// ChangeEvent describes a change of a single config key
+type ChangeEvent struct {
+	// Key is the changed key
+	Key string
+
+	// OldValue is the value before the change, nil if the key was not set
+	OldValue any
+
+	// NewValue is the value after the change, nil if the key was removed
+	NewValue any
+
+	// Source names what caused the change (set, default, file, db, url, dir, ssm, rollback, ...)
+	Source string
+
+	// Time is when the change was detected
+	Time time.Time
+}
+
+// WithEventBuffer sets the capacity of channels returned by Events
+func WithEventBuffer(size int) Option {
+	return func(c *viperConfig) {
+		c.events.buffer = size
+	}
+}
+
+// eventHub fans change events out to subscribers
+type eventHub struct {
+	mu          sync.Mutex
+	buffer      int
+	subscribers []chan ChangeEvent
+	state       map[string]any
+}
+
+// Events returns a channel receiving a ChangeEvent for every key changed by
+// Set, SetDefault, loads, reloads and rollbacks. Each call creates a new
+// subscription. Events are dropped if the subscriber falls behind by more than
+// the buffer size. Channels are closed when the context set by WithContext is done.
+func (c *viperConfig) Events() <-chan ChangeEvent {
+	c.events.mu.Lock()
+	defer c.events.mu.Unlock()
+
+	ch := make(chan ChangeEvent, c.events.buffer)
+	if len(c.events.subscribers) == 0 {
+		c.events.state = c.flatSettings()
+		go c.closeEventsOnDone()
+	}
+	c.events.subscribers = append(c.events.subscribers, ch)
+	return ch
+}
+
+// emitChanges publishes an event for every key whose resolved value changed since the last call
+func (c *viperConfig) emitChanges(source string) {
+	c.events.mu.Lock()
+	defer c.events.mu.Unlock()
+
+	if len(c.events.subscribers) == 0 {
+		return
+	}
+
+	now := time.Now()
+	current := c.flatSettings()
+	var events []ChangeEvent
+	for key, newValue := range current {
+		oldValue, ok := c.events.state[key]
+		if !ok || !reflect.DeepEqual(oldValue, newValue) {
+			events = append(events, ChangeEvent{Key: key, OldValue: oldValue, NewValue: newValue, Source: source, Time: now})
+		}
+	}
+	for key, oldValue := range c.events.state {
+		if _, ok := current[key]; !ok {
+			events = append(events, ChangeEvent{Key: key, OldValue: oldValue, Source: source, Time: now})
+		}
+	}
+	c.events.state = current
+
+	for _, event := range events {
+		for _, ch := range c.events.subscribers {
+			select {
+			case ch <- event:
+			default:
+			}
+		}
+	}
+}
+
+// flatSettings returns the resolved value of every leaf key
+func (c *viperConfig) flatSettings() map[string]any {
+	keys := c.viper().AllKeys()
+	settings := make(map[string]any, len(keys))
+	for _, key := range keys {
+		if value := c.Get(key); value != nil {
+			settings[key] = value
+		}
+	}
+	return settings
+}
+
+func (c *viperConfig) closeEventsOnDone() {
+	<-c.ctx.Done()
+
+	c.events.mu.Lock()
+	defer c.events.mu.Unlock()
+
+	for _, ch := range c.events.subscribers {
+		close(ch)
+	}
+	c.events.subscribers = nil
+}
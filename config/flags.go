@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// BindFlags binds every flag of fs to the key of the same name. A flag set on
+// the command line takes precedence over environment variables, config files
+// and defaults; an unset flag only provides its default value.
+func (c *viperConfig) BindFlags(fs *pflag.FlagSet) error {
+	if err := c.checkFrozen("bind flags"); err != nil {
+		return err
+	}
+
+	if err := c.update(false, func(s *state) error {
+		s.flagSets = append(s.flagSets, fs)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to bind flags: %w", err)
+	}
+
+	c.emitChanges("flags")
+	return nil
+}
+
+// BindFlag binds a single flag to key, e.g. the --port flag to server.port
+func (c *viperConfig) BindFlag(key string, flag *pflag.Flag) error {
+	if err := c.checkFrozen("bind flag " + key); err != nil {
+		return err
+	}
+
+	if flag == nil {
+		return fmt.Errorf("flag for key %s is nil", key)
+	}
+	if err := c.update(false, func(s *state) error {
+		s.flags = append(s.flags, flagBinding{key: key, flag: flag})
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to bind flag %s: %w", flag.Name, err)
+	}
+
+	c.emitChanges("flags")
+	return nil
+}
@@ -0,0 +1,195 @@
+// Package flags provides feature flags evaluated from a config block:
+//
+//	features:
+//	  new_checkout: true
+//	  dark_mode:
+//	    enabled: true
+//	    rollout: 25          # percent of ids
+//	    rollout_by: user_id  # attribute hashed for the rollout, "id" by default
+//	    targets:             # always on for these attribute values
+//	      country: [VN, US]
+//	      plan: [pro]
+//
+// Flags follow the config: when it reloads, the new block takes effect.
+package flags
+
+import (
+	"hash/fnv"
+	"strings"
+	"sync"
+
+	"github.com/ducconit/gocore/config"
+	"github.com/spf13/cast"
+)
+
+// DefaultKey is the config key holding the features block
+const DefaultKey = "features"
+
+// DefaultRolloutAttribute is the attribute hashed for percentage rollouts
+const DefaultRolloutAttribute = "id"
+
+// Attributes describe the subject a flag is evaluated for (user id, country, plan...)
+type Attributes map[string]string
+
+// Feature is the definition of a single flag
+type Feature struct {
+	// Enabled is the kill switch, a disabled feature is off for everyone
+	Enabled bool
+
+	// Rollout is the percentage (0-100) of subjects the feature is on for,
+	// nil means everyone
+	Rollout *float64
+
+	// RolloutBy is the attribute hashed to place a subject in the rollout
+	RolloutBy string
+
+	// Targets maps attribute names to values the feature is always on for
+	Targets map[string][]string
+}
+
+type Option func(*Flags)
+
+// WithKey sets the config key holding the features block
+func WithKey(key string) Option {
+	return func(f *Flags) {
+		f.key = key
+	}
+}
+
+// Flags evaluates feature flags from a config
+type Flags struct {
+	key      string
+	mu       sync.RWMutex
+	features map[string]Feature
+}
+
+// New creates feature flags backed by cfg, updated whenever cfg reloads
+func New(cfg config.Config, options ...Option) *Flags {
+	f := &Flags{key: DefaultKey}
+	for _, opt := range options {
+		opt(f)
+	}
+
+	cfg.Watch(f.key, f.update)
+
+	return f
+}
+
+func (f *Flags) update(value any) {
+	features := make(map[string]Feature)
+	for name, raw := range cast.ToStringMap(value) {
+		features[strings.ToLower(name)] = parseFeature(raw)
+	}
+
+	f.mu.Lock()
+	f.features = features
+	f.mu.Unlock()
+}
+
+func parseFeature(raw any) Feature {
+	def, ok := raw.(map[string]any)
+	if !ok {
+		return Feature{Enabled: cast.ToBool(raw)}
+	}
+
+	feature := Feature{
+		Enabled:   true,
+		RolloutBy: DefaultRolloutAttribute,
+		Targets:   make(map[string][]string),
+	}
+	if enabled, ok := def["enabled"]; ok {
+		feature.Enabled = cast.ToBool(enabled)
+	}
+	if rollout, ok := def["rollout"]; ok {
+		percent := cast.ToFloat64(rollout)
+		feature.Rollout = &percent
+	}
+	if by := cast.ToString(def["rollout_by"]); by != "" {
+		feature.RolloutBy = strings.ToLower(by)
+	}
+	for attr, values := range cast.ToStringMap(def["targets"]) {
+		feature.Targets[strings.ToLower(attr)] = cast.ToStringSlice(values)
+	}
+	return feature
+}
+
+// Feature returns the definition of the named feature
+func (f *Flags) Feature(name string) (Feature, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	feature, ok := f.features[strings.ToLower(name)]
+	return feature, ok
+}
+
+// Features returns the names of all defined features
+func (f *Flags) Features() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	names := make([]string, 0, len(f.features))
+	for name := range f.features {
+		names = append(names, name)
+	}
+	return names
+}
+
+// IsEnabled reports whether the named feature is on. Features with a rollout
+// or targets need attributes, see IsEnabledFor.
+func (f *Flags) IsEnabled(name string) bool {
+	return f.IsEnabledFor(name, nil)
+}
+
+// IsEnabledFor reports whether the named feature is on for the subject
+// described by attrs. Unknown features are off.
+func (f *Flags) IsEnabledFor(name string, attrs Attributes) bool {
+	feature, ok := f.Feature(name)
+	if !ok || !feature.Enabled {
+		return false
+	}
+
+	attrs = normalize(attrs)
+	for attr, values := range feature.Targets {
+		if value, ok := attrs[attr]; ok && containsFold(values, value) {
+			return true
+		}
+	}
+
+	if feature.Rollout != nil {
+		id, ok := attrs[feature.RolloutBy]
+		if !ok {
+			return *feature.Rollout >= 100
+		}
+		return bucket(name, id) < *feature.Rollout
+	}
+
+	// Targeted features without a rollout are only on for their targets
+	return len(feature.Targets) == 0
+}
+
+// bucket places id in [0, 100) consistently for a feature, so a subject stays
+// in the rollout as the percentage grows
+func bucket(name, id string) float64 {
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(name)))
+	h.Write([]byte{':'})
+	h.Write([]byte(id))
+	return float64(h.Sum32()%10000) / 100
+}
+
+func normalize(attrs Attributes) Attributes {
+	out := make(Attributes, len(attrs))
+	for k, v := range attrs {
+		out[strings.ToLower(k)] = v
+	}
+	return out
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package flags
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ducconit/gocore/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlags(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(configFile, []byte(`
+features:
+  new_checkout: true
+  legacy_api: false
+  dark_mode:
+    rollout: 30
+    rollout_by: user_id
+    targets:
+      country: [VN]
+  beta:
+    targets:
+      plan: [pro]
+  killed:
+    enabled: false
+    rollout: 100
+`), 0644))
+
+	cfg := config.NewConfig(config.WithReloadDebounce(0))
+	assert.NoError(t, cfg.LoadFromFile(configFile))
+	f := New(cfg)
+
+	assert.True(t, f.IsEnabled("new_checkout"))
+	assert.True(t, f.IsEnabled("NEW_CHECKOUT"))
+	assert.False(t, f.IsEnabled("legacy_api"))
+	assert.False(t, f.IsEnabled("unknown"))
+	assert.False(t, f.IsEnabled("killed"))
+
+	// Targeting
+	assert.True(t, f.IsEnabledFor("beta", Attributes{"plan": "pro"}))
+	assert.False(t, f.IsEnabledFor("beta", Attributes{"plan": "free"}))
+	assert.True(t, f.IsEnabledFor("dark_mode", Attributes{"country": "vn", "user_id": "x"}))
+
+	// Rollout is deterministic and close to the percentage
+	enabled := 0
+	for i := 0; i < 10000; i++ {
+		attrs := Attributes{"user_id": fmt.Sprint(i)}
+		on := f.IsEnabledFor("dark_mode", attrs)
+		assert.Equal(t, on, f.IsEnabledFor("dark_mode", attrs))
+		if on {
+			enabled++
+		}
+	}
+	assert.InDelta(t, 3000, enabled, 300)
+	assert.False(t, f.IsEnabled("dark_mode"))
+
+	// Live update on reload
+	assert.NoError(t, os.WriteFile(configFile, []byte(`
+features:
+  new_checkout: false
+  dark_mode:
+    rollout: 100
+`), 0644))
+	assert.NoError(t, cfg.Reload())
+
+	assert.False(t, f.IsEnabled("new_checkout"))
+	assert.True(t, f.IsEnabled("dark_mode"))
+	assert.False(t, f.IsEnabledFor("beta", Attributes{"plan": "pro"}))
+}
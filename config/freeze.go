@@ -0,0 +1,49 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrFrozen is returned when a frozen configuration is modified
+var ErrFrozen = errors.New("config is frozen")
+
+// WithFrozenPanic makes modifications of a frozen configuration panic instead
+// of returning ErrFrozen, which surfaces accidental mutations early in development
+func WithFrozenPanic(enabled bool) Option {
+	return func(c *viperConfig) {
+		c.frozenPanic = enabled
+	}
+}
+
+// Freeze makes the configuration read-only. Subsequent Set, SetDefault,
+// loads, reloads and rollbacks fail with ErrFrozen, and the watchers and
+// pollers stop applying changes.
+func (c *viperConfig) Freeze() {
+	c.frozen.Store(true)
+}
+
+// IsFrozen reports whether the configuration is read-only
+func (c *viperConfig) IsFrozen() bool {
+	return c.frozen.Load()
+}
+
+// checkFrozen returns ErrFrozen, or panics when WithFrozenPanic is set, if the
+// configuration is frozen. Only explicit modifications use it.
+func (c *viperConfig) checkFrozen(op string) error {
+	err := c.frozenErr(op)
+	if err != nil && c.frozenPanic {
+		panic(err)
+	}
+	return err
+}
+
+// frozenErr returns ErrFrozen if the configuration is frozen. Background
+// reloads and refreshes use it: they never panic, and skip their update once
+// the configuration is frozen.
+func (c *viperConfig) frozenErr(op string) error {
+	if !c.frozen.Load() {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrFrozen, op)
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// The getters below read the published viper instance through Get, where
+// ${...} references are expanded and ENC[...] values decrypted.
+
+func (c *viperConfig) Get(key string) any {
+	value, _ := c.resolve(key)
+	return value
+}
+
+// resolve returns the value of key with references expanded and values
+// decrypted, and the decryption failures
+func (c *viperConfig) resolve(key string) (any, error) {
+	lcaseKey := strings.ToLower(key)
+	return c.resolveValue(lcaseKey, c.viper().Get(lcaseKey), map[string]bool{lcaseKey: true})
+}
+
+func (c *viperConfig) IsSet(key string) bool {
+	return c.viper().IsSet(key)
+}
+
+func (c *viperConfig) AllKeys() []string {
+	return c.viper().AllKeys()
+}
+
+func (c *viperConfig) GetString(key string) string {
+	return cast.ToString(c.Get(key))
+}
+
+func (c *viperConfig) GetBool(key string) bool {
+	return cast.ToBool(c.Get(key))
+}
+
+func (c *viperConfig) GetInt(key string) int {
+	return cast.ToInt(c.Get(key))
+}
+
+func (c *viperConfig) GetInt32(key string) int32 {
+	return cast.ToInt32(c.Get(key))
+}
+
+func (c *viperConfig) GetInt64(key string) int64 {
+	return cast.ToInt64(c.Get(key))
+}
+
+func (c *viperConfig) GetUint(key string) uint {
+	return cast.ToUint(c.Get(key))
+}
+
+func (c *viperConfig) GetUint32(key string) uint32 {
+	return cast.ToUint32(c.Get(key))
+}
+
+func (c *viperConfig) GetUint64(key string) uint64 {
+	return cast.ToUint64(c.Get(key))
+}
+
+func (c *viperConfig) GetFloat64(key string) float64 {
+	return cast.ToFloat64(c.Get(key))
+}
+
+func (c *viperConfig) GetTime(key string) time.Time {
+	return cast.ToTime(c.Get(key))
+}
+
+func (c *viperConfig) GetDuration(key string) time.Duration {
+	return cast.ToDuration(c.Get(key))
+}
+
+func (c *viperConfig) GetIntSlice(key string) []int {
+	return cast.ToIntSlice(c.Get(key))
+}
+
+func (c *viperConfig) GetStringSlice(key string) []string {
+	return cast.ToStringSlice(c.Get(key))
+}
+
+func (c *viperConfig) GetStringMap(key string) map[string]any {
+	return cast.ToStringMap(c.Get(key))
+}
+
+func (c *viperConfig) GetStringMapString(key string) map[string]string {
+	return cast.ToStringMapString(c.Get(key))
+}
+
+func (c *viperConfig) GetStringMapStringSlice(key string) map[string][]string {
+	return cast.ToStringMapStringSlice(c.Get(key))
+}
+
+func (c *viperConfig) GetSizeInBytes(key string) uint {
+	return parseSizeInBytes(cast.ToString(c.Get(key)))
+}
+
+func (c *viperConfig) AllSettings() map[string]any {
+	settings, _ := c.allSettings()
+	return settings
+}
+
+// allSettings returns AllSettings and the decryption failures
+func (c *viperConfig) allSettings() (map[string]any, error) {
+	settings, err := c.resolveValue("", c.viper().AllSettings(), nil)
+	m, _ := settings.(map[string]any)
+	return m, err
+}
+
+// Unmarshal fails when a value cannot be decrypted, rather than decoding its ciphertext
+func (c *viperConfig) Unmarshal(rawVal any, opts ...viper.DecoderConfigOption) error {
+	settings, err := c.allSettings()
+	if err != nil {
+		return err
+	}
+	return c.decode(settings, rawVal, opts...)
+}
+
+// UnmarshalKey fails when a value cannot be decrypted, rather than decoding its ciphertext
+func (c *viperConfig) UnmarshalKey(key string, rawVal any, opts ...viper.DecoderConfigOption) error {
+	value, err := c.resolve(key)
+	if err != nil {
+		return err
+	}
+	return c.decode(value, rawVal, opts...)
+}
+
+// GetOrDefault returns the value of key converted to T, or def when the key is
+// not set or its value cannot be converted
+//
+//	port := config.GetOrDefault(cfg, "server.port", 8080)
+//	timeout := config.GetOrDefault(cfg, "server.timeout", 5*time.Second)
+func GetOrDefault[T any](cfg Config, key string, def T) T {
+	if cfg == nil || !cfg.IsSet(key) {
+		return def
+	}
+
+	value := cfg.Get(key)
+	if value == nil {
+		return def
+	}
+	if v, ok := value.(T); ok {
+		return v
+	}
+
+	var (
+		result any
+		err    error
+	)
+	switch any(def).(type) {
+	case time.Duration:
+		result, err = cast.ToDurationE(value)
+	case time.Time:
+		result, err = cast.ToTimeE(value)
+	default:
+		var out T
+		err = cfg.UnmarshalKey(key, &out)
+		result = out
+	}
+	if err != nil {
+		return def
+	}
+	return result.(T)
+}
+
+// parseSizeInBytes converts strings like 1GB or 12 mb into an unsigned integer number of bytes
+func parseSizeInBytes(sizeStr string) uint {
+	sizeStr = strings.TrimSpace(sizeStr)
+	lastChar := len(sizeStr) - 1
+	multiplier := uint(1)
+
+	if lastChar > 1 && (sizeStr[lastChar] == 'b' || sizeStr[lastChar] == 'B') {
+		switch unicode.ToLower(rune(sizeStr[lastChar-1])) {
+		case 'k':
+			multiplier = 1 << 10
+			sizeStr = strings.TrimSpace(sizeStr[:lastChar-1])
+		case 'm':
+			multiplier = 1 << 20
+			sizeStr = strings.TrimSpace(sizeStr[:lastChar-1])
+		case 'g':
+			multiplier = 1 << 30
+			sizeStr = strings.TrimSpace(sizeStr[:lastChar-1])
+		default:
+			sizeStr = strings.TrimSpace(sizeStr[:lastChar])
+		}
+	}
+
+	size := cast.ToInt(sizeStr)
+	if size < 0 {
+		size = 0
+	}
+	return uint(size) * multiplier
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"time"
 
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -62,12 +63,24 @@ func AllKeys() []string {
 	return globalConfig.AllKeys()
 }
 
-func Set(key string, value any) {
-	globalConfig.Set(key, value)
+func Set(key string, value any) error {
+	return globalConfig.Set(key, value)
 }
 
-func SetDefault(key string, value any) {
-	globalConfig.SetDefault(key, value)
+func SetDefault(key string, value any) error {
+	return globalConfig.SetDefault(key, value)
+}
+
+func SetDefaultsFromStruct(s any) error {
+	return globalConfig.SetDefaultsFromStruct(s)
+}
+
+func Freeze() {
+	globalConfig.Freeze()
+}
+
+func IsFrozen() bool {
+	return globalConfig.IsFrozen()
 }
 
 func LoadFromFile(path string, options ...Option) error {
@@ -78,13 +91,58 @@ func LoadFromDB(db any, tableName string) error {
 	return globalConfig.LoadFromDB(db, tableName)
 }
 
+func LoadFromURL(url string, options ...Option) error {
+	return globalConfig.LoadFromURL(url, options...)
+}
+
+func LoadFromDir(dir string, options ...Option) error {
+	return globalConfig.LoadFromDir(dir, options...)
+}
+
+func LoadFromSource(src Source, options ...Option) error {
+	return globalConfig.LoadFromSource(src, options...)
+}
+
+func BindFlags(fs *pflag.FlagSet) error {
+	return globalConfig.BindFlags(fs)
+}
+
+func BindFlag(key string, flag *pflag.Flag) error {
+	return globalConfig.BindFlag(key, flag)
+}
+
 func Reload() error {
 	return globalConfig.Reload()
 }
 
+func ReloadLayer(name string) error {
+	return globalConfig.ReloadLayer(name)
+}
+
+func Layers() []string {
+	return globalConfig.Layers()
+}
+
 func Watch(key string, callback func(any)) {
 	globalConfig.Watch(key, callback)
 }
+
+func ValidateSchema(schema any) error {
+	return globalConfig.ValidateSchema(schema)
+}
+
+func Events() <-chan ChangeEvent {
+	return globalConfig.Events()
+}
+
+func History() []Snapshot {
+	return globalConfig.History()
+}
+
+func Rollback(n int) error {
+	return globalConfig.Rollback(n)
+}
+
 func GetDuration(key string) time.Duration {
 	return globalConfig.GetDuration(key)
 }
@@ -132,3 +190,8 @@ func Unmarshal(rawVal any, opts ...viper.DecoderConfigOption) error {
 func UnmarshalKey(key string, rawVal any, opts ...viper.DecoderConfigOption) error {
 	return globalConfig.UnmarshalKey(key, rawVal, opts...)
 }
+
+// GetGlobalOrDefault is GetOrDefault on the global config
+func GetGlobalOrDefault[T any](key string, def T) T {
+	return GetOrDefault(globalConfig, key, def)
+}
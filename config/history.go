@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultHistorySize is the number of loaded states kept for rollback
+var DefaultHistorySize = 10
+
+// Snapshot is a configuration state recorded after a load or reload
+type Snapshot struct {
+	// Version increases with every recorded state
+	Version int
+
+	// Source names what produced the state (file, db, url, dir, ssm, rollback, ...)
+	Source string
+
+	// LoadedAt is when the state was recorded
+	LoadedAt time.Time
+
+	// Settings are the raw settings, before interpolation and decryption
+	Settings map[string]any
+}
+
+// WithHistorySize sets how many loaded states are kept. Zero disables history.
+func WithHistorySize(size int) Option {
+	return func(c *viperConfig) {
+		c.history.resize(size)
+	}
+}
+
+// history is a bounded list of snapshots, oldest first
+type history struct {
+	mu        sync.RWMutex
+	size      int
+	version   int
+	snapshots []Snapshot
+}
+
+func (h *history) record(source string, settings map[string]any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.size <= 0 {
+		return
+	}
+
+	h.version++
+	h.snapshots = append(h.snapshots, Snapshot{
+		Version:  h.version,
+		Source:   source,
+		LoadedAt: time.Now(),
+		Settings: settings,
+	})
+	if len(h.snapshots) > h.size {
+		h.snapshots = h.snapshots[len(h.snapshots)-h.size:]
+	}
+}
+
+func (h *history) resize(size int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.size = size
+	if size <= 0 {
+		h.snapshots = nil
+	} else if len(h.snapshots) > size {
+		h.snapshots = h.snapshots[len(h.snapshots)-size:]
+	}
+}
+
+// History returns the recorded states, newest first. History()[n] is the
+// state restored by Rollback(n).
+func (c *viperConfig) History() []Snapshot {
+	c.history.mu.RLock()
+	defer c.history.mu.RUnlock()
+
+	snapshots := make([]Snapshot, len(c.history.snapshots))
+	for i, s := range c.history.snapshots {
+		snapshots[len(snapshots)-1-i] = s
+	}
+	return snapshots
+}
+
+// Rollback restores the state recorded n loads ago and fires watchers for
+// the keys that change. The rollback itself is recorded as a new state, and
+// lasts until the next load or reload.
+func (c *viperConfig) Rollback(n int) error {
+	if err := c.checkFrozen("rollback"); err != nil {
+		return err
+	}
+
+	snapshots := c.History()
+	if n < 1 || n >= len(snapshots) {
+		return fmt.Errorf("cannot roll back %d versions: %d available", n, len(snapshots)-1)
+	}
+	target := snapshots[n]
+
+	if err := c.restoreSnapshot(copyMap(target.Settings)); err != nil {
+		return fmt.Errorf("failed to restore snapshot: %w", err)
+	}
+
+	c.applied("rollback")
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// ErrInterpolationCycle is returned when config references form a cycle
+var ErrInterpolationCycle = errors.New("config interpolation cycle")
+
+// resolveValue expands ${...} references and decrypts ENC[...] values inside
+// strings, maps and slices; key is the key of value. Unresolved references
+// are left untouched. Values that fail to decrypt are left as ciphertext,
+// reported once per key and returned as errors wrapping ErrDecryption.
+func (c *viperConfig) resolveValue(key string, value any, visiting map[string]bool) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return c.resolveItem(key, v, visiting)
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		var errs []error
+		for k, item := range v {
+			resolved, err := c.resolveValue(joinKey(key, k), item, visiting)
+			out[k] = resolved
+			errs = append(errs, err)
+		}
+		return out, errors.Join(errs...)
+	case []any:
+		out := make([]any, len(v))
+		var errs []error
+		for i, item := range v {
+			resolved, err := c.resolveValue(key, item, visiting)
+			out[i] = resolved
+			errs = append(errs, err)
+		}
+		return out, errors.Join(errs...)
+	case []string:
+		out := make([]string, len(v))
+		var errs []error
+		for i, item := range v {
+			resolved, err := c.resolveItem(key, item, visiting)
+			out[i] = resolved
+			errs = append(errs, err)
+		}
+		return out, errors.Join(errs...)
+	default:
+		return value, nil
+	}
+}
+
+// resolveItem resolves a string of key, keeping only decryption errors
+func (c *viperConfig) resolveItem(key, s string, visiting map[string]bool) (string, error) {
+	resolved, err := c.resolveString(s, visiting)
+	if err == nil || !errors.Is(err, ErrDecryption) {
+		return resolved, nil
+	}
+
+	err = fmt.Errorf("config key %s: %w", key, err)
+	if c.decryptors.report(key) {
+		c.logError("failed to decrypt config value", err, zap.String("key", key))
+	}
+	return resolved, err
+}
+
+// resolveString interpolates s and decrypts the result if it is an ENC[...] value
+func (c *viperConfig) resolveString(s string, visiting map[string]bool) (string, error) {
+	s, err := c.interpolate(s, visiting)
+	if err != nil {
+		return s, err
+	}
+	return c.decryptors.decrypt(s)
+}
+
+// interpolate expands references in s:
+//
+//	${name}          config key "name", falling back to the environment variable "name"
+//	${name:-default} as above, using default when neither is set
+//	$${name}         the literal text "${name}"
+//
+// visiting holds the config keys currently being resolved and is used to detect cycles.
+func (c *viperConfig) interpolate(s string, visiting map[string]bool) (string, error) {
+	if !c.interpolation || !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var (
+		b    strings.Builder
+		errs []error
+	)
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			b.WriteString(s)
+			break
+		}
+
+		// Escaped reference: $${...}
+		if start > 0 && s[start-1] == '$' {
+			b.WriteString(s[:start-1])
+			end := strings.IndexByte(s[start:], '}')
+			if end < 0 {
+				b.WriteString(s[start:])
+				break
+			}
+			b.WriteString(s[start : start+end+1])
+			s = s[start+end+1:]
+			continue
+		}
+
+		end := strings.IndexByte(s[start:], '}')
+		if end < 0 {
+			b.WriteString(s)
+			break
+		}
+
+		b.WriteString(s[:start])
+		ref := s[start : start+end+1]
+		value, err := c.resolveReference(ref[2:len(ref)-1], visiting)
+		if err != nil {
+			errs = append(errs, err)
+			b.WriteString(ref)
+		} else {
+			b.WriteString(value)
+		}
+		s = s[start+end+1:]
+	}
+
+	return b.String(), errors.Join(errs...)
+}
+
+func (c *viperConfig) resolveReference(ref string, visiting map[string]bool) (string, error) {
+	name, def, hasDefault := strings.Cut(ref, ":-")
+	name = strings.TrimSpace(name)
+
+	key := strings.ToLower(name)
+	if c.viper().IsSet(key) {
+		if visiting[key] {
+			return "", fmt.Errorf("%w: %s", ErrInterpolationCycle, name)
+		}
+
+		if visiting == nil {
+			visiting = make(map[string]bool)
+		}
+		visiting[key] = true
+		defer delete(visiting, key)
+
+		raw := c.viper().Get(key)
+		if s, ok := raw.(string); ok {
+			return c.resolveString(s, visiting)
+		}
+		resolved, err := c.resolveValue(key, raw, visiting)
+		return fmt.Sprint(resolved), err
+	}
+
+	if value, ok := os.LookupEnv(name); ok {
+		return value, nil
+	}
+	if hasDefault {
+		return c.interpolate(def, visiting)
+	}
+	return "", fmt.Errorf("unresolved config reference: %s", name)
+}
@@ -0,0 +1,309 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/cast"
+)
+
+// ErrUnknownLayer is returned by ReloadLayer for a name that is not a layer
+var ErrUnknownLayer = errors.New("unknown config layer")
+
+// layer is one source of a layered config
+type layer struct {
+	name string
+
+	// load reads the values of the layer, it is nil for the environment layer
+	load func(c *viperConfig) (map[string]any, error)
+
+	// env marks the environment layer, which looks up the keys of the other layers
+	env    bool
+	prefix string
+
+	// path is the file watched for changes, if any
+	path string
+
+	values map[string]any
+}
+
+// LayeredConfig builds a Config from an ordered list of sources. Later
+// sources take precedence over earlier ones and every source can be
+// reloaded on its own with ReloadLayer:
+//
+//	cfg, err := config.NewLayeredConfig().
+//		WithFile("config.yaml").  // lowest precedence
+//		WithDB(db, "settings").
+//		WithEnv("APP").           // highest precedence
+//		Build()
+//
+// Values set with Set still override every layer.
+type LayeredConfig struct {
+	options []Option
+	layers  []*layer
+}
+
+// NewLayeredConfig creates a layered config builder, options apply to the built config
+func NewLayeredConfig(options ...Option) *LayeredConfig {
+	return &LayeredConfig{options: options}
+}
+
+// WithFile adds a config file layer named after its path. The file is
+// watched and the layer reloaded when it changes.
+func (b *LayeredConfig) WithFile(path string) *LayeredConfig {
+	b.layers = append(b.layers, &layer{
+		name: path,
+		path: path,
+		load: func(c *viperConfig) (map[string]any, error) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read config file: %w", err)
+			}
+
+			configType := c.configType
+			if configType == "" {
+				configType = strings.TrimPrefix(filepath.Ext(path), ".")
+			}
+			return parseDocument(configType, data)
+		},
+	})
+	return b
+}
+
+// WithEnv adds an environment layer named "env". Keys of the other layers are
+// looked up as PREFIX_KEY_NAME (KEY_NAME without a prefix).
+func (b *LayeredConfig) WithEnv(prefix string) *LayeredConfig {
+	b.layers = append(b.layers, &layer{name: "env", env: true, prefix: prefix})
+	return b
+}
+
+// WithDB adds a database layer named "db:<tableName>", see LoadFromDB
+func (b *LayeredConfig) WithDB(db any, tableName string) *LayeredConfig {
+	b.layers = append(b.layers, &layer{
+		name: "db:" + tableName,
+		load: func(*viperConfig) (map[string]any, error) {
+			return loadFromDB(db, tableName)
+		},
+	})
+	return b
+}
+
+// WithLayer adds a custom layer. Keys of the returned values may be nested
+// maps or dotted paths.
+func (b *LayeredConfig) WithLayer(name string, load func() (map[string]any, error)) *LayeredConfig {
+	b.layers = append(b.layers, &layer{
+		name: name,
+		load: func(*viperConfig) (map[string]any, error) {
+			return load()
+		},
+	})
+	return b
+}
+
+// Build loads every layer and returns the resulting config
+func (b *LayeredConfig) Build() (Config, error) {
+	seen := make(map[string]bool, len(b.layers))
+	for _, l := range b.layers {
+		if seen[l.name] {
+			return nil, fmt.Errorf("duplicate config layer: %s", l.name)
+		}
+		seen[l.name] = true
+	}
+
+	// The environment only applies through an env layer, at its position
+	c := newViperConfig(&state{}, b.options...)
+	c.layers = b.layers
+
+	if err := c.reloadLayers(c.layers...); err != nil {
+		return nil, err
+	}
+
+	for _, l := range c.layers {
+		if l.path == "" {
+			continue
+		}
+		name := l.name
+		if err := c.startFileWatcher(l.path, func() error { return c.reloadLayer(name) }); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Layers returns the layer names from lowest to highest precedence
+func (c *viperConfig) Layers() []string {
+	names := make([]string, 0, len(c.layers))
+	for _, l := range c.layers {
+		names = append(names, l.name)
+	}
+	return names
+}
+
+// ReloadLayer reloads a single layer of a layered config
+func (c *viperConfig) ReloadLayer(name string) error {
+	if err := c.checkFrozen("reload " + name); err != nil {
+		return err
+	}
+	return c.reloadLayer(name)
+}
+
+// reloadLayer reloads a single layer, it is the ReloadLayer of the file watcher
+func (c *viperConfig) reloadLayer(name string) error {
+	if err := c.frozenErr("reload " + name); err != nil {
+		return err
+	}
+
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+
+	for _, l := range c.layers {
+		if l.name == name {
+			return c.reloadLayers(l)
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownLayer, name)
+}
+
+// reloadLayers loads the given layers and merges all layers into the config.
+// If any layer fails to load or a validator rejects the result, the previous
+// values are kept.
+func (c *viperConfig) reloadLayers(layers ...*layer) error {
+	values := make([]map[string]any, len(layers))
+	for i, l := range layers {
+		if l.load == nil {
+			continue
+		}
+		loaded, err := l.load(c)
+		if err != nil {
+			return fmt.Errorf("failed to load layer %s: %w", l.name, err)
+		}
+		values[i] = nestKeys(loaded)
+	}
+
+	previous := make([]map[string]any, len(layers))
+	for i, l := range layers {
+		previous[i] = l.values
+		if l.load != nil {
+			l.values = values[i]
+		}
+	}
+
+	if err := c.mergeLayers(); err != nil {
+		for i, l := range layers {
+			l.values = previous[i]
+		}
+		return err
+	}
+
+	source := "layers"
+	if len(layers) == 1 {
+		source = layers[0].name
+	}
+	c.applied(source)
+
+	return nil
+}
+
+// mergeLayers replaces the config values with the validated merge of every layer
+func (c *viperConfig) mergeLayers() error {
+	keys := make(map[string]bool)
+	for _, l := range c.layers {
+		if !l.env {
+			collectKeys("", l.values, keys)
+		}
+	}
+	for _, key := range c.viper().AllKeys() {
+		keys[key] = true
+	}
+
+	merged := make(map[string]any)
+	for _, l := range c.layers {
+		if l.env {
+			l.values = envValues(l.prefix, keys)
+		}
+		mergeMaps(merged, l.values)
+	}
+
+	return c.replaceConfigLayer(merged, true)
+}
+
+// envValues returns the values of the environment variables matching keys
+func envValues(prefix string, keys map[string]bool) map[string]any {
+	values := make(map[string]any)
+	for key := range keys {
+		name := strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+		if prefix != "" {
+			name = strings.ToUpper(prefix) + "_" + name
+		}
+		if value, ok := os.LookupEnv(name); ok {
+			values[key] = value
+		}
+	}
+	return nestKeys(values)
+}
+
+// nestKeys lowercases keys and expands dotted keys into nested maps
+func nestKeys(values map[string]any) map[string]any {
+	out := make(map[string]any, len(values))
+	for key, value := range values {
+		if isMap(value) {
+			value = nestKeys(cast.ToStringMap(value))
+		}
+
+		path := strings.Split(strings.ToLower(key), ".")
+		m := out
+		for _, part := range path[:len(path)-1] {
+			next, ok := m[part].(map[string]any)
+			if !ok {
+				next = make(map[string]any)
+				m[part] = next
+			}
+			m = next
+		}
+
+		last := path[len(path)-1]
+		if existing, ok := m[last].(map[string]any); ok && isMap(value) {
+			mergeMaps(existing, value.(map[string]any))
+		} else {
+			m[last] = value
+		}
+	}
+	return out
+}
+
+// mergeMaps deep merges src into dst, values of src win
+func mergeMaps(dst, src map[string]any) {
+	for key, value := range src {
+		srcMap, ok := value.(map[string]any)
+		if !ok {
+			dst[key] = value
+			continue
+		}
+
+		dstMap, ok := dst[key].(map[string]any)
+		if !ok {
+			dstMap = make(map[string]any, len(srcMap))
+			dst[key] = dstMap
+		}
+		mergeMaps(dstMap, srcMap)
+	}
+}
+
+func collectKeys(prefix string, values map[string]any, keys map[string]bool) {
+	for key, value := range values {
+		if m, ok := value.(map[string]any); ok {
+			collectKeys(joinKey(prefix, key), m, keys)
+			continue
+		}
+		keys[joinKey(prefix, key)] = true
+	}
+}
+
+func isMap(value any) bool {
+	return value != nil && reflect.TypeOf(value).Kind() == reflect.Map
+}
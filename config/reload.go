@@ -0,0 +1,188 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// DefaultReloadDebounce is how long the file watcher waits for writes to settle before reloading
+var DefaultReloadDebounce = 100 * time.Millisecond
+
+// Validator checks a newly loaded configuration. Returning an error rejects
+// the new state and keeps the previous one.
+type Validator func(cfg Config) error
+
+// WithValidator adds a validator run on every file and URL load or reload
+func WithValidator(v Validator) Option {
+	return func(c *viperConfig) {
+		c.validators = append(c.validators, v)
+	}
+}
+
+// WithReloadDebounce sets how long the file watcher waits for writes to settle
+// before reloading. Editors and deploy tools often emit several events per save.
+func WithReloadDebounce(d time.Duration) Option {
+	return func(c *viperConfig) {
+		c.debounce = d
+	}
+}
+
+// fileConfigType returns the type used to parse the config file
+func (c *viperConfig) fileConfigType() string {
+	if c.configType != "" {
+		return c.configType
+	}
+	return strings.TrimPrefix(filepath.Ext(c.configFile), ".")
+}
+
+// readFile reads the config file and applies it
+func (c *viperConfig) readFile() error {
+	data, err := os.ReadFile(c.configFile)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	return c.applyDocument(fileDocument, c.fileConfigType(), data)
+}
+
+// applyDocument replaces the document of origin with data, the other
+// documents are kept. An empty or half-written document never replaces the
+// current state, and neither does one rejected by a validator.
+func (c *viperConfig) applyDocument(origin, configType string, data []byte) error {
+	values, err := parseDocument(configType, data)
+	if err != nil {
+		return err
+	}
+
+	return c.update(true, func(s *state) error {
+		s.setDocument(origin, values)
+		return nil
+	})
+}
+
+// parseDocument parses data on a scratch instance and returns its settings
+func parseDocument(configType string, data []byte) (map[string]any, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, fmt.Errorf("config document is empty")
+	}
+
+	scratch := viper.New()
+	scratch.SetConfigType(configType)
+	if err := scratch.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	return scratch.AllSettings(), nil
+}
+
+// replaceConfigLayer replaces the merged layers of a layered config and
+// publishes the result
+func (c *viperConfig) replaceConfigLayer(values map[string]any, validate bool) error {
+	return c.update(validate, func(s *state) error {
+		s.config = values
+		s.snapshot = nil
+		return nil
+	})
+}
+
+// restoreSnapshot replaces the config layer and every document with
+// settings until the next load
+func (c *viperConfig) restoreSnapshot(settings map[string]any) error {
+	return c.update(false, func(s *state) error {
+		s.snapshot = settings
+		return nil
+	})
+}
+
+// validate runs the validators against cfg
+func (c *viperConfig) validate(cfg Config) error {
+	for _, validator := range c.validators {
+		if err := validator(cfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watchFile reloads the config file when it changes, once writes have settled
+func (c *viperConfig) watchFile(path string) error {
+	c.watchOnce.Do(func() {
+		c.watchErr = c.startFileWatcher(path, c.reload)
+	})
+	return c.watchErr
+}
+
+// startFileWatcher calls reload when the file at path changes
+func (c *viperConfig) startFileWatcher(path string, reload func() error) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch config file: %w", err)
+	}
+
+	// Watch the directory rather than the file, editors replace files on save
+	// and Kubernetes swaps symlinks
+	path = filepath.Clean(path)
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch config file: %w", err)
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		realPath, _ := filepath.EvalSymlinks(path)
+		var (
+			mu    sync.Mutex
+			timer *time.Timer
+		)
+		schedule := func() {
+			mu.Lock()
+			defer mu.Unlock()
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(c.debounce, func() {
+				if err := reload(); err != nil && !errors.Is(err, ErrFrozen) {
+					c.logError("failed to reload config", err)
+				}
+			})
+		}
+
+		for {
+			select {
+			case <-c.ctx.Done():
+				mu.Lock()
+				if timer != nil {
+					timer.Stop()
+				}
+				mu.Unlock()
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				currentPath, _ := filepath.EvalSymlinks(path)
+				written := filepath.Clean(event.Name) == path && event.Has(fsnotify.Write|fsnotify.Create)
+				if written || (currentPath != "" && currentPath != realPath) {
+					realPath = currentPath
+					schedule()
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				c.logError("config watcher error", err)
+			}
+		}
+	}()
+
+	return nil
+}
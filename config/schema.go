@@ -0,0 +1,420 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+// Violation is a single schema validation failure
+type Violation struct {
+	// Key is the config key that failed validation
+	Key string
+
+	// Message describes the failure
+	Message string
+}
+
+// SchemaError aggregates every violation found by ValidateSchema
+type SchemaError struct {
+	Violations []Violation
+}
+
+// Error implements the error interface
+func (e *SchemaError) Error() string {
+	lines := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		lines = append(lines, fmt.Sprintf("%s: %s", v.Key, v.Message))
+	}
+	return fmt.Sprintf("config schema validation failed (%d violations):\n  %s", len(lines), strings.Join(lines, "\n  "))
+}
+
+type schemaValidator struct {
+	config     *viperConfig
+	violations []Violation
+}
+
+func (v *schemaValidator) add(key, format string, args ...any) {
+	v.violations = append(v.violations, Violation{Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+// ValidateSchema checks the configuration against schema and returns a
+// *SchemaError listing every violation. schema is either a struct (or pointer
+// to struct) or a JSON Schema given as []byte, string or map[string]any.
+//
+// Struct fields are mapped to keys through their mapstructure tag (or the
+// lowercased field name) and accept rules in a validate tag:
+//
+//	Port int    `mapstructure:"port" validate:"required,min=1,max=65535"`
+//	Mode string `mapstructure:"mode" validate:"oneof=debug release"`
+//
+// The JSON Schema keywords type, properties, required, enum, minimum, maximum
+// and items are supported.
+func (c *viperConfig) ValidateSchema(schema any) error {
+	v := &schemaValidator{config: c}
+
+	switch s := schema.(type) {
+	case []byte:
+		if err := v.validateJSONSchemaDocument(s); err != nil {
+			return err
+		}
+	case string:
+		if err := v.validateJSONSchemaDocument([]byte(s)); err != nil {
+			return err
+		}
+	case map[string]any:
+		v.validateJSONSchema("", s)
+	default:
+		t := reflect.TypeOf(schema)
+		for t != nil && t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if t == nil || t.Kind() != reflect.Struct {
+			return fmt.Errorf("unsupported schema type: %T", schema)
+		}
+		v.validateStruct("", t)
+	}
+
+	if len(v.violations) == 0 {
+		return nil
+	}
+	return &SchemaError{Violations: v.violations}
+}
+
+func (v *schemaValidator) validateStruct(prefix string, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, squash, ok := fieldKey(field)
+		if !ok {
+			continue
+		}
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+
+		if squash && fieldType.Kind() == reflect.Struct {
+			v.validateStruct(prefix, fieldType)
+			continue
+		}
+
+		key := joinKey(prefix, name)
+		rules := parseRules(field.Tag.Get("validate"))
+		v.validateField(key, fieldType, rules)
+	}
+}
+
+func (v *schemaValidator) validateField(key string, t reflect.Type, rules map[string]string) {
+	if !v.config.IsSet(key) {
+		if _, required := rules["required"]; required {
+			v.add(key, "is required")
+		}
+		// Nested structs may still contain required keys
+		if t.Kind() == reflect.Struct && !isScalarStruct(t) {
+			v.validateStruct(key, t)
+		}
+		return
+	}
+
+	value := v.config.Get(key)
+	if !v.checkKind(key, t, value) {
+		return
+	}
+
+	if t.Kind() == reflect.Struct && !isScalarStruct(t) {
+		v.validateStruct(key, t)
+		return
+	}
+
+	if allowed, ok := rules["oneof"]; ok {
+		options := strings.Fields(allowed)
+		if !containsString(options, cast.ToString(value)) {
+			v.add(key, "must be one of [%s], got %v", strings.Join(options, ", "), value)
+		}
+	}
+	if min, ok := rules["min"]; ok {
+		v.checkBound(key, t, value, min, true)
+	}
+	if max, ok := rules["max"]; ok {
+		v.checkBound(key, t, value, max, false)
+	}
+}
+
+// checkKind reports whether value can be decoded into t, adding a violation otherwise
+func (v *schemaValidator) checkKind(key string, t reflect.Type, value any) bool {
+	var err error
+	switch {
+	case t == reflect.TypeOf(time.Duration(0)):
+		_, err = cast.ToDurationE(value)
+	case t == reflect.TypeOf(time.Time{}):
+		_, err = cast.ToTimeE(value)
+	default:
+		switch t.Kind() {
+		case reflect.String:
+			if isComposite(value) {
+				err = fmt.Errorf("expected a string")
+			}
+		case reflect.Bool:
+			_, err = cast.ToBoolE(value)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			_, err = cast.ToInt64E(value)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			_, err = cast.ToUint64E(value)
+		case reflect.Float32, reflect.Float64:
+			_, err = cast.ToFloat64E(value)
+		case reflect.Slice, reflect.Array:
+			if _, isString := value.(string); !isString && !isList(value) {
+				err = fmt.Errorf("expected a list")
+			}
+		case reflect.Map, reflect.Struct:
+			_, err = cast.ToStringMapE(value)
+		}
+	}
+
+	if err != nil {
+		v.add(key, "expected %s, got %T (%v)", typeName(t), value, value)
+		return false
+	}
+	return true
+}
+
+func (v *schemaValidator) checkBound(key string, t reflect.Type, value any, bound string, isMin bool) {
+	var (
+		actual, limit float64
+		err           error
+	)
+	what := "value"
+	if t == reflect.TypeOf(time.Duration(0)) {
+		var d time.Duration
+		d, err = time.ParseDuration(bound)
+		actual, limit = float64(cast.ToDuration(value)), float64(d)
+	} else {
+		limit, err = strconv.ParseFloat(bound, 64)
+		switch t.Kind() {
+		case reflect.String:
+			actual, what = float64(len(cast.ToString(value))), "length"
+		case reflect.Slice, reflect.Array, reflect.Map:
+			actual, what = float64(reflect.ValueOf(value).Len()), "length"
+		default:
+			actual = cast.ToFloat64(value)
+		}
+	}
+	if err != nil {
+		v.add(key, "invalid bound %q in schema", bound)
+		return
+	}
+
+	if isMin && actual < limit {
+		v.add(key, "%s must be >= %s, got %v", what, bound, value)
+	}
+	if !isMin && actual > limit {
+		v.add(key, "%s must be <= %s, got %v", what, bound, value)
+	}
+}
+
+func (v *schemaValidator) validateJSONSchemaDocument(data []byte) error {
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return fmt.Errorf("invalid json schema: %w", err)
+	}
+	v.validateJSONSchema("", schema)
+	return nil
+}
+
+// validateJSONSchema validates the value at key (the whole config for the root) against schema
+func (v *schemaValidator) validateJSONSchema(key string, schema map[string]any) {
+	var value any
+	if key == "" {
+		value = v.config.AllSettings()
+	} else {
+		value = v.config.Get(key)
+	}
+
+	if typ, ok := schema["type"].(string); ok && !matchesJSONType(typ, value) {
+		v.add(keyOrRoot(key), "expected %s, got %T (%v)", typ, value, value)
+		return
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, option := range enum {
+			found = found || fmt.Sprint(option) == fmt.Sprint(value)
+		}
+		if !found {
+			v.add(keyOrRoot(key), "must be one of %v, got %v", enum, value)
+		}
+	}
+
+	if minimum, ok := schema["minimum"].(float64); ok {
+		if n, err := cast.ToFloat64E(value); err == nil && n < minimum {
+			v.add(keyOrRoot(key), "must be >= %v, got %v", minimum, value)
+		}
+	}
+	if maximum, ok := schema["maximum"].(float64); ok {
+		if n, err := cast.ToFloat64E(value); err == nil && n > maximum {
+			v.add(keyOrRoot(key), "must be <= %v, got %v", maximum, value)
+		}
+	}
+
+	if items, ok := schema["items"].(map[string]any); ok {
+		if itemType, ok := items["type"].(string); ok {
+			list := reflect.ValueOf(value)
+			for i := 0; isList(value) && i < list.Len(); i++ {
+				item := list.Index(i).Interface()
+				if !matchesJSONType(itemType, item) {
+					v.add(fmt.Sprintf("%s[%d]", keyOrRoot(key), i), "expected %s, got %T (%v)", itemType, item, item)
+				}
+			}
+		}
+	}
+
+	if required, ok := schema["required"].([]any); ok {
+		for _, name := range required {
+			child := joinKey(key, strings.ToLower(fmt.Sprint(name)))
+			if !v.config.IsSet(child) {
+				v.add(child, "is required")
+			}
+		}
+	}
+
+	if properties, ok := schema["properties"].(map[string]any); ok {
+		names := make([]string, 0, len(properties))
+		for name := range properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			child := joinKey(key, strings.ToLower(name))
+			childSchema, ok := properties[name].(map[string]any)
+			if !ok {
+				continue
+			}
+			if v.config.IsSet(child) {
+				v.validateJSONSchema(child, childSchema)
+			} else if _, nested := childSchema["properties"]; nested {
+				// Check required keys of absent objects
+				v.validateMissingObject(child, childSchema)
+			}
+		}
+	}
+}
+
+func (v *schemaValidator) validateMissingObject(key string, schema map[string]any) {
+	if required, ok := schema["required"].([]any); ok {
+		for _, name := range required {
+			v.add(joinKey(key, strings.ToLower(fmt.Sprint(name))), "is required")
+		}
+	}
+}
+
+func matchesJSONType(typ string, value any) bool {
+	var err error
+	switch typ {
+	case "string":
+		if isComposite(value) {
+			return false
+		}
+	case "integer":
+		var f float64
+		f, err = cast.ToFloat64E(value)
+		if err == nil && f != float64(int64(f)) {
+			return false
+		}
+	case "number":
+		_, err = cast.ToFloat64E(value)
+	case "boolean":
+		_, err = cast.ToBoolE(value)
+	case "array":
+		return isList(value)
+	case "object":
+		_, err = cast.ToStringMapE(value)
+	case "null":
+		return value == nil
+	}
+	return err == nil
+}
+
+func parseRules(tag string) map[string]string {
+	rules := make(map[string]string)
+	for _, rule := range strings.Split(tag, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		name, arg, _ := strings.Cut(rule, "=")
+		rules[name] = arg
+	}
+	return rules
+}
+
+// isScalarStruct reports whether a struct type is decoded from a single value
+func isScalarStruct(t reflect.Type) bool {
+	return t == reflect.TypeOf(time.Time{})
+}
+
+func isList(value any) bool {
+	kind := reflect.ValueOf(value).Kind()
+	return kind == reflect.Slice || kind == reflect.Array
+}
+
+func isComposite(value any) bool {
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		return true
+	default:
+		return false
+	}
+}
+
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if t == reflect.TypeOf(time.Duration(0)) {
+			return "duration"
+		}
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "list"
+	case reflect.Map, reflect.Struct:
+		if isScalarStruct(t) {
+			return "time"
+		}
+		return "object"
+	default:
+		return t.Kind().String()
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func joinKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+func keyOrRoot(key string) string {
+	if key == "" {
+		return "(root)"
+	}
+	return key
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"context"
+	"sync"
+)
+
+// Source is a config origin implemented outside this package, such as the
+// AWS stores of config/awsconfig, see LoadFromSource
+type Source interface {
+	// Name identifies the source in change events and the history
+	Name() string
+
+	// Load returns every key of the source with its value
+	Load(ctx context.Context) (map[string]any, error)
+}
+
+// customSource tracks the keys loaded from a Source
+type customSource struct {
+	config *viperConfig
+	src    Source
+	prefix string
+	mu     sync.Mutex
+	keys   map[string]bool
+}
+
+// LoadFromSource loads the keys of src, after the key prefix, and loads them
+// again on Reload and every refresh interval. Keys missing from a later load
+// are unset.
+func (c *viperConfig) LoadFromSource(src Source, options ...Option) error {
+	if err := c.checkFrozen("load " + src.Name()); err != nil {
+		return err
+	}
+
+	// Apply options
+	c.apply(options)
+
+	s := &customSource{
+		config: c,
+		src:    src,
+		prefix: c.keyPrefix,
+		keys:   make(map[string]bool),
+	}
+	if err := s.refresh(c.ctx); err != nil {
+		return err
+	}
+	c.addSource(s)
+
+	return nil
+}
+
+func (s *customSource) refresh(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.config.frozenErr("refresh"); err != nil {
+		return err
+	}
+
+	loaded, err := s.src.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]any, len(loaded))
+	for key, value := range loaded {
+		values[s.prefix+key] = value
+	}
+
+	c := s.config
+	keys, err := c.replaceKeys(s.keys, values)
+	if err != nil {
+		return err
+	}
+	s.keys = keys
+
+	c.applied(s.src.Name())
+
+	return nil
+}
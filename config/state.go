@@ -0,0 +1,233 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// state is everything a viper instance is built from. A published instance is
+// never mutated: writers copy the state, change the copy, build a new instance
+// from it and swap it in atomically, so readers see either the old or the new
+// configuration and never a half-applied one.
+type state struct {
+	envPrefix    string
+	envReplacer  *strings.Replacer
+	automaticEnv bool
+
+	defaults  []setting
+	config    map[string]any
+	documents []document
+	snapshot  map[string]any
+	overrides []setting
+	flagSets  []*pflag.FlagSet
+	flags     []flagBinding
+}
+
+type setting struct {
+	key   string
+	value any
+}
+
+// document is the parsed content of the config file or of a URL
+type document struct {
+	origin string
+	values map[string]any
+}
+
+// fileDocument is the origin of the config file document
+const fileDocument = "file"
+
+type flagBinding struct {
+	key  string
+	flag *pflag.Flag
+}
+
+func (s *state) clone() *state {
+	next := *s
+	next.defaults = append([]setting(nil), s.defaults...)
+	next.documents = append([]document(nil), s.documents...)
+	next.overrides = append([]setting(nil), s.overrides...)
+	next.flagSets = append([]*pflag.FlagSet(nil), s.flagSets...)
+	next.flags = append([]flagBinding(nil), s.flags...)
+	return &next
+}
+
+// set records an override, replacing an earlier one for the same key
+func (s *state) set(key string, value any) {
+	s.overrides = appendSetting(s.overrides, key, value)
+}
+
+// setDefault records a default, replacing an earlier one for the same key
+func (s *state) setDefault(key string, value any) {
+	s.defaults = appendSetting(s.defaults, key, value)
+}
+
+// setDocument replaces the document of origin, or adds it. The config file
+// always comes first, URL documents follow in load order so they take
+// precedence over it. A restored snapshot is dropped.
+func (s *state) setDocument(origin string, values map[string]any) {
+	s.snapshot = nil
+	for i, d := range s.documents {
+		if d.origin == origin {
+			s.documents[i].values = values
+			return
+		}
+	}
+
+	d := document{origin: origin, values: values}
+	if origin == fileDocument {
+		s.documents = append([]document{d}, s.documents...)
+	} else {
+		s.documents = append(s.documents, d)
+	}
+}
+
+func appendSetting(settings []setting, key string, value any) []setting {
+	key = strings.ToLower(key)
+	for i, s := range settings {
+		if s.key == key {
+			settings = append(settings[:i], settings[i+1:]...)
+			break
+		}
+	}
+	return append(settings, setting{key: key, value: value})
+}
+
+// build creates a new viper instance from the state
+func (s *state) build() (*viper.Viper, error) {
+	v := viper.New()
+	if s.envReplacer != nil {
+		v.SetEnvKeyReplacer(s.envReplacer)
+	}
+	if s.envPrefix != "" {
+		v.SetEnvPrefix(s.envPrefix)
+	}
+	if s.automaticEnv {
+		v.AutomaticEnv()
+	}
+
+	for _, d := range s.defaults {
+		v.SetDefault(d.key, d.value)
+	}
+
+	// viper keeps references to the nested maps, copy them so the instances
+	// never share mutable state. A restored snapshot replaces the config
+	// layer and every document until the next load.
+	layers := []map[string]any{s.snapshot}
+	if s.snapshot == nil {
+		layers = []map[string]any{s.config}
+		for _, d := range s.documents {
+			layers = append(layers, d.values)
+		}
+	}
+	for _, values := range layers {
+		if values == nil {
+			continue
+		}
+		if err := v.MergeConfigMap(copyMap(values)); err != nil {
+			return nil, fmt.Errorf("failed to apply config: %w", err)
+		}
+	}
+
+	for _, o := range s.overrides {
+		v.Set(o.key, o.value)
+	}
+
+	for _, fs := range s.flagSets {
+		if err := v.BindPFlags(fs); err != nil {
+			return nil, fmt.Errorf("failed to bind flags: %w", err)
+		}
+	}
+	for _, f := range s.flags {
+		if err := v.BindPFlag(f.key, f.flag); err != nil {
+			return nil, fmt.Errorf("failed to bind flag %s: %w", f.flag.Name, err)
+		}
+	}
+
+	return v, nil
+}
+
+// viper returns the published viper instance. It must only be read.
+func (c *viperConfig) viper() *viper.Viper {
+	return c.current.Load()
+}
+
+// update applies fn to a copy of the state and publishes the result. When
+// validate is set, validators see the new configuration before it is
+// published and can reject it, in which case nothing changes.
+func (c *viperConfig) update(validate bool, fn func(s *state) error) error {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	next := c.state.clone()
+	if err := fn(next); err != nil {
+		return err
+	}
+
+	v, err := next.build()
+	if err != nil {
+		return err
+	}
+
+	if validate {
+		if err := c.validate(c.view(v)); err != nil {
+			return fmt.Errorf("config rejected: %w", err)
+		}
+	}
+
+	c.state = next
+	c.current.Store(v)
+	c.decryptors.reset()
+	return nil
+}
+
+// view returns a read-only Config over v, used to validate a configuration
+// before it is published
+func (c *viperConfig) view(v *viper.Viper) *viperConfig {
+	view := &viperConfig{
+		interpolation: c.interpolation,
+		decryptors:    c.decryptors,
+		logger:        c.logger,
+		decodeHooks:   c.decodeHooks,
+		ctx:           c.ctx,
+		state:         &state{},
+		watches:       make(map[string][]func(any)),
+		lastState:     make(map[string]any),
+	}
+	view.current.Store(v)
+	view.frozen.Store(true)
+	return view
+}
+
+// copyMap deep copies the nested maps and slices of m
+func copyMap(m map[string]any) map[string]any {
+	out := make(map[string]any, len(m))
+	for key, value := range m {
+		out[key] = copyValue(value)
+	}
+	return out
+}
+
+func copyValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		return copyMap(v)
+	case map[any]any:
+		out := make(map[any]any, len(v))
+		for key, item := range v {
+			out[key] = copyValue(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = copyValue(item)
+		}
+		return out
+	default:
+		return value
+	}
+}
@@ -1,25 +1,27 @@
 package errors
 
-import (
-	"fmt"
-	"runtime"
-)
+import "fmt"
 
 // Error represents a custom error with stack trace and metadata
 type Error struct {
-	Message    string
-	Code       string
-	Err        error
-	StackTrace string
-	Metadata   map[string]any
+	Message  string
+	Code     string
+	Kind     Kind
+	Err      error
+	Metadata map[string]any
+
+	// stack is the program counters captured at creation, frames the frames
+	// of an unmarshaled error
+	stack  []uintptr
+	frames []Frame
 }
 
 // New creates a new Error instance
 func New(message string) *Error {
 	return &Error{
-		Message:    message,
-		StackTrace: getStackTrace(),
-		Metadata:   make(map[string]any),
+		Message:  message,
+		stack:    callers(0),
+		Metadata: make(map[string]any),
 	}
 }
 
@@ -30,10 +32,10 @@ func Wrap(err error, message string) *Error {
 	}
 
 	return &Error{
-		Message:    message,
-		Err:        err,
-		StackTrace: getStackTrace(),
-		Metadata:   make(map[string]any),
+		Message:  message,
+		Err:      err,
+		stack:    callers(0),
+		Metadata: make(map[string]any),
 	}
 }
 
@@ -52,6 +54,9 @@ func (e *Error) WithMetadata(key string, value any) *Error {
 // Error implements the error interface
 func (e *Error) Error() string {
 	if e.Err != nil {
+		if e.Message == "" {
+			return e.Err.Error()
+		}
 		return fmt.Sprintf("%s: %v", e.Message, e.Err)
 	}
 	return e.Message
@@ -61,20 +66,3 @@ func (e *Error) Error() string {
 func (e *Error) Unwrap() error {
 	return e.Err
 }
-
-func getStackTrace() string {
-	const depth = 32
-	var pcs [depth]uintptr
-	n := runtime.Callers(3, pcs[:])
-	frames := runtime.CallersFrames(pcs[:n])
-
-	var trace string
-	for {
-		frame, more := frames.Next()
-		trace += fmt.Sprintf("\n%s:%d", frame.File, frame.Line)
-		if !more {
-			break
-		}
-	}
-	return trace
-}
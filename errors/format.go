@@ -0,0 +1,71 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format implements fmt.Formatter: %s and %v print the message of Error,
+// %q quotes it, and %+v prints the chain, outermost first, with the stack
+// of each error created by this package:
+//
+//	load profile
+//		/app/user.go:42
+//		/app/main.go:17
+//	caused by: connection refused
+func (e *Error) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if f.Flag('+') {
+			writeChain(f, e)
+			return
+		}
+		_, _ = io.WriteString(f, e.Error())
+	case 's':
+		_, _ = io.WriteString(f, e.Error())
+	case 'q':
+		_, _ = fmt.Fprintf(f, "%q", e.Error())
+	}
+}
+
+// writeChain writes err and its causes for %+v
+func writeChain(w io.Writer, err error) {
+	for i := 0; err != nil; i++ {
+		if i > 0 {
+			_, _ = io.WriteString(w, "\ncaused by: ")
+		}
+
+		e, ok := err.(*Error)
+		if !ok {
+			_, _ = io.WriteString(w, message(err))
+			err = errors.Unwrap(err)
+			continue
+		}
+
+		members := e.Errors()
+		if members == nil {
+			_, _ = io.WriteString(w, e.Message)
+		} else {
+			_, _ = fmt.Fprintf(w, "%d errors", len(members))
+		}
+		_, _ = io.WriteString(w, strings.ReplaceAll(e.StackTrace(), "\n", "\n\t"))
+		for j, member := range members {
+			_, _ = fmt.Fprintf(w, "\n[%d] %s", j, strings.ReplaceAll(fmt.Sprintf("%+v", member), "\n", "\n    "))
+		}
+		if members != nil {
+			return
+		}
+		err = e.Err
+	}
+}
+
+// message returns the message of err without the messages of its causes
+func message(err error) string {
+	msg := err.Error()
+	if cause := errors.Unwrap(err); cause != nil {
+		msg = strings.TrimSuffix(msg, ": "+cause.Error())
+	}
+	return msg
+}
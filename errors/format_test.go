@@ -0,0 +1,30 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat(t *testing.T) {
+	root := errors.New("connection refused")
+	err := Wrap(fmt.Errorf("query users: %w", root), "load profile")
+
+	assert.Equal(t, "load profile: query users: connection refused", fmt.Sprintf("%v", err))
+	assert.Equal(t, "load profile: query users: connection refused", fmt.Sprintf("%s", err))
+	assert.Equal(t, `"load profile: query users: connection refused"`, fmt.Sprintf("%q", err))
+
+	assert.Regexp(t, regexp.MustCompile(`^load profile\n\t\S+/format_test.go:\d+\n(\t\S+:\d+\n)*caused by: query users\ncaused by: connection refused$`),
+		fmt.Sprintf("%+v", err))
+}
+
+func TestFormat_Join(t *testing.T) {
+	err := Join(New("name is required"), errors.New("email is invalid"))
+
+	assert.Equal(t, "2 errors: name is required; email is invalid", fmt.Sprintf("%v", err))
+	assert.Regexp(t, regexp.MustCompile(`^2 errors\n\t\S+/format_test.go:\d+\n(\t\S+:\d+\n)*\[0\] name is required\n    \t\S+/format_test.go:\d+\n(    \t\S+:\d+\n)*\[1\] email is invalid$`),
+		fmt.Sprintf("%+v", err))
+}
@@ -0,0 +1,117 @@
+package errors
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// RequestIDHeader is the response header WriteJSON reads the request ID from
+var RequestIDHeader = "X-Request-ID"
+
+// ErrorResponse is the JSON body written by WriteJSON
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody describes the error of an ErrorResponse
+type ErrorBody struct {
+	Code      string         `json:"code"`
+	Message   string         `json:"message"`
+	RequestID string         `json:"request_id,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+	Errors    []ErrorBody    `json:"errors,omitempty"`
+}
+
+// HTTPStatus returns the status code matching the kind of the error, or its
+// code when it has no kind, 500 Internal Server Error when neither matches
+func (e *Error) HTTPStatus() int {
+	kind := KindOf(e)
+	if kind == "" {
+		kind = Kind(e.Code)
+	}
+	return kindStatus(kind)
+}
+
+// HTTPStatus returns the status code matching the kind of err, 500 Internal
+// Server Error when it has none, see Error.HTTPStatus
+func HTTPStatus(err error) int {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.HTTPStatus()
+	}
+	return kindStatus(KindOf(err))
+}
+
+func kindStatus(kind Kind) int {
+	switch kind {
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindInvalidArgument:
+		return http.StatusBadRequest
+	case KindUnauthorized:
+		return http.StatusUnauthorized
+	case KindConflict:
+		return http.StatusConflict
+	case KindUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// WriteJSON responds with err as an ErrorResponse, with the status of
+// HTTPStatus. The code is the one of the error, else its kind. Server
+// errors get the status text as message and no details, so internals do not
+// leak to clients, the others the error message and its metadata as
+// details, the errors of Join are listed, and the request ID is the
+// RequestIDHeader of the response, set by the RequestID middleware of
+// service/http.
+func WriteJSON(w http.ResponseWriter, err error) {
+	body := errorBody(err)
+	body.RequestID = w.Header().Get(RequestIDHeader)
+
+	var e *Error
+	if errors.As(err, &e) && len(e.Errors()) > 1 {
+		// List the messages clients may see only
+		var msgs []string
+		for _, member := range e.Errors() {
+			b := errorBody(member)
+			body.Errors = append(body.Errors, b)
+			msgs = append(msgs, b.Message)
+		}
+		if HTTPStatus(err) < http.StatusInternalServerError {
+			body.Message = strconv.Itoa(len(msgs)) + " errors: " + strings.Join(msgs, "; ")
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(HTTPStatus(err))
+	_ = json.NewEncoder(w).Encode(ErrorResponse{Error: body})
+}
+
+func errorBody(err error) ErrorBody {
+	body := ErrorBody{
+		Code:    string(KindOf(err)),
+		Message: err.Error(),
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		if e.Code != "" {
+			body.Code = e.Code
+		}
+		body.Details = e.Metadata
+	}
+	if body.Code == "" {
+		body.Code = string(KindInternal)
+	}
+	if status := HTTPStatus(err); status >= http.StatusInternalServerError {
+		body.Message = http.StatusText(status)
+		body.Details = nil
+	}
+	return body
+}
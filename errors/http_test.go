@@ -0,0 +1,53 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPStatus(t *testing.T) {
+	assert.Equal(t, http.StatusNotFound, NotFound("no user").HTTPStatus())
+	assert.Equal(t, http.StatusBadRequest, InvalidArgument("bad id").HTTPStatus())
+	assert.Equal(t, http.StatusUnauthorized, Unauthorized("no token").HTTPStatus())
+	assert.Equal(t, http.StatusConflict, Conflict("duplicate").HTTPStatus())
+	assert.Equal(t, http.StatusInternalServerError, Internal("bug").HTTPStatus())
+	assert.Equal(t, http.StatusServiceUnavailable, Unavailable("db down").HTTPStatus())
+
+	// The kind of the chain first, then the code
+	assert.Equal(t, http.StatusNotFound, Wrap(NotFound("no user"), "load profile").HTTPStatus())
+	assert.Equal(t, http.StatusConflict, New("duplicate").WithCode("conflict").HTTPStatus())
+	assert.Equal(t, http.StatusInternalServerError, New("boom").WithCode("E42").HTTPStatus())
+
+	assert.Equal(t, http.StatusNotFound, HTTPStatus(fmt.Errorf("handler: %w", NotFound("no user"))))
+	assert.Equal(t, http.StatusInternalServerError, HTTPStatus(errors.New("plain")))
+}
+
+func TestWriteJSON(t *testing.T) {
+	w := httptest.NewRecorder()
+	w.Header().Set(RequestIDHeader, "req-1")
+	WriteJSON(w, NotFound("user not found").WithMetadata("user_id", 42))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"error":{"code":"not_found","message":"user not found","request_id":"req-1","details":{"user_id":42}}}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	WriteJSON(w, InvalidArgument("bad email").WithCode("INVALID_EMAIL"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":{"code":"INVALID_EMAIL","message":"bad email"}}`, w.Body.String())
+
+	// Server errors do not leak their message or metadata
+	w = httptest.NewRecorder()
+	WriteJSON(w, Unavailable("db down").WithMetadata("host", "db-1.internal"))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"error":{"code":"unavailable","message":"Service Unavailable"}}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	WriteJSON(w, fmt.Errorf("query users: %w", errors.New("password authentication failed")))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"error":{"code":"internal","message":"Internal Server Error"}}`, w.Body.String())
+}
@@ -0,0 +1,97 @@
+package errors
+
+import (
+	"strconv"
+	"strings"
+)
+
+// joined is the cause of the errors created by Join, it unwraps to all of
+// its members
+type joined []error
+
+func (j joined) Error() string {
+	msgs := make([]string, len(j))
+	for i, err := range j {
+		msgs[i] = err.Error()
+	}
+	if len(j) == 1 {
+		return msgs[0]
+	}
+	return strconv.Itoa(len(j)) + " errors: " + strings.Join(msgs, "; ")
+}
+
+func (j joined) Unwrap() []error {
+	return j
+}
+
+// Join creates an error reporting every non-nil error of errs, e.g. for
+// validation or batched operations, nil when there is none. Its message lists
+// them, e.g. "2 errors: name is required; email is invalid", errors.Is and
+// errors.As find each of them, and Errors returns them with their codes and
+// metadata.
+func Join(errs ...error) *Error {
+	return join(nil, errs)
+}
+
+// Append adds the non-nil errors of errs to e, joining them with e first
+// when it is not from Join, see Join. Collect failures starting from a nil
+// *Error, then return ErrorOrNil:
+//
+//	var errs *errors.Error
+//	for _, item := range items {
+//		errs = errors.Append(errs, validate(item))
+//	}
+//	return errs.ErrorOrNil()
+func Append(e *Error, errs ...error) *Error {
+	if e == nil {
+		return join(nil, errs)
+	}
+	if _, ok := e.Err.(joined); !ok || e.Message != "" {
+		return join(e, errs)
+	}
+	for _, err := range errs {
+		if err != nil {
+			e.Err = append(e.Err.(joined), err)
+		}
+	}
+	return e
+}
+
+func join(first *Error, errs []error) *Error {
+	var members joined
+	if first != nil {
+		members = append(members, first)
+	}
+	for _, err := range errs {
+		if err != nil {
+			members = append(members, err)
+		}
+	}
+	if len(members) == 0 {
+		return nil
+	}
+
+	return &Error{
+		Err:      members,
+		stack:    callers(1),
+		Metadata: make(map[string]any),
+	}
+}
+
+// Errors returns the errors joined by Join or Append, nil for other errors.
+// Loggers such as zap list them as the causes of e.
+func (e *Error) Errors() []error {
+	if j, ok := e.Err.(joined); ok && e.Message == "" {
+		return append([]error(nil), j...)
+	}
+	return nil
+}
+
+// ErrorOrNil returns e as an error, nil when e is nil, so a nil *Error is not
+// returned as a non-nil error
+func (e *Error) ErrorOrNil() error {
+	if e == nil {
+		return nil
+	}
+	return e
+}
@@ -0,0 +1,75 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errTimeout = errors.New("timeout")
+
+func TestJoin(t *testing.T) {
+	name := InvalidArgument("name is required").WithCode("NAME_REQUIRED")
+	email := InvalidArgument("email is invalid").WithMetadata("field", "email")
+
+	err := Join(name, nil, email, fmt.Errorf("sync: %w", errTimeout))
+	assert.Equal(t, "3 errors: name is required; email is invalid; sync: timeout", err.Error())
+	assert.Equal(t, []error{name, email, fmt.Errorf("sync: %w", errTimeout)}, err.Errors())
+	assert.Contains(t, err.StackTrace(), "join_test.go")
+	assert.True(t, errors.Is(err, errTimeout))
+
+	var member *Error
+	assert.True(t, errors.As(err, &member))
+	assert.True(t, IsKind(err, KindInvalidArgument))
+
+	assert.Equal(t, "email is invalid", Join(email).Error())
+	assert.Nil(t, Join())
+	assert.Nil(t, Join(nil, nil))
+	assert.Nil(t, Join().ErrorOrNil())
+	assert.Nil(t, name.Errors())
+}
+
+func TestAppend(t *testing.T) {
+	var errs *Error
+	for _, err := range []error{nil, errTimeout, nil} {
+		errs = Append(errs, err)
+	}
+	assert.Equal(t, []error{errTimeout}, errs.Errors())
+	assert.Contains(t, errs.StackTrace(), "join_test.go")
+
+	errs = Append(errs, NotFound("no user"), NotFound("no order"))
+	assert.Len(t, errs.Errors(), 3)
+	assert.Error(t, errs.ErrorOrNil())
+
+	// A plain error is the first member of the new join
+	wrapped := Wrap(errTimeout, "sync")
+	errs = Append(wrapped, Conflict("duplicate"))
+	assert.Equal(t, "2 errors: sync: timeout; duplicate", errs.Error())
+
+	errs = nil
+	errs = Append(errs, nil)
+	assert.Nil(t, errs.ErrorOrNil())
+}
+
+func TestWriteJSON_Join(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteJSON(w, Join(
+		InvalidArgument("name is required").WithCode("NAME_REQUIRED"),
+		InvalidArgument("email is invalid").WithMetadata("field", "email"),
+		errors.New("password authentication failed"),
+	))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":{
+		"code": "invalid_argument",
+		"message": "3 errors: name is required; email is invalid; Internal Server Error",
+		"errors": [
+			{"code": "NAME_REQUIRED", "message": "name is required"},
+			{"code": "invalid_argument", "message": "email is invalid", "details": {"field": "email"}},
+			{"code": "internal", "message": "Internal Server Error"}
+		]
+	}}`, w.Body.String())
+}
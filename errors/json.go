@@ -0,0 +1,88 @@
+package errors
+
+import "encoding/json"
+
+// MarshalStackTrace makes MarshalJSON include the stack frames, which are
+// left out by default so they do not leak through API responses
+var MarshalStackTrace = false
+
+// errorJSON is the JSON form of an Error
+type errorJSON struct {
+	Message  string         `json:"message,omitempty"`
+	Code     string         `json:"code,omitempty"`
+	Kind     Kind           `json:"kind,omitempty"`
+	Metadata map[string]any `json:"metadata,omitempty"`
+	Stack    []Frame        `json:"stack,omitempty"`
+	Cause    *Error         `json:"cause,omitempty"`
+	Errors   []*Error       `json:"errors,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler, e.g. to send errors in queue
+// messages. The chain is nested under "cause", and the errors of Join under
+// "errors". Causes not created by this package keep their message only.
+func (e *Error) MarshalJSON() ([]byte, error) {
+	v := errorJSON{
+		Code:     e.Code,
+		Kind:     e.Kind,
+		Metadata: e.Metadata,
+	}
+	if MarshalStackTrace {
+		v.Stack = e.Frames()
+	}
+
+	if members := e.Errors(); members != nil {
+		for _, err := range members {
+			v.Errors = append(v.Errors, asError(err))
+		}
+	} else {
+		v.Message = e.Message
+		if e.Err != nil {
+			v.Cause = asError(e.Err)
+		}
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, reconstructing an error
+// marshaled by MarshalJSON with the same message, kind and codes. Metadata
+// values are decoded as by encoding/json, e.g. numbers as float64.
+func (e *Error) UnmarshalJSON(data []byte) error {
+	var v errorJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*e = Error{
+		Message:  v.Message,
+		Code:     v.Code,
+		Kind:     v.Kind,
+		Metadata: v.Metadata,
+	}
+	if e.Metadata == nil {
+		e.Metadata = make(map[string]any)
+	}
+	if len(v.Stack) > 0 {
+		e.frames = v.Stack
+	}
+
+	switch {
+	case len(v.Errors) > 0:
+		members := make(joined, len(v.Errors))
+		for i, m := range v.Errors {
+			members[i] = m
+		}
+		e.Err = members
+	case v.Cause != nil:
+		e.Err = v.Cause
+	}
+	return nil
+}
+
+// asError returns err as an *Error, keeping only the message of errors not
+// created by this package
+func asError(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	return &Error{Message: err.Error()}
+}
@@ -0,0 +1,63 @@
+package errors
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSON(t *testing.T) {
+	root := fmt.Errorf("query users: %w", errors.New("connection refused"))
+	err := Wrap(root, "load profile").WithKind(KindUnavailable).WithCode("DB_DOWN").WithMetadata("attempt", 3)
+
+	data, marshalErr := json.Marshal(err)
+	assert.NoError(t, marshalErr)
+	assert.JSONEq(t, `{
+		"message": "load profile",
+		"code": "DB_DOWN",
+		"kind": "unavailable",
+		"metadata": {"attempt": 3},
+		"cause": {"message": "query users: connection refused"}
+	}`, string(data))
+
+	var decoded *Error
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, err.Error(), decoded.Error())
+	assert.Equal(t, "DB_DOWN", decoded.Code)
+	assert.True(t, IsKind(decoded, KindUnavailable))
+	assert.Equal(t, map[string]any{"attempt": float64(3)}, decoded.Metadata)
+	assert.Empty(t, decoded.Frames())
+	decoded.WithMetadata("retried", true)
+}
+
+func TestJSON_Join(t *testing.T) {
+	err := Join(InvalidArgument("name is required"), errors.New("email is invalid"))
+
+	data, marshalErr := json.Marshal(err)
+	assert.NoError(t, marshalErr)
+	assert.JSONEq(t, `{"errors": [{"message": "name is required", "kind": "invalid_argument"}, {"message": "email is invalid"}]}`, string(data))
+
+	var decoded Error
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, err.Error(), decoded.Error())
+	assert.Len(t, decoded.Errors(), 2)
+	assert.True(t, IsKind(&decoded, KindInvalidArgument))
+}
+
+func TestJSON_StackTrace(t *testing.T) {
+	MarshalStackTrace = true
+	defer func() { MarshalStackTrace = false }()
+
+	err := New("boom")
+	data, marshalErr := json.Marshal(err)
+	assert.NoError(t, marshalErr)
+	assert.Contains(t, string(data), "json_test.go")
+
+	var decoded Error
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, err.Frames(), decoded.Frames())
+	assert.Equal(t, err.StackTrace(), decoded.StackTrace())
+}
@@ -0,0 +1,104 @@
+package errors
+
+import "errors"
+
+// Kind classifies errors by their meaning, so callers branch on what went
+// wrong rather than on messages
+type Kind string
+
+// Predefined kinds
+const (
+	// KindNotFound means the requested resource does not exist
+	KindNotFound Kind = "not_found"
+
+	// KindInvalidArgument means the request is malformed or fails validation
+	KindInvalidArgument Kind = "invalid_argument"
+
+	// KindUnauthorized means the caller is not authenticated or not allowed
+	KindUnauthorized Kind = "unauthorized"
+
+	// KindConflict means the request conflicts with the current state, e.g. a duplicate
+	KindConflict Kind = "conflict"
+
+	// KindInternal means a bug or an unexpected failure
+	KindInternal Kind = "internal"
+
+	// KindUnavailable means a dependency is down, the request can be retried
+	KindUnavailable Kind = "unavailable"
+)
+
+// NotFound creates an error of KindNotFound
+func NotFound(message string) *Error {
+	return newKind(KindNotFound, message)
+}
+
+// InvalidArgument creates an error of KindInvalidArgument
+func InvalidArgument(message string) *Error {
+	return newKind(KindInvalidArgument, message)
+}
+
+// Unauthorized creates an error of KindUnauthorized
+func Unauthorized(message string) *Error {
+	return newKind(KindUnauthorized, message)
+}
+
+// Conflict creates an error of KindConflict
+func Conflict(message string) *Error {
+	return newKind(KindConflict, message)
+}
+
+// Internal creates an error of KindInternal
+func Internal(message string) *Error {
+	return newKind(KindInternal, message)
+}
+
+// Unavailable creates an error of KindUnavailable
+func Unavailable(message string) *Error {
+	return newKind(KindUnavailable, message)
+}
+
+func newKind(kind Kind, message string) *Error {
+	return &Error{
+		Message:  message,
+		Kind:     kind,
+		stack:    callers(1),
+		Metadata: make(map[string]any),
+	}
+}
+
+// WithKind sets the kind of the error, e.g. of a wrapped error
+func (e *Error) WithKind(kind Kind) *Error {
+	e.Kind = kind
+	return e
+}
+
+// KindOf returns the kind of err: the kind of the outermost *Error of its
+// chain having one, empty when none has
+func KindOf(err error) Kind {
+	queue := []error{err}
+	for len(queue) > 0 {
+		err, queue = queue[0], queue[1:]
+		if err == nil {
+			continue
+		}
+
+		var e *Error
+		if errors.As(err, &e) && e.Kind != "" {
+			return e.Kind
+		}
+		switch u := err.(type) {
+		case *Error:
+			queue = append(queue, u.Err)
+		case interface{ Unwrap() []error }:
+			queue = append(queue, u.Unwrap()...)
+		case interface{ Unwrap() error }:
+			queue = append(queue, u.Unwrap())
+		}
+	}
+	return ""
+}
+
+// IsKind reports whether err is of kind, see KindOf
+func IsKind(err error, kind Kind) bool {
+	return KindOf(err) == kind
+}
@@ -0,0 +1,42 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKinds(t *testing.T) {
+	for kind, fn := range map[Kind]func(string) *Error{
+		KindNotFound:        NotFound,
+		KindInvalidArgument: InvalidArgument,
+		KindUnauthorized:    Unauthorized,
+		KindConflict:        Conflict,
+		KindInternal:        Internal,
+		KindUnavailable:     Unavailable,
+	} {
+		err := fn("boom")
+		assert.Equal(t, "boom", err.Error())
+		assert.True(t, IsKind(err, kind))
+		assert.True(t, strings.HasPrefix(err.StackTrace(), "\n"))
+		assert.Contains(t, err.StackTrace(), "kind_test.go")
+		assert.NotContains(t, err.StackTrace(), "kind.go")
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	notFound := NotFound("user not found")
+
+	assert.Equal(t, KindNotFound, KindOf(Wrap(notFound, "failed to load profile")))
+	assert.Equal(t, KindNotFound, KindOf(fmt.Errorf("handler: %w", notFound)))
+	assert.Equal(t, KindNotFound, KindOf(errors.Join(errors.New("other"), notFound)))
+	assert.Equal(t, KindInternal, KindOf(Wrap(notFound, "corrupt index").WithKind(KindInternal)))
+
+	assert.Equal(t, Kind(""), KindOf(nil))
+	assert.Equal(t, Kind(""), KindOf(New("plain")))
+	assert.False(t, IsKind(errors.New("plain"), KindNotFound))
+	assert.False(t, IsKind(notFound, KindConflict))
+}
@@ -0,0 +1,59 @@
+package errors
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Frame is a frame of the stack captured when an error is created
+type Frame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// String returns the frame as "file:line"
+func (f Frame) String() string {
+	return f.File + ":" + strconv.Itoa(f.Line)
+}
+
+// callers returns the program counters of the stack of the caller of its
+// caller, skipping skip more frames
+func callers(skip int) []uintptr {
+	const depth = 32
+	var pcs [depth]uintptr
+	n := runtime.Callers(3+skip, pcs[:])
+	return pcs[:n]
+}
+
+// Frames returns the stack captured when e was created, innermost first
+func (e *Error) Frames() []Frame {
+	if e.frames != nil {
+		return append([]Frame(nil), e.frames...)
+	}
+	if len(e.stack) == 0 {
+		return nil
+	}
+
+	var frames []Frame
+	it := runtime.CallersFrames(e.stack)
+	for {
+		frame, more := it.Next()
+		frames = append(frames, Frame{Function: frame.Function, File: frame.File, Line: frame.Line})
+		if !more {
+			break
+		}
+	}
+	return frames
+}
+
+// StackTrace returns the frames of e as text, one "file:line" per line, each
+// preceded by a newline
+func (e *Error) StackTrace() string {
+	var sb strings.Builder
+	for _, f := range e.Frames() {
+		sb.WriteString("\n" + f.String())
+	}
+	return sb.String()
+}
@@ -0,0 +1,32 @@
+package errors
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrames(t *testing.T) {
+	err := New("boom")
+
+	frames := err.Frames()
+	if assert.NotEmpty(t, frames) {
+		assert.Equal(t, "github.com/ducconit/gocore/errors.TestFrames", frames[0].Function)
+		assert.True(t, strings.HasSuffix(frames[0].File, "/errors/stack_test.go"))
+		assert.Equal(t, 11, frames[0].Line)
+		assert.Equal(t, frames[0].File+":11", frames[0].String())
+	}
+
+	lines := strings.Split(err.StackTrace(), "\n")
+	assert.Equal(t, "", lines[0])
+	assert.Equal(t, frames[0].String(), lines[1])
+	assert.Len(t, lines, len(frames)+1)
+
+	// Frames returns a copy
+	frames[0].Line = 0
+	assert.Equal(t, 11, err.Frames()[0].Line)
+
+	assert.Nil(t, (&Error{}).Frames())
+	assert.Empty(t, (&Error{}).StackTrace())
+}
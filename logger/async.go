@@ -0,0 +1,146 @@
+package logger
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// Defaults for WithAsyncOutput
+const (
+	DefaultAsyncBufferSize    = 1024
+	DefaultAsyncFlushInterval = time.Second
+)
+
+// ErrWriterClosed is returned when writing to a closed async output
+var ErrWriterClosed = errors.New("logger: async writer closed")
+
+// WithAsyncOutput adds an output written by a background goroutine, so slow
+// sinks (network, disk) don't block logging calls. Up to bufferSize entries
+// are queued before logging blocks, and buffered data is written out every
+// flushInterval and on Flush, Sync and Close.
+func WithAsyncOutput(w io.Writer, bufferSize int, flushInterval time.Duration) Option {
+	return func(l *Logger) {
+		aw := newAsyncWriter(w, bufferSize, flushInterval)
+		l.addOutput(aw, aw)
+	}
+}
+
+// asyncWriter queues writes and performs them on a background goroutine
+type asyncWriter struct {
+	w       io.Writer
+	entries chan []byte
+	flushes chan chan error
+	done    chan struct{}
+	stopped chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func newAsyncWriter(w io.Writer, bufferSize int, flushInterval time.Duration) *asyncWriter {
+	if bufferSize <= 0 {
+		bufferSize = DefaultAsyncBufferSize
+	}
+	if flushInterval <= 0 {
+		flushInterval = DefaultAsyncFlushInterval
+	}
+
+	aw := &asyncWriter{
+		w:       w,
+		entries: make(chan []byte, bufferSize),
+		flushes: make(chan chan error),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go aw.run(flushInterval)
+	return aw
+}
+
+// Write queues a copy of p, the caller may reuse p
+func (aw *asyncWriter) Write(p []byte) (int, error) {
+	aw.mu.RLock()
+	defer aw.mu.RUnlock()
+
+	if aw.closed {
+		return 0, ErrWriterClosed
+	}
+
+	entry := make([]byte, len(p))
+	copy(entry, p)
+	aw.entries <- entry
+	return len(p), nil
+}
+
+// Sync writes out every queued entry and syncs the underlying writer if it supports it
+func (aw *asyncWriter) Sync() error {
+	aw.mu.RLock()
+	defer aw.mu.RUnlock()
+
+	if aw.closed {
+		return nil
+	}
+
+	reply := make(chan error, 1)
+	aw.flushes <- reply
+	return <-reply
+}
+
+// Close writes out every queued entry and stops the background goroutine.
+// The underlying writer is not closed.
+func (aw *asyncWriter) Close() error {
+	aw.mu.Lock()
+	if aw.closed {
+		aw.mu.Unlock()
+		return nil
+	}
+	aw.closed = true
+	aw.mu.Unlock()
+
+	close(aw.done)
+	<-aw.stopped
+	return nil
+}
+
+func (aw *asyncWriter) run(flushInterval time.Duration) {
+	defer close(aw.stopped)
+
+	bw := bufio.NewWriter(aw.w)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	drain := func() {
+		for {
+			select {
+			case entry := <-aw.entries:
+				_, _ = bw.Write(entry)
+			default:
+				return
+			}
+		}
+	}
+	flush := func() error {
+		drain()
+		err := bw.Flush()
+		if s, ok := aw.w.(interface{ Sync() error }); ok {
+			err = errors.Join(err, s.Sync())
+		}
+		return err
+	}
+
+	for {
+		select {
+		case entry := <-aw.entries:
+			_, _ = bw.Write(entry)
+		case <-ticker.C:
+			_ = bw.Flush()
+		case reply := <-aw.flushes:
+			reply <- flush()
+		case <-aw.done:
+			_ = flush()
+			return
+		}
+	}
+}
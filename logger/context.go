@@ -0,0 +1,132 @@
+package logger
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// Field names used by the built-in context extractors
+const (
+	RequestIDKey = "request_id"
+	TraceIDKey   = "trace_id"
+	SpanIDKey    = "span_id"
+)
+
+// ContextExtractor returns the fields to attach to logs written with a context
+type ContextExtractor func(ctx context.Context) []zap.Field
+
+type (
+	loggerKey    struct{}
+	requestIDKey struct{}
+	traceKey     struct{}
+	fieldsKey    struct{}
+)
+
+type traceIDs struct {
+	traceID string
+	spanID  string
+}
+
+// WithContextExtractor adds extractors run by Ctx and FromContext, e.g. to read
+// OpenTelemetry span contexts:
+//
+//	logger.WithContextExtractor(func(ctx context.Context) []zap.Field {
+//		sc := trace.SpanContextFromContext(ctx)
+//		if !sc.IsValid() {
+//			return nil
+//		}
+//		return []zap.Field{zap.String(logger.TraceIDKey, sc.TraceID().String())}
+//	})
+func WithContextExtractor(extractors ...ContextExtractor) Option {
+	return func(l *Logger) {
+		l.extractors = append(l.extractors, extractors...)
+	}
+}
+
+// ContextWithLogger returns a copy of ctx carrying l, retrieved with FromContext
+func ContextWithLogger(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// ContextWithRequestID returns a copy of ctx carrying a request ID
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, if any
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// ContextWithTrace returns a copy of ctx carrying trace and span IDs
+func ContextWithTrace(ctx context.Context, traceID, spanID string) context.Context {
+	return context.WithValue(ctx, traceKey{}, traceIDs{traceID: traceID, spanID: spanID})
+}
+
+// TraceFromContext returns the trace and span IDs carried by ctx, if any
+func TraceFromContext(ctx context.Context) (traceID, spanID string) {
+	ids, _ := ctx.Value(traceKey{}).(traceIDs)
+	return ids.traceID, ids.spanID
+}
+
+// ContextWithFields returns a copy of ctx carrying fields added to every log
+// written with it, on top of the fields already carried
+func ContextWithFields(ctx context.Context, fields ...zap.Field) context.Context {
+	existing, _ := ctx.Value(fieldsKey{}).([]zap.Field)
+	merged := make([]zap.Field, 0, len(existing)+len(fields))
+	merged = append(merged, existing...)
+	merged = append(merged, fields...)
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+// contextFields runs the built-in extractors
+func contextFields(ctx context.Context) []zap.Field {
+	var fields []zap.Field
+	if id := RequestIDFromContext(ctx); id != "" {
+		fields = append(fields, zap.String(RequestIDKey, id))
+	}
+	if traceID, spanID := TraceFromContext(ctx); traceID != "" {
+		fields = append(fields, zap.String(TraceIDKey, traceID))
+		if spanID != "" {
+			fields = append(fields, zap.String(SpanIDKey, spanID))
+		}
+	}
+	if extra, ok := ctx.Value(fieldsKey{}).([]zap.Field); ok {
+		fields = append(fields, extra...)
+	}
+	return fields
+}
+
+// Ctx returns a logger with the request ID, trace IDs and fields carried by
+// ctx attached, plus those returned by the extractors
+func (l *Logger) Ctx(ctx context.Context) *Logger {
+	if ctx == nil {
+		return l
+	}
+
+	fields := contextFields(ctx)
+	for _, extract := range l.extractors {
+		fields = append(fields, extract(ctx)...)
+	}
+	if len(fields) == 0 {
+		return l
+	}
+	return l.With(fields...)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger, with
+// the context fields attached (see Ctx)
+func FromContext(ctx context.Context) *Logger {
+	l := defaultLogger
+	if ctx != nil {
+		if cl, ok := ctx.Value(loggerKey{}).(*Logger); ok && cl != nil {
+			l = cl
+		}
+	}
+	if l == nil {
+		return nil
+	}
+	return l.Ctx(ctx)
+}
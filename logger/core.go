@@ -0,0 +1,40 @@
+package logger
+
+import (
+	"io"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// WithCore adds a core receiving the entries enabled by the logger level,
+// such as the OpenTelemetry exporter of logger/otlplog. Flush syncs the core
+// and Close closes it if it is an io.Closer.
+func WithCore(core zapcore.Core) Option {
+	return func(l *Logger) {
+		l.cores = append(l.cores, core)
+		if c, ok := core.(io.Closer); ok {
+			l.closers = append(l.closers, c)
+		}
+	}
+}
+
+// levelCore filters the entries passed to a core by level
+type levelCore struct {
+	zapcore.Core
+	level zapcore.LevelEnabler
+}
+
+func (c *levelCore) Enabled(level zapcore.Level) bool {
+	return c.level.Enabled(level) && c.Core.Enabled(level)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), level: c.level}
+}
+
+func (c *levelCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.level.Enabled(entry.Level) {
+		return ce
+	}
+	return c.Core.Check(entry, ce)
+}
@@ -0,0 +1,197 @@
+// Package echolog plugs a gocore logger into Echo
+//
+//	e := echo.New()
+//	e.Logger = echolog.New(log)
+//	e.Use(echolog.Middleware(log), echolog.Recover(log))
+package echolog
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"go.uber.org/zap"
+)
+
+// RequestIDHeader is the header the request ID is read from
+var RequestIDHeader = echo.HeaderXRequestID
+
+// Logger adapts a gocore logger to echo.Logger
+type Logger struct {
+	logger *logger.Logger
+	prefix string
+}
+
+var _ echo.Logger = (*Logger)(nil)
+
+// New returns l as an echo.Logger. Levels are shared with l.
+func New(l *logger.Logger) *Logger {
+	return &Logger{logger: l}
+}
+
+// Output returns a writer logging at InfoLevel
+func (e *Logger) Output() io.Writer {
+	return e.logger.Writer(logger.InfoLevel)
+}
+
+// SetOutput is a no-op, outputs are configured on the gocore logger
+func (e *Logger) SetOutput(io.Writer) {}
+
+func (e *Logger) Prefix() string {
+	return e.prefix
+}
+
+// SetPrefix sets a prefix logged in the "prefix" field
+func (e *Logger) SetPrefix(p string) {
+	e.prefix = p
+}
+
+func (e *Logger) Level() log.Lvl {
+	switch e.logger.GetLevel() {
+	case logger.DebugLevel:
+		return log.DEBUG
+	case logger.InfoLevel:
+		return log.INFO
+	case logger.WarnLevel:
+		return log.WARN
+	case logger.ErrorLevel:
+		return log.ERROR
+	default:
+		return log.OFF
+	}
+}
+
+func (e *Logger) SetLevel(v log.Lvl) {
+	switch v {
+	case log.DEBUG:
+		e.logger.SetLevel(logger.DebugLevel)
+	case log.INFO:
+		e.logger.SetLevel(logger.InfoLevel)
+	case log.WARN:
+		e.logger.SetLevel(logger.WarnLevel)
+	case log.ERROR:
+		e.logger.SetLevel(logger.ErrorLevel)
+	case log.OFF:
+		e.logger.SetLevel(logger.FatalLevel)
+	}
+}
+
+// SetHeader is a no-op, the format is configured on the gocore logger
+func (e *Logger) SetHeader(string) {}
+
+func (e *Logger) log(level logger.Level, msg string, fields ...zap.Field) {
+	if e.prefix != "" {
+		fields = append(fields, zap.String("prefix", e.prefix))
+	}
+	e.logger.Log(level, msg, fields...)
+}
+
+func (e *Logger) logj(level logger.Level, j log.JSON) {
+	fields := make([]zap.Field, 0, len(j))
+	for k, v := range j {
+		fields = append(fields, zap.Any(k, v))
+	}
+	e.log(level, "", fields...)
+}
+
+func (e *Logger) Print(i ...any)                 { e.log(logger.InfoLevel, fmt.Sprint(i...)) }
+func (e *Logger) Printf(format string, a ...any) { e.log(logger.InfoLevel, fmt.Sprintf(format, a...)) }
+func (e *Logger) Printj(j log.JSON)              { e.logj(logger.InfoLevel, j) }
+func (e *Logger) Debug(i ...any)                 { e.log(logger.DebugLevel, fmt.Sprint(i...)) }
+func (e *Logger) Debugf(format string, a ...any) { e.log(logger.DebugLevel, fmt.Sprintf(format, a...)) }
+func (e *Logger) Debugj(j log.JSON)              { e.logj(logger.DebugLevel, j) }
+func (e *Logger) Info(i ...any)                  { e.log(logger.InfoLevel, fmt.Sprint(i...)) }
+func (e *Logger) Infof(format string, a ...any)  { e.log(logger.InfoLevel, fmt.Sprintf(format, a...)) }
+func (e *Logger) Infoj(j log.JSON)               { e.logj(logger.InfoLevel, j) }
+func (e *Logger) Warn(i ...any)                  { e.log(logger.WarnLevel, fmt.Sprint(i...)) }
+func (e *Logger) Warnf(format string, a ...any)  { e.log(logger.WarnLevel, fmt.Sprintf(format, a...)) }
+func (e *Logger) Warnj(j log.JSON)               { e.logj(logger.WarnLevel, j) }
+func (e *Logger) Error(i ...any)                 { e.log(logger.ErrorLevel, fmt.Sprint(i...)) }
+func (e *Logger) Errorf(format string, a ...any) { e.log(logger.ErrorLevel, fmt.Sprintf(format, a...)) }
+func (e *Logger) Errorj(j log.JSON)              { e.logj(logger.ErrorLevel, j) }
+func (e *Logger) Fatal(i ...any)                 { e.log(logger.FatalLevel, fmt.Sprint(i...)) }
+func (e *Logger) Fatalf(format string, a ...any) { e.log(logger.FatalLevel, fmt.Sprintf(format, a...)) }
+func (e *Logger) Fatalj(j log.JSON)              { e.logj(logger.FatalLevel, j) }
+func (e *Logger) Panic(i ...any)                 { e.log(logger.PanicLevel, fmt.Sprint(i...)) }
+func (e *Logger) Panicf(format string, a ...any) { e.log(logger.PanicLevel, fmt.Sprintf(format, a...)) }
+func (e *Logger) Panicj(j log.JSON)              { e.logj(logger.PanicLevel, j) }
+
+// Middleware logs every request once it is handled: at InfoLevel, WarnLevel
+// for 4xx and ErrorLevel for 5xx responses. The request context carries the
+// logger and the request ID, so handlers can use logger.FromContext.
+func Middleware(l *logger.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			req := c.Request()
+
+			ctx := logger.ContextWithLogger(req.Context(), l)
+			if id := req.Header.Get(RequestIDHeader); id != "" {
+				ctx = logger.ContextWithRequestID(ctx, id)
+			}
+			c.SetRequest(req.WithContext(ctx))
+
+			err := next(c)
+			if err != nil {
+				// Let the error handler write the response so the status is known
+				c.Error(err)
+			}
+
+			res := c.Response()
+			fields := []zap.Field{
+				zap.String("method", req.Method),
+				zap.String("path", req.URL.Path),
+				zap.Int("status", res.Status),
+				zap.Duration("latency", time.Since(start)),
+				zap.String("client_ip", c.RealIP()),
+				zap.Int64("size", res.Size),
+			}
+			if err != nil {
+				fields = append(fields, zap.Error(err))
+			}
+
+			l.Ctx(ctx).Log(statusLevel(res.Status), "http request", fields...)
+			return nil
+		}
+	}
+}
+
+// Recover recovers from panics in handlers, logs them with the stack trace
+// and passes an error to Echo's error handler
+func Recover(l *logger.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					if r == http.ErrAbortHandler {
+						panic(r)
+					}
+					l.Ctx(c.Request().Context()).Error("panic recovered",
+						zap.Any("panic", r),
+						zap.String("method", c.Request().Method),
+						zap.String("path", c.Request().URL.Path),
+						zap.Stack("stacktrace"),
+					)
+					err = fmt.Errorf("panic: %v", r)
+				}
+			}()
+
+			return next(c)
+		}
+	}
+}
+
+func statusLevel(status int) logger.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return logger.ErrorLevel
+	case status >= http.StatusBadRequest:
+		return logger.WarnLevel
+	default:
+		return logger.InfoLevel
+	}
+}
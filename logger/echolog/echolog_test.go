@@ -0,0 +1,64 @@
+package echolog
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEcho(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(logger.WithOutput(&buf))
+
+	e := echo.New()
+	e.Logger = New(l)
+	e.Use(Middleware(l), Recover(l))
+	e.GET("/ok", func(c echo.Context) error {
+		c.Logger().Infof("hello %s", "echo")
+		return c.String(http.StatusOK, "ok")
+	})
+	e.GET("/missing", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusNotFound, "missing")
+	})
+	e.GET("/panic", func(c echo.Context) error {
+		panic("boom")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/ok", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"msg":"hello echo"`)
+	assert.Contains(t, lines[1], `"status":200`)
+	assert.Contains(t, lines[1], `"request_id":"req-1"`)
+
+	buf.Reset()
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, buf.String(), `"level":"warn"`)
+	assert.Contains(t, buf.String(), `"status":404`)
+
+	buf.Reset()
+	w = httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, buf.String(), `"msg":"panic recovered"`)
+	assert.Contains(t, buf.String(), `"status":500`)
+
+	// Levels are shared with the gocore logger
+	e.Logger.SetLevel(log.WARN)
+	assert.Equal(t, logger.WarnLevel, l.GetLevel())
+	assert.Equal(t, log.WARN, e.Logger.Level())
+}
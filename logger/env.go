@@ -0,0 +1,80 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Environment variables read by FromEnv
+const (
+	EnvLevel    = "LOG_LEVEL"
+	EnvFormat   = "LOG_FORMAT"
+	EnvFile     = "LOG_FILE"
+	EnvSampling = "LOG_SAMPLING"
+)
+
+// FromEnv creates a logger configured by the environment, opts are applied after it:
+//
+//	LOG_LEVEL     debug, info, warn, error... (default info)
+//	LOG_FORMAT    json or console (default json)
+//	LOG_FILE      a file path, stdout or stderr (default stdout)
+//	LOG_SAMPLING  "initial,thereafter" per second, "on" for 100,100 or "off" (default off)
+func FromEnv(opts ...Option) *Logger {
+	var envOpts []Option
+
+	if level := os.Getenv(EnvLevel); level != "" {
+		envOpts = append(envOpts, WithLevelString(level))
+	}
+
+	switch format := strings.ToLower(os.Getenv(EnvFormat)); format {
+	case "", string(JSONFormat):
+	case string(ConsoleFormat), "text":
+		envOpts = append(envOpts, WithFormat(ConsoleFormat))
+	default:
+		fmt.Printf("Error parsing %s: unknown format %q\n", EnvFormat, format)
+	}
+
+	switch file := os.Getenv(EnvFile); file {
+	case "", "stdout":
+		envOpts = append(envOpts, WithConsole())
+	case "stderr":
+		envOpts = append(envOpts, WithOutput(os.Stderr))
+	default:
+		envOpts = append(envOpts, WithFile(file))
+	}
+
+	if sampling := os.Getenv(EnvSampling); sampling != "" {
+		initial, thereafter, err := parseSampling(sampling)
+		if err != nil {
+			fmt.Printf("Error parsing %s: %v\n", EnvSampling, err)
+		} else {
+			envOpts = append(envOpts, WithSampling(initial, thereafter))
+		}
+	}
+
+	return New(append(envOpts, opts...)...)
+}
+
+// parseSampling parses a LOG_SAMPLING value, zero initial disables sampling
+func parseSampling(value string) (initial, thereafter int, err error) {
+	switch strings.ToLower(value) {
+	case "on", "true", "1":
+		return 100, 100, nil
+	case "off", "false", "0":
+		return 0, 0, nil
+	}
+
+	first, rest, ok := strings.Cut(value, ",")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid sampling %q, expected \"initial,thereafter\"", value)
+	}
+	if initial, err = strconv.Atoi(strings.TrimSpace(first)); err != nil || initial < 0 {
+		return 0, 0, fmt.Errorf("invalid sampling initial %q", first)
+	}
+	if thereafter, err = strconv.Atoi(strings.TrimSpace(rest)); err != nil || thereafter < 0 {
+		return 0, 0, fmt.Errorf("invalid sampling thereafter %q", rest)
+	}
+	return initial, thereafter, nil
+}
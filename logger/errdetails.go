@@ -0,0 +1,154 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	gerrors "github.com/ducconit/gocore/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ErrorDetailsSuffix is appended to the key of an error field to name its details field
+const ErrorDetailsSuffix = "_details"
+
+// maxErrorCauses bounds the rendered chain, in case of cyclic Unwrap
+const maxErrorCauses = 32
+
+// WithErrorDetails renders the cause chain of logged errors in a structured
+// "<key>_details" field next to the single-line error string. Each cause
+// has its message and type, and gocore errors add their code, kind, metadata and
+// stack frames.
+func WithErrorDetails() Option {
+	return func(l *Logger) {
+		l.errorDetails = true
+	}
+}
+
+// errorDetailsCore adds a details field for every error field, it wraps each
+// core rather than the tee so that every core still applies its own level
+type errorDetailsCore struct {
+	zapcore.Core
+}
+
+func (c *errorDetailsCore) With(fields []zapcore.Field) zapcore.Core {
+	return &errorDetailsCore{Core: c.Core.With(withErrorDetails(fields))}
+}
+
+func (c *errorDetailsCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return ce.AddCore(entry, c)
+	}
+	return ce
+}
+
+func (c *errorDetailsCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, withErrorDetails(fields))
+}
+
+// withErrorDetails returns fields with a details field after each error field
+func withErrorDetails(fields []zapcore.Field) []zapcore.Field {
+	var out []zapcore.Field
+	for i, f := range fields {
+		err, ok := f.Interface.(error)
+		if f.Type != zapcore.ErrorType || !ok {
+			if out != nil {
+				out = append(out, f)
+			}
+			continue
+		}
+		if out == nil {
+			out = make([]zapcore.Field, 0, len(fields)+1)
+			out = append(out, fields[:i]...)
+		}
+		out = append(out, f, zap.Array(f.Key+ErrorDetailsSuffix, causesOf(err)))
+	}
+	if out == nil {
+		return fields
+	}
+	return out
+}
+
+// errorChain is an error and its causes, outermost first
+type errorChain []error
+
+func (chain errorChain) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	var errs error
+	for _, err := range chain {
+		errs = errors.Join(errs, enc.AppendObject(errorCause{err}))
+	}
+	return errs
+}
+
+// errorCause renders one error of a chain
+type errorCause struct {
+	err error
+}
+
+func (c errorCause) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("message", causeMessage(c.err))
+	enc.AddString("type", fmt.Sprintf("%T", c.err))
+
+	if e, ok := c.err.(*gerrors.Error); ok {
+		if e.Code != "" {
+			enc.AddString("code", e.Code)
+		}
+		if e.Kind != "" {
+			enc.AddString("kind", string(e.Kind))
+		}
+		if len(e.Metadata) > 0 {
+			if err := enc.AddReflected("metadata", e.Metadata); err != nil {
+				return err
+			}
+		}
+		if frames := e.Frames(); len(frames) > 0 {
+			return enc.AddArray("stack", zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+				var errs error
+				for _, frame := range frames {
+					errs = errors.Join(errs, enc.AppendObject(zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+						enc.AddString("function", frame.Function)
+						enc.AddString("file", frame.File)
+						enc.AddInt("line", frame.Line)
+						return nil
+					})))
+				}
+				return errs
+			}))
+		}
+	}
+	return nil
+}
+
+// causeMessage returns the message of err without the messages of its causes
+func causeMessage(err error) string {
+	if e, ok := err.(*gerrors.Error); ok {
+		return e.Message
+	}
+	msg := err.Error()
+	if cause := errors.Unwrap(err); cause != nil {
+		msg = strings.TrimSuffix(msg, ": "+cause.Error())
+	}
+	return msg
+}
+
+// causesOf walks the chain of err breadth-first, following joined errors
+func causesOf(err error) errorChain {
+	var chain errorChain
+	queue := []error{err}
+	for len(queue) > 0 && len(chain) < maxErrorCauses {
+		err, queue = queue[0], queue[1:]
+		if err == nil {
+			continue
+		}
+		chain = append(chain, err)
+
+		switch e := err.(type) {
+		case interface{ Unwrap() []error }:
+			queue = append(queue, e.Unwrap()...)
+		case interface{ Unwrap() error }:
+			queue = append(queue, e.Unwrap())
+		}
+	}
+	return chain
+}
@@ -0,0 +1,82 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultFailoverProbeInterval is how often WithFailover retries a failed primary
+var DefaultFailoverProbeInterval = 30 * time.Second
+
+// WithFailover adds an output writing to primary, or to fallback while primary
+// fails (network sink down, disk full). Every DefaultFailoverProbeInterval an
+// entry is tried on primary again, which is restored once it succeeds.
+// Neither writer is closed by the logger.
+func WithFailover(primary, fallback io.Writer) Option {
+	return func(l *Logger) {
+		l.addOutput(newFailoverWriter(primary, fallback, DefaultFailoverProbeInterval), nil)
+	}
+}
+
+// failoverWriter routes writes to fallback while primary fails
+type failoverWriter struct {
+	primary       io.Writer
+	fallback      io.Writer
+	probeInterval time.Duration
+	now           func() time.Time
+
+	mu        sync.Mutex
+	failed    bool
+	lastProbe time.Time
+}
+
+func newFailoverWriter(primary, fallback io.Writer, probeInterval time.Duration) *failoverWriter {
+	return &failoverWriter{
+		primary:       primary,
+		fallback:      fallback,
+		probeInterval: probeInterval,
+		now:           time.Now,
+	}
+}
+
+func (w *failoverWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.failed || w.now().Sub(w.lastProbe) >= w.probeInterval {
+		n, err := w.primary.Write(p)
+		if err == nil {
+			if w.failed {
+				w.failed = false
+				fmt.Fprintln(os.Stderr, "logger: primary output restored")
+			}
+			return n, nil
+		}
+
+		if !w.failed {
+			w.failed = true
+			fmt.Fprintf(os.Stderr, "logger: primary output failed, using fallback: %v\n", err)
+		}
+		w.lastProbe = w.now()
+	}
+
+	return w.fallback.Write(p)
+}
+
+// Sync syncs both writers if they support it
+func (w *failoverWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var errs []error
+	for _, out := range []io.Writer{w.primary, w.fallback} {
+		if s, ok := out.(interface{ Sync() error }); ok {
+			errs = append(errs, s.Sync())
+		}
+	}
+	return errors.Join(errs...)
+}
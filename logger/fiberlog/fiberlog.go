@@ -0,0 +1,161 @@
+// Package fiberlog plugs a gocore logger into Fiber
+//
+//	app := fiber.New()
+//	app.Use(fiberlog.Middleware(log), fiberlog.Recover(log))
+//	fiberlog.UseAsDefault(log)
+package fiberlog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"go.uber.org/zap"
+)
+
+// RequestIDHeader is the header the request ID is read from
+var RequestIDHeader = fiber.HeaderXRequestID
+
+// Logger adapts a gocore logger to Fiber's log.AllLogger
+type Logger struct {
+	logger *logger.Logger
+}
+
+var _ log.AllLogger = (*Logger)(nil)
+
+// New returns l as a Fiber logger. Levels are shared with l.
+func New(l *logger.Logger) *Logger {
+	return &Logger{logger: l}
+}
+
+// UseAsDefault makes l the logger behind Fiber's log package
+func UseAsDefault(l *logger.Logger) {
+	log.SetLogger(New(l))
+}
+
+// SetLevel sets the level of the gocore logger, Trace maps to Debug
+func (f *Logger) SetLevel(level log.Level) {
+	switch level {
+	case log.LevelTrace, log.LevelDebug:
+		f.logger.SetLevel(logger.DebugLevel)
+	case log.LevelInfo:
+		f.logger.SetLevel(logger.InfoLevel)
+	case log.LevelWarn:
+		f.logger.SetLevel(logger.WarnLevel)
+	case log.LevelError:
+		f.logger.SetLevel(logger.ErrorLevel)
+	case log.LevelFatal:
+		f.logger.SetLevel(logger.FatalLevel)
+	case log.LevelPanic:
+		f.logger.SetLevel(logger.PanicLevel)
+	}
+}
+
+// SetOutput is a no-op, outputs are configured on the gocore logger
+func (f *Logger) SetOutput(io.Writer) {}
+
+// WithContext returns a logger with the context fields attached, see logger.Ctx
+func (f *Logger) WithContext(ctx context.Context) log.CommonLogger {
+	return &Logger{logger: f.logger.Ctx(ctx)}
+}
+
+func (f *Logger) sugar() *zap.SugaredLogger {
+	return f.logger.Logger.Sugar()
+}
+
+func (f *Logger) Trace(v ...any)                 { f.sugar().Debug(v...) }
+func (f *Logger) Debug(v ...any)                 { f.sugar().Debug(v...) }
+func (f *Logger) Info(v ...any)                  { f.sugar().Info(v...) }
+func (f *Logger) Warn(v ...any)                  { f.sugar().Warn(v...) }
+func (f *Logger) Error(v ...any)                 { f.sugar().Error(v...) }
+func (f *Logger) Fatal(v ...any)                 { f.sugar().Fatal(v...) }
+func (f *Logger) Panic(v ...any)                 { f.sugar().Panic(v...) }
+func (f *Logger) Tracef(format string, v ...any) { f.sugar().Debugf(format, v...) }
+func (f *Logger) Debugf(format string, v ...any) { f.sugar().Debugf(format, v...) }
+func (f *Logger) Infof(format string, v ...any)  { f.sugar().Infof(format, v...) }
+func (f *Logger) Warnf(format string, v ...any)  { f.sugar().Warnf(format, v...) }
+func (f *Logger) Errorf(format string, v ...any) { f.sugar().Errorf(format, v...) }
+func (f *Logger) Fatalf(format string, v ...any) { f.sugar().Fatalf(format, v...) }
+func (f *Logger) Panicf(format string, v ...any) { f.sugar().Panicf(format, v...) }
+func (f *Logger) Tracew(msg string, kv ...any)   { f.sugar().Debugw(msg, kv...) }
+func (f *Logger) Debugw(msg string, kv ...any)   { f.sugar().Debugw(msg, kv...) }
+func (f *Logger) Infow(msg string, kv ...any)    { f.sugar().Infow(msg, kv...) }
+func (f *Logger) Warnw(msg string, kv ...any)    { f.sugar().Warnw(msg, kv...) }
+func (f *Logger) Errorw(msg string, kv ...any)   { f.sugar().Errorw(msg, kv...) }
+func (f *Logger) Fatalw(msg string, kv ...any)   { f.sugar().Fatalw(msg, kv...) }
+func (f *Logger) Panicw(msg string, kv ...any)   { f.sugar().Panicw(msg, kv...) }
+
+// Middleware logs every request once it is handled: at InfoLevel, WarnLevel
+// for 4xx and ErrorLevel for 5xx responses. The user context carries the
+// logger and the request ID, so handlers can use logger.FromContext(c.UserContext()).
+func Middleware(l *logger.Logger) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		ctx := logger.ContextWithLogger(c.UserContext(), l)
+		if id := c.Get(RequestIDHeader); id != "" {
+			ctx = logger.ContextWithRequestID(ctx, id)
+		}
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+		if err != nil {
+			// Let the error handler write the response so the status is known
+			if handlerErr := c.App().ErrorHandler(c, err); handlerErr != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+
+		status := c.Response().StatusCode()
+		fields := []zap.Field{
+			zap.String("method", c.Method()),
+			zap.String("path", c.Path()),
+			zap.Int("status", status),
+			zap.Duration("latency", time.Since(start)),
+			zap.String("client_ip", c.IP()),
+			zap.Int("size", len(c.Response().Body())),
+		}
+		if err != nil {
+			fields = append(fields, zap.Error(err))
+		}
+
+		l.Ctx(ctx).Log(statusLevel(status), "http request", fields...)
+		return nil
+	}
+}
+
+// Recover recovers from panics in handlers, logs them with the stack trace
+// and passes an error to Fiber's error handler
+func Recover(l *logger.Logger) fiber.Handler {
+	return func(c *fiber.Ctx) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				l.Ctx(c.UserContext()).Error("panic recovered",
+					zap.Any("panic", r),
+					zap.String("method", c.Method()),
+					zap.String("path", c.Path()),
+					zap.Stack("stacktrace"),
+				)
+				err = fiber.NewError(fiber.StatusInternalServerError, fmt.Sprintf("panic: %v", r))
+			}
+		}()
+
+		return c.Next()
+	}
+}
+
+func statusLevel(status int) logger.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return logger.ErrorLevel
+	case status >= http.StatusBadRequest:
+		return logger.WarnLevel
+	default:
+		return logger.InfoLevel
+	}
+}
@@ -0,0 +1,53 @@
+package fiberlog
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFiber(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(logger.WithOutput(&buf))
+
+	app := fiber.New()
+	app.Use(Middleware(l), Recover(l))
+	app.Get("/ok", func(c *fiber.Ctx) error {
+		New(l).WithContext(c.UserContext()).Infow("in handler", "user", "42")
+		return c.SendString("ok")
+	})
+	app.Get("/panic", func(c *fiber.Ctx) error {
+		panic("boom")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/ok", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"msg":"in handler"`)
+	assert.Contains(t, lines[0], `"user":"42"`)
+	assert.Contains(t, lines[0], `"request_id":"req-1"`)
+	assert.Contains(t, lines[1], `"status":200`)
+
+	buf.Reset()
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/panic", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Contains(t, buf.String(), `"msg":"panic recovered"`)
+	assert.Contains(t, buf.String(), `"status":500`)
+
+	// Levels are shared with the gocore logger
+	New(l).SetLevel(log.LevelError)
+	assert.Equal(t, logger.ErrorLevel, l.GetLevel())
+}
@@ -0,0 +1,89 @@
+package logger
+
+import (
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Fields are structured fields keyed by name, for callers not using zap directly:
+//
+//	log.WithFields(logger.Fields{}.Str("user", id).Int("attempt", n).Err(err)).Warn("login failed")
+type Fields map[string]any
+
+// Str sets a string field
+func (f Fields) Str(key, value string) Fields {
+	f[key] = value
+	return f
+}
+
+// Int sets an int field
+func (f Fields) Int(key string, value int) Fields {
+	f[key] = value
+	return f
+}
+
+// Int64 sets an int64 field
+func (f Fields) Int64(key string, value int64) Fields {
+	f[key] = value
+	return f
+}
+
+// Float sets a float64 field
+func (f Fields) Float(key string, value float64) Fields {
+	f[key] = value
+	return f
+}
+
+// Bool sets a bool field
+func (f Fields) Bool(key string, value bool) Fields {
+	f[key] = value
+	return f
+}
+
+// Dur sets a duration field
+func (f Fields) Dur(key string, value time.Duration) Fields {
+	f[key] = value
+	return f
+}
+
+// Time sets a time field
+func (f Fields) Time(key string, value time.Time) Fields {
+	f[key] = value
+	return f
+}
+
+// Err sets the "error" field, nil errors are skipped
+func (f Fields) Err(err error) Fields {
+	if err != nil {
+		f["error"] = err
+	}
+	return f
+}
+
+// Any sets a field of any type
+func (f Fields) Any(key string, value any) Fields {
+	f[key] = value
+	return f
+}
+
+// Zap returns the fields as zap fields, sorted by key
+func (f Fields) Zap() []zap.Field {
+	keys := make([]string, 0, len(f))
+	for key := range f {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fields := make([]zap.Field, 0, len(keys))
+	for _, key := range keys {
+		fields = append(fields, zap.Any(key, f[key]))
+	}
+	return fields
+}
+
+// WithFields returns a logger with the fields attached, see With
+func (l *Logger) WithFields(fields map[string]any) *Logger {
+	return l.With(Fields(fields).Zap()...)
+}
@@ -0,0 +1,87 @@
+// Package ginlog plugs a gocore logger into Gin
+//
+//	r := gin.New()
+//	r.Use(ginlog.Middleware(log), ginlog.Recovery(log))
+//	ginlog.UseAsDefault(log)
+package ginlog
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RequestIDHeader is the header the request ID is read from
+var RequestIDHeader = "X-Request-ID"
+
+// Middleware logs every request once it is handled: at InfoLevel, WarnLevel
+// for 4xx and ErrorLevel for 5xx responses. The request context carries the
+// logger and the request ID, so handlers can use logger.FromContext.
+func Middleware(l *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		ctx := logger.ContextWithLogger(c.Request.Context(), l)
+		if id := c.GetHeader(RequestIDHeader); id != "" {
+			ctx = logger.ContextWithRequestID(ctx, id)
+		}
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		fields := []zap.Field{
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.Int("status", status),
+			zap.Duration("latency", time.Since(start)),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Int("size", c.Writer.Size()),
+		}
+		if len(c.Errors) > 0 {
+			fields = append(fields, zap.String("errors", c.Errors.String()))
+		}
+
+		l.Ctx(ctx).Log(statusLevel(status), "http request", fields...)
+	}
+}
+
+// Recovery recovers from panics in handlers, logs them with the stack trace
+// and responds with 500 Internal Server Error
+func Recovery(l *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if r := recover(); r != nil {
+				l.Ctx(c.Request.Context()).Error("panic recovered",
+					zap.Any("panic", r),
+					zap.String("method", c.Request.Method),
+					zap.String("path", c.Request.URL.Path),
+					zap.Stack("stacktrace"),
+				)
+				c.AbortWithStatus(http.StatusInternalServerError)
+			}
+		}()
+
+		c.Next()
+	}
+}
+
+// UseAsDefault sends Gin's own debug and error output through l
+func UseAsDefault(l *logger.Logger) {
+	gin.DefaultWriter = l.Writer(logger.DebugLevel)
+	gin.DefaultErrorWriter = l.Writer(logger.ErrorLevel)
+}
+
+func statusLevel(status int) logger.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return logger.ErrorLevel
+	case status >= http.StatusBadRequest:
+		return logger.WarnLevel
+	default:
+		return logger.InfoLevel
+	}
+}
@@ -1,6 +1,10 @@
 package logger
 
-import "go.uber.org/zap"
+import (
+	"os"
+
+	"go.uber.org/zap"
+)
 
 var (
 	defaultLogger *Logger
@@ -30,18 +34,57 @@ func Error(msg string, fields ...zap.Field) {
 	}
 }
 
+func DPanic(msg string, fields ...zap.Field) {
+	if defaultLogger != nil {
+		defaultLogger.DPanic(msg, fields...)
+	}
+}
+
 func Panic(msg string, fields ...zap.Field) {
 	if defaultLogger != nil {
 		defaultLogger.Panic(msg, fields...)
 	}
 }
 
+// Fatal logs with the default logger, flushes buffered outputs and exits with
+// status 1. It exits even without a default logger.
+func Fatal(msg string, fields ...zap.Field) {
+	if defaultLogger != nil {
+		defaultLogger.Fatal(msg, fields...)
+	}
+	os.Exit(1)
+}
+
+// With returns a logger scoped with fields. Without a default logger, the
+// returned logger discards everything.
 func With(fields ...zap.Field) *Logger {
 	if defaultLogger != nil {
 		return defaultLogger.With(fields...)
 	}
+	return New().With(fields...)
+}
+
+// ForService returns a child of the default logger for a service, see Logger.ForService
+func ForService(name string) *Logger {
+	if defaultLogger != nil {
+		return defaultLogger.ForService(name)
+	}
+	return New().ForService(name)
+}
+
+// WithFields returns a logger with the fields attached, see With
+func WithFields(fields map[string]any) *Logger {
+	return With(Fields(fields).Zap()...)
+}
+
+// Flush writes out the entries queued by async outputs of the default logger
+func Flush() error {
+	if defaultLogger != nil {
+		return defaultLogger.Flush()
+	}
 	return nil
 }
+
 func Instance() *Logger {
 	return defaultLogger
 }
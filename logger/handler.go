@@ -0,0 +1,117 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+// LevelHandlerPath is the path MountLevelHandler serves on
+const LevelHandlerPath = "/debug/log/level"
+
+// Mux registers handlers, it is implemented by *http.ServeMux
+type Mux interface {
+	Handle(pattern string, handler http.Handler)
+}
+
+// MountLevelHandler serves LevelHandler on mux at LevelHandlerPath
+func MountLevelHandler(mux Mux) {
+	mux.Handle(LevelHandlerPath, LevelHandler())
+}
+
+type levelPayload struct {
+	Logger  string            `json:"logger,omitempty"`
+	Level   string            `json:"level,omitempty"`
+	Loggers map[string]string `json:"loggers,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// LevelHandler returns an http.Handler reading and changing log levels at runtime.
+//
+// GET returns the level of the default logger and of every registered logger:
+//
+//	{"level":"info","loggers":{"db":"debug"}}
+//
+// PUT sets the level of the default logger, or of a registered logger when
+// "logger" is given, from a JSON body or form values:
+//
+//	curl -X PUT -H 'Content-Type: application/json' -d '{"level":"debug","logger":"db"}' localhost:8080/debug/log/level
+//	curl -X PUT -d 'level=debug' localhost:8080/debug/log/level
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			if name := r.URL.Query().Get("logger"); name != "" {
+				l, ok := Get(name)
+				if !ok {
+					writeLevelPayload(w, http.StatusNotFound, levelPayload{Error: fmt.Sprintf("unknown logger %q", name)})
+					return
+				}
+				writeLevelPayload(w, http.StatusOK, levelPayload{Logger: name, Level: l.GetLevel().String()})
+				return
+			}
+			writeLevelPayload(w, http.StatusOK, currentLevels())
+
+		case http.MethodPut:
+			var req levelPayload
+			if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/x-www-form-urlencoded" {
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					writeLevelPayload(w, http.StatusBadRequest, levelPayload{Error: fmt.Sprintf("invalid body: %v", err)})
+					return
+				}
+			} else {
+				req.Logger = r.FormValue("logger")
+				req.Level = r.FormValue("level")
+			}
+
+			var level Level
+			if err := level.UnmarshalText([]byte(req.Level)); err != nil || req.Level == "" {
+				writeLevelPayload(w, http.StatusBadRequest, levelPayload{Error: fmt.Sprintf("invalid level %q", req.Level)})
+				return
+			}
+
+			l := Instance()
+			if req.Logger != "" {
+				var ok bool
+				if l, ok = Get(req.Logger); !ok {
+					writeLevelPayload(w, http.StatusNotFound, levelPayload{Error: fmt.Sprintf("unknown logger %q", req.Logger)})
+					return
+				}
+			}
+			if l == nil {
+				writeLevelPayload(w, http.StatusNotFound, levelPayload{Error: "no default logger"})
+				return
+			}
+
+			l.SetLevel(level)
+			writeLevelPayload(w, http.StatusOK, levelPayload{Logger: req.Logger, Level: level.String()})
+
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			writeLevelPayload(w, http.StatusMethodNotAllowed, levelPayload{Error: "only GET and PUT are supported"})
+		}
+	})
+}
+
+func currentLevels() levelPayload {
+	var payload levelPayload
+	if l := Instance(); l != nil {
+		payload.Level = l.GetLevel().String()
+	}
+	for _, name := range Names() {
+		if l, ok := Get(name); ok {
+			if payload.Loggers == nil {
+				payload.Loggers = make(map[string]string)
+			}
+			payload.Loggers[name] = l.GetLevel().String()
+		}
+	}
+	return payload
+}
+
+func writeLevelPayload(w http.ResponseWriter, status int, payload levelPayload) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(payload)
+}
@@ -0,0 +1,66 @@
+package logger
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// Hook receives log entries with their fields, e.g. to forward errors to
+// Sentry, Slack or an alerting system. Returned errors are reported on stderr.
+type Hook func(entry zapcore.Entry, fields []zapcore.Field) error
+
+type hook struct {
+	level zapcore.LevelEnabler
+	fn    Hook
+}
+
+// WithHook calls hook for every entry enabled by the logger level
+func WithHook(fn Hook) Option {
+	return func(l *Logger) {
+		l.hooks = append(l.hooks, hook{level: l.level, fn: fn})
+	}
+}
+
+// WithLevelHook calls hook for every entry at or above level, regardless of the logger level
+func WithLevelHook(level Level, fn Hook) Option {
+	return func(l *Logger) {
+		l.hooks = append(l.hooks, hook{level: level, fn: fn})
+	}
+}
+
+// hookCore is a zapcore.Core passing entries to a hook
+type hookCore struct {
+	hook   hook
+	fields []zapcore.Field
+}
+
+func (c *hookCore) Enabled(level zapcore.Level) bool {
+	return c.hook.level.Enabled(level)
+}
+
+func (c *hookCore) With(fields []zapcore.Field) zapcore.Core {
+	merged := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	merged = append(merged, c.fields...)
+	merged = append(merged, fields...)
+	return &hookCore{hook: c.hook, fields: merged}
+}
+
+func (c *hookCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return ce.AddCore(entry, c)
+	}
+	return ce
+}
+
+func (c *hookCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	all := fields
+	if len(c.fields) > 0 {
+		all = make([]zapcore.Field, 0, len(c.fields)+len(fields))
+		all = append(all, c.fields...)
+		all = append(all, fields...)
+	}
+	return c.hook.fn(entry, all)
+}
+
+func (c *hookCore) Sync() error {
+	return nil
+}
@@ -0,0 +1,86 @@
+// Package kafkalog ships gocore log entries to a Kafka topic
+//
+//	log := logger.New(
+//		logger.WithConsole(),
+//		logger.WithShipper(kafkalog.New([]string{"kafka:9092"}, "app-logs")),
+//	)
+//	defer log.Close()
+package kafkalog
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/segmentio/kafka-go"
+)
+
+// MessageWriter writes messages to Kafka, it is implemented by *kafka.Writer
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+var _ MessageWriter = (*kafka.Writer)(nil)
+
+// Publisher publishes log entries as Kafka messages
+type Publisher struct {
+	writer MessageWriter
+	key    []byte
+}
+
+var _ logger.Publisher = (*Publisher)(nil)
+
+// Option configures a Publisher
+type Option func(*Publisher)
+
+// WithKey sets the message key, e.g. the service name, so entries of one
+// service land on the same partition and stay ordered
+func WithKey(key string) Option {
+	return func(p *Publisher) {
+		p.key = []byte(key)
+	}
+}
+
+// New returns a publisher writing to topic on brokers. Batching is done by
+// the logger, so the writer sends every batch right away.
+func New(brokers []string, topic string, opts ...Option) *Publisher {
+	return NewPublisher(&kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.LeastBytes{},
+		BatchSize:    logger.DefaultShipBatchSize,
+		BatchTimeout: time.Millisecond,
+		RequiredAcks: kafka.RequireOne,
+	}, opts...)
+}
+
+// NewPublisher returns a publisher using an existing writer, e.g. a
+// *kafka.Writer with TLS or SASL configured
+func NewPublisher(w MessageWriter, opts ...Option) *Publisher {
+	p := &Publisher{writer: w}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Publish writes entries as one message each
+func (p *Publisher) Publish(ctx context.Context, entries [][]byte) error {
+	msgs := make([]kafka.Message, len(entries))
+	for i, entry := range entries {
+		msgs[i] = kafka.Message{Key: p.key, Value: entry}
+	}
+	if err := p.writer.WriteMessages(ctx, msgs...); err != nil {
+		return fmt.Errorf("failed to write kafka messages: %w", err)
+	}
+	return nil
+}
+
+// Close closes the underlying writer if it supports it
+func (p *Publisher) Close() error {
+	if c, ok := p.writer.(interface{ Close() error }); ok {
+		return c.Close()
+	}
+	return nil
+}
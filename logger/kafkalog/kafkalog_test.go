@@ -0,0 +1,40 @@
+package kafkalog
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeWriter struct {
+	msgs []kafka.Message
+	err  error
+}
+
+func (w *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func TestPublisher(t *testing.T) {
+	w := &fakeWriter{}
+	l := logger.New(logger.WithShipper(NewPublisher(w, WithKey("checkout"))))
+
+	l.Info("order placed")
+	assert.NoError(t, l.Close())
+
+	assert.Len(t, w.msgs, 1)
+	assert.Equal(t, "checkout", string(w.msgs[0].Key))
+	assert.Contains(t, string(w.msgs[0].Value), `"msg":"order placed"`)
+
+	w.err = errors.New("broker down")
+	err := NewPublisher(w).Publish(context.Background(), [][]byte{[]byte("{}")})
+	assert.ErrorIs(t, err, w.err)
+}
@@ -1,26 +1,61 @@
 package logger
 
 import (
+	"errors"
 	"io"
+	"os"
 	"sync"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
+// Time format presets for WithTimeFormat, any other value is used as a time layout
+const (
+	TimeFormatRFC3339     = time.RFC3339
+	TimeFormatRFC3339Nano = time.RFC3339Nano
+	TimeFormatISO8601     = "iso8601"
+	TimeFormatUnix        = "unix"
+	TimeFormatUnixMilli   = "unixmilli"
+	TimeFormatUnixNano    = "unixnano"
+)
+
+// Format is the encoding of log entries
+type Format string
+
+// Formats for WithFormat
+const (
+	JSONFormat    Format = "json"
+	ConsoleFormat Format = "console"
+)
+
 // Logger represents a logger instance
 type Logger struct {
 	*zap.Logger
-	level   Level
-	outputs []io.Writer
-	mu      sync.RWMutex
+	level        zap.AtomicLevel
+	timeFormat   string
+	format       Format
+	sampling     *samplingConfig
+	extractors   []ContextExtractor
+	hooks        []hook
+	outputs      []output
+	lastHandle   OutputHandle
+	closers      []io.Closer
+	suppressor   *suppressor
+	metrics      *Metrics
+	errorDetails bool
+	name         string
+	version      string
+	cores        []zapcore.Core
+	mu           sync.RWMutex
 }
 
 // New creates a new logger instance
 func New(opts ...Option) *Logger {
 	l := &Logger{
-		level:   InfoLevel, // default level
-		outputs: make([]io.Writer, 0),
+		level:   zap.NewAtomicLevelAt(InfoLevel), // default level
+		outputs: make([]output, 0),
 	}
 
 	// Apply options
@@ -28,6 +63,13 @@ func New(opts ...Option) *Logger {
 		opt(l)
 	}
 
+	l.build()
+	return l
+}
+
+// build recreates the zap logger from the outputs. Every core shares the
+// atomic level, so SetLevel takes effect immediately.
+func (l *Logger) build() {
 	// Create encoder config
 	encConfig := zapcore.EncoderConfig{
 		MessageKey:     "msg",
@@ -38,24 +80,69 @@ func New(opts ...Option) *Logger {
 		StacktraceKey:  "stacktrace",
 		LineEnding:     zapcore.DefaultLineEnding,
 		EncodeLevel:    zapcore.LowercaseLevelEncoder,
-		EncodeTime:     zapcore.RFC3339NanoTimeEncoder,
+		EncodeTime:     timeEncoder(l.timeFormat),
 		EncodeDuration: zapcore.SecondsDurationEncoder,
 		EncodeCaller:   zapcore.ShortCallerEncoder,
 	}
 
 	// Create cores
 	var cores []zapcore.Core
-	if len(l.outputs) > 0 {
-		enc := zapcore.NewJSONEncoder(encConfig)
-		for _, output := range l.outputs {
-			core := zapcore.NewCore(enc, zapcore.AddSync(output), l.level)
-			cores = append(cores, core)
+	var enc zapcore.Encoder
+	if l.format == ConsoleFormat {
+		enc = zapcore.NewConsoleEncoder(encConfig)
+	} else {
+		enc = zapcore.NewJSONEncoder(encConfig)
+	}
+	for _, o := range l.outputs {
+		core := zapcore.NewCore(enc, zapcore.AddSync(o.w), l.level)
+		cores = append(cores, core)
+	}
+	for _, h := range l.hooks {
+		cores = append(cores, &hookCore{hook: h})
+	}
+	if l.metrics != nil {
+		cores = append(cores, &metricsCore{level: l.level, metrics: l.metrics})
+	}
+	for _, c := range l.cores {
+		cores = append(cores, &levelCore{Core: c, level: l.level})
+	}
+
+	if l.errorDetails {
+		for i, core := range cores {
+			cores[i] = &errorDetailsCore{Core: core}
 		}
 	}
 
+	core := zapcore.NewTee(cores...)
+	if l.sampling != nil {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, l.sampling.initial, l.sampling.thereafter)
+	}
+	if l.suppressor != nil {
+		core = &suppressCore{Core: core, s: l.suppressor}
+	}
+
 	// Create logger
-	l.Logger = zap.New(zapcore.NewTee(cores...))
-	return l
+	l.Logger = zap.New(core, zap.WithFatalHook(fatalHook{l})).Named(l.name)
+}
+
+// timeEncoder returns the encoder for a WithTimeFormat value
+func timeEncoder(format string) zapcore.TimeEncoder {
+	switch format {
+	case "", TimeFormatRFC3339Nano:
+		return zapcore.RFC3339NanoTimeEncoder
+	case TimeFormatRFC3339:
+		return zapcore.RFC3339TimeEncoder
+	case TimeFormatISO8601:
+		return zapcore.ISO8601TimeEncoder
+	case TimeFormatUnix:
+		return zapcore.EpochTimeEncoder
+	case TimeFormatUnixMilli:
+		return zapcore.EpochMillisTimeEncoder
+	case TimeFormatUnixNano:
+		return zapcore.EpochNanosTimeEncoder
+	default:
+		return zapcore.TimeEncoderOfLayout(format)
+	}
 }
 
 // Default returns the default logger instance
@@ -77,11 +164,9 @@ func init() {
 	SetDefault(Default())
 }
 
-// SetLevel sets the minimum log level
+// SetLevel sets the minimum log level of the logger and every logger derived from it with With
 func (l *Logger) SetLevel(level Level) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	l.level = level
+	l.level.SetLevel(level)
 }
 
 // SetLevelString sets the minimum log level using a string
@@ -91,50 +176,59 @@ func (l *Logger) SetLevelString(levelStr string) {
 
 // GetLevel returns the current log level
 func (l *Logger) GetLevel() Level {
+	return l.level.Level()
+}
+
+// Flush writes out the entries queued by async and shipping outputs and the
+// cores of WithCore. Call it before exiting
+// if the logger is not closed.
+func (l *Logger) Flush() error {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
-	return l.level
+	return l.flush()
 }
 
-// AddOutput adds a new output writer
-func (l *Logger) AddOutput(w io.Writer) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	l.outputs = append(l.outputs, w)
-
-	// Update zap logger with new output
-	encConfig := zapcore.EncoderConfig{
-		MessageKey:     "msg",
-		LevelKey:       "level",
-		TimeKey:        "time",
-		NameKey:        "logger",
-		CallerKey:      "caller",
-		StacktraceKey:  "stacktrace",
-		LineEnding:     zapcore.DefaultLineEnding,
-		EncodeLevel:    zapcore.LowercaseLevelEncoder,
-		EncodeTime:     zapcore.ISO8601TimeEncoder,
-		EncodeDuration: zapcore.SecondsDurationEncoder,
-		EncodeCaller:   zapcore.ShortCallerEncoder,
+// flush is Flush for callers holding the lock
+func (l *Logger) flush() error {
+	if l.suppressor != nil {
+		l.suppressor.flush()
 	}
 
-	var cores []zapcore.Core
-	enc := zapcore.NewJSONEncoder(encConfig)
-	for _, output := range l.outputs {
-		core := zapcore.NewCore(enc, zapcore.AddSync(output), l.level)
-		cores = append(cores, core)
+	var errs []error
+	for _, o := range l.outputs {
+		switch w := o.w.(type) {
+		case *asyncWriter:
+			errs = append(errs, w.Sync())
+		case *ShipWriter:
+			errs = append(errs, w.Sync())
+		}
 	}
-
-	l.Logger = zap.New(zapcore.NewTee(cores...))
+	for _, c := range l.cores {
+		errs = append(errs, c.Sync())
+	}
+	return errors.Join(errs...)
 }
 
-// ClearOutputs removes all output writers
-func (l *Logger) ClearOutputs() {
+// Close flushes buffered logs and closes the outputs the logger opened, such
+// as files from WithFile and WithRotatingFile, and the cores of WithCore
+func (l *Logger) Close() error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	l.outputs = make([]io.Writer, 0)
 
-	// Reset zap logger
-	l.Logger = zap.New(zapcore.NewTee())
+	_ = l.Logger.Sync()
+
+	errs := []error{closeOutputs(l.outputs)}
+	for i := range l.outputs {
+		l.outputs[i].closer = nil
+	}
+	for _, c := range l.closers {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	l.closers = nil
+
+	return errors.Join(errs...)
 }
 
 func (l *Logger) Log(level Level, msg string, fields ...zap.Field) {
@@ -159,16 +253,47 @@ func (l *Logger) Error(msg string, fields ...zap.Field) {
 	l.Log(ErrorLevel, msg, fields...)
 }
 
+func (l *Logger) DPanic(msg string, fields ...zap.Field) {
+	l.Log(DPanicLevel, msg, fields...)
+}
+
 func (l *Logger) Panic(msg string, fields ...zap.Field) {
 	l.Log(PanicLevel, msg, fields...)
 }
 
+// Fatal logs at FatalLevel, flushes buffered outputs and exits with status 1
+func (l *Logger) Fatal(msg string, fields ...zap.Field) {
+	l.Log(FatalLevel, msg, fields...)
+}
+
+// fatalHook flushes buffered outputs before exiting, it runs while Log holds the lock
+type fatalHook struct {
+	l *Logger
+}
+
+func (h fatalHook) OnWrite(*zapcore.CheckedEntry, []zapcore.Field) {
+	_ = h.l.flush()
+	os.Exit(1)
+}
+
 func (l *Logger) With(fields ...zap.Field) *Logger {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 	return &Logger{
-		Logger:  l.Logger.With(fields...),
-		level:   l.level,
-		outputs: l.outputs,
+		Logger:       l.Logger.With(fields...),
+		level:        l.level,
+		timeFormat:   l.timeFormat,
+		format:       l.format,
+		sampling:     l.sampling,
+		extractors:   l.extractors,
+		hooks:        l.hooks,
+		outputs:      l.outputs,
+		lastHandle:   l.lastHandle,
+		suppressor:   l.suppressor,
+		metrics:      l.metrics,
+		errorDetails: l.errorDetails,
+		name:         l.name,
+		version:      l.version,
+		cores:        l.cores,
 	}
 }
@@ -2,16 +2,28 @@ package logger
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
 	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	gerrors "github.com/ducconit/gocore/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestNewLogger(t *testing.T) {
@@ -72,9 +84,10 @@ func newTestLogger(buf *bytes.Buffer) *Logger {
 
 	// Create logger
 	l := &Logger{
-		Logger:  zap.New(core),
-		level:   DebugLevel,
-		outputs: []io.Writer{buf},
+		Logger:     zap.New(core),
+		level:      zap.NewAtomicLevelAt(DebugLevel),
+		outputs:    []output{{handle: 1, w: buf}},
+		lastHandle: 1,
 	}
 
 	return l
@@ -183,9 +196,10 @@ func TestLogger_MultipleOutputs(t *testing.T) {
 
 	// Create logger
 	logger := &Logger{
-		Logger:  zap.New(zapcore.NewTee(core1, core2)),
-		level:   DebugLevel,
-		outputs: []io.Writer{&buf1, &buf2},
+		Logger:     zap.New(zapcore.NewTee(core1, core2)),
+		level:      zap.NewAtomicLevelAt(DebugLevel),
+		outputs:    []output{{handle: 1, w: &buf1}, {handle: 2, w: &buf2}},
+		lastHandle: 2,
 	}
 
 	// Log message
@@ -259,3 +273,727 @@ func TestLogger_TimeFormat(t *testing.T) {
 	assert.Contains(t, output, "time")
 	assert.Contains(t, output, time.Now().Format("2006"))
 }
+
+func TestLogger_RotatingFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	logFile := filepath.Join(tmpDir, "app.log")
+
+	logger := New(WithRotatingFile(logFile, 1, 2, 0, false))
+
+	// Write a bit more than 4MB so the file rotates several times
+	payload := strings.Repeat("x", 1024)
+	for i := 0; i < 4*1024+100; i++ {
+		logger.Info(payload)
+	}
+	assert.NoError(t, logger.Close())
+
+	// The active file plus at most two backups are kept
+	assert.Eventually(t, func() bool {
+		files, err := os.ReadDir(tmpDir)
+		return err == nil && len(files) == 3
+	}, 2*time.Second, 20*time.Millisecond)
+
+	info, err := os.Stat(logFile)
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, info.Size(), int64(1024*1024))
+}
+
+func TestLogger_SetLevelAtRuntime(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(WithOutput(&buf), WithLevel(InfoLevel))
+	child := logger.With(zap.String("module", "test"))
+
+	logger.Debug("hidden")
+	assert.Empty(t, buf.String())
+
+	logger.SetLevelString("debug")
+	assert.Equal(t, DebugLevel, logger.GetLevel())
+	logger.Debug("visible")
+	child.Debug("child visible")
+	assert.Contains(t, buf.String(), "visible")
+	assert.Contains(t, buf.String(), "child visible")
+
+	buf.Reset()
+	logger.SetLevel(ErrorLevel)
+	logger.Warn("hidden warning")
+	child.Warn("hidden child warning")
+	assert.Empty(t, buf.String())
+
+	// Outputs added later follow the current level
+	var extra bytes.Buffer
+	logger.AddOutput(&extra)
+	logger.Info("hidden info")
+	logger.Error("shown error")
+	assert.NotContains(t, extra.String(), "hidden info")
+	assert.Contains(t, extra.String(), "shown error")
+}
+
+func TestLogger_WithTimeFormat(t *testing.T) {
+	tests := []struct {
+		format string
+		match  string
+	}{
+		{"", `"time":"\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d+`},
+		{TimeFormatRFC3339, `"time":"\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(Z|[+-]\d{2}:\d{2})"`},
+		{TimeFormatISO8601, `"time":"\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}`},
+		{TimeFormatUnix, `"time":\d+\.\d+`},
+		{TimeFormatUnixMilli, `"time":\d{13}`},
+		{"2006/01/02 15:04", `"time":"\d{4}/\d{2}/\d{2} \d{2}:\d{2}"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := New(WithOutput(&buf), WithTimeFormat(tt.format))
+			logger.Info("test time format")
+			assert.Regexp(t, tt.match, buf.String())
+
+			// Outputs added later use the same format
+			var extra bytes.Buffer
+			logger.AddOutput(&extra)
+			logger.Info("again")
+			assert.Regexp(t, tt.match, extra.String())
+		})
+	}
+}
+
+func TestLogger_Context(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(WithOutput(&buf), WithContextExtractor(func(ctx context.Context) []zap.Field {
+		if tenant, ok := ctx.Value("tenant").(string); ok {
+			return []zap.Field{zap.String("tenant", tenant)}
+		}
+		return nil
+	}))
+
+	ctx := ContextWithRequestID(context.Background(), "req-1")
+	ctx = ContextWithTrace(ctx, "trace-1", "span-1")
+	ctx = ContextWithFields(ctx, zap.String("user_id", "42"))
+	ctx = context.WithValue(ctx, "tenant", "acme")
+
+	logger.Ctx(ctx).Info("handled")
+	output := buf.String()
+	assert.Contains(t, output, `"request_id":"req-1"`)
+	assert.Contains(t, output, `"trace_id":"trace-1"`)
+	assert.Contains(t, output, `"span_id":"span-1"`)
+	assert.Contains(t, output, `"user_id":"42"`)
+	assert.Contains(t, output, `"tenant":"acme"`)
+
+	// The logger travels with the context
+	buf.Reset()
+	ctx = ContextWithLogger(ctx, logger)
+	FromContext(ctx).Info("from context")
+	assert.Contains(t, buf.String(), `"request_id":"req-1"`)
+	assert.Contains(t, buf.String(), "from context")
+
+	// Without a logger in the context the default logger is used
+	assert.NotNil(t, FromContext(context.Background()))
+	assert.Same(t, logger, logger.Ctx(context.Background()))
+}
+
+func TestLogger_RedirectStdLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(WithOutput(&buf), WithLevel(DebugLevel))
+
+	restore := RedirectStdLogAt(logger, WarnLevel)
+	log.Printf("third party says %d", 42)
+	log.Print("line one\nline two")
+	restore()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Contains(t, lines[0], `"level":"warn"`)
+	assert.Contains(t, lines[0], `"msg":"third party says 42"`)
+	assert.Contains(t, lines[2], `"msg":"line two"`)
+
+	// Output is restored
+	buf.Reset()
+	var stdBuf bytes.Buffer
+	log.SetOutput(&stdBuf)
+	defer log.SetOutput(os.Stderr)
+	log.Print("not captured")
+	assert.Empty(t, buf.String())
+
+	// The writer can also be handed to libraries directly
+	_, err := fmt.Fprintln(logger.Writer(ErrorLevel), "from writer")
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), `"level":"error"`)
+}
+
+// slowWriter simulates a slow sink
+type slowWriter struct {
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	delay time.Duration
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.delay)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *slowWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func TestLogger_AsyncOutput(t *testing.T) {
+	sink := &slowWriter{delay: 50 * time.Millisecond}
+	logger := New(WithAsyncOutput(sink, 100, time.Hour))
+
+	// Logging does not wait for the sink
+	start := time.Now()
+	for i := 0; i < 50; i++ {
+		logger.Info("async entry", zap.Int("i", i))
+	}
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+
+	assert.NoError(t, logger.Flush())
+	assert.Equal(t, 50, strings.Count(sink.String(), "async entry"))
+
+	logger.Info("last entry")
+	assert.NoError(t, logger.Close())
+	assert.Contains(t, sink.String(), "last entry")
+
+	// Writes after Close fail instead of blocking
+	_, err := logger.outputs[0].w.Write([]byte("x"))
+	assert.ErrorIs(t, err, ErrWriterClosed)
+}
+
+func TestLogger_Hooks(t *testing.T) {
+	var (
+		buf     bytes.Buffer
+		mu      sync.Mutex
+		all     []string
+		alerts  []string
+		alertAt []map[string]any
+	)
+
+	logger := New(
+		WithOutput(&buf),
+		WithLevel(WarnLevel),
+		WithHook(func(entry zapcore.Entry, fields []zapcore.Field) error {
+			mu.Lock()
+			defer mu.Unlock()
+			all = append(all, entry.Message)
+			return nil
+		}),
+		WithLevelHook(ErrorLevel, func(entry zapcore.Entry, fields []zapcore.Field) error {
+			enc := zapcore.NewMapObjectEncoder()
+			for _, f := range fields {
+				f.AddTo(enc)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			alerts = append(alerts, entry.Message)
+			alertAt = append(alertAt, enc.Fields)
+			return nil
+		}),
+	)
+
+	child := logger.With(zap.String("module", "billing"))
+	child.Info("ignored")
+	child.Warn("slow query")
+	child.Error("payment failed", zap.String("order", "42"))
+
+	assert.Equal(t, []string{"slow query", "payment failed"}, all)
+	assert.Equal(t, []string{"payment failed"}, alerts)
+	assert.Equal(t, map[string]any{"module": "billing", "order": "42"}, alertAt[0])
+
+	// Hooks follow runtime level changes and survive output changes
+	logger.SetLevel(InfoLevel)
+	logger.ClearOutputs()
+	logger.Info("now visible")
+	assert.Equal(t, "now visible", all[len(all)-1])
+}
+
+// closingCore records entries, syncs and closes
+type closingCore struct {
+	zapcore.Core
+	synced int
+	closed bool
+}
+
+func (c *closingCore) Sync() error {
+	c.synced++
+	return nil
+}
+
+func (c *closingCore) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestLogger_WithCore(t *testing.T) {
+	observed, logs := observer.New(DebugLevel)
+	core := &closingCore{Core: observed}
+
+	logger := New(WithLevel(InfoLevel), WithCore(core))
+	logger.Debug("dropped by level")
+	logger.With(zap.String("order", "42")).Info("order placed")
+	assert.Equal(t, 1, logs.Len())
+	assert.Equal(t, "order placed", logs.All()[0].Message)
+	assert.Equal(t, "42", logs.All()[0].ContextMap()["order"])
+
+	assert.NoError(t, logger.Flush())
+	assert.Positive(t, core.synced)
+	assert.NoError(t, logger.Close())
+	assert.True(t, core.closed)
+}
+
+type fakePublisher struct {
+	mu      sync.Mutex
+	batches [][][]byte
+	fail    int
+	block   chan struct{}
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, entries [][]byte) error {
+	if p.block != nil {
+		<-p.block
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fail > 0 {
+		p.fail--
+		return fmt.Errorf("unavailable")
+	}
+	p.batches = append(p.batches, entries)
+	return nil
+}
+
+func (p *fakePublisher) entries() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var all []string
+	for _, batch := range p.batches {
+		for _, entry := range batch {
+			all = append(all, string(entry))
+		}
+	}
+	return all
+}
+
+func TestLogger_Shipper(t *testing.T) {
+	t.Run("batches and flushes", func(t *testing.T) {
+		pub := &fakePublisher{fail: 1}
+		logger := New(WithShipper(pub, WithBatchSize(2), WithBatchTimeout(time.Hour), WithMaxRetries(1)))
+
+		logger.Info("one")
+		logger.Info("two")
+		logger.Info("three")
+		assert.NoError(t, logger.Flush())
+
+		entries := pub.entries()
+		assert.Len(t, entries, 3)
+		assert.Contains(t, entries[0], `"msg":"one"`)
+		assert.Contains(t, entries[2], `"msg":"three"`)
+
+		pub.mu.Lock()
+		assert.Len(t, pub.batches, 2)
+		pub.mu.Unlock()
+		assert.NoError(t, logger.Close())
+	})
+
+	t.Run("drops when full", func(t *testing.T) {
+		pub := &fakePublisher{block: make(chan struct{})}
+		w := NewShipWriter(pub, WithBatchSize(1), WithBufferSize(1), WithDropWhenFull())
+
+		for i := 0; i < 10; i++ {
+			_, err := w.Write([]byte("entry"))
+			assert.NoError(t, err)
+		}
+		assert.Greater(t, w.Dropped(), uint64(0))
+
+		close(pub.block)
+		assert.NoError(t, w.Close())
+		_, err := w.Write([]byte("late"))
+		assert.ErrorIs(t, err, ErrWriterClosed)
+	})
+}
+
+func TestLogger_RemoveOutput(t *testing.T) {
+	var main, extra bytes.Buffer
+	logger := New(WithOutput(&main))
+
+	h := logger.AddOutput(&extra)
+	logger.Info("both")
+	assert.NoError(t, logger.RemoveOutput(h))
+	logger.Info("main only")
+
+	assert.Contains(t, main.String(), "main only")
+	assert.Contains(t, extra.String(), "both")
+	assert.NotContains(t, extra.String(), "main only")
+	assert.ErrorIs(t, logger.RemoveOutput(h), ErrUnknownOutput)
+
+	// Files opened by the logger are closed on removal
+	path := filepath.Join(t.TempDir(), "debug", "debug.log")
+	fh, err := logger.AddFileOutput(path)
+	assert.NoError(t, err)
+	logger.Debug("not enabled")
+	logger.Warn("debugging")
+	assert.NoError(t, logger.RemoveOutput(fh))
+	logger.Warn("after removal")
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "debugging")
+	assert.NotContains(t, string(data), "after removal")
+	assert.NoError(t, logger.Close())
+}
+
+func TestGlobal_ScopedAndDPanic(t *testing.T) {
+	var buf bytes.Buffer
+	prev := Instance()
+	defer SetDefault(prev)
+	SetDefault(New(WithOutput(&buf)))
+
+	With(zap.String("component", "worker")).Info("started")
+	DPanic("unexpected state")
+
+	assert.Contains(t, buf.String(), `"component":"worker"`)
+	assert.Contains(t, buf.String(), `"level":"dpanic"`)
+
+	SetDefault(nil)
+	assert.NotPanics(t, func() { With(zap.String("k", "v")).Info("discarded") })
+}
+
+func TestGlobal_Fatal(t *testing.T) {
+	path := os.Getenv("LOGGER_FATAL_FILE")
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			os.Exit(2)
+		}
+		SetDefault(New(WithAsyncOutput(f, 10, time.Hour)))
+		Fatal("shutting down", zap.String("reason", "test"))
+		return
+	}
+
+	path = filepath.Join(t.TempDir(), "fatal.log")
+	cmd := exec.Command(os.Args[0], "-test.run=^TestGlobal_Fatal$")
+	cmd.Env = append(os.Environ(), "LOGGER_FATAL_FILE="+path)
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	assert.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 1, exitErr.ExitCode())
+
+	// The async output is flushed before exiting
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"msg":"shutting down"`)
+}
+
+func TestLogger_Suppression(t *testing.T) {
+	t.Run("dedup", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := New(WithOutput(&buf), WithDedup(time.Minute))
+		now := time.Now()
+		logger.suppressor.now = func() time.Time { return now }
+
+		for i := 0; i < 5; i++ {
+			logger.Error("db timeout", zap.Int("attempt", i))
+		}
+		logger.Error("other error")
+		logger.Warn("db timeout")
+
+		now = now.Add(time.Minute)
+		logger.Error("db timeout")
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Len(t, lines, 5)
+		assert.Contains(t, lines[0], `"attempt":0`)
+		assert.Contains(t, lines[1], `"msg":"other error"`)
+		assert.Contains(t, lines[2], `"level":"warn"`)
+		assert.Contains(t, lines[3], `"msg":"db timeout (repeated 4 times)"`)
+		assert.Contains(t, lines[3], `"repeated":4`)
+		assert.Contains(t, lines[4], `"msg":"db timeout"`)
+	})
+
+	t.Run("rate limit", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := New(WithOutput(&buf), WithRateLimit(3, time.Minute))
+
+		child := logger.With(zap.String("module", "queue"))
+		for i := 0; i < 10; i++ {
+			child.Error("job failed")
+		}
+		assert.Equal(t, 3, strings.Count(buf.String(), `"msg":"job failed"`))
+
+		// Pending drops are reported on Flush
+		assert.NoError(t, logger.Flush())
+		assert.Contains(t, buf.String(), `"msg":"job failed (repeated 7 times)"`)
+		assert.Contains(t, buf.String(), `"module":"queue","repeated":7`)
+	})
+}
+
+func TestLevelHandler(t *testing.T) {
+	prev := Instance()
+	defer SetDefault(prev)
+	SetDefault(New(WithLevel(InfoLevel)))
+
+	db := New(WithLevel(WarnLevel))
+	Register("db", db)
+	defer Unregister("db")
+
+	mux := http.NewServeMux()
+	MountLevelHandler(mux)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	do := func(method, path, contentType, body string) (int, string) {
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		assert.NoError(t, err)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		res, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer res.Body.Close()
+		data, _ := io.ReadAll(res.Body)
+		return res.StatusCode, strings.TrimSpace(string(data))
+	}
+
+	status, body := do(http.MethodGet, LevelHandlerPath, "", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"level":"info","loggers":{"db":"warn"}}`, body)
+
+	status, _ = do(http.MethodPut, LevelHandlerPath, "application/json", `{"level":"debug"}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, DebugLevel, Instance().GetLevel())
+
+	status, _ = do(http.MethodPut, LevelHandlerPath, "application/x-www-form-urlencoded", "logger=db&level=error")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, ErrorLevel, db.GetLevel())
+
+	status, body = do(http.MethodGet, LevelHandlerPath+"?logger=db", "", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"logger":"db","level":"error"}`, body)
+
+	status, _ = do(http.MethodPut, LevelHandlerPath, "application/json", `{"level":"loud"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = do(http.MethodPut, LevelHandlerPath, "application/json", `{"level":"info","logger":"cache"}`)
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = do(http.MethodDelete, LevelHandlerPath, "", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, status)
+}
+
+func TestFromEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	t.Setenv(EnvLevel, "warn")
+	t.Setenv(EnvFormat, "console")
+	t.Setenv(EnvFile, path)
+	t.Setenv(EnvSampling, "2,0")
+
+	logger := FromEnv()
+	assert.Equal(t, WarnLevel, logger.GetLevel())
+
+	logger.Info("below level")
+	for i := 0; i < 5; i++ {
+		logger.Warn("disk almost full")
+	}
+	assert.NoError(t, logger.Close())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "below level")
+	assert.Equal(t, 2, strings.Count(string(data), "disk almost full"))
+	// Console format is tab separated, not JSON
+	assert.Contains(t, string(data), "\twarn\tdisk almost full")
+
+	initial, thereafter, err := parseSampling("on")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{100, 100}, []int{initial, thereafter})
+	_, _, err = parseSampling("lots")
+	assert.Error(t, err)
+}
+
+func TestLogger_FilePattern(t *testing.T) {
+	dir := t.TempDir()
+	pattern := filepath.Join(dir, "app-%Y-%m-%d.log")
+
+	// Leftovers from earlier days
+	for _, day := range []string{"2024-03-01", "2024-03-02", "2024-03-03"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "app-"+day+".log"), []byte("old\n"), 0644))
+	}
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	assert.NoError(t, os.Chtimes(filepath.Join(dir, "app-2024-03-01.log"), old, old))
+
+	w := newPatternWriter(pattern, WithMaxAge(7*24*time.Hour), WithMaxFiles(2))
+	now := time.Date(2024, 3, 4, 23, 59, 0, 0, time.Local)
+	w.now = func() time.Time { return now }
+
+	logger := New(WithLevel(InfoLevel))
+	logger.AddOutput(w)
+
+	logger.Info("before midnight")
+	now = now.Add(2 * time.Minute)
+	logger.Info("after midnight")
+	assert.NoError(t, w.Close())
+
+	data, err := os.ReadFile(filepath.Join(dir, "app-2024-03-04.log"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "before midnight")
+	data, err = os.ReadFile(filepath.Join(dir, "app-2024-03-05.log"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "after midnight")
+
+	// Expired and surplus files are removed on rotation
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.log"))
+	assert.Equal(t, []string{
+		filepath.Join(dir, "app-2024-03-03.log"),
+		filepath.Join(dir, "app-2024-03-04.log"),
+		filepath.Join(dir, "app-2024-03-05.log"),
+	}, matches)
+
+	assert.Equal(t, "logs/100%-24.log", formatPattern("logs/100%%-%y.log", now))
+}
+
+func TestLogger_Metrics(t *testing.T) {
+	metrics := NewMetrics()
+	api := New(WithName("api"), WithMetrics(metrics), WithLevel(InfoLevel))
+	db := New(WithName("db"), WithMetrics(metrics), WithOutput(io.Discard))
+
+	api.Debug("not emitted")
+	api.Info("request")
+	api.With(zap.String("k", "v")).Error("failed")
+	db.Error("timeout")
+	db.Error("timeout")
+
+	assert.Equal(t, uint64(1), metrics.Count(InfoLevel, "api"))
+	assert.Equal(t, uint64(0), metrics.Count(DebugLevel, "api"))
+	assert.Equal(t, uint64(3), metrics.CountByLevel(ErrorLevel))
+
+	reg := prometheus.NewRegistry()
+	assert.NoError(t, metrics.Register(reg))
+	expected := `
+# HELP log_entries_total Number of log entries emitted, by level and logger.
+# TYPE log_entries_total counter
+log_entries_total{level="error",logger="api"} 1
+log_entries_total{level="info",logger="api"} 1
+log_entries_total{level="error",logger="db"} 2
+`
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), MetricName))
+}
+
+func TestLogger_WithFieldsMap(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(WithOutput(&buf))
+
+	fields := Fields{}.
+		Str("user", "alice").
+		Int("attempt", 3).
+		Bool("locked", false).
+		Dur("elapsed", 1500*time.Millisecond).
+		Err(fmt.Errorf("bad password")).
+		Err(nil)
+	logger.WithFields(fields).Warn("login failed")
+	logger.WithFields(map[string]any{"order": 42}).Info("placed")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"attempt":3,"elapsed":1.5,"error":"bad password","locked":false,"user":"alice"`)
+	assert.Contains(t, lines[1], `"order":42`)
+}
+
+func TestLogger_ErrorDetails(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(WithOutput(&buf), WithErrorDetails())
+
+	root := fmt.Errorf("connection refused")
+	err := fmt.Errorf("query users: %w", gerrors.Wrap(root, "load profile").WithCode("DB_DOWN").WithKind(gerrors.KindUnavailable))
+	logger.Error("request failed", zap.Error(err))
+
+	var entry map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "query users: load profile: connection refused", entry["error"])
+
+	details, ok := entry["error_details"].([]any)
+	assert.True(t, ok)
+	assert.Len(t, details, 3)
+
+	first := details[0].(map[string]any)
+	assert.Equal(t, "query users", first["message"])
+	assert.Equal(t, "*fmt.wrapError", first["type"])
+
+	second := details[1].(map[string]any)
+	assert.Equal(t, "load profile", second["message"])
+	assert.Equal(t, "DB_DOWN", second["code"])
+	assert.Equal(t, "unavailable", second["kind"])
+	stack, ok := second["stack"].([]any)
+	if assert.True(t, ok) && assert.NotEmpty(t, stack) {
+		frame := stack[0].(map[string]any)
+		assert.Equal(t, "github.com/ducconit/gocore/logger.TestLogger_ErrorDetails", frame["function"])
+		assert.Contains(t, frame["file"], "logger_test.go")
+		assert.NotZero(t, frame["line"])
+	}
+
+	assert.Equal(t, "connection refused", details[2].(map[string]any)["message"])
+
+	// Fields attached with With get details too, other entries are unchanged
+	buf.Reset()
+	logger.With(zap.Error(root)).Info("retrying")
+	assert.Contains(t, buf.String(), `"error_details":[{"message":"connection refused","type":"*errors.errorString"}]`)
+	buf.Reset()
+	logger.Info("plain")
+	assert.NotContains(t, buf.String(), "_details")
+}
+
+type flakyWriter struct {
+	bytes.Buffer
+	down bool
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	if w.down {
+		return 0, fmt.Errorf("connection reset")
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestLogger_Failover(t *testing.T) {
+	primary := &flakyWriter{}
+	var fallback bytes.Buffer
+
+	w := newFailoverWriter(primary, &fallback, time.Minute)
+	now := time.Now()
+	w.now = func() time.Time { return now }
+
+	logger := New()
+	logger.AddOutput(w)
+
+	logger.Info("first")
+	primary.down = true
+	logger.Info("second")
+	primary.down = false
+	logger.Info("third") // primary is not probed before the interval
+
+	now = now.Add(time.Minute)
+	logger.Info("fourth")
+	logger.Info("fifth")
+
+	assert.Contains(t, primary.String(), "first")
+	assert.Contains(t, fallback.String(), "second")
+	assert.Contains(t, fallback.String(), "third")
+	assert.Contains(t, primary.String(), "fourth")
+	assert.Contains(t, primary.String(), "fifth")
+	assert.NotContains(t, fallback.String(), "first")
+	assert.NotContains(t, fallback.String(), "fourth")
+}
+
+func TestLogger_ForService(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(WithOutput(&buf), WithVersion("1.4.0"))
+
+	logger.ForService("http").Info("listening")
+	assert.Contains(t, buf.String(), `"service":"http","instance_id":"`+InstanceID+`","version":"1.4.0"`)
+
+	// Derived loggers keep the version
+	buf.Reset()
+	logger.With(zap.String("region", "eu")).ForService("worker").Info("started")
+	assert.Contains(t, buf.String(), `"version":"1.4.0"`)
+}
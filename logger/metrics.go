@@ -0,0 +1,149 @@
+package logger
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap/zapcore"
+)
+
+// MetricName is the name of the Prometheus counter exported by Metrics
+const MetricName = "log_entries_total"
+
+// WithMetrics counts the entries emitted by the logger in m, by level and
+// logger name (see WithName). A Metrics may be shared by several loggers.
+func WithMetrics(m *Metrics) Option {
+	return func(l *Logger) {
+		l.metrics = m
+	}
+}
+
+type metricKey struct {
+	level zapcore.Level
+	name  string
+}
+
+// Metrics counts emitted log entries. It is a prometheus.Collector, so it can
+// be registered to alert on error-rate spikes.
+type Metrics struct {
+	mu     sync.RWMutex
+	counts map[metricKey]*atomic.Uint64
+	desc   *prometheus.Desc
+}
+
+var _ prometheus.Collector = (*Metrics)(nil)
+
+// NewMetrics creates a new, empty set of counters
+func NewMetrics() *Metrics {
+	return &Metrics{
+		counts: make(map[metricKey]*atomic.Uint64),
+		desc: prometheus.NewDesc(MetricName,
+			"Number of log entries emitted, by level and logger.",
+			[]string{"level", "logger"}, nil),
+	}
+}
+
+func (m *Metrics) inc(level zapcore.Level, name string) {
+	key := metricKey{level: level, name: name}
+
+	m.mu.RLock()
+	counter, ok := m.counts[key]
+	m.mu.RUnlock()
+
+	if !ok {
+		m.mu.Lock()
+		if counter, ok = m.counts[key]; !ok {
+			counter = new(atomic.Uint64)
+			m.counts[key] = counter
+		}
+		m.mu.Unlock()
+	}
+	counter.Add(1)
+}
+
+// Count returns the entries emitted at level by the logger named name
+func (m *Metrics) Count(level Level, name string) uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if counter, ok := m.counts[metricKey{level: level, name: name}]; ok {
+		return counter.Load()
+	}
+	return 0
+}
+
+// CountByLevel returns the entries emitted at level by every logger
+func (m *Metrics) CountByLevel(level Level) uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var total uint64
+	for key, counter := range m.counts {
+		if key.level == level {
+			total += counter.Load()
+		}
+	}
+	return total
+}
+
+// Register registers the counters with reg, e.g. prometheus.DefaultRegisterer
+func (m *Metrics) Register(reg prometheus.Registerer) error {
+	return reg.Register(m)
+}
+
+// Describe implements prometheus.Collector
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.desc
+}
+
+// Collect implements prometheus.Collector
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.mu.RLock()
+	keys := make([]metricKey, 0, len(m.counts))
+	for key := range m.counts {
+		keys = append(keys, key)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name != keys[j].name {
+			return keys[i].name < keys[j].name
+		}
+		return keys[i].level < keys[j].level
+	})
+	for _, key := range keys {
+		ch <- prometheus.MustNewConstMetric(m.desc, prometheus.CounterValue,
+			float64(m.Count(key.level, key.name)), key.level.String(), key.name)
+	}
+}
+
+// metricsCore counts the entries enabled by the logger level
+type metricsCore struct {
+	level   zapcore.LevelEnabler
+	metrics *Metrics
+}
+
+func (c *metricsCore) Enabled(level zapcore.Level) bool {
+	return c.level.Enabled(level)
+}
+
+func (c *metricsCore) With([]zapcore.Field) zapcore.Core {
+	return c
+}
+
+func (c *metricsCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return ce.AddCore(entry, c)
+	}
+	return ce
+}
+
+func (c *metricsCore) Write(entry zapcore.Entry, _ []zapcore.Field) error {
+	c.metrics.inc(entry.Level, entry.LoggerName)
+	return nil
+}
+
+func (c *metricsCore) Sync() error {
+	return nil
+}
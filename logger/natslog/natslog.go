@@ -0,0 +1,52 @@
+// Package natslog ships gocore log entries to a NATS subject
+//
+//	nc, _ := nats.Connect(nats.DefaultURL)
+//	log := logger.New(
+//		logger.WithConsole(),
+//		logger.WithShipper(natslog.New(nc, "logs.app")),
+//	)
+//	defer log.Close()
+package natslog
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/nats-io/nats.go"
+)
+
+// Conn publishes NATS messages, it is implemented by *nats.Conn
+type Conn interface {
+	Publish(subject string, data []byte) error
+	FlushWithContext(ctx context.Context) error
+}
+
+var _ Conn = (*nats.Conn)(nil)
+
+// Publisher publishes log entries as NATS messages
+type Publisher struct {
+	conn    Conn
+	subject string
+}
+
+var _ logger.Publisher = (*Publisher)(nil)
+
+// New returns a publisher sending entries to subject
+func New(conn Conn, subject string) *Publisher {
+	return &Publisher{conn: conn, subject: subject}
+}
+
+// Publish sends entries as one message each and waits for the server to
+// acknowledge them, so a slow server pushes back on the logger
+func (p *Publisher) Publish(ctx context.Context, entries [][]byte) error {
+	for _, entry := range entries {
+		if err := p.conn.Publish(p.subject, entry); err != nil {
+			return fmt.Errorf("failed to publish nats message: %w", err)
+		}
+	}
+	if err := p.conn.FlushWithContext(ctx); err != nil {
+		return fmt.Errorf("failed to flush nats connection: %w", err)
+	}
+	return nil
+}
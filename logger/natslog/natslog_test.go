@@ -0,0 +1,49 @@
+package natslog
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeConn struct {
+	subjects []string
+	data     []string
+	flushes  int
+	err      error
+}
+
+func (c *fakeConn) Publish(subject string, data []byte) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.subjects = append(c.subjects, subject)
+	c.data = append(c.data, string(data))
+	return nil
+}
+
+func (c *fakeConn) FlushWithContext(ctx context.Context) error {
+	c.flushes++
+	return nil
+}
+
+func TestPublisher(t *testing.T) {
+	conn := &fakeConn{}
+	l := logger.New(logger.WithShipper(New(conn, "logs.app")))
+
+	l.Info("first")
+	l.Warn("second")
+	assert.NoError(t, l.Close())
+
+	assert.Equal(t, []string{"logs.app", "logs.app"}, conn.subjects)
+	assert.Contains(t, conn.data[0], `"msg":"first"`)
+	assert.Contains(t, conn.data[1], `"msg":"second"`)
+	assert.Equal(t, 1, conn.flushes)
+
+	conn.err = errors.New("disconnected")
+	err := New(conn, "logs.app").Publish(context.Background(), [][]byte{[]byte("{}")})
+	assert.ErrorIs(t, err, conn.err)
+}
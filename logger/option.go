@@ -5,6 +5,8 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 // Option represents a logger option
@@ -13,21 +15,62 @@ type Option func(*Logger)
 // WithLevel sets the minimum log level
 func WithLevel(level Level) Option {
 	return func(l *Logger) {
-		l.level = level
+		l.level.SetLevel(level)
 	}
 }
 
 // WithLevelString sets the minimum log level using a string
 func WithLevelString(levelStr string) Option {
 	return func(l *Logger) {
-		l.level = ParseLevel(levelStr)
+		l.level.SetLevel(ParseLevel(levelStr))
+	}
+}
+
+// WithTimeFormat sets how timestamps are encoded: a time layout such as
+// time.RFC3339 or one of the TimeFormat presets
+func WithTimeFormat(format string) Option {
+	return func(l *Logger) {
+		l.timeFormat = format
+	}
+}
+
+// WithName names the logger, the name is logged in the "logger" field and
+// labels the entries counted by WithMetrics
+func WithName(name string) Option {
+	return func(l *Logger) {
+		l.name = name
+	}
+}
+
+// WithFormat sets the encoding of entries, JSONFormat by default
+func WithFormat(format Format) Option {
+	return func(l *Logger) {
+		l.format = format
+	}
+}
+
+// samplingConfig holds the WithSampling settings
+type samplingConfig struct {
+	initial    int
+	thereafter int
+}
+
+// WithSampling caps the entries logged per second for each message: the
+// first initial ones are logged, then every thereafter-th one (none if zero)
+func WithSampling(initial, thereafter int) Option {
+	return func(l *Logger) {
+		if initial <= 0 {
+			l.sampling = nil
+			return
+		}
+		l.sampling = &samplingConfig{initial: initial, thereafter: thereafter}
 	}
 }
 
 // WithConsole adds console output
 func WithConsole() Option {
 	return func(l *Logger) {
-		l.outputs = append(l.outputs, os.Stdout)
+		l.addOutput(os.Stdout, nil)
 	}
 }
 
@@ -48,14 +91,32 @@ func WithFile(filename string) Option {
 			return
 		}
 
-		l.outputs = append(l.outputs, f)
+		l.addOutput(f, f)
+	}
+}
+
+// WithRotatingFile adds file output rotated once it reaches maxSizeMB
+// megabytes. At most maxBackups rotated files are kept, for at most maxAgeDays
+// days (zero keeps them all), and rotated files are gzipped if compress is set.
+func WithRotatingFile(path string, maxSizeMB, maxBackups, maxAgeDays int, compress bool) Option {
+	return func(l *Logger) {
+		w := &lumberjack.Logger{
+			Filename:   path,
+			MaxSize:    maxSizeMB,
+			MaxBackups: maxBackups,
+			MaxAge:     maxAgeDays,
+			Compress:   compress,
+			LocalTime:  true,
+		}
+
+		l.addOutput(w, w)
 	}
 }
 
 // WithOutput adds a custom output writer
 func WithOutput(w io.Writer) Option {
 	return func(l *Logger) {
-		l.outputs = append(l.outputs, w)
+		l.addOutput(w, nil)
 	}
 }
 
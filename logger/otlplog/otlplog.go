@@ -0,0 +1,125 @@
+// Package otlplog exports gocore log entries to an OpenTelemetry collector
+// over OTLP/HTTP
+//
+//	core, err := otlplog.New("http://otel-collector:4318",
+//		otlplog.WithResource("checkout", "1.4.0", "production"),
+//	)
+//	log := logger.New(logger.WithConsole(), logger.WithCore(core))
+//	defer log.Close()
+package otlplog
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/contrib/bridges/otelzap"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.uber.org/zap/zapcore"
+)
+
+// Option configures a Core
+type Option func(*options)
+
+type options struct {
+	resource []attribute.KeyValue
+	exporter []otlploghttp.Option
+}
+
+// WithResource sets the attributes describing the service, exported with every record
+func WithResource(serviceName, version, env string, attrs ...attribute.KeyValue) Option {
+	return func(o *options) {
+		o.resource = append([]attribute.KeyValue{
+			semconv.ServiceName(serviceName),
+			semconv.ServiceVersion(version),
+			semconv.DeploymentEnvironment(env),
+		}, attrs...)
+	}
+}
+
+// WithExporterOptions passes options to the OTLP/HTTP exporter, e.g. headers or TLS
+func WithExporterOptions(opts ...otlploghttp.Option) Option {
+	return func(o *options) {
+		o.exporter = append(o.exporter, opts...)
+	}
+}
+
+// Core is a zapcore.Core exporting entries as OpenTelemetry log records,
+// see logger.WithCore. Records are batched: Sync flushes them and Close
+// shuts the exporter down.
+type Core struct {
+	zapcore.Core
+	provider *sdklog.LoggerProvider
+}
+
+// New returns a core exporting to endpoint, a URL such as
+// "http://collector:4318" (the path defaults to /v1/logs) or a host:port
+// using https. When endpoint is empty the OTEL_EXPORTER_OTLP_* environment
+// variables apply.
+func New(endpoint string, opts ...Option) (*Core, error) {
+	o := &options{}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	exporterOpts := o.exporter
+	if endpoint != "" {
+		if strings.Contains(endpoint, "://") {
+			if u, err := url.Parse(endpoint); err == nil && strings.Trim(u.Path, "/") == "" {
+				endpoint = strings.TrimSuffix(endpoint, "/") + "/v1/logs"
+			}
+			exporterOpts = append([]otlploghttp.Option{otlploghttp.WithEndpointURL(endpoint)}, exporterOpts...)
+		} else {
+			exporterOpts = append([]otlploghttp.Option{otlploghttp.WithEndpoint(endpoint)}, exporterOpts...)
+		}
+	}
+
+	exporter, err := otlploghttp.New(context.Background(), exporterOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create otlp exporter: %w", err)
+	}
+	return NewCore(exporter, opts...), nil
+}
+
+// NewCore returns a core exporting through exporter, e.g. an OTLP/gRPC
+// exporter. Only WithResource applies.
+func NewCore(exporter sdklog.Exporter, opts ...Option) *Core {
+	o := &options{}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(o.resource...))
+	if err != nil {
+		res = resource.NewSchemaless(o.resource...)
+	}
+	provider := sdklog.NewLoggerProvider(
+		sdklog.WithResource(res),
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter)),
+	)
+	return &Core{
+		Core:     otelzap.NewCore("github.com/ducconit/gocore/logger", otelzap.WithLoggerProvider(provider)),
+		provider: provider,
+	}
+}
+
+var _ zapcore.Core = (*Core)(nil)
+
+// Sync exports the batched records
+func (c *Core) Sync() error {
+	return c.provider.ForceFlush(context.Background())
+}
+
+// Close exports the batched records and shuts the exporter down
+func (c *Core) Close() error {
+	return c.provider.Shutdown(context.Background())
+}
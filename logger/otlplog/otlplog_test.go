@@ -0,0 +1,48 @@
+package otlplog
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestCore(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		bodies [][]byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, body)
+		mu.Unlock()
+		assert.Equal(t, "/v1/logs", r.URL.Path)
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	core, err := New(server.URL, WithResource("checkout", "1.2.3", "staging"))
+	assert.NoError(t, err)
+	log := logger.New(logger.WithLevel(logger.InfoLevel), logger.WithCore(core))
+	log.Debug("dropped by level")
+	log.With(zap.String("order", "42")).Info("order placed")
+	assert.NoError(t, log.Flush())
+	assert.NoError(t, log.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	all := string(bytes.Join(bodies, nil))
+	assert.Contains(t, all, "order placed")
+	assert.Contains(t, all, "checkout")
+	assert.Contains(t, all, "1.2.3")
+	assert.Contains(t, all, "staging")
+	assert.NotContains(t, all, "dropped by level")
+}
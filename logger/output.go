@@ -0,0 +1,109 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ErrUnknownOutput is returned by RemoveOutput for a handle that is not attached
+var ErrUnknownOutput = errors.New("logger: unknown output")
+
+// OutputHandle identifies an output, see AddOutput and RemoveOutput
+type OutputHandle uint64
+
+// output is a writer attached to the logger. closer is set when the logger
+// opened the writer and must close it.
+type output struct {
+	handle OutputHandle
+	w      io.Writer
+	closer io.Closer
+}
+
+// addOutput attaches w, the caller holds the lock or owns the logger
+func (l *Logger) addOutput(w io.Writer, closer io.Closer) OutputHandle {
+	l.lastHandle++
+	l.outputs = append(l.outputs, output{handle: l.lastHandle, w: w, closer: closer})
+	return l.lastHandle
+}
+
+// AddOutput adds a new output writer. The returned handle detaches it with RemoveOutput.
+func (l *Logger) AddOutput(w io.Writer) OutputHandle {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h := l.addOutput(w, nil)
+
+	// Update zap logger with new output
+	l.build()
+	return h
+}
+
+// AddFileOutput adds a file output, e.g. a temporary debug file. The file is
+// closed by RemoveOutput, ClearOutputs or Close.
+func (l *Logger) AddFileOutput(filename string) (OutputHandle, error) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h := l.addOutput(f, f)
+	l.build()
+	return h, nil
+}
+
+// RemoveOutput detaches an output and closes it if the logger opened it.
+// Loggers derived with With before the call keep writing to it.
+func (l *Logger) RemoveOutput(h OutputHandle) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i, o := range l.outputs {
+		if o.handle != h {
+			continue
+		}
+
+		// Copy so loggers derived with With keep their outputs
+		outputs := make([]output, 0, len(l.outputs)-1)
+		outputs = append(outputs, l.outputs[:i]...)
+		l.outputs = append(outputs, l.outputs[i+1:]...)
+		l.build()
+
+		if o.closer != nil {
+			return o.closer.Close()
+		}
+		return nil
+	}
+	return ErrUnknownOutput
+}
+
+// ClearOutputs removes all output writers and closes the ones the logger opened
+func (l *Logger) ClearOutputs() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_ = closeOutputs(l.outputs)
+	l.outputs = make([]output, 0)
+
+	// Reset zap logger, hooks are kept
+	l.build()
+}
+
+// closeOutputs closes the outputs the logger opened
+func closeOutputs(outputs []output) error {
+	var errs []error
+	for _, o := range outputs {
+		if o.closer == nil {
+			continue
+		}
+		if err := o.closer.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
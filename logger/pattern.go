@@ -0,0 +1,203 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PatternOption configures WithFilePattern
+type PatternOption func(*patternWriter)
+
+// WithMaxAge removes rotated files last written more than d ago
+func WithMaxAge(d time.Duration) PatternOption {
+	return func(w *patternWriter) {
+		w.maxAge = d
+	}
+}
+
+// WithMaxFiles keeps at most n rotated files besides the current one
+func WithMaxFiles(n int) PatternOption {
+	return func(w *patternWriter) {
+		w.maxFiles = n
+	}
+}
+
+// WithFilePattern adds file output rotated by time. The file name is pattern
+// with strftime-like verbs replaced by the current local time, so
+// "logs/app-%Y-%m-%d.log" gets a new file per day and "%Y%m%d-%H" per hour.
+// Supported verbs are %Y, %y, %m, %d, %H, %M and %%.
+func WithFilePattern(pattern string, opts ...PatternOption) Option {
+	return func(l *Logger) {
+		w := newPatternWriter(pattern, opts...)
+		l.addOutput(w, w)
+	}
+}
+
+// patternWriter writes to the file named by the pattern for the current time
+type patternWriter struct {
+	pattern  string
+	glob     string
+	maxAge   time.Duration
+	maxFiles int
+	now      func() time.Time
+
+	mu   sync.Mutex
+	name string
+	file *os.File
+}
+
+func newPatternWriter(pattern string, opts ...PatternOption) *patternWriter {
+	w := &patternWriter{
+		pattern: pattern,
+		glob:    patternGlob(pattern),
+		now:     time.Now,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+func (w *patternWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	name := formatPattern(w.pattern, w.now())
+	if w.file == nil || name != w.name {
+		if err := w.open(name); err != nil {
+			return 0, err
+		}
+	}
+	return w.file.Write(p)
+}
+
+// open switches to the file name, the caller holds the lock
+func (w *patternWriter) open(name string) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	if w.file != nil {
+		_ = w.file.Close()
+	}
+	w.file = f
+	w.name = name
+
+	if w.maxAge > 0 || w.maxFiles > 0 {
+		w.cleanup(name)
+	}
+	return nil
+}
+
+// cleanup removes rotated files beyond the retention, keeping current. It
+// runs once per rotation, the caller holds the lock.
+func (w *patternWriter) cleanup(current string) {
+	matches, err := filepath.Glob(w.glob)
+	if err != nil {
+		return
+	}
+
+	// Names sort chronologically for the usual year-first patterns
+	sort.Sort(sort.Reverse(sort.StringSlice(matches)))
+
+	kept := 0
+	for _, name := range matches {
+		if name == current {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		expired := w.maxAge > 0 && w.now().Sub(info.ModTime()) > w.maxAge
+		if !expired && (w.maxFiles <= 0 || kept < w.maxFiles) {
+			kept++
+			continue
+		}
+		_ = os.Remove(name)
+	}
+}
+
+func (w *patternWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+func (w *patternWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// formatPattern replaces the pattern verbs with t
+func formatPattern(pattern string, t time.Time) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' || i+1 == len(pattern) {
+			b.WriteByte(pattern[i])
+			continue
+		}
+		i++
+		switch pattern[i] {
+		case 'Y':
+			fmt.Fprintf(&b, "%04d", t.Year())
+		case 'y':
+			fmt.Fprintf(&b, "%02d", t.Year()%100)
+		case 'm':
+			fmt.Fprintf(&b, "%02d", int(t.Month()))
+		case 'd':
+			fmt.Fprintf(&b, "%02d", t.Day())
+		case 'H':
+			fmt.Fprintf(&b, "%02d", t.Hour())
+		case 'M':
+			fmt.Fprintf(&b, "%02d", t.Minute())
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(pattern[i])
+		}
+	}
+	return b.String()
+}
+
+// patternGlob returns a glob matching every file of the pattern
+func patternGlob(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' || i+1 == len(pattern) {
+			b.WriteByte(pattern[i])
+			continue
+		}
+		i++
+		switch pattern[i] {
+		case 'Y', 'y', 'm', 'd', 'H', 'M':
+			b.WriteByte('*')
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(pattern[i])
+		}
+	}
+	return b.String()
+}
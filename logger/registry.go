@@ -0,0 +1,47 @@
+package logger
+
+import (
+	"sort"
+	"sync"
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]*Logger)
+)
+
+// Register makes l available by name, e.g. to LevelHandler. Registering a
+// name again replaces the logger.
+func Register(name string, l *Logger) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = l
+}
+
+// Unregister removes a named logger
+func Unregister(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(registry, name)
+}
+
+// Get returns a named logger
+func Get(name string) (*Logger, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	l, ok := registry[name]
+	return l, ok
+}
+
+// Names returns the names of the registered loggers, sorted
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+
+	"go.uber.org/zap"
+)
+
+// Field names added by ForService
+const (
+	ServiceKey    = "service"
+	InstanceIDKey = "instance_id"
+	VersionKey    = "version"
+)
+
+// InstanceID identifies this process in ForService loggers, "<hostname>-<pid>" by default
+var InstanceID = defaultInstanceID()
+
+func defaultInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// WithVersion sets the version logged by ForService loggers. It defaults to
+// the main module version.
+func WithVersion(version string) Option {
+	return func(l *Logger) {
+		l.version = version
+	}
+}
+
+// ForService returns a child logger for a service, every entry carries the
+// service name, InstanceID and version
+func (l *Logger) ForService(name string) *Logger {
+	return l.With(
+		zap.String(ServiceKey, name),
+		zap.String(InstanceIDKey, InstanceID),
+		zap.String(VersionKey, l.serviceVersion()),
+	)
+}
+
+func (l *Logger) serviceVersion() string {
+	if l.version != "" {
+		return l.version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "unknown"
+}
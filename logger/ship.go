@@ -0,0 +1,253 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults for NewShipWriter
+const (
+	DefaultShipBatchSize    = 100
+	DefaultShipBatchTimeout = time.Second
+	DefaultShipBufferSize   = 10000
+	DefaultShipMaxRetries   = 3
+	DefaultShipTimeout      = 10 * time.Second
+)
+
+// Publisher sends a batch of JSON encoded entries to a log pipeline, see the
+// kafkalog and natslog packages
+type Publisher interface {
+	Publish(ctx context.Context, entries [][]byte) error
+}
+
+// ShipOption configures a ShipWriter
+type ShipOption func(*ShipWriter)
+
+// WithBatchSize sets how many entries are published together
+func WithBatchSize(n int) ShipOption {
+	return func(w *ShipWriter) {
+		if n > 0 {
+			w.batchSize = n
+		}
+	}
+}
+
+// WithBatchTimeout sets how long a partial batch waits before it is published
+func WithBatchTimeout(d time.Duration) ShipOption {
+	return func(w *ShipWriter) {
+		if d > 0 {
+			w.batchTimeout = d
+		}
+	}
+}
+
+// WithBufferSize sets how many entries are queued while the publisher is busy
+func WithBufferSize(n int) ShipOption {
+	return func(w *ShipWriter) {
+		if n > 0 {
+			w.bufferSize = n
+		}
+	}
+}
+
+// WithMaxRetries sets how often a failed batch is retried before it is dropped
+func WithMaxRetries(n int) ShipOption {
+	return func(w *ShipWriter) {
+		if n >= 0 {
+			w.maxRetries = n
+		}
+	}
+}
+
+// WithDropWhenFull drops entries instead of blocking the caller when the
+// buffer is full, see ShipWriter.Dropped
+func WithDropWhenFull() ShipOption {
+	return func(w *ShipWriter) {
+		w.dropWhenFull = true
+	}
+}
+
+// WithShipper adds an output publishing entries in batches, see NewShipWriter
+func WithShipper(p Publisher, opts ...ShipOption) Option {
+	return func(l *Logger) {
+		w := NewShipWriter(p, opts...)
+		l.addOutput(w, w)
+	}
+}
+
+// ShipWriter is an io.Writer publishing entries in batches from a background
+// goroutine. When the buffer is full, writes block until there is room
+// (backpressure) or, with WithDropWhenFull, the entry is dropped.
+type ShipWriter struct {
+	publisher    Publisher
+	batchSize    int
+	batchTimeout time.Duration
+	bufferSize   int
+	maxRetries   int
+	dropWhenFull bool
+
+	entries chan []byte
+	flushes chan chan error
+	done    chan struct{}
+	stopped chan struct{}
+	dropped atomic.Uint64
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewShipWriter starts a writer publishing to p
+func NewShipWriter(p Publisher, opts ...ShipOption) *ShipWriter {
+	w := &ShipWriter{
+		publisher:    p,
+		batchSize:    DefaultShipBatchSize,
+		batchTimeout: DefaultShipBatchTimeout,
+		bufferSize:   DefaultShipBufferSize,
+		maxRetries:   DefaultShipMaxRetries,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	w.entries = make(chan []byte, w.bufferSize)
+	w.flushes = make(chan chan error)
+	w.done = make(chan struct{})
+	w.stopped = make(chan struct{})
+	go w.run()
+	return w
+}
+
+// Write queues a copy of p, the caller may reuse p
+func (w *ShipWriter) Write(p []byte) (int, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		return 0, ErrWriterClosed
+	}
+
+	entry := make([]byte, len(p))
+	copy(entry, p)
+
+	if w.dropWhenFull {
+		select {
+		case w.entries <- entry:
+		default:
+			w.dropped.Add(1)
+		}
+		return len(p), nil
+	}
+
+	w.entries <- entry
+	return len(p), nil
+}
+
+// Dropped returns the number of entries dropped because the buffer was full
+// or publishing failed
+func (w *ShipWriter) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+// Sync publishes every queued entry
+func (w *ShipWriter) Sync() error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		return nil
+	}
+
+	reply := make(chan error, 1)
+	w.flushes <- reply
+	return <-reply
+}
+
+// Close publishes every queued entry and stops the background goroutine.
+// The publisher is not closed.
+func (w *ShipWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+
+	close(w.done)
+	<-w.stopped
+	return nil
+}
+
+func (w *ShipWriter) run() {
+	defer close(w.stopped)
+
+	batch := make([][]byte, 0, w.batchSize)
+	timer := time.NewTimer(w.batchTimeout)
+	defer timer.Stop()
+
+	publish := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := w.publish(batch)
+		batch = make([][]byte, 0, w.batchSize)
+		return err
+	}
+	drain := func() error {
+		for {
+			select {
+			case entry := <-w.entries:
+				batch = append(batch, entry)
+				if len(batch) >= w.batchSize {
+					_ = publish()
+				}
+			default:
+				return publish()
+			}
+		}
+	}
+
+	for {
+		select {
+		case entry := <-w.entries:
+			batch = append(batch, entry)
+			if len(batch) >= w.batchSize {
+				_ = publish()
+				timer.Reset(w.batchTimeout)
+			}
+		case <-timer.C:
+			_ = publish()
+			timer.Reset(w.batchTimeout)
+		case reply := <-w.flushes:
+			reply <- drain()
+		case <-w.done:
+			_ = drain()
+			return
+		}
+	}
+}
+
+// publish sends a batch, retrying with a linear backoff. Each attempt is
+// bounded by DefaultShipTimeout.
+func (w *ShipWriter) publish(batch [][]byte) error {
+	var err error
+	for attempt := 0; attempt <= w.maxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultShipTimeout)
+		err = w.publisher.Publish(ctx, batch)
+		cancel()
+		if err == nil {
+			return nil
+		}
+	}
+
+	w.dropped.Add(uint64(len(batch)))
+	fmt.Fprintf(os.Stderr, "logger: failed to publish %d entries: %v\n", len(batch), err)
+	return fmt.Errorf("failed to publish log entries: %w", err)
+}
@@ -0,0 +1,52 @@
+package logger
+
+import (
+	"bytes"
+	"io"
+	"log"
+)
+
+// levelWriter logs every line written to it
+type levelWriter struct {
+	logger *Logger
+	level  Level
+}
+
+// Writer returns an io.Writer that logs each line written to it at level,
+// for libraries that only accept a writer
+func (l *Logger) Writer(level Level) io.Writer {
+	return &levelWriter{logger: l, level: level}
+}
+
+func (w *levelWriter) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\r\n"), []byte("\n")) {
+		line = bytes.TrimRight(line, "\r")
+		if len(line) > 0 {
+			w.logger.Log(w.level, string(line))
+		}
+	}
+	return len(p), nil
+}
+
+// RedirectStdLog sends the output of the standard library log package to l at
+// InfoLevel and returns a function restoring the previous output
+func RedirectStdLog(l *Logger) func() {
+	return RedirectStdLogAt(l, InfoLevel)
+}
+
+// RedirectStdLogAt sends the output of the standard library log package to l
+// at level and returns a function restoring the previous output
+func RedirectStdLogAt(l *Logger, level Level) func() {
+	flags, prefix, output := log.Flags(), log.Prefix(), log.Writer()
+
+	// Timestamps are added by the logger
+	log.SetFlags(0)
+	log.SetPrefix("")
+	log.SetOutput(l.Writer(level))
+
+	return func() {
+		log.SetFlags(flags)
+		log.SetPrefix(prefix)
+		log.SetOutput(output)
+	}
+}
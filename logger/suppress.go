@@ -0,0 +1,166 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// maxSuppressKeys bounds the tracked messages, expired ones are pruned beyond it
+const maxSuppressKeys = 1024
+
+// WithDedup drops repeats of a message (same level and text) logged within
+// window of its first occurrence. Once the window has passed, a single
+// "<msg> (repeated N times)" entry reports how many were dropped.
+func WithDedup(window time.Duration) Option {
+	return WithRateLimit(1, window)
+}
+
+// WithRateLimit lets through at most burst entries per message (same level
+// and text) in each window, to stop error storms from filling disks. Dropped
+// entries are reported like WithDedup.
+func WithRateLimit(burst int, window time.Duration) Option {
+	return func(l *Logger) {
+		if burst <= 0 || window <= 0 {
+			return
+		}
+		l.suppressor = &suppressor{
+			burst:   burst,
+			window:  window,
+			buckets: make(map[suppressKey]*suppressBucket),
+			now:     time.Now,
+		}
+	}
+}
+
+type suppressKey struct {
+	level zapcore.Level
+	msg   string
+}
+
+// suppressBucket counts the entries of one message in the current window
+type suppressBucket struct {
+	start      time.Time
+	count      int
+	suppressed int
+	core       zapcore.Core
+	entry      zapcore.Entry
+}
+
+// suppressor tracks messages for every core derived from the logger
+type suppressor struct {
+	burst  int
+	window time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	buckets map[suppressKey]*suppressBucket
+}
+
+// allow reports whether entry may be written, writing the summary of the
+// previous window if it had drops
+func (s *suppressor) allow(core zapcore.Core, entry zapcore.Entry) bool {
+	now := s.now()
+	key := suppressKey{level: entry.Level, msg: entry.Message}
+
+	var summaries []*suppressBucket
+
+	s.mu.Lock()
+	b := s.buckets[key]
+	if b != nil && now.Sub(b.start) >= s.window {
+		if b.suppressed > 0 {
+			summaries = append(summaries, b)
+		}
+		b = nil
+	}
+	if b == nil {
+		if len(s.buckets) >= maxSuppressKeys {
+			summaries = append(summaries, s.prune(now)...)
+		}
+		b = &suppressBucket{start: now}
+		s.buckets[key] = b
+	}
+
+	b.count++
+	allowed := b.count <= s.burst
+	if !allowed {
+		b.suppressed++
+		b.core = core
+		b.entry = entry
+	}
+	s.mu.Unlock()
+
+	for _, summary := range summaries {
+		writeSummary(summary, now)
+	}
+	return allowed
+}
+
+// prune removes expired buckets and returns the ones with drops, the caller holds the lock
+func (s *suppressor) prune(now time.Time) []*suppressBucket {
+	var summaries []*suppressBucket
+	for key, b := range s.buckets {
+		if now.Sub(b.start) < s.window {
+			continue
+		}
+		if b.suppressed > 0 {
+			summaries = append(summaries, b)
+		}
+		delete(s.buckets, key)
+	}
+	return summaries
+}
+
+// flush writes the summaries of the drops so far, e.g. before the logger is closed
+func (s *suppressor) flush() {
+	now := s.now()
+
+	var summaries []*suppressBucket
+
+	s.mu.Lock()
+	for _, b := range s.buckets {
+		if b.suppressed > 0 {
+			summary := *b
+			summaries = append(summaries, &summary)
+			b.suppressed = 0
+		}
+	}
+	s.mu.Unlock()
+
+	for _, summary := range summaries {
+		writeSummary(summary, now)
+	}
+}
+
+func writeSummary(b *suppressBucket, now time.Time) {
+	entry := b.entry
+	entry.Time = now
+	entry.Message = fmt.Sprintf("%s (repeated %d times)", entry.Message, b.suppressed)
+	entry.Stack = ""
+	b.core.Check(entry, nil).Write(zap.Int("repeated", b.suppressed))
+}
+
+// suppressCore drops the entries the suppressor does not allow
+type suppressCore struct {
+	zapcore.Core
+	s *suppressor
+}
+
+func (c *suppressCore) With(fields []zapcore.Field) zapcore.Core {
+	return &suppressCore{Core: c.Core.With(fields), s: c.s}
+}
+
+func (c *suppressCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Core.Enabled(entry.Level) || !c.s.allow(c.Core, entry) {
+		return ce
+	}
+	return c.Core.Check(entry, ce)
+}
+
+func (c *suppressCore) Sync() error {
+	c.s.flush()
+	return c.Core.Sync()
+}
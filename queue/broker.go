@@ -0,0 +1,351 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrBrokerClosed is returned when using a closed broker
+var ErrBrokerClosed = errors.New("broker is closed")
+
+// Broker publishes messages to topics. Every subscriber of a topic receives
+// every message published after it subscribed (fan-out).
+type Broker interface {
+	// Publish sends a message to the subscribers of topic
+	Publish(ctx context.Context, topic string, msg *Message) error
+
+	// Subscribe returns a consumer receiving the messages of topic once started
+	Subscribe(topic string) (Consumer, error)
+
+	// Close stops every subscription and releases the broker
+	Close() error
+}
+
+// subscription is a Consumer fed through a channel, shared by the brokers.
+// Failed handlers are retried RetryCount times, RetryDelay apart.
+type subscription struct {
+	topic    string
+	opts     *Options
+	messages chan *Message
+	onStart  func(ctx context.Context) error
+	onStop   func() error
+
+	mu           sync.Mutex
+	handler      func(ctx context.Context, msg *Message) error
+	batchHandler func(ctx context.Context, msgs []*Message) error
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
+	pauser       pauser
+}
+
+func newSubscription(topic string, opts *Options) *subscription {
+	size := opts.MaxSize
+	if size <= 0 {
+		size = 1
+	}
+	return &subscription{
+		topic:    topic,
+		opts:     opts,
+		messages: make(chan *Message, size),
+	}
+}
+
+// OnMessage sets the message handler
+func (s *subscription) OnMessage(handler func(ctx context.Context, msg *Message) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handler = handler
+}
+
+// OnBatch sets a handler called with up to BatchSize messages at a time, replacing OnMessage
+func (s *subscription) OnBatch(handler func(ctx context.Context, msgs []*Message) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batchHandler = handler
+}
+
+// Start starts delivering messages to the handler
+func (s *subscription) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.handler == nil && s.batchHandler == nil {
+		return errors.New("message handler is not set")
+	}
+	if s.cancel != nil {
+		return errors.New("consumer already started")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	if s.onStart != nil {
+		if err := s.onStart(ctx); err != nil {
+			cancel()
+			return err
+		}
+	}
+	s.cancel = cancel
+
+	s.wg.Add(1)
+	go s.run(ctx, s.handler, s.batchHandler)
+	return nil
+}
+
+// Stop stops delivering messages and waits for in-flight handlers to return
+func (s *subscription) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.cancel = nil
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+
+	var err error
+	if s.onStop != nil {
+		err = s.onStop()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Pause stops handling messages until Resume, waiting for in-flight
+// handlers. Published messages are buffered meanwhile.
+func (s *subscription) Pause(ctx context.Context) error {
+	return s.pauser.pause(ctx)
+}
+
+// Resume continues handling messages after Pause
+func (s *subscription) Resume(ctx context.Context) error {
+	s.pauser.resume()
+	return nil
+}
+
+// deliver queues msg for the handler, blocking while the buffer is full
+func (s *subscription) deliver(ctx context.Context, msg *Message) error {
+	select {
+	case s.messages <- msg:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *subscription) run(ctx context.Context, handler func(context.Context, *Message) error, batchHandler func(context.Context, []*Message) error) {
+	defer s.wg.Done()
+
+	for {
+		var msg *Message
+		select {
+		case <-ctx.Done():
+			return
+		case msg = <-s.messages:
+		}
+
+		if s.expired(ctx, msg) {
+			continue
+		}
+
+		if err := s.pauser.acquire(ctx); err != nil {
+			return
+		}
+		s.handle(ctx, msg, handler, batchHandler)
+		s.pauser.release()
+	}
+}
+
+// handle runs the handler on msg, or the batch handler on msg and the messages buffered behind it
+func (s *subscription) handle(ctx context.Context, msg *Message, handler func(context.Context, *Message) error, batchHandler func(context.Context, []*Message) error) {
+	if batchHandler == nil {
+		s.retry(ctx, func() error {
+			return safeCall(s.opts, s.topic, func() error { return handler(ctx, msg) })
+		})
+		return
+	}
+
+	batch := []*Message{msg}
+collect:
+	for len(batch) < s.opts.BatchSize {
+		select {
+		case next := <-s.messages:
+			if !s.expired(ctx, next) {
+				batch = append(batch, next)
+			}
+		default:
+			break collect
+		}
+	}
+	s.retry(ctx, func() error {
+		return safeCall(s.opts, s.topic, func() error { return batchHandler(ctx, batch) })
+	})
+}
+
+// expired reports whether msg has expired, passing it to OnExpired
+func (s *subscription) expired(ctx context.Context, msg *Message) bool {
+	if !msg.Expired() {
+		return false
+	}
+	if s.opts.OnExpired != nil {
+		s.opts.OnExpired(ctx, msg)
+	}
+	return true
+}
+
+// retry calls fn until it succeeds, at most 1+RetryCount times. A
+// RequeueError replaces RetryDelay before the next attempt.
+func (s *subscription) retry(ctx context.Context, fn func() error) {
+	delay := s.opts.RetryDelay
+	for attempt := 0; attempt <= s.opts.RetryCount; attempt++ {
+		if attempt > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+		err := fn()
+		if err == nil {
+			return
+		}
+		delay = s.opts.RetryDelay
+		if d := requeueDelay(err); d > 0 {
+			delay = d
+		}
+	}
+}
+
+var _ Broker = (*MemoryBroker)(nil)
+
+// MemoryBroker is an in-process Broker, e.g. for events between modules.
+// Each subscriber buffers up to MaxSize messages, Publish blocks while a
+// subscriber's buffer is full.
+type MemoryBroker struct {
+	opts *Options
+
+	mu     sync.RWMutex
+	topics map[string]map[*subscription]struct{}
+	closed bool
+}
+
+// NewMemoryBroker creates a new in-process broker
+func NewMemoryBroker(opts *Options) *MemoryBroker {
+	if opts == nil {
+		opts = NewOptions()
+	}
+	return &MemoryBroker{
+		opts:   opts,
+		topics: make(map[string]map[*subscription]struct{}),
+	}
+}
+
+// Publish delivers a copy of msg to every subscriber of topic
+func (b *MemoryBroker) Publish(ctx context.Context, topic string, msg *Message) error {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return ErrBrokerClosed
+	}
+	subs := make([]*subscription, 0, len(b.topics[topic]))
+	for s := range b.topics[topic] {
+		subs = append(subs, s)
+	}
+	b.mu.RUnlock()
+
+	for _, s := range subs {
+		if err := s.deliver(ctx, copyMessage(msg, "")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Subscribe returns a consumer of topic, messages are buffered from now on
+// and handled once it is started. Stopping it unsubscribes.
+func (b *MemoryBroker) Subscribe(topic string) (Consumer, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrBrokerClosed
+	}
+
+	s := newSubscription(topic, b.opts)
+	s.onStart = func(context.Context) error {
+		return b.subscribe(topic, s)
+	}
+	s.onStop = func() error {
+		b.unsubscribe(topic, s)
+		return nil
+	}
+
+	b.addSubscription(topic, s)
+	return s, nil
+}
+
+// Close stops every subscription
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	var subs []*subscription
+	for _, topic := range b.topics {
+		for s := range topic {
+			subs = append(subs, s)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		_ = s.Stop(context.Background())
+	}
+	return nil
+}
+
+// subscribe adds s back to topic when it is restarted
+func (b *MemoryBroker) subscribe(topic string, s *subscription) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrBrokerClosed
+	}
+	b.addSubscription(topic, s)
+	return nil
+}
+
+// addSubscription registers s, the caller holds the lock
+func (b *MemoryBroker) addSubscription(topic string, s *subscription) {
+	if b.topics[topic] == nil {
+		b.topics[topic] = make(map[*subscription]struct{})
+	}
+	b.topics[topic][s] = struct{}{}
+}
+
+func (b *MemoryBroker) unsubscribe(topic string, s *subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.topics[topic], s)
+	if len(b.topics[topic]) == 0 {
+		delete(b.topics, topic)
+	}
+}
@@ -0,0 +1,132 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryBroker(t *testing.T) {
+	ctx := context.Background()
+	opts := NewOptions()
+	opts.RetryDelay = time.Millisecond
+	broker := NewMemoryBroker(opts)
+
+	var (
+		mu       sync.Mutex
+		received = map[string][]string{}
+		failures int
+	)
+	subscribe := func(name, topic string) Consumer {
+		sub, err := broker.Subscribe(topic)
+		assert.NoError(t, err)
+		sub.OnMessage(func(ctx context.Context, msg *Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if name == "flaky" && failures < 2 {
+				failures++
+				return errors.New("try again")
+			}
+			received[name] = append(received[name], string(msg.Body))
+			return nil
+		})
+		assert.NoError(t, sub.Start(ctx))
+		return sub
+	}
+
+	billing := subscribe("billing", "orders")
+	subscribe("flaky", "orders")
+	subscribe("audit", "users")
+
+	assert.NoError(t, broker.Publish(ctx, "orders", &Message{Body: []byte("order-1")}))
+	assert.NoError(t, broker.Publish(ctx, "users", &Message{Body: []byte("user-1")}))
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received["billing"]) == 1 && len(received["flaky"]) == 1 && len(received["audit"]) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	// Stopped subscribers no longer receive messages
+	assert.NoError(t, billing.Stop(ctx))
+	assert.NoError(t, broker.Publish(ctx, "orders", &Message{Body: []byte("order-2")}))
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received["flaky"]) == 2
+	}, time.Second, 5*time.Millisecond)
+
+	mu.Lock()
+	assert.Equal(t, []string{"order-1"}, received["billing"])
+	assert.Equal(t, []string{"user-1"}, received["audit"])
+	mu.Unlock()
+
+	assert.NoError(t, broker.Close())
+	assert.ErrorIs(t, broker.Publish(ctx, "orders", &Message{}), ErrBrokerClosed)
+}
+
+func TestMemoryBroker_Batch(t *testing.T) {
+	ctx := context.Background()
+	opts := NewOptions()
+	opts.BatchSize = 10
+	broker := NewMemoryBroker(opts)
+	defer broker.Close()
+
+	sub, err := broker.Subscribe("events")
+	assert.NoError(t, err)
+
+	// Published before Start, buffered and handled as one batch
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, broker.Publish(ctx, "events", &Message{Body: []byte{byte('a' + i)}}))
+	}
+
+	batches := make(chan []*Message, 1)
+	sub.OnBatch(func(ctx context.Context, msgs []*Message) error {
+		batches <- msgs
+		return nil
+	})
+	assert.NoError(t, sub.Start(ctx))
+
+	select {
+	case batch := <-batches:
+		assert.Len(t, batch, 5)
+	case <-time.After(time.Second):
+		t.Fatal("batch not delivered")
+	}
+}
+
+func TestMemoryBroker_Pause(t *testing.T) {
+	ctx := context.Background()
+	broker := NewMemoryBroker(nil)
+	defer broker.Close()
+
+	sub, err := broker.Subscribe("orders")
+	assert.NoError(t, err)
+	handled := make(chan string, 10)
+	sub.OnMessage(func(ctx context.Context, msg *Message) error {
+		handled <- string(msg.Body)
+		return nil
+	})
+	assert.NoError(t, sub.Start(ctx))
+	assert.NoError(t, sub.Pause(ctx))
+
+	// Buffered while paused, handled once resumed
+	assert.NoError(t, broker.Publish(ctx, "orders", &Message{Body: []byte("order-1")}))
+	select {
+	case <-handled:
+		t.Fatal("handled while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	assert.NoError(t, sub.Resume(ctx))
+	select {
+	case body := <-handled:
+		assert.Equal(t, "order-1", body)
+	case <-time.After(time.Second):
+		t.Fatal("not handled after resume")
+	}
+}
@@ -0,0 +1,161 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+)
+
+// MetadataContentType is the metadata key holding the content type of a message body
+const MetadataContentType = "content_type"
+
+// Content types of the built-in codecs
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeMsgPack  = "application/msgpack"
+	ContentTypeProtobuf = "application/x-protobuf"
+)
+
+// ErrUnknownContentType is returned when decoding a body no codec is registered for
+var ErrUnknownContentType = errors.New("unknown content type")
+
+// Codec encodes values into message bodies and back
+type Codec interface {
+	// ContentType is recorded in the metadata of encoded messages
+	ContentType() string
+
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// Built-in codecs, registered by default
+var (
+	JSON     Codec = jsonCodec{}
+	MsgPack  Codec = msgpackCodec{}
+	Protobuf Codec = protobufCodec{}
+)
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{
+		ContentTypeJSON:     JSON,
+		ContentTypeMsgPack:  MsgPack,
+		ContentTypeProtobuf: Protobuf,
+	}
+)
+
+// RegisterCodec makes c available for decoding messages of its content type
+func RegisterCodec(c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[c.ContentType()] = c
+}
+
+// CodecFor returns the codec registered for contentType
+func CodecFor(contentType string) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	c, ok := codecs[contentType]
+	return c, ok
+}
+
+// Encode returns a message with v encoded by c as body and its content type in metadata
+func Encode(c Codec, v any) (*Message, error) {
+	body, err := c.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode message: %w", err)
+	}
+	return &Message{
+		Body:     body,
+		Metadata: map[string]string{MetadataContentType: c.ContentType()},
+	}, nil
+}
+
+// Decode decodes the body of msg with the codec of its content type.
+// Messages without a content type are decoded as JSON.
+func Decode[T any](msg *Message) (T, error) {
+	var v T
+
+	contentType := msg.Metadata[MetadataContentType]
+	if contentType == "" {
+		contentType = ContentTypeJSON
+	}
+	c, ok := CodecFor(contentType)
+	if !ok {
+		return v, fmt.Errorf("failed to decode message: %w: %s", ErrUnknownContentType, contentType)
+	}
+
+	// Pointer types, e.g. generated protobuf messages, are decoded into a new value
+	target := any(&v)
+	if t := reflect.TypeOf(v); t != nil && t.Kind() == reflect.Pointer {
+		v = reflect.New(t.Elem()).Interface().(T)
+		target = v
+	}
+	if err := c.Unmarshal(msg.Body, target); err != nil {
+		return v, fmt.Errorf("failed to decode message: %w", err)
+	}
+	return v, nil
+}
+
+// Handle adapts a handler of decoded values for Consumer.OnMessage. Bodies
+// that cannot be decoded fail like the handler would.
+func Handle[T any](handler func(ctx context.Context, v T) error) func(ctx context.Context, msg *Message) error {
+	return func(ctx context.Context, msg *Message) error {
+		v, err := Decode[T](msg)
+		if err != nil {
+			return err
+		}
+		return handler(ctx, v)
+	}
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string { return ContentTypeJSON }
+
+func (jsonCodec) Marshal(v any) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// msgpackHandle honours the codec and json struct tags
+var msgpackHandle = &codec.MsgpackHandle{}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ContentType() string { return ContentTypeMsgPack }
+
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	var b []byte
+	err := codec.NewEncoderBytes(&b, msgpackHandle).Encode(v)
+	return b, err
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v any) error {
+	return codec.NewDecoderBytes(data, msgpackHandle).Decode(v)
+}
+
+type protobufCodec struct{}
+
+func (protobufCodec) ContentType() string { return ContentTypeProtobuf }
+
+func (protobufCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (protobufCodec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%T is not a proto.Message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
@@ -0,0 +1,62 @@
+package queue
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type order struct {
+	ID    string `json:"id"`
+	Total int    `json:"total"`
+}
+
+func TestCodecs(t *testing.T) {
+	for _, c := range []Codec{JSON, MsgPack} {
+		msg, err := Encode(c, order{ID: "o-1", Total: 42})
+		assert.NoError(t, err)
+		assert.Equal(t, c.ContentType(), msg.Metadata[MetadataContentType])
+
+		v, err := Decode[order](msg)
+		assert.NoError(t, err, c.ContentType())
+		assert.Equal(t, order{ID: "o-1", Total: 42}, v)
+
+		p, err := Decode[*order](msg)
+		assert.NoError(t, err, c.ContentType())
+		assert.Equal(t, &order{ID: "o-1", Total: 42}, p)
+	}
+
+	msg, err := Encode(Protobuf, wrapperspb.String("hello"))
+	assert.NoError(t, err)
+	v, err := Decode[*wrapperspb.StringValue](msg)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", v.GetValue())
+
+	_, err = Encode(Protobuf, order{})
+	assert.Error(t, err)
+
+	// Without a content type the body is JSON
+	plain, err := Decode[order](&Message{Body: []byte(`{"id":"o-2"}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, "o-2", plain.ID)
+
+	_, err = Decode[order](&Message{Metadata: map[string]string{MetadataContentType: "text/csv"}})
+	assert.ErrorIs(t, err, ErrUnknownContentType)
+}
+
+func TestHandle(t *testing.T) {
+	var got order
+	handler := Handle(func(ctx context.Context, o order) error {
+		got = o
+		return nil
+	})
+
+	msg, err := Encode(MsgPack, order{ID: "o-3", Total: 7})
+	assert.NoError(t, err)
+	assert.NoError(t, handler(context.Background(), msg))
+	assert.Equal(t, order{ID: "o-3", Total: 7}, got)
+
+	assert.Error(t, handler(context.Background(), &Message{Body: []byte("not json")}))
+}
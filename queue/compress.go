@@ -0,0 +1,121 @@
+package queue
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"sync"
+)
+
+// MetadataContentEncoding is the metadata key holding the compression of a message body
+const MetadataContentEncoding = "content_encoding"
+
+// DefaultCompressThreshold is the body size above which messages are compressed by default
+const DefaultCompressThreshold = 1024
+
+// ErrUnknownContentEncoding is returned when reading a body no compressor is registered for
+var ErrUnknownContentEncoding = errors.New("unknown content encoding")
+
+// Compressor compresses message bodies and back
+type Compressor interface {
+	// Encoding is recorded in the metadata of compressed messages
+	Encoding() string
+
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// Gzip is the built-in gzip compressor, registered by default
+var Gzip Compressor = gzipCompressor{}
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[string]Compressor{
+		"gzip": Gzip,
+	}
+)
+
+// RegisterCompressor makes c available for decompressing messages of its encoding
+func RegisterCompressor(c Compressor) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	compressors[c.Encoding()] = c
+}
+
+// CompressorFor returns the compressor registered for encoding
+func CompressorFor(encoding string) (Compressor, bool) {
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	c, ok := compressors[encoding]
+	return c, ok
+}
+
+// compress returns a copy of msg with its body compressed by opts.Compression,
+// or msg itself when the body is below opts.CompressThreshold
+func compress(opts *Options, msg *Message) (*Message, error) {
+	c := opts.Compression
+	if c == nil || len(msg.Body) < opts.CompressThreshold || msg.Metadata[MetadataContentEncoding] != "" {
+		return msg, nil
+	}
+
+	body, err := c.Compress(msg.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress message: %w", err)
+	}
+
+	compressed := *msg
+	compressed.Body = body
+	compressed.Metadata = make(map[string]string, len(msg.Metadata)+1)
+	maps.Copy(compressed.Metadata, msg.Metadata)
+	compressed.Metadata[MetadataContentEncoding] = c.Encoding()
+	return &compressed, nil
+}
+
+// decompress restores the body of a message compressed by compress
+func decompress(msg *Message) error {
+	encoding := msg.Metadata[MetadataContentEncoding]
+	if encoding == "" {
+		return nil
+	}
+
+	c, ok := CompressorFor(encoding)
+	if !ok {
+		return fmt.Errorf("failed to decompress message: %w: %s", ErrUnknownContentEncoding, encoding)
+	}
+	body, err := c.Decompress(msg.Body)
+	if err != nil {
+		return fmt.Errorf("failed to decompress message: %w", err)
+	}
+
+	msg.Body = body
+	delete(msg.Metadata, MetadataContentEncoding)
+	return nil
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Encoding() string { return "gzip" }
+
+func (gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
@@ -0,0 +1,60 @@
+package queue
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompress(t *testing.T) {
+	opts := NewOptions()
+	opts.Compression = Gzip
+
+	small := &Message{Body: []byte("small")}
+	msg, err := compress(opts, small)
+	assert.NoError(t, err)
+	assert.Same(t, small, msg)
+
+	body := bytes.Repeat([]byte("payload "), 1000)
+	large := &Message{Body: body, Metadata: map[string]string{"k": "v"}}
+	msg, err = compress(opts, large)
+	assert.NoError(t, err)
+	assert.Less(t, len(msg.Body), len(body))
+	assert.Equal(t, "gzip", msg.Metadata[MetadataContentEncoding])
+	assert.Empty(t, large.Metadata[MetadataContentEncoding])
+
+	assert.NoError(t, decompress(msg))
+	assert.Equal(t, body, msg.Body)
+	assert.Equal(t, map[string]string{"k": "v"}, msg.Metadata)
+
+	unknown := &Message{Body: body, Metadata: map[string]string{MetadataContentEncoding: "lz4"}}
+	assert.ErrorIs(t, decompress(unknown), ErrUnknownContentEncoding)
+}
+
+func TestRedisMessageCompression(t *testing.T) {
+	opts := NewOptions()
+	opts.Compression = Gzip
+	q := &RedisQueue{opts: opts}
+
+	body := bytes.Repeat([]byte("payload "), 1000)
+	values, err := q.encode(&Message{ID: "1", Body: body})
+	assert.NoError(t, err)
+	assert.Less(t, len(values[fieldBody].([]byte)), len(body))
+
+	// Redis returns every field as a string
+	entry := redis.XMessage{ID: "1-0", Values: make(map[string]any, len(values))}
+	for k, v := range values {
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		entry.Values[k] = fmt.Sprint(v)
+	}
+
+	msg, err := decodeMessage(entry)
+	assert.NoError(t, err)
+	assert.Equal(t, body, msg.Body)
+	assert.Empty(t, msg.Metadata[MetadataContentEncoding])
+}
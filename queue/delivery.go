@@ -0,0 +1,88 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Delivery is a message received through Subscribe. It stays in the queue
+// until it is acknowledged with Ack, or released with Nack.
+type Delivery struct {
+	*Message
+	queue Queue
+}
+
+// Ack acknowledges the message and removes it from the queue
+func (d *Delivery) Ack(ctx context.Context) error {
+	return d.queue.Ack(ctx, d.Message)
+}
+
+// Nack releases the message so it is delivered again
+func (d *Delivery) Nack(ctx context.Context) error {
+	return d.queue.Nack(ctx, d.Message)
+}
+
+// NackAfter releases the message so it is delivered again once delay has passed
+func (d *Delivery) NackAfter(ctx context.Context, delay time.Duration) error {
+	return d.queue.NackAfter(ctx, d.Message, delay)
+}
+
+// NackWithError releases the message like NackAfter, recording cause if it
+// is dead-lettered
+func (d *Delivery) NackWithError(ctx context.Context, delay time.Duration, cause error) error {
+	return d.queue.NackWithError(ctx, d.Message, delay, cause)
+}
+
+// subscribe feeds the messages received from q into the returned channel
+// until ctx is done, then closes it. notify, if set, returns a channel closed
+// on the next push; otherwise q is polled every PollInterval.
+func subscribe(ctx context.Context, q Queue, opts *Options, notify func() <-chan struct{}, expire func(ctx context.Context, msg *Message) error) <-chan *Delivery {
+	ch := make(chan *Delivery)
+
+	interval := opts.PollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	go func() {
+		defer close(ch)
+
+		for ctx.Err() == nil {
+			var pushed <-chan struct{}
+			if notify != nil {
+				pushed = notify()
+			}
+
+			msg, err := q.Receive(ctx)
+			if err != nil {
+				delay := interval
+				if !errors.Is(err, ErrQueueEmpty) {
+					delay = opts.RetryDelay
+				}
+				timer := time.NewTimer(delay)
+				select {
+				case <-ctx.Done():
+				case <-pushed:
+				case <-timer.C:
+				}
+				timer.Stop()
+				continue
+			}
+
+			if msg.Expired() {
+				_ = expire(ctx, msg)
+				continue
+			}
+
+			select {
+			case ch <- &Delivery{Message: msg, queue: q}:
+			case <-ctx.Done():
+				// Nobody is receiving anymore, hand the message back
+				_ = q.Nack(context.Background(), msg)
+				return
+			}
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,53 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryQueueSubscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	q, err := NewMemoryQueue("jobs", nil)
+	assert.NoError(t, err)
+
+	deliveries, err := q.Subscribe(ctx)
+	assert.NoError(t, err)
+
+	assert.NoError(t, q.Push(ctx, &Message{Body: []byte("a")}))
+	assert.NoError(t, q.Push(ctx, (&Message{Body: []byte("stale")}).WithTTL(-time.Second)))
+	assert.NoError(t, q.Push(ctx, &Message{Body: []byte("b")}))
+
+	next := func() *Delivery {
+		select {
+		case d := <-deliveries:
+			return d
+		case <-time.After(time.Second):
+			t.Fatal("no delivery")
+			return nil
+		}
+	}
+
+	a := next()
+	assert.Equal(t, "a", string(a.Body))
+	assert.NoError(t, a.Nack(ctx))
+
+	// Nacked messages come back, expired ones are skipped
+	got := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		d := next()
+		got[string(d.Body)] = true
+		assert.NoError(t, d.Ack(ctx))
+	}
+	assert.Equal(t, map[string]bool{"a": true, "b": true}, got)
+
+	cancel()
+	_, open := <-deliveries
+	assert.False(t, open)
+
+	length, err := q.Length(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), length)
+}
@@ -0,0 +1,883 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WAL record operations
+const (
+	walPush = "push"
+	walAck  = "ack"
+)
+
+// walCompactMin is the number of stale records that triggers a compaction
+const walCompactMin = 1000
+
+// walRecord is a line of the write-ahead log
+type walRecord struct {
+	Op      string   `json:"op"`
+	Key     string   `json:"key"`
+	Message *Message `json:"msg,omitempty"`
+}
+
+// memoryEntry is a message held by a MemoryQueue
+type memoryEntry struct {
+	seq      uint64
+	key      string
+	msg      *Message
+	attempts int
+	deadline time.Time
+}
+
+var _ Queue = (*MemoryQueue)(nil)
+
+// MemoryQueue implements Queue in process memory. With Options.WALPath set,
+// pushed messages are appended to a write-ahead log and the ones not yet
+// acknowledged are recovered when the queue is opened again.
+type MemoryQueue struct {
+	name string
+	opts *Options
+
+	mu       sync.Mutex
+	seq      uint64
+	ready    []*memoryEntry
+	inflight map[string]*memoryEntry
+
+	wal     *os.File
+	records int
+
+	// pushed is closed and replaced on every push to wake up consumers
+	pushed chan struct{}
+	// freed is closed and replaced when messages leave the queue, to wake up
+	// blocked producers
+	freed chan struct{}
+}
+
+// NewMemoryQueue creates a new in-memory queue, recovering it from the WAL if configured
+func NewMemoryQueue(name string, opts *Options) (*MemoryQueue, error) {
+	if opts == nil {
+		opts = NewOptions()
+	}
+
+	q := &MemoryQueue{
+		name:     name,
+		opts:     opts,
+		inflight: make(map[string]*memoryEntry),
+		pushed:   make(chan struct{}),
+		freed:    make(chan struct{}),
+	}
+
+	if opts.WALPath != "" {
+		if err := q.recover(); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+// Push adds a message to the queue
+func (q *MemoryQueue) Push(ctx context.Context, msg *Message) error {
+	return q.PushBatch(ctx, []*Message{msg})
+}
+
+// PushBatch adds messages to the queue with a single WAL write
+func (q *MemoryQueue) PushBatch(ctx context.Context, msgs []*Message) error {
+	for _, msg := range msgs {
+		if msg == nil {
+			return errors.New("message is nil")
+		}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.reserve(ctx, len(msgs)); err != nil {
+		return err
+	}
+
+	entries := make([]*memoryEntry, len(msgs))
+	records := make([]walRecord, len(msgs))
+	for i, msg := range msgs {
+		q.seq++
+		key := strconv.FormatUint(q.seq, 10)
+		if msg.ID == "" {
+			msg.ID = key
+		}
+		if msg.Timestamp.IsZero() {
+			msg.Timestamp = time.Now()
+		}
+
+		stored := *msg
+		stored.receipt = ""
+		entries[i] = &memoryEntry{seq: q.seq, key: key, msg: &stored}
+		records[i] = walRecord{Op: walPush, Key: key, Message: &stored}
+	}
+
+	if err := q.log(records...); err != nil {
+		return err
+	}
+	q.ready = append(q.ready, entries...)
+	q.notify()
+	return nil
+}
+
+// reserve makes room for n messages following the Backpressure policy. The
+// caller holds the lock, it is released while blocking.
+func (q *MemoryQueue) reserve(ctx context.Context, n int) error {
+	limit := q.opts.MaxSize
+	if limit <= 0 || int64(q.size()+n) <= limit {
+		return nil
+	}
+	if int64(n) > limit {
+		return ErrQueueFull
+	}
+
+	switch q.opts.Backpressure {
+	case BackpressureBlock:
+		for int64(q.size()+n) > limit {
+			freed := q.freed
+			q.mu.Unlock()
+			select {
+			case <-ctx.Done():
+				q.mu.Lock()
+				return ctx.Err()
+			case <-freed:
+			}
+			q.mu.Lock()
+		}
+		return nil
+
+	case BackpressureDropOldest:
+		// Only messages not yet delivered can be dropped
+		excess := q.size() + n - int(limit)
+		if excess > len(q.ready) {
+			return ErrQueueFull
+		}
+		records := make([]walRecord, excess)
+		for i, e := range q.ready[:excess] {
+			records[i] = walRecord{Op: walAck, Key: e.key}
+		}
+		if err := q.log(records...); err != nil {
+			return err
+		}
+		clear(q.ready[:excess])
+		q.ready = q.ready[excess:]
+		return nil
+
+	default:
+		return ErrQueueFull
+	}
+}
+
+// free wakes up the producers blocked on a full queue, the caller holds the lock
+func (q *MemoryQueue) free() {
+	close(q.freed)
+	q.freed = make(chan struct{})
+}
+
+// Pop retrieves and removes the next message
+func (q *MemoryQueue) Pop(ctx context.Context) (*Message, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	e := q.next()
+	if e == nil {
+		return nil, ErrQueueEmpty
+	}
+	if err := q.log(walRecord{Op: walAck, Key: e.key}); err != nil {
+		q.ready = append([]*memoryEntry{e}, q.ready...)
+		return nil, err
+	}
+	q.free()
+	return copyMessage(e.msg, ""), q.compact()
+}
+
+// Receive returns the next message without removing it. Messages not
+// acknowledged within ClaimMinIdle are returned first.
+func (q *MemoryQueue) Receive(ctx context.Context) (*Message, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.requeueExpired()
+
+	e := q.next()
+	if e == nil {
+		return nil, ErrQueueEmpty
+	}
+	e.attempts++
+	e.deadline = time.Time{}
+	if q.opts.ClaimMinIdle > 0 {
+		e.deadline = time.Now().Add(q.opts.ClaimMinIdle)
+	}
+	q.inflight[e.key] = e
+	return copyMessage(e.msg, e.key), nil
+}
+
+// Ack acknowledges a received message and removes it from the queue
+func (q *MemoryQueue) Ack(ctx context.Context, msg *Message) error {
+	if msg == nil || msg.receipt == "" {
+		return ErrNotReceived
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.inflight[msg.receipt]; !ok {
+		return ErrNotReceived
+	}
+	if err := q.log(walRecord{Op: walAck, Key: msg.receipt}); err != nil {
+		return err
+	}
+	delete(q.inflight, msg.receipt)
+	q.free()
+	return q.compact()
+}
+
+// Nack releases a received message so it is returned by the next Receive.
+// After MaxAttempts deliveries it is dropped instead.
+func (q *MemoryQueue) Nack(ctx context.Context, msg *Message) error {
+	return q.release(msg, errNacked, time.Time{})
+}
+
+// NackAfter releases a received message so it is returned by Receive once
+// delay has passed. After MaxAttempts deliveries it is dropped instead.
+func (q *MemoryQueue) NackAfter(ctx context.Context, msg *Message, delay time.Duration) error {
+	return q.release(msg, errNacked, time.Now().Add(delay))
+}
+
+// NackWithError is NackAfter reporting cause to OnDeadLetter when the
+// message is dropped
+func (q *MemoryQueue) NackWithError(ctx context.Context, msg *Message, delay time.Duration, cause error) error {
+	if cause == nil {
+		cause = errNacked
+	}
+	return q.release(msg, cause, time.Now().Add(delay))
+}
+
+// release implements NackAfter, holding the message back until until. cause
+// is reported to OnDeadLetter.
+func (q *MemoryQueue) release(msg *Message, cause error, until time.Time) error {
+	if msg == nil || msg.receipt == "" {
+		return ErrNotReceived
+	}
+
+	q.mu.Lock()
+	e, ok := q.inflight[msg.receipt]
+	if !ok {
+		q.mu.Unlock()
+		return ErrNotReceived
+	}
+	delete(q.inflight, msg.receipt)
+
+	if q.opts.MaxAttempts <= 0 || e.attempts < q.opts.MaxAttempts {
+		if time.Now().Before(until) {
+			// Held back until requeueExpired picks it up
+			e.deadline = until
+			q.inflight[e.key] = e
+		} else {
+			q.ready = append([]*memoryEntry{e}, q.ready...)
+			q.notify()
+		}
+		q.mu.Unlock()
+		return nil
+	}
+
+	err := q.log(walRecord{Op: walAck, Key: e.key})
+	if err == nil {
+		err = q.compact()
+	}
+	q.free()
+	q.mu.Unlock()
+
+	// Outside the lock, the hook may use the queue
+	if q.opts.OnDeadLetter != nil {
+		q.opts.OnDeadLetter(copyMessage(e.msg, ""), cause)
+	}
+	return err
+}
+
+// expire acks an expired message after calling OnExpired, and reports it to
+// OnDeadLetter with DeadLetterExpired
+func (q *MemoryQueue) expire(ctx context.Context, msg *Message) error {
+	if q.opts.OnExpired != nil {
+		q.opts.OnExpired(ctx, msg)
+	}
+	if err := q.Ack(ctx, msg); err != nil {
+		return err
+	}
+	if q.opts.DeadLetterExpired && q.opts.OnDeadLetter != nil {
+		q.opts.OnDeadLetter(copyMessage(msg, ""), ErrMessageExpired)
+	}
+	return nil
+}
+
+// unreceive puts a received message back in front of the queue, or holds it
+// back until until, as if it had not been delivered
+func (q *MemoryQueue) unreceive(msg *Message, until time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	e, ok := q.inflight[msg.receipt]
+	if !ok {
+		return
+	}
+	e.attempts--
+	if time.Now().Before(until) {
+		e.deadline = until
+		return
+	}
+	delete(q.inflight, msg.receipt)
+	q.ready = append([]*memoryEntry{e}, q.ready...)
+	q.notify()
+}
+
+// memoryReceiptLess orders receipts, the push sequence numbers of the entries
+func memoryReceiptLess(a, b string) bool {
+	x, _ := strconv.ParseUint(a, 10, 64)
+	y, _ := strconv.ParseUint(b, 10, 64)
+	return x < y
+}
+
+// heldUntil reports whether the message of receipt is still queued, and
+// until when it is held back, zero once it is ready
+func (q *MemoryQueue) heldUntil(receipt string) (time.Time, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if e, ok := q.inflight[receipt]; ok {
+		return e.deadline, true
+	}
+	for _, e := range q.ready {
+		if e.key == receipt {
+			return time.Time{}, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Subscribe returns a channel of received messages, closed once ctx is done.
+// Each delivery must be acknowledged with Ack or released with Nack; expired
+// messages are skipped.
+func (q *MemoryQueue) Subscribe(ctx context.Context) (<-chan *Delivery, error) {
+	return subscribe(ctx, q, q.opts, q.waitCh, q.expire), nil
+}
+
+// Peek returns the next message without consuming it
+func (q *MemoryQueue) Peek(ctx context.Context) (*Message, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.requeueExpired()
+	if len(q.ready) == 0 {
+		return nil, ErrQueueEmpty
+	}
+	return copyMessage(q.ready[0].msg, ""), nil
+}
+
+// Length returns the number of messages in the queue, including received ones
+func (q *MemoryQueue) Length(ctx context.Context) (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return int64(q.size()), nil
+}
+
+// Clear removes all messages from the queue and truncates the WAL
+func (q *MemoryQueue) Clear(ctx context.Context) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.ready = nil
+	q.inflight = make(map[string]*memoryEntry)
+	q.free()
+	if q.wal != nil {
+		return q.rewrite()
+	}
+	return nil
+}
+
+// Close closes the WAL, the queue must not be used afterwards
+func (q *MemoryQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.wal == nil {
+		return nil
+	}
+	err := q.wal.Close()
+	q.wal = nil
+	return err
+}
+
+// Name returns the queue name
+func (q *MemoryQueue) Name() string {
+	return q.name
+}
+
+// notify wakes up the consumers waiting for messages, the caller holds the lock
+func (q *MemoryQueue) notify() {
+	close(q.pushed)
+	q.pushed = make(chan struct{})
+}
+
+// waitCh returns a channel closed on the next push
+func (q *MemoryQueue) waitCh() <-chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.pushed
+}
+
+func (q *MemoryQueue) size() int {
+	return len(q.ready) + len(q.inflight)
+}
+
+// next removes the first ready entry, the caller holds the lock
+func (q *MemoryQueue) next() *memoryEntry {
+	if len(q.ready) == 0 {
+		return nil
+	}
+	e := q.ready[0]
+	q.ready[0] = nil
+	q.ready = q.ready[1:]
+	return e
+}
+
+// requeueExpired makes received and delayed messages past their deadline ready again
+func (q *MemoryQueue) requeueExpired() {
+	now := time.Now()
+	var expired []*memoryEntry
+	for key, e := range q.inflight {
+		if !e.deadline.IsZero() && now.After(e.deadline) {
+			expired = append(expired, e)
+			delete(q.inflight, key)
+		}
+	}
+	if len(expired) == 0 {
+		return
+	}
+
+	// Oldest first, ahead of messages never delivered
+	sortEntries(expired)
+	q.ready = append(expired, q.ready...)
+}
+
+// log appends records to the WAL, the caller holds the lock
+func (q *MemoryQueue) log(records ...walRecord) error {
+	if q.wal == nil {
+		return nil
+	}
+
+	var buf []byte
+	for _, r := range records {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("failed to encode wal record: %w", err)
+		}
+		buf = append(buf, line...)
+		buf = append(buf, '\n')
+	}
+
+	if _, err := q.wal.Write(buf); err != nil {
+		return fmt.Errorf("failed to write wal: %w", err)
+	}
+	if q.opts.WALSync {
+		if err := q.wal.Sync(); err != nil {
+			return fmt.Errorf("failed to sync wal: %w", err)
+		}
+	}
+	q.records += len(records)
+	return nil
+}
+
+// compact rewrites the WAL once most of its records are stale
+func (q *MemoryQueue) compact() error {
+	if q.wal == nil {
+		return nil
+	}
+	live := q.size()
+	if q.records-live < walCompactMin || q.records < 2*live {
+		return nil
+	}
+	return q.rewrite()
+}
+
+// rewrite replaces the WAL with the messages still in the queue
+func (q *MemoryQueue) rewrite() error {
+	path := q.opts.WALPath
+	tmp := path + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to compact wal: %w", err)
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	entries := make([]*memoryEntry, 0, q.size())
+	for _, e := range q.inflight {
+		entries = append(entries, e)
+	}
+	sortEntries(entries)
+	entries = append(entries, q.ready...)
+	for _, e := range entries {
+		if err := enc.Encode(walRecord{Op: walPush, Key: e.key, Message: e.msg}); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to compact wal: %w", err)
+		}
+	}
+	if err := errors.Join(w.Flush(), f.Sync(), f.Close()); err != nil {
+		return fmt.Errorf("failed to compact wal: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to compact wal: %w", err)
+	}
+
+	wal, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open wal: %w", err)
+	}
+	if q.wal != nil {
+		_ = q.wal.Close()
+	}
+	q.wal = wal
+	q.records = len(entries)
+	return nil
+}
+
+// recover replays the WAL and opens it for appending
+func (q *MemoryQueue) recover() error {
+	path := q.opts.WALPath
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create wal directory: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open wal: %w", err)
+	}
+	defer f.Close()
+
+	pending := make(map[string]*memoryEntry)
+	var order []*memoryEntry
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var r walRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			// A torn last write after a crash, everything before it is valid
+			break
+		}
+
+		switch r.Op {
+		case walPush:
+			if r.Message == nil {
+				continue
+			}
+			seq, err := strconv.ParseUint(r.Key, 10, 64)
+			if err != nil {
+				continue
+			}
+			e := &memoryEntry{seq: seq, key: r.Key, msg: r.Message}
+			pending[r.Key] = e
+			order = append(order, e)
+			q.seq = max(q.seq, seq)
+		case walAck:
+			delete(pending, r.Key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read wal: %w", err)
+	}
+
+	for _, e := range order {
+		if pending[e.key] == e {
+			q.ready = append(q.ready, e)
+		}
+	}
+
+	// Start from a compact log holding only the recovered messages
+	return q.rewrite()
+}
+
+// MemoryConsumer consumes a MemoryQueue. Consumers of the same queue form a
+// group: they compete for its messages and each message is handled by exactly
+// one of them, so members can join and leave at any time. Failed messages are
+// released with Nack and handled again by any member, until MaxAttempts.
+type MemoryConsumer struct {
+	queue        *MemoryQueue
+	handler      func(ctx context.Context, msg *Message) error
+	batchHandler func(ctx context.Context, msgs []*Message) error
+
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	pauser pauser
+	locks  partitionLocks
+}
+
+// NewMemoryConsumer creates a new consumer joining the queue's group
+func NewMemoryConsumer(q *MemoryQueue) *MemoryConsumer {
+	return &MemoryConsumer{queue: q, locks: partitionLocks{less: memoryReceiptLess}}
+}
+
+// OnMessage sets the message handler
+func (c *MemoryConsumer) OnMessage(handler func(ctx context.Context, msg *Message) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handler = handler
+}
+
+// OnBatch sets a handler called with up to BatchSize messages at a time,
+// replacing OnMessage. When it fails, every message of the batch is released.
+func (c *MemoryConsumer) OnBatch(handler func(ctx context.Context, msgs []*Message) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.batchHandler = handler
+}
+
+// Start starts consuming in the background
+func (c *MemoryConsumer) Start(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.handler == nil && c.batchHandler == nil {
+		return errors.New("message handler is not set")
+	}
+	if c.cancel != nil {
+		return errors.New("consumer already started")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	c.ctx, c.cancel = ctx, cancel
+
+	c.wg.Add(1)
+	go c.run(ctx, c.handler, c.batchHandler)
+	return nil
+}
+
+// Stop stops consuming and waits for in-flight handlers to return
+func (c *MemoryConsumer) Stop(ctx context.Context) error {
+	c.mu.Lock()
+	cancel := c.cancel
+	c.ctx, c.cancel = nil, nil
+	c.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Pause stops receiving messages until Resume, waiting for in-flight
+// handlers. Messages stay in the queue for the other consumers.
+func (c *MemoryConsumer) Pause(ctx context.Context) error {
+	return c.pauser.pause(ctx)
+}
+
+// Resume continues receiving messages after Pause
+func (c *MemoryConsumer) Resume(ctx context.Context) error {
+	c.pauser.resume()
+	return nil
+}
+
+// Health reports whether the consuming loop is running. They stop once
+// Stop is called or the context given to Start is done.
+func (c *MemoryConsumer) Health() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ctx != nil && c.ctx.Err() == nil
+}
+
+// Paused reports whether the consumer is paused
+func (c *MemoryConsumer) Paused() bool {
+	return c.pauser.isPaused()
+}
+
+func (c *MemoryConsumer) run(ctx context.Context, handler func(context.Context, *Message) error, batchHandler func(context.Context, []*Message) error) {
+	defer c.wg.Done()
+
+	size := max(c.queue.opts.Workers, 1)
+	if batchHandler != nil {
+		size = max(c.queue.opts.BatchSize, 1)
+	}
+
+	for ctx.Err() == nil {
+		if err := c.pauser.acquire(ctx); err != nil {
+			return
+		}
+
+		// Subscribe before receiving so a push in between is not missed
+		pushed := c.queue.waitCh()
+
+		msgs := make([]*Message, 0, size)
+		for len(msgs) < size {
+			msg, err := c.queue.Receive(ctx)
+			if err != nil {
+				break
+			}
+			msgs = append(msgs, msg)
+		}
+		if len(msgs) == 0 {
+			c.pauser.release()
+			c.wait(ctx, pushed)
+			continue
+		}
+
+		c.handle(ctx, msgs, handler, batchHandler)
+		c.pauser.release()
+	}
+}
+
+// handle runs the handler on received messages and acks or releases them
+func (c *MemoryConsumer) handle(ctx context.Context, msgs []*Message, handler func(context.Context, *Message) error, batchHandler func(context.Context, []*Message) error) {
+	live := msgs[:0]
+	for _, msg := range msgs {
+		if msg.Expired() {
+			_ = c.queue.expire(ctx, msg)
+			continue
+		}
+		live = append(live, msg)
+	}
+	msgs = live
+	if len(msgs) == 0 {
+		return
+	}
+
+	if batchHandler != nil {
+		err := safeCall(c.queue.opts, c.queue.name, func() error { return batchHandler(ctx, msgs) })
+		until := requeueAt(err)
+		for _, msg := range msgs {
+			if err != nil {
+				_ = c.queue.release(msg, err, until)
+			} else {
+				_ = c.queue.Ack(context.Background(), msg)
+			}
+		}
+		return
+	}
+
+	runPartitions(len(msgs), partitions(msgs, partitionKey), func(group []*Message) {
+		key := partitionKey(group[0])
+		if key != "" {
+			if !c.locks.acquire(key) {
+				c.holdBack(key, time.Time{}, group)
+				return
+			}
+			defer c.locks.release(key)
+		}
+
+		for i, msg := range group {
+			if key != "" && c.waitsBehind(key, group[i:]) {
+				return
+			}
+			err := safeCall(c.queue.opts, c.queue.name, func() error { return handler(ctx, msg) })
+			if err != nil {
+				// Put the rest of the partition back behind the failed
+				// message, so it is not handled ahead of it
+				until := requeueAt(err)
+				for j := len(group) - 1; j > i; j-- {
+					c.queue.unreceive(group[j], until)
+				}
+				_ = c.queue.release(msg, err, until)
+				if key != "" {
+					c.locks.wait(key, group[i:]...)
+				}
+				return
+			}
+			_ = c.queue.Ack(context.Background(), msg)
+			if key != "" {
+				c.locks.done(key, msg.receipt)
+			}
+		}
+	})
+}
+
+// waitsBehind holds msgs back while an older message of key waits to be
+// handled again, and reports whether it did
+func (c *MemoryConsumer) waitsBehind(key string, msgs []*Message) bool {
+	for {
+		blocker, ok := c.locks.ahead(key, msgs[0].receipt)
+		if !ok {
+			return false
+		}
+		until, queued := c.queue.heldUntil(blocker)
+		if !queued {
+			// Acked or dropped in the meantime
+			c.locks.done(key, blocker)
+			continue
+		}
+		c.holdBack(key, until, msgs)
+		return true
+	}
+}
+
+// holdBack puts msgs back until until, or the next poll, as waiting messages
+// of key. Messages held back until a delayed message come back together with
+// it and are sorted behind it.
+func (c *MemoryConsumer) holdBack(key string, until time.Time, msgs []*Message) {
+	if now := time.Now(); !until.After(now) {
+		until = now.Add(c.pollInterval())
+	}
+	for j := len(msgs) - 1; j >= 0; j-- {
+		c.queue.unreceive(msgs[j], until)
+	}
+	c.locks.wait(key, msgs...)
+}
+
+// wait blocks until a push, PollInterval or ctx is done. Polling picks up
+// messages whose visibility timeout has expired.
+func (c *MemoryConsumer) wait(ctx context.Context, pushed <-chan struct{}) {
+	timer := time.NewTimer(c.pollInterval())
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-pushed:
+	case <-timer.C:
+	}
+}
+
+// pollInterval returns PollInterval, one second if it is not set
+func (c *MemoryConsumer) pollInterval() time.Duration {
+	if c.queue.opts.PollInterval <= 0 {
+		return time.Second
+	}
+	return c.queue.opts.PollInterval
+}
+
+// copyMessage returns a copy of msg carrying receipt
+func copyMessage(msg *Message, receipt string) *Message {
+	c := *msg
+	c.receipt = receipt
+	return &c
+}
+
+// sortEntries orders entries by push order
+func sortEntries(entries []*memoryEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].seq < entries[j].seq
+	})
+}
@@ -0,0 +1,415 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryQueue(t *testing.T) {
+	ctx := context.Background()
+	opts := NewOptions()
+	opts.MaxSize = 3
+	opts.ClaimMinIdle = 50 * time.Millisecond
+
+	q, err := NewMemoryQueue("jobs", opts)
+	assert.NoError(t, err)
+
+	assert.NoError(t, q.Push(ctx, &Message{Body: []byte("a")}))
+	assert.NoError(t, q.PushBatch(ctx, []*Message{{Body: []byte("b")}, {Body: []byte("c")}}))
+	assert.ErrorIs(t, q.Push(ctx, &Message{Body: []byte("d")}), ErrQueueFull)
+
+	peeked, err := q.Peek(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(peeked.Body))
+
+	popped, err := q.Pop(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(popped.Body))
+	assert.ErrorIs(t, q.Ack(ctx, popped), ErrNotReceived)
+
+	// Nacked messages come back first
+	b, err := q.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(b.Body))
+	assert.NoError(t, q.Nack(ctx, b))
+	b, err = q.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(b.Body))
+
+	// Unacknowledged messages reappear after the visibility timeout
+	c, err := q.Receive(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, q.Ack(ctx, c))
+	_, err = q.Receive(ctx)
+	assert.ErrorIs(t, err, ErrQueueEmpty)
+
+	time.Sleep(60 * time.Millisecond)
+	again, err := q.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, b.ID, again.ID)
+	assert.NoError(t, q.Ack(ctx, again))
+
+	length, err := q.Length(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), length)
+}
+
+func TestMemoryQueue_WAL(t *testing.T) {
+	ctx := context.Background()
+	opts := NewOptions()
+	opts.WALPath = filepath.Join(t.TempDir(), "wal", "jobs.log")
+
+	q, err := NewMemoryQueue("jobs", opts)
+	assert.NoError(t, err)
+	for _, body := range []string{"a", "b", "c", "d"} {
+		assert.NoError(t, q.Push(ctx, &Message{Body: []byte(body), Metadata: map[string]string{"k": body}}))
+	}
+
+	_, err = q.Pop(ctx)
+	assert.NoError(t, err)
+	b, err := q.Receive(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, q.Ack(ctx, b))
+	_, err = q.Receive(ctx) // "c" is received but never acknowledged
+	assert.NoError(t, err)
+
+	// Simulate a crash with a torn last record
+	assert.NoError(t, q.Close())
+	f, err := os.OpenFile(opts.WALPath, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteString(`{"op":"push","key":"9","msg":{"Bo`)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	q, err = NewMemoryQueue("jobs", opts)
+	assert.NoError(t, err)
+	defer q.Close()
+
+	length, err := q.Length(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), length)
+
+	c, err := q.Pop(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "c", string(c.Body))
+	assert.Equal(t, "c", c.Metadata["k"])
+
+	// New messages don't reuse recovered keys
+	assert.NoError(t, q.Push(ctx, &Message{Body: []byte("e")}))
+	d, _ := q.Pop(ctx)
+	e, _ := q.Pop(ctx)
+	assert.Equal(t, "d", string(d.Body))
+	assert.Equal(t, "e", string(e.Body))
+	assert.Equal(t, "5", e.ID)
+}
+
+func TestMemoryConsumerGroup(t *testing.T) {
+	ctx := context.Background()
+	opts := NewOptions()
+	opts.PollInterval = 10 * time.Millisecond
+
+	q, err := NewMemoryQueue("jobs", opts)
+	assert.NoError(t, err)
+
+	var mu sync.Mutex
+	handled := make(map[string]int)
+	newMember := func() *MemoryConsumer {
+		c := NewMemoryConsumer(q)
+		c.OnMessage(func(ctx context.Context, msg *Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+			handled[msg.ID]++
+			return nil
+		})
+		assert.NoError(t, c.Start(ctx))
+		return c
+	}
+
+	first, second := newMember(), newMember()
+	for i := 0; i < 50; i++ {
+		assert.NoError(t, q.Push(ctx, &Message{Body: []byte("job")}))
+	}
+
+	// A member leaving leaves the remaining messages to the others
+	assert.NoError(t, first.Stop(ctx))
+	for i := 0; i < 50; i++ {
+		assert.NoError(t, q.Push(ctx, &Message{Body: []byte("job")}))
+	}
+
+	assert.Eventually(t, func() bool {
+		length, _ := q.Length(ctx)
+		return length == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.NoError(t, second.Stop(ctx))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, handled, 100)
+	for id, n := range handled {
+		assert.Equal(t, 1, n, id)
+	}
+}
+
+func TestMemoryConsumerPartitions(t *testing.T) {
+	ctx := context.Background()
+	opts := NewOptions()
+	opts.Workers = 4
+	opts.PollInterval = 10 * time.Millisecond
+
+	q, err := NewMemoryQueue("jobs", opts)
+	assert.NoError(t, err)
+
+	var mu sync.Mutex
+	handled := make(map[string][]string)
+	failed := false
+	started := make(chan string, 100)
+	release := make(chan struct{})
+
+	c := NewMemoryConsumer(q)
+	c.OnMessage(func(ctx context.Context, msg *Message) error {
+		key := partitionKey(msg)
+		started <- key
+		<-release
+
+		mu.Lock()
+		defer mu.Unlock()
+		if key == "a" && string(msg.Body) == "2" && !failed {
+			failed = true
+			return errors.New("boom")
+		}
+		handled[key] = append(handled[key], string(msg.Body))
+		return nil
+	})
+
+	var msgs []*Message
+	for i := 0; i < 5; i++ {
+		for _, key := range []string{"a", "b"} {
+			msgs = append(msgs, &Message{
+				Body:     []byte(strconv.Itoa(i)),
+				Metadata: map[string]string{MetadataPartitionKey: key},
+			})
+		}
+	}
+	assert.NoError(t, q.PushBatch(ctx, msgs))
+	assert.NoError(t, c.Start(ctx))
+
+	// Both keys are handled concurrently
+	keys := map[string]bool{<-started: true, <-started: true}
+	assert.Equal(t, map[string]bool{"a": true, "b": true}, keys)
+	close(release)
+
+	assert.Eventually(t, func() bool {
+		length, _ := q.Length(ctx)
+		return length == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.NoError(t, c.Stop(ctx))
+
+	// Each key in order, the failed message retried before the next one
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"0", "1", "2", "3", "4"}, handled["a"])
+	assert.Equal(t, []string{"0", "1", "2", "3", "4"}, handled["b"])
+}
+
+func TestMemoryConsumerPartitions_AcrossBatches(t *testing.T) {
+	ctx := context.Background()
+	opts := NewOptions()
+	opts.Workers = 1
+	opts.PollInterval = 10 * time.Millisecond
+
+	q, err := NewMemoryQueue("jobs", opts)
+	assert.NoError(t, err)
+
+	var (
+		mu      sync.Mutex
+		handled []string
+		failed  bool
+	)
+	c := NewMemoryConsumer(q)
+	c.OnMessage(func(ctx context.Context, msg *Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		// Every batch holds a single message, the later ones are fetched
+		// while the failed one is delayed
+		if string(msg.Body) == "1" && !failed {
+			failed = true
+			return RequeueAfter(50*time.Millisecond, errors.New("busy"))
+		}
+		handled = append(handled, string(msg.Body))
+		return nil
+	})
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, q.Push(ctx, &Message{
+			Body:     []byte(strconv.Itoa(i)),
+			Metadata: map[string]string{MetadataPartitionKey: "a"},
+		}))
+	}
+	assert.NoError(t, c.Start(ctx))
+
+	assert.Eventually(t, func() bool {
+		length, _ := q.Length(ctx)
+		return length == 0
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.NoError(t, c.Stop(ctx))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"0", "1", "2", "3", "4"}, handled)
+}
+
+func TestPartitions(t *testing.T) {
+	groups := partitions([]string{"a1", "b1", "-1", "a2", "-2", "b2"}, func(s string) string {
+		if s[0] == '-' {
+			return ""
+		}
+		return s[:1]
+	})
+	assert.Equal(t, [][]string{{"a1", "a2"}, {"b1", "b2"}, {"-1"}, {"-2"}}, groups)
+}
+
+func TestPartitionLocks(t *testing.T) {
+	l := partitionLocks{less: memoryReceiptLess}
+	msg := func(receipt string) *Message { return &Message{receipt: receipt} }
+
+	assert.True(t, l.acquire("a"))
+	assert.False(t, l.acquire("a"))
+	l.release("a")
+
+	// Waiting messages are kept oldest first
+	l.wait("a", msg("3"), msg("10"))
+	l.wait("a", msg("2"), msg("3"))
+	_, ok := l.ahead("a", "2")
+	assert.False(t, ok)
+	blocker, ok := l.ahead("a", "3")
+	assert.True(t, ok)
+	assert.Equal(t, "2", blocker)
+
+	l.done("a", "2")
+	l.done("a", "3")
+	blocker, ok = l.ahead("a", "11")
+	assert.True(t, ok)
+	assert.Equal(t, "10", blocker)
+	l.done("a", "10")
+	_, ok = l.ahead("a", "11")
+	assert.False(t, ok)
+}
+
+func TestMemoryConsumerPause(t *testing.T) {
+	ctx := context.Background()
+	opts := NewOptions()
+	opts.PollInterval = 10 * time.Millisecond
+
+	q, err := NewMemoryQueue("jobs", opts)
+	assert.NoError(t, err)
+
+	handled := make(chan string, 10)
+	c := NewMemoryConsumer(q)
+	c.OnMessage(func(ctx context.Context, msg *Message) error {
+		handled <- string(msg.Body)
+		return nil
+	})
+	assert.NoError(t, c.Start(ctx))
+	defer c.Stop(ctx)
+
+	assert.NoError(t, c.Pause(ctx))
+	assert.True(t, c.Paused())
+	assert.NoError(t, q.Push(ctx, &Message{Body: []byte("a")}))
+
+	select {
+	case <-handled:
+		t.Fatal("handled while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+	length, _ := q.Length(ctx)
+	assert.Equal(t, int64(1), length)
+
+	assert.NoError(t, c.Resume(ctx))
+	assert.False(t, c.Paused())
+	select {
+	case body := <-handled:
+		assert.Equal(t, "a", body)
+	case <-time.After(time.Second):
+		t.Fatal("not handled after resume")
+	}
+}
+
+func TestMemoryQueueBackpressure(t *testing.T) {
+	ctx := context.Background()
+
+	full := func(policy Backpressure) *MemoryQueue {
+		opts := NewOptions()
+		opts.MaxSize = 2
+		opts.Backpressure = policy
+		q, err := NewMemoryQueue("jobs", opts)
+		assert.NoError(t, err)
+		assert.NoError(t, q.PushBatch(ctx, []*Message{{Body: []byte("a")}, {Body: []byte("b")}}))
+		return q
+	}
+
+	t.Run("drop new", func(t *testing.T) {
+		q := full(BackpressureDropNew)
+		assert.ErrorIs(t, q.Push(ctx, &Message{Body: []byte("c")}), ErrQueueFull)
+
+		length, err := q.Length(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), length)
+	})
+
+	t.Run("drop oldest", func(t *testing.T) {
+		q := full(BackpressureDropOldest)
+		assert.NoError(t, q.Push(ctx, &Message{Body: []byte("c")}))
+
+		// Delivered messages are never dropped
+		a, err := q.Receive(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "b", string(a.Body))
+		assert.ErrorIs(t, q.PushBatch(ctx, []*Message{{Body: []byte("d")}, {Body: []byte("e")}}), ErrQueueFull)
+
+		msg, err := q.Pop(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "c", string(msg.Body))
+	})
+
+	t.Run("block", func(t *testing.T) {
+		q := full(BackpressureBlock)
+
+		timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, q.Push(timeout, &Message{Body: []byte("c")}), context.DeadlineExceeded)
+
+		done := make(chan error, 1)
+		go func() {
+			done <- q.Push(ctx, &Message{Body: []byte("c")})
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("push did not block")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		_, err := q.Pop(ctx)
+		assert.NoError(t, err)
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("push still blocked")
+		}
+	})
+
+	t.Run("batch larger than the queue", func(t *testing.T) {
+		q := full(BackpressureBlock)
+		batch := []*Message{{Body: []byte("c")}, {Body: []byte("d")}, {Body: []byte("e")}}
+		assert.ErrorIs(t, q.PushBatch(ctx, batch), ErrQueueFull)
+	})
+}
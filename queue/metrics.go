@@ -0,0 +1,223 @@
+package queue
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricsNamespace prefixes the names of the metrics exported by Metrics
+const MetricsNamespace = "queue"
+
+// depthTimeout bounds the Length call of each queue during a scrape
+const depthTimeout = 2 * time.Second
+
+// Metrics collects per queue metrics: depth, enqueued and dequeued
+// messages, handler latency, retries and dead-lettered messages. It is a
+// prometheus.Collector, register it to alert on backlogs.
+type Metrics struct {
+	enqueued     *prometheus.CounterVec
+	dequeued     *prometheus.CounterVec
+	duration     *prometheus.HistogramVec
+	retries      *prometheus.CounterVec
+	deadLettered *prometheus.CounterVec
+	depth        *prometheus.Desc
+
+	mu     sync.RWMutex
+	queues map[string]Queue
+}
+
+var _ prometheus.Collector = (*Metrics)(nil)
+
+// NewMetrics creates a new, empty set of queue metrics
+func NewMetrics() *Metrics {
+	labels := []string{"queue"}
+	return &Metrics{
+		enqueued: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "enqueued_total",
+			Help:      "Number of messages pushed, by queue.",
+		}, labels),
+		dequeued: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "dequeued_total",
+			Help:      "Number of messages popped or received, by queue.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: MetricsNamespace,
+			Name:      "handler_duration_seconds",
+			Help:      "Time spent handling messages, by queue and result.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"queue", "result"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "retries_total",
+			Help:      "Number of messages released for another delivery, by queue.",
+		}, labels),
+		deadLettered: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "dead_lettered_total",
+			Help:      "Number of messages moved to the dead-letter queue or dropped, by queue.",
+		}, labels),
+		depth: prometheus.NewDesc(prometheus.BuildFQName(MetricsNamespace, "", "depth"),
+			"Number of messages in the queue.", labels, nil),
+		queues: make(map[string]Queue),
+	}
+}
+
+// Queue wraps q so pushes, receives and nacks are counted under name. The
+// depth of q is read when the metrics are collected.
+func (m *Metrics) Queue(name string, q Queue) Queue {
+	m.mu.Lock()
+	m.queues[name] = q
+	m.mu.Unlock()
+	return &instrumentedQueue{Queue: q, name: name, metrics: m}
+}
+
+// Handler wraps a message handler, observing its latency under name. Failed
+// messages are counted as retries.
+func (m *Metrics) Handler(name string, handler func(ctx context.Context, msg *Message) error) func(ctx context.Context, msg *Message) error {
+	return func(ctx context.Context, msg *Message) error {
+		return m.observe(name, 1, func() error { return handler(ctx, msg) })
+	}
+}
+
+// BatchHandler wraps a batch handler like Handler
+func (m *Metrics) BatchHandler(name string, handler func(ctx context.Context, msgs []*Message) error) func(ctx context.Context, msgs []*Message) error {
+	return func(ctx context.Context, msgs []*Message) error {
+		return m.observe(name, len(msgs), func() error { return handler(ctx, msgs) })
+	}
+}
+
+// OnDeadLetter returns a hook for Options.OnDeadLetter counting messages under name
+func (m *Metrics) OnDeadLetter(name string) func(msg *Message, cause error) {
+	return func(*Message, error) {
+		m.deadLettered.WithLabelValues(name).Inc()
+	}
+}
+
+func (m *Metrics) observe(name string, count int, fn func() error) error {
+	start := time.Now()
+	err := fn()
+
+	result := "success"
+	if err != nil {
+		result = "error"
+		m.retries.WithLabelValues(name).Add(float64(count))
+	}
+	m.duration.WithLabelValues(name, result).Observe(time.Since(start).Seconds())
+	return err
+}
+
+// Register registers the metrics with reg, e.g. prometheus.DefaultRegisterer
+func (m *Metrics) Register(reg prometheus.Registerer) error {
+	return reg.Register(m)
+}
+
+// Describe implements prometheus.Collector
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.enqueued.Describe(ch)
+	m.dequeued.Describe(ch)
+	m.duration.Describe(ch)
+	m.retries.Describe(ch)
+	m.deadLettered.Describe(ch)
+	ch <- m.depth
+}
+
+// Collect implements prometheus.Collector
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.enqueued.Collect(ch)
+	m.dequeued.Collect(ch)
+	m.duration.Collect(ch)
+	m.retries.Collect(ch)
+	m.deadLettered.Collect(ch)
+
+	m.mu.RLock()
+	names := make([]string, 0, len(m.queues))
+	for name := range m.queues {
+		names = append(names, name)
+	}
+	m.mu.RUnlock()
+
+	sort.Strings(names)
+	for _, name := range names {
+		m.mu.RLock()
+		q := m.queues[name]
+		m.mu.RUnlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), depthTimeout)
+		length, err := q.Length(ctx)
+		cancel()
+		if err != nil {
+			// Skip rather than report a misleading zero
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(m.depth, prometheus.GaugeValue, float64(length), name)
+	}
+}
+
+// instrumentedQueue counts the operations of a Queue
+type instrumentedQueue struct {
+	Queue
+	name    string
+	metrics *Metrics
+}
+
+func (q *instrumentedQueue) Push(ctx context.Context, msg *Message) error {
+	if err := q.Queue.Push(ctx, msg); err != nil {
+		return err
+	}
+	q.metrics.enqueued.WithLabelValues(q.name).Inc()
+	return nil
+}
+
+func (q *instrumentedQueue) PushBatch(ctx context.Context, msgs []*Message) error {
+	if err := q.Queue.PushBatch(ctx, msgs); err != nil {
+		return err
+	}
+	q.metrics.enqueued.WithLabelValues(q.name).Add(float64(len(msgs)))
+	return nil
+}
+
+func (q *instrumentedQueue) Pop(ctx context.Context) (*Message, error) {
+	msg, err := q.Queue.Pop(ctx)
+	if err == nil {
+		q.metrics.dequeued.WithLabelValues(q.name).Inc()
+	}
+	return msg, err
+}
+
+func (q *instrumentedQueue) Receive(ctx context.Context) (*Message, error) {
+	msg, err := q.Queue.Receive(ctx)
+	if err == nil {
+		q.metrics.dequeued.WithLabelValues(q.name).Inc()
+	}
+	return msg, err
+}
+
+func (q *instrumentedQueue) Nack(ctx context.Context, msg *Message) error {
+	if err := q.Queue.Nack(ctx, msg); err != nil {
+		return err
+	}
+	q.metrics.retries.WithLabelValues(q.name).Inc()
+	return nil
+}
+
+func (q *instrumentedQueue) NackAfter(ctx context.Context, msg *Message, delay time.Duration) error {
+	if err := q.Queue.NackAfter(ctx, msg, delay); err != nil {
+		return err
+	}
+	q.metrics.retries.WithLabelValues(q.name).Inc()
+	return nil
+}
+
+func (q *instrumentedQueue) NackWithError(ctx context.Context, msg *Message, delay time.Duration, cause error) error {
+	if err := q.Queue.NackWithError(ctx, msg, delay, cause); err != nil {
+		return err
+	}
+	q.metrics.retries.WithLabelValues(q.name).Inc()
+	return nil
+}
@@ -0,0 +1,49 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	m := NewMetrics()
+
+	opts := NewOptions()
+	opts.MaxAttempts = 1
+	opts.OnDeadLetter = m.OnDeadLetter("jobs")
+	mq, err := NewMemoryQueue("jobs", opts)
+	assert.NoError(t, err)
+	q := m.Queue("jobs", mq)
+
+	assert.NoError(t, q.Push(ctx, &Message{Body: []byte("a")}))
+	assert.NoError(t, q.PushBatch(ctx, []*Message{{Body: []byte("b")}, {Body: []byte("c")}}))
+	_, err = q.Pop(ctx)
+	assert.NoError(t, err)
+	msg, err := q.Receive(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, q.Nack(ctx, msg))
+
+	handler := m.Handler("jobs", func(ctx context.Context, msg *Message) error {
+		return errors.New("boom")
+	})
+	assert.Error(t, handler(ctx, msg))
+
+	assert.Equal(t, float64(3), testutil.ToFloat64(m.enqueued))
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.dequeued))
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.retries))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.deadLettered))
+
+	expected := `
+# HELP queue_depth Number of messages in the queue.
+# TYPE queue_depth gauge
+queue_depth{queue="jobs"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(m, strings.NewReader(expected), "queue_depth"))
+	assert.Equal(t, 1, testutil.CollectAndCount(m, "queue_handler_duration_seconds"))
+}
@@ -0,0 +1,258 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultOutboxTable is the outbox table used when none is given
+const DefaultOutboxTable = "queue_outbox"
+
+// OutboxMessage is a row of the outbox table
+type OutboxMessage struct {
+	ID        uint64 `gorm:"primaryKey;autoIncrement"`
+	Queue     string `gorm:"size:255;index"`
+	MessageID string `gorm:"size:255"`
+	Body      []byte
+	Metadata  string
+	Timestamp time.Time
+	CreatedAt time.Time
+}
+
+// Outbox implements the transactional outbox pattern on GORM: Send stores a
+// message in the caller's transaction, so it is only published if the
+// transaction commits, and the relay pushes committed rows to their queue.
+// Delivery is at-least-once, a crash between pushing and deleting a row
+// publishes it again.
+type Outbox struct {
+	db    *gorm.DB
+	table string
+	opts  *Options
+
+	mu     sync.RWMutex
+	queues map[string]Queue
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewOutbox creates an outbox stored in table, creating the table if needed.
+// The relay publishes up to BatchSize rows every PollInterval.
+func NewOutbox(db *gorm.DB, table string, opts *Options) (*Outbox, error) {
+	if table == "" {
+		table = DefaultOutboxTable
+	}
+	if opts == nil {
+		opts = NewOptions()
+	}
+
+	if err := db.Table(table).AutoMigrate(&OutboxMessage{}); err != nil {
+		return nil, fmt.Errorf("failed to create outbox table: %w", err)
+	}
+
+	return &Outbox{
+		db:     db,
+		table:  table,
+		opts:   opts,
+		queues: make(map[string]Queue),
+	}, nil
+}
+
+// Register sets the queue the messages sent to name are published to
+func (o *Outbox) Register(name string, q Queue) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.queues[name] = q
+}
+
+// Send stores msg for the queue registered as name, as part of tx
+func (o *Outbox) Send(tx *gorm.DB, name string, msg *Message) error {
+	if msg == nil {
+		return errors.New("message is nil")
+	}
+
+	metadata, err := json.Marshal(msg.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	timestamp := msg.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	row := &OutboxMessage{
+		Queue:     name,
+		MessageID: msg.ID,
+		Body:      msg.Body,
+		Metadata:  string(metadata),
+		Timestamp: timestamp,
+	}
+	if err := tx.Table(o.table).Create(row).Error; err != nil {
+		return fmt.Errorf("failed to write outbox: %w", err)
+	}
+	return nil
+}
+
+// Relay publishes up to BatchSize committed rows to their queues, oldest
+// first, and returns how many were published. Rows of unregistered queues
+// are left in the outbox.
+func (o *Outbox) Relay(ctx context.Context) (int, error) {
+	o.mu.RLock()
+	names := make([]string, 0, len(o.queues))
+	for name := range o.queues {
+		names = append(names, name)
+	}
+	o.mu.RUnlock()
+
+	if len(names) == 0 {
+		return 0, nil
+	}
+	sort.Strings(names)
+
+	published := 0
+	var pushErr error
+	err := o.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// SKIP LOCKED lets several relays share the outbox, where supported
+		var rows []OutboxMessage
+		err := tx.Table(o.table).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("queue IN ?", names).
+			Order("id").
+			Limit(max(o.opts.BatchSize, 1)).
+			Find(&rows).Error
+		if err != nil {
+			return fmt.Errorf("failed to read outbox: %w", err)
+		}
+
+		// Push each queue's rows in order, stopping at the first failure
+		// so the remaining rows are retried later
+		var ids []uint64
+		for _, group := range partitions(rows, func(r OutboxMessage) string { return r.Queue }) {
+			msgs := make([]*Message, 0, len(group))
+			for _, row := range group {
+				msg, err := row.message()
+				if err != nil {
+					return err
+				}
+				msgs = append(msgs, msg)
+			}
+
+			if err := o.queue(group[0].Queue).PushBatch(ctx, msgs); err != nil {
+				pushErr = fmt.Errorf("failed to publish outbox messages: %w", err)
+				break
+			}
+			for _, row := range group {
+				ids = append(ids, row.ID)
+			}
+		}
+
+		if len(ids) > 0 {
+			if err := tx.Table(o.table).Where("id IN ?", ids).Delete(&OutboxMessage{}).Error; err != nil {
+				return fmt.Errorf("failed to delete outbox rows: %w", err)
+			}
+		}
+		published = len(ids)
+		return nil
+	})
+	if err != nil {
+		// Rolled back, the pushed rows will be published again
+		return 0, err
+	}
+	return published, pushErr
+}
+
+// Start relays committed rows every PollInterval in the background
+func (o *Outbox) Start(ctx context.Context) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.cancel != nil {
+		return errors.New("outbox relay already started")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	o.cancel = cancel
+
+	o.wg.Add(1)
+	go o.run(ctx)
+	return nil
+}
+
+// Stop stops the relay and waits for the running batch
+func (o *Outbox) Stop(ctx context.Context) error {
+	o.mu.Lock()
+	cancel := o.cancel
+	o.cancel = nil
+	o.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		o.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (o *Outbox) run(ctx context.Context) {
+	defer o.wg.Done()
+
+	interval := o.opts.PollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// Drain full batches right away, wait once the outbox is empty
+		n, err := o.Relay(ctx)
+		if err == nil && n >= max(o.opts.BatchSize, 1) {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (o *Outbox) queue(name string) Queue {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.queues[name]
+}
+
+// message decodes the row into a Message
+func (r *OutboxMessage) message() (*Message, error) {
+	msg := &Message{
+		ID:        r.MessageID,
+		Body:      r.Body,
+		Timestamp: r.Timestamp,
+	}
+	if r.Metadata != "" {
+		if err := json.Unmarshal([]byte(r.Metadata), &msg.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to decode metadata: %w", err)
+		}
+	}
+	return msg, nil
+}
@@ -0,0 +1,71 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestOutbox(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "outbox.db")), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	assert.NoError(t, err)
+
+	opts := NewOptions()
+	opts.BatchSize = 2
+	outbox, err := NewOutbox(db, "", opts)
+	assert.NoError(t, err)
+
+	q, err := NewMemoryQueue("emails", nil)
+	assert.NoError(t, err)
+	outbox.Register("emails", q)
+
+	// Only committed messages are relayed
+	err = db.Transaction(func(tx *gorm.DB) error {
+		assert.NoError(t, outbox.Send(tx, "emails", &Message{Body: []byte("rolled back")}))
+		return errors.New("abort")
+	})
+	assert.Error(t, err)
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, body := range []string{"a", "b", "c"} {
+			msg := &Message{Body: []byte(body), Metadata: map[string]string{"k": body}}
+			if err := outbox.Send(tx, "emails", msg); err != nil {
+				return err
+			}
+		}
+		return outbox.Send(tx, "unregistered", &Message{Body: []byte("x")})
+	})
+	assert.NoError(t, err)
+
+	n, err := outbox.Relay(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	n, err = outbox.Relay(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	n, err = outbox.Relay(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	for _, body := range []string{"a", "b", "c"} {
+		msg, err := q.Pop(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, body, string(msg.Body))
+		assert.Equal(t, body, msg.Metadata["k"])
+	}
+	_, err = q.Pop(ctx)
+	assert.ErrorIs(t, err, ErrQueueEmpty)
+
+	var remaining int64
+	assert.NoError(t, db.Table(DefaultOutboxTable).Count(&remaining).Error)
+	assert.Equal(t, int64(1), remaining)
+}
@@ -0,0 +1,142 @@
+package queue
+
+import (
+	"slices"
+	"sort"
+	"sync"
+)
+
+// MetadataPartitionKey is the metadata key routing a message to a partition.
+// Messages with the same key are handled one at a time, in order.
+const MetadataPartitionKey = "partition_key"
+
+// partitions groups items by partition key, keeping their order. Items
+// without a key are not ordered and get a group of their own.
+func partitions[T any](items []T, key func(T) string) [][]T {
+	var groups [][]T
+	index := make(map[string]int)
+	for _, item := range items {
+		k := key(item)
+		if k == "" {
+			groups = append(groups, []T{item})
+			continue
+		}
+		if i, ok := index[k]; ok {
+			groups[i] = append(groups[i], item)
+			continue
+		}
+		index[k] = len(groups)
+		groups = append(groups, []T{item})
+	}
+	return groups
+}
+
+// runPartitions calls fn for every group on up to workers goroutines and
+// waits for them to return
+func runPartitions[T any](workers int, groups [][]T, fn func(group []T)) {
+	if workers <= 1 || len(groups) == 1 {
+		for _, group := range groups {
+			fn(group)
+		}
+		return
+	}
+
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for _, group := range groups {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(group)
+		}()
+	}
+	wg.Wait()
+}
+
+// partitionKey returns the partition key of msg
+func partitionKey(msg *Message) string {
+	return msg.Metadata[MetadataPartitionKey]
+}
+
+// partitionLocks keeps the messages of a partition key in order across
+// batches. A key is taken while a group of its messages is handled. A failed
+// message and the later messages held back behind it wait, oldest first,
+// until they are handled, acked or dead-lettered, and no message of the key
+// is handled ahead of an older one still waiting.
+type partitionLocks struct {
+	// less orders receipts by push order
+	less func(a, b string) bool
+
+	mu      sync.Mutex
+	busy    map[string]bool
+	waiting map[string][]string
+}
+
+// acquire takes key, unless another group of the key is being handled
+func (l *partitionLocks) acquire(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.busy[key] {
+		return false
+	}
+	if l.busy == nil {
+		l.busy = make(map[string]bool)
+	}
+	l.busy[key] = true
+	return true
+}
+
+// release returns key
+func (l *partitionLocks) release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.busy, key)
+}
+
+// ahead returns the oldest waiting message of key pushed before receipt, if any
+func (l *partitionLocks) ahead(key, receipt string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if waiting := l.waiting[key]; len(waiting) > 0 && l.less(waiting[0], receipt) {
+		return waiting[0], true
+	}
+	return "", false
+}
+
+// wait records messages of key that wait to be handled again
+func (l *partitionLocks) wait(key string, msgs ...*Message) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	waiting := l.waiting[key]
+	for _, msg := range msgs {
+		i := sort.Search(len(waiting), func(i int) bool { return !l.less(waiting[i], msg.receipt) })
+		if i < len(waiting) && waiting[i] == msg.receipt {
+			continue
+		}
+		waiting = slices.Insert(waiting, i, msg.receipt)
+	}
+	if l.waiting == nil {
+		l.waiting = make(map[string][]string)
+	}
+	l.waiting[key] = waiting
+}
+
+// done forgets a waiting message of key once it is handled or has left the queue
+func (l *partitionLocks) done(key, receipt string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	waiting := slices.DeleteFunc(l.waiting[key], func(r string) bool { return r == receipt })
+	if len(waiting) == 0 {
+		delete(l.waiting, key)
+		return
+	}
+	l.waiting[key] = waiting
+}
@@ -0,0 +1,87 @@
+package queue
+
+import (
+	"context"
+	"sync"
+)
+
+// pauser gates the loops of a consumer, see Consumer.Pause
+type pauser struct {
+	mu      sync.Mutex
+	paused  bool
+	resumed chan struct{}
+	busy    int
+	idle    chan struct{}
+}
+
+// acquire blocks while paused, then marks a handler in-flight until release
+func (p *pauser) acquire(ctx context.Context) error {
+	for {
+		p.mu.Lock()
+		if !p.paused {
+			p.busy++
+			p.mu.Unlock()
+			return nil
+		}
+		resumed := p.resumed
+		p.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-resumed:
+		}
+	}
+}
+
+func (p *pauser) release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.busy--
+	if p.busy == 0 && p.idle != nil {
+		close(p.idle)
+		p.idle = nil
+	}
+}
+
+// pause stops new acquires and waits for the in-flight ones to be released
+func (p *pauser) pause(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.paused {
+		p.paused = true
+		p.resumed = make(chan struct{})
+	}
+	if p.busy == 0 {
+		p.mu.Unlock()
+		return nil
+	}
+	if p.idle == nil {
+		p.idle = make(chan struct{})
+	}
+	idle := p.idle
+	p.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-idle:
+		return nil
+	}
+}
+
+func (p *pauser) resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.paused {
+		p.paused = false
+		close(p.resumed)
+	}
+}
+
+func (p *pauser) isPaused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.paused
+}
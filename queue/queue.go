@@ -2,7 +2,52 @@ package queue
 
 import (
 	"context"
+	"errors"
 	"time"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrQueueEmpty is returned when there is no message to read
+	ErrQueueEmpty = errors.New("queue is empty")
+
+	// ErrQueueFull is returned when pushing to a queue holding MaxSize
+	// messages, see Backpressure
+	ErrQueueFull = errors.New("queue is full")
+
+	// ErrNotReceived is returned when acking a message that was not returned by Receive
+	ErrNotReceived = errors.New("message was not received from this queue")
+
+	// ErrMessageExpired is the dead-letter cause of messages whose TTL has passed
+	ErrMessageExpired = errors.New("message expired")
+
+	// errNacked is the dead-letter cause of messages released with Nack
+	errNacked = errors.New("message was nacked")
+)
+
+// Metadata keys set on messages moved to a dead-letter queue
+const (
+	MetadataDeadLetterError    = "dlq_error"
+	MetadataDeadLetterAttempts = "dlq_attempts"
+	MetadataDeadLetterSource   = "dlq_source"
+	MetadataDeadLetterFailedAt = "dlq_failed_at"
+)
+
+// Backpressure selects what Push does when a queue holds MaxSize messages
+type Backpressure int
+
+const (
+	// BackpressureDropNew rejects the pushed messages with ErrQueueFull
+	BackpressureDropNew Backpressure = iota
+
+	// BackpressureBlock waits for room until the context is done
+	BackpressureBlock
+
+	// BackpressureDropOldest discards the oldest messages not yet delivered
+	// to make room
+	BackpressureDropOldest
 )
 
 // Message represents a queue message
@@ -11,22 +56,49 @@ type Message struct {
 	Body      []byte
 	Metadata  map[string]string
 	Timestamp time.Time
+
+	// receipt identifies the delivery of a received message for Ack and Nack
+	receipt string
 }
 
 // Queue interface defines methods for queue operations
 type Queue interface {
 	// Push adds a message to the queue
 	Push(ctx context.Context, msg *Message) error
-	
-	// Pop retrieves and removes a message from the queue
+
+	// PushBatch adds messages to the queue in a single round trip
+	PushBatch(ctx context.Context, msgs []*Message) error
+
+	// Pop retrieves and removes a message from the queue. The message is
+	// lost if the caller crashes before processing it, see Receive
 	Pop(ctx context.Context) (*Message, error)
-	
+
+	// Receive retrieves a message that stays in the queue until it is
+	// acknowledged with Ack. Messages that are not acknowledged within the
+	// visibility timeout are delivered again (at-least-once)
+	Receive(ctx context.Context) (*Message, error)
+
+	// Ack acknowledges a received message and removes it from the queue
+	Ack(ctx context.Context, msg *Message) error
+
+	// Nack releases a received message so it is delivered again right away
+	Nack(ctx context.Context, msg *Message) error
+
+	// NackAfter releases a received message so it is delivered again once
+	// delay has passed, without burning attempts in the meantime. RedisQueue
+	// caps delay at ClaimMinIdle.
+	NackAfter(ctx context.Context, msg *Message, delay time.Duration) error
+
+	// NackWithError is NackAfter recording cause, instead of a generic nack
+	// error, when the message is dead-lettered
+	NackWithError(ctx context.Context, msg *Message, delay time.Duration, cause error) error
+
 	// Peek retrieves but does not remove a message from the queue
 	Peek(ctx context.Context) (*Message, error)
-	
+
 	// Length returns the number of messages in the queue
 	Length(ctx context.Context) (int64, error)
-	
+
 	// Clear removes all messages from the queue
 	Clear(ctx context.Context) error
 }
@@ -35,22 +107,33 @@ type Queue interface {
 type Consumer interface {
 	// Start starts consuming messages
 	Start(ctx context.Context) error
-	
+
 	// Stop stops consuming messages
 	Stop(ctx context.Context) error
-	
+
+	// Pause stops handling new messages until Resume, without losing the
+	// consumer's position. It waits for in-flight handlers to return
+	Pause(ctx context.Context) error
+
+	// Resume continues handling messages after Pause
+	Resume(ctx context.Context) error
+
 	// OnMessage is called when a message is received
 	OnMessage(handler func(ctx context.Context, msg *Message) error)
+
+	// OnBatch is called with up to Options.BatchSize messages at a time,
+	// instead of OnMessage. The batch succeeds or fails as a whole
+	OnBatch(handler func(ctx context.Context, msgs []*Message) error)
 }
 
 // Producer interface defines methods for message production
 type Producer interface {
 	// Start starts the producer
 	Start(ctx context.Context) error
-	
+
 	// Stop stops the producer
 	Stop(ctx context.Context) error
-	
+
 	// Send sends a message
 	Send(ctx context.Context, msg *Message) error
 }
@@ -59,18 +142,90 @@ type Producer interface {
 type Options struct {
 	// MaxSize is the maximum number of messages in the queue
 	MaxSize int64
-	
+
+	// Backpressure is what Push does when the queue holds MaxSize messages.
+	// Defaults to BackpressureDropNew
+	Backpressure Backpressure
+
 	// BatchSize is the number of messages to process in a batch
 	BatchSize int
-	
+
 	// PollInterval is the interval between polls
 	PollInterval time.Duration
-	
-	// RetryCount is the number of times to retry failed operations
+
+	// Workers is the number of messages a consumer handles concurrently.
+	// Messages with the same MetadataPartitionKey are handled in order, one
+	// at a time, while different keys are handled in parallel
+	Workers int
+
+	// RetryCount is the number of times to retry failed operations, such as
+	// broker and scheduled handlers. Queue messages are capped by
+	// MaxAttempts instead
 	RetryCount int
-	
+
 	// RetryDelay is the delay between retries
 	RetryDelay time.Duration
+
+	// Logger receives consumer errors such as handler panics. Defaults to
+	// the global logger
+	Logger *logger.Logger
+
+	// Redis options. default addr is localhost:6379
+	RedisOptions *redis.Options
+
+	// Group is the consumer group shared by all replicas. Defaults to the queue name
+	Group string
+
+	// ConsumerName identifies this replica inside the group. Defaults to hostname-pid
+	ConsumerName string
+
+	// ClaimMinIdle is the visibility timeout: how long a message may stay
+	// unacknowledged before it is delivered again, to any consumer. Zero
+	// disables redelivery. On Redis it also caps the delay of NackAfter and
+	// RequeueAfter, and requeued messages wait for the next claim, up to
+	// another ClaimMinIdle
+	ClaimMinIdle time.Duration
+
+	// MemberTimeout is how long a member of a consumer group may stay idle,
+	// with no pending messages, before it is removed from the group. Zero
+	// keeps members until they leave
+	MemberTimeout time.Duration
+
+	// DeadLetterQueue receives messages whose handler failed MaxAttempts
+	// times, with the failure in their metadata. Empty retries forever
+	DeadLetterQueue string
+
+	// OnDeadLetter is called when a message is moved to DeadLetterQueue, or
+	// dropped after MaxAttempts by a MemoryQueue, see Metrics.OnDeadLetter
+	OnDeadLetter func(msg *Message, cause error)
+
+	// OnExpired is called with messages skipped by a consumer because
+	// their TTL has passed, see Message.WithTTL
+	OnExpired func(ctx context.Context, msg *Message)
+
+	// DeadLetterExpired moves expired messages to DeadLetterQueue instead of
+	// dropping them
+	DeadLetterExpired bool
+
+	// MaxAttempts is how many deliveries a message gets before it is moved
+	// to DeadLetterQueue, requeues included. Zero retries forever
+	MaxAttempts int
+
+	// Compression compresses the bodies stored by Redis backends, nil
+	// stores them as is. Consumers decompress them transparently
+	Compression Compressor
+
+	// CompressThreshold is the body size in bytes from which Compression
+	// applies. Defaults to DefaultCompressThreshold
+	CompressThreshold int
+
+	// WALPath is the write-ahead log of a MemoryQueue. When set, messages
+	// survive a restart until they are acknowledged
+	WALPath string
+
+	// WALSync syncs the WAL to disk on every write, so messages also
+	// survive a power loss at the cost of throughput
+	WALSync bool
 }
 
 // NewOptions creates default queue options
@@ -79,7 +234,14 @@ func NewOptions() *Options {
 		MaxSize:      10000,
 		BatchSize:    100,
 		PollInterval: time.Second,
+		Workers:      1,
 		RetryCount:   3,
 		RetryDelay:   time.Second,
+		RedisOptions: &redis.Options{
+			Addr: "localhost:6379",
+		},
+		ClaimMinIdle:      30 * time.Second,
+		MemberTimeout:     time.Hour,
+		CompressThreshold: DefaultCompressThreshold,
 	}
 }
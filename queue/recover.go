@@ -0,0 +1,38 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/ducconit/gocore/logger"
+	"go.uber.org/zap"
+)
+
+// ErrHandlerPanic is wrapped by the error of a handler that panicked
+var ErrHandlerPanic = errors.New("handler panicked")
+
+// safeCall runs a handler, turning a panic into an error wrapping
+// ErrHandlerPanic so the message takes the retry and dead-letter path. The
+// panic is logged with its stack.
+func safeCall(opts *Options, name string, fn func() error) (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		err = fmt.Errorf("%w: %v", ErrHandlerPanic, r)
+
+		fields := []zap.Field{
+			zap.String("queue", name),
+			zap.Any("panic", r),
+			zap.ByteString("stack", debug.Stack()),
+		}
+		if opts.Logger != nil {
+			opts.Logger.Error("queue handler panicked", fields...)
+		} else {
+			logger.Error("queue handler panicked", fields...)
+		}
+	}()
+	return fn()
+}
@@ -0,0 +1,71 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+// syncBuffer is a bytes.Buffer safe for the logger and the test goroutine
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestHandlerPanicRecovery(t *testing.T) {
+	ctx := context.Background()
+	var logs syncBuffer
+
+	opts := NewOptions()
+	opts.PollInterval = 5 * time.Millisecond
+	opts.MaxAttempts = 1
+	opts.Logger = logger.New(logger.WithOutput(&logs))
+
+	causes := make(chan error, 1)
+	opts.OnDeadLetter = func(msg *Message, cause error) {
+		causes <- cause
+	}
+
+	q, err := NewMemoryQueue("jobs", opts)
+	assert.NoError(t, err)
+
+	handled := make(chan string, 1)
+	c := NewMemoryConsumer(q)
+	c.OnMessage(func(ctx context.Context, msg *Message) error {
+		if string(msg.Body) == "bad" {
+			panic("nil map")
+		}
+		handled <- string(msg.Body)
+		return nil
+	})
+	assert.NoError(t, c.Start(ctx))
+	defer c.Stop(ctx)
+
+	assert.NoError(t, q.Push(ctx, &Message{Body: []byte("bad")}))
+	assert.ErrorIs(t, <-causes, ErrHandlerPanic)
+
+	// The consumer keeps going
+	assert.NoError(t, q.Push(ctx, &Message{Body: []byte("good")}))
+	assert.Equal(t, "good", <-handled)
+
+	assert.Contains(t, logs.String(), "queue handler panicked")
+	assert.Contains(t, logs.String(), "nil map")
+	assert.Contains(t, logs.String(), "stack")
+}
@@ -0,0 +1,1144 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Stream entry field names
+const (
+	fieldID        = "id"
+	fieldBody      = "body"
+	fieldMetadata  = "metadata"
+	fieldTimestamp = "timestamp"
+)
+
+// GroupStats describes the state of a consumer group on a stream
+type GroupStats struct {
+	// Stream is the name of the underlying Redis stream
+	Stream string
+
+	// Group is the consumer group name
+	Group string
+
+	// Length is the number of entries still stored in the stream
+	Length int64
+
+	// Pending is the number of delivered but not yet acknowledged entries
+	Pending int64
+
+	// Lag is the number of entries not yet delivered to the group
+	Lag int64
+
+	// Consumers lists the members of the group
+	Consumers []ConsumerStats
+}
+
+// ConsumerStats describes a single member of a consumer group
+type ConsumerStats struct {
+	Name    string
+	Pending int64
+	Idle    time.Duration
+}
+
+// PendingEntry describes a delivered message that has not been acknowledged yet
+type PendingEntry struct {
+	ID         string
+	Consumer   string
+	Idle       time.Duration
+	Deliveries int64
+}
+
+// RedisQueue implements Queue on top of a Redis stream read through a consumer group
+type RedisQueue struct {
+	client   redis.UniversalClient
+	stream   string
+	group    string
+	consumer string
+	opts     *Options
+}
+
+// NewRedisQueue creates a new Redis Streams queue and ensures its consumer group exists
+func NewRedisQueue(name string, opts *Options) (*RedisQueue, error) {
+	if opts == nil {
+		opts = NewOptions()
+	}
+
+	if name == "" {
+		return nil, errors.New("queue name is required")
+	}
+
+	redisOptions := opts.RedisOptions
+	if redisOptions == nil {
+		redisOptions = &redis.Options{Addr: "localhost:6379"}
+	}
+
+	client := redis.NewClient(redisOptions)
+	// Test connection
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, err
+	}
+
+	return NewRedisQueueWithClient(client, name, opts)
+}
+
+// NewRedisQueueWithClient creates a new Redis Streams queue using an existing client
+func NewRedisQueueWithClient(client redis.UniversalClient, name string, opts *Options) (*RedisQueue, error) {
+	if opts == nil {
+		opts = NewOptions()
+	}
+
+	group := opts.Group
+	if group == "" {
+		group = name
+	}
+
+	consumer := opts.ConsumerName
+	if consumer == "" {
+		consumer = defaultConsumerName()
+	}
+
+	q := &RedisQueue{
+		client:   client,
+		stream:   name,
+		group:    group,
+		consumer: consumer,
+		opts:     opts,
+	}
+
+	if err := q.ensureGroup(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// Push adds a message to the stream, see Backpressure for a full stream
+func (q *RedisQueue) Push(ctx context.Context, msg *Message) error {
+	if msg == nil {
+		return errors.New("message is nil")
+	}
+	return q.PushBatch(ctx, []*Message{msg})
+}
+
+// PushBatch adds messages to the stream in one round trip. With MaxSize set,
+// the messages are added together or not at all, unless the Backpressure is
+// BackpressureDropOldest.
+func (q *RedisQueue) PushBatch(ctx context.Context, msgs []*Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	values := make([]map[string]any, len(msgs))
+	for i, msg := range msgs {
+		if msg == nil {
+			return errors.New("message is nil")
+		}
+		v, err := q.encode(msg)
+		if err != nil {
+			return err
+		}
+		values[i] = v
+	}
+
+	var ids []string
+	var err error
+	if q.opts.MaxSize <= 0 || q.opts.Backpressure == BackpressureDropOldest {
+		ids, err = q.add(ctx, values)
+	} else {
+		ids, err = q.addBounded(ctx, values)
+	}
+	if err != nil {
+		return err
+	}
+
+	for i, msg := range msgs {
+		if msg.ID == "" {
+			msg.ID = ids[i]
+		}
+	}
+	return nil
+}
+
+// add pipelines the entries, trimming the stream to about MaxSize
+func (q *RedisQueue) add(ctx context.Context, values []map[string]any) ([]string, error) {
+	cmds := make([]*redis.StringCmd, len(values))
+	_, err := q.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, v := range values {
+			args := &redis.XAddArgs{
+				Stream: q.stream,
+				Values: v,
+			}
+			if q.opts.MaxSize > 0 {
+				args.MaxLen = q.opts.MaxSize
+				args.Approx = true
+			}
+			cmds[i] = pipe.XAdd(ctx, args)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to push messages: %w", err)
+	}
+
+	ids := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		ids[i] = cmd.Val()
+	}
+	return ids, nil
+}
+
+// boundedAddScript adds the entries only if the stream has room for all of them.
+// ARGV holds MaxSize, the entry count, then per entry its field count and fields.
+var boundedAddScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local n = tonumber(ARGV[2])
+if redis.call('XLEN', KEYS[1]) + n > limit then
+	return false
+end
+local ids = {}
+local i = 3
+for m = 1, n do
+	local count = tonumber(ARGV[i])
+	ids[m] = redis.call('XADD', KEYS[1], '*', unpack(ARGV, i + 1, i + count))
+	i = i + count + 1
+end
+return ids
+`)
+
+// addBounded adds the entries atomically once the stream has room, failing
+// with ErrQueueFull or waiting for room with BackpressureBlock
+func (q *RedisQueue) addBounded(ctx context.Context, values []map[string]any) ([]string, error) {
+	if int64(len(values)) > q.opts.MaxSize {
+		return nil, ErrQueueFull
+	}
+
+	args := []any{q.opts.MaxSize, len(values)}
+	for _, v := range values {
+		args = append(args, 2*len(v))
+		for field, value := range v {
+			args = append(args, field, value)
+		}
+	}
+
+	interval := q.opts.PollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	for {
+		ids, err := boundedAddScript.Run(ctx, q.client, []string{q.stream}, args...).StringSlice()
+		if err == nil {
+			return ids, nil
+		}
+		if !errors.Is(err, redis.Nil) {
+			return nil, fmt.Errorf("failed to push messages: %w", err)
+		}
+		if q.opts.Backpressure != BackpressureBlock {
+			return nil, ErrQueueFull
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Pop reads the next message for this consumer, acknowledges it and removes it from the stream
+func (q *RedisQueue) Pop(ctx context.Context) (*Message, error) {
+	entries, err := q.read(ctx, 1, -1)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrQueueEmpty
+	}
+
+	if err := q.ack(ctx, entries[0].ID); err != nil {
+		return nil, err
+	}
+	return decodeMessage(entries[0])
+}
+
+// Receive returns the next message for this consumer without acknowledging
+// it. Messages left unacknowledged for ClaimMinIdle are returned first.
+func (q *RedisQueue) Receive(ctx context.Context) (*Message, error) {
+	var entries []redis.XMessage
+	if q.opts.ClaimMinIdle > 0 {
+		claimed, _, err := q.claim(ctx, "0-0", 1)
+		if err != nil {
+			return nil, err
+		}
+		entries = claimed
+	}
+	if len(entries) == 0 {
+		read, err := q.read(ctx, 1, -1)
+		if err != nil {
+			return nil, err
+		}
+		entries = read
+	}
+	if len(entries) == 0 {
+		return nil, ErrQueueEmpty
+	}
+
+	msg, err := decodeMessage(entries[0])
+	if err != nil {
+		// Malformed entries can never succeed, drop them
+		_ = q.ack(ctx, entries[0].ID)
+		return nil, err
+	}
+	msg.receipt = entries[0].ID
+	return msg, nil
+}
+
+// Subscribe returns a channel of messages received as this group member,
+// polled every PollInterval and closed once ctx is done. Each delivery must
+// be acknowledged with Ack or released with Nack; expired messages are skipped.
+func (q *RedisQueue) Subscribe(ctx context.Context) (<-chan *Delivery, error) {
+	// Fail early when Redis is unreachable
+	if err := q.ensureGroup(ctx); err != nil {
+		return nil, err
+	}
+
+	expire := func(ctx context.Context, msg *Message) error {
+		return q.expire(ctx, msg.receipt, msg)
+	}
+	return subscribe(ctx, q, q.opts, nil, expire), nil
+}
+
+// Ack acknowledges a received message and removes it from the stream
+func (q *RedisQueue) Ack(ctx context.Context, msg *Message) error {
+	if msg == nil || msg.receipt == "" {
+		return ErrNotReceived
+	}
+	return q.ack(ctx, msg.receipt)
+}
+
+// Nack makes a received message claimable right away instead of after
+// ClaimMinIdle. Once it has been delivered MaxAttempts times it is moved to
+// the DeadLetterQueue instead.
+func (q *RedisQueue) Nack(ctx context.Context, msg *Message) error {
+	return q.NackAfter(ctx, msg, 0)
+}
+
+// NackAfter makes a received message claimable once delay has passed. The
+// delay is capped at ClaimMinIdle, the visibility timeout: the entry stays
+// pending in place, so it keeps its position among the messages of its
+// partition key, and is picked up by the next claim, up to another
+// ClaimMinIdle later. Once it has been delivered MaxAttempts times it is
+// moved to the DeadLetterQueue instead.
+func (q *RedisQueue) NackAfter(ctx context.Context, msg *Message, delay time.Duration) error {
+	return q.NackWithError(ctx, msg, delay, errNacked)
+}
+
+// NackWithError is NackAfter recording cause in the metadata of the message
+// when it is moved to the DeadLetterQueue
+func (q *RedisQueue) NackWithError(ctx context.Context, msg *Message, delay time.Duration, cause error) error {
+	if msg == nil || msg.receipt == "" {
+		return ErrNotReceived
+	}
+	if cause == nil {
+		cause = errNacked
+	}
+	return q.requeue(ctx, msg.receipt, msg, delay, cause)
+}
+
+// requeue makes a pending entry claimable after delay, at most ClaimMinIdle,
+// or moves it to the dead-letter queue with cause once it has run out of
+// attempts
+func (q *RedisQueue) requeue(ctx context.Context, id string, msg *Message, delay time.Duration, cause error) error {
+	moved, err := q.deadLetter(ctx, id, msg, cause)
+	if err != nil || moved {
+		return err
+	}
+
+	// Set the idle time so the entry reaches ClaimMinIdle after delay, JUSTID
+	// keeps the delivery count
+	idle := max(q.opts.ClaimMinIdle-delay, 0)
+	err = q.client.Do(ctx, "XCLAIM", q.stream, q.group, q.consumer, 0, id,
+		"IDLE", idle.Milliseconds(), "JUSTID").Err()
+	if err != nil {
+		return fmt.Errorf("failed to nack message: %w", err)
+	}
+	return nil
+}
+
+// Peek returns the next message to be delivered to the group without consuming it
+func (q *RedisQueue) Peek(ctx context.Context) (*Message, error) {
+	info, err := q.groupInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := q.client.XRangeN(ctx, q.stream, "("+info.LastDeliveredID, "+", 1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to peek message: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, ErrQueueEmpty
+	}
+	return decodeMessage(entries[0])
+}
+
+// Length returns the number of messages stored in the stream, including pending ones
+func (q *RedisQueue) Length(ctx context.Context) (int64, error) {
+	return q.client.XLen(ctx, q.stream).Result()
+}
+
+// Clear removes the stream and recreates an empty consumer group
+func (q *RedisQueue) Clear(ctx context.Context) error {
+	if err := q.client.Del(ctx, q.stream).Err(); err != nil {
+		return fmt.Errorf("failed to clear queue: %w", err)
+	}
+	return q.ensureGroup(ctx)
+}
+
+// Stats returns pending-entry and lag information for the consumer group
+func (q *RedisQueue) Stats(ctx context.Context) (*GroupStats, error) {
+	info, err := q.groupInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := q.Length(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	consumers, err := q.client.XInfoConsumers(ctx, q.stream, q.group).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read consumers: %w", err)
+	}
+
+	stats := &GroupStats{
+		Stream:    q.stream,
+		Group:     q.group,
+		Length:    length,
+		Pending:   info.Pending,
+		Lag:       info.Lag,
+		Consumers: make([]ConsumerStats, 0, len(consumers)),
+	}
+	for _, c := range consumers {
+		stats.Consumers = append(stats.Consumers, ConsumerStats{
+			Name:    c.Name,
+			Pending: c.Pending,
+			Idle:    c.Idle,
+		})
+	}
+	return stats, nil
+}
+
+// PendingEntries lists up to count unacknowledged messages, oldest first
+func (q *RedisQueue) PendingEntries(ctx context.Context, count int64) ([]PendingEntry, error) {
+	pending, err := q.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: q.stream,
+		Group:  q.group,
+		Start:  "-",
+		End:    "+",
+		Count:  count,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pending entries: %w", err)
+	}
+
+	entries := make([]PendingEntry, 0, len(pending))
+	for _, p := range pending {
+		entries = append(entries, PendingEntry{
+			ID:         p.ID,
+			Consumer:   p.Consumer,
+			Idle:       p.Idle,
+			Deliveries: p.RetryCount,
+		})
+	}
+	return entries, nil
+}
+
+// Group returns the consumer group name
+func (q *RedisQueue) Group() string {
+	return q.group
+}
+
+// ConsumerName returns the name this replica uses inside the group
+func (q *RedisQueue) ConsumerName() string {
+	return q.consumer
+}
+
+func (q *RedisQueue) ensureGroup(ctx context.Context) error {
+	err := q.client.XGroupCreateMkStream(ctx, q.stream, q.group, "0").Err()
+	if err != nil && !isBusyGroup(err) {
+		return fmt.Errorf("failed to create consumer group: %w", err)
+	}
+	return nil
+}
+
+func (q *RedisQueue) groupInfo(ctx context.Context) (*redis.XInfoGroup, error) {
+	groups, err := q.client.XInfoGroups(ctx, q.stream).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read consumer groups: %w", err)
+	}
+	for i := range groups {
+		if groups[i].Name == q.group {
+			return &groups[i], nil
+		}
+	}
+	return nil, fmt.Errorf("consumer group not found: %s", q.group)
+}
+
+// read fetches new entries for this consumer. A negative block returns immediately.
+func (q *RedisQueue) read(ctx context.Context, count int64, block time.Duration) ([]redis.XMessage, error) {
+	streams, err := q.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    q.group,
+		Consumer: q.consumer,
+		Streams:  []string{q.stream, ">"},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read messages: %w", err)
+	}
+
+	var entries []redis.XMessage
+	for _, s := range streams {
+		entries = append(entries, s.Messages...)
+	}
+	return entries, nil
+}
+
+// claim takes over entries that have been pending longer than ClaimMinIdle
+func (q *RedisQueue) claim(ctx context.Context, start string, count int64) ([]redis.XMessage, string, error) {
+	entries, next, err := q.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   q.stream,
+		Group:    q.group,
+		Consumer: q.consumer,
+		MinIdle:  q.opts.ClaimMinIdle,
+		Start:    start,
+		Count:    count,
+	}).Result()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to claim messages: %w", err)
+	}
+	return entries, next, nil
+}
+
+// ack acknowledges entries and removes them from the stream
+func (q *RedisQueue) ack(ctx context.Context, ids ...string) error {
+	_, err := q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAck(ctx, q.stream, q.group, ids...)
+		pipe.XDel(ctx, q.stream, ids...)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to ack messages: %w", err)
+	}
+	return nil
+}
+
+// leave hands this consumer's pending entries over to the most recently
+// active other member, claimable right away, and removes this consumer from
+// the group. Without another member or claiming, the entries stay with this
+// consumer until it comes back.
+func (q *RedisQueue) leave(ctx context.Context) error {
+	if q.opts.ClaimMinIdle <= 0 {
+		return nil
+	}
+
+	consumers, err := q.client.XInfoConsumers(ctx, q.stream, q.group).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read consumers: %w", err)
+	}
+	heir := ""
+	var heirIdle time.Duration
+	for _, c := range consumers {
+		if c.Name != q.consumer && (heir == "" || c.Idle < heirIdle) {
+			heir, heirIdle = c.Name, c.Idle
+		}
+	}
+	if heir == "" {
+		return nil
+	}
+
+	for {
+		pending, err := q.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream:   q.stream,
+			Group:    q.group,
+			Start:    "-",
+			End:      "+",
+			Count:    int64(max(q.opts.BatchSize, 1)),
+			Consumer: q.consumer,
+		}).Result()
+		if err != nil {
+			return fmt.Errorf("failed to read pending entries: %w", err)
+		}
+		if len(pending) == 0 {
+			break
+		}
+
+		// IDLE makes the entries claimable by the next claim of any member
+		args := []any{"XCLAIM", q.stream, q.group, heir, 0}
+		for _, p := range pending {
+			args = append(args, p.ID)
+		}
+		args = append(args, "IDLE", q.opts.ClaimMinIdle.Milliseconds(), "JUSTID")
+		if err := q.client.Do(ctx, args...).Err(); err != nil {
+			return fmt.Errorf("failed to hand over pending entries: %w", err)
+		}
+	}
+
+	if err := q.client.XGroupDelConsumer(ctx, q.stream, q.group, q.consumer).Err(); err != nil {
+		return fmt.Errorf("failed to leave consumer group: %w", err)
+	}
+	return nil
+}
+
+// pruneMembers removes members idle for MemberTimeout with nothing pending,
+// e.g. replicas that crashed or were scaled down
+func (q *RedisQueue) pruneMembers(ctx context.Context) error {
+	if q.opts.MemberTimeout <= 0 {
+		return nil
+	}
+
+	consumers, err := q.client.XInfoConsumers(ctx, q.stream, q.group).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read consumers: %w", err)
+	}
+	for _, c := range consumers {
+		if c.Name == q.consumer || c.Pending > 0 || c.Idle < q.opts.MemberTimeout {
+			continue
+		}
+		if err := q.client.XGroupDelConsumer(ctx, q.stream, q.group, c.Name).Err(); err != nil {
+			return fmt.Errorf("failed to remove consumer: %w", err)
+		}
+	}
+	return nil
+}
+
+// pendingEntry returns the pending entry id, nil once it has been acknowledged
+func (q *RedisQueue) pendingEntry(ctx context.Context, id string) (*redis.XPendingExt, error) {
+	pending, err := q.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: q.stream,
+		Group:  q.group,
+		Start:  id,
+		End:    id,
+		Count:  1,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pending entry: %w", err)
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+	return &pending[0], nil
+}
+
+// deliveries returns how often an entry has been delivered to the group
+func (q *RedisQueue) deliveries(ctx context.Context, id string) (int64, error) {
+	pending, err := q.pendingEntry(ctx, id)
+	if err != nil || pending == nil {
+		return 0, err
+	}
+	return pending.RetryCount, nil
+}
+
+// streamIDLess orders stream entry IDs, written as milliseconds-sequence
+func streamIDLess(a, b string) bool {
+	aMs, aSeq, _ := strings.Cut(a, "-")
+	bMs, bSeq, _ := strings.Cut(b, "-")
+	x, _ := strconv.ParseUint(aMs, 10, 64)
+	y, _ := strconv.ParseUint(bMs, 10, 64)
+	if x != y {
+		return x < y
+	}
+	x, _ = strconv.ParseUint(aSeq, 10, 64)
+	y, _ = strconv.ParseUint(bSeq, 10, 64)
+	return x < y
+}
+
+// holdBack leaves received messages pending without counting their delivery,
+// claimable together with the entry blocker, which the claim returns first,
+// or after ClaimMinIdle without a blocker
+func (q *RedisQueue) holdBack(ctx context.Context, blocker string, msgs []*Message) {
+	var idle time.Duration
+	if blocker != "" {
+		if pending, err := q.pendingEntry(ctx, blocker); err == nil && pending != nil {
+			idle = pending.Idle
+		}
+	}
+
+	for _, msg := range msgs {
+		deliveries, err := q.deliveries(ctx, msg.receipt)
+		if err != nil || deliveries == 0 {
+			continue
+		}
+		_ = q.client.Do(ctx, "XCLAIM", q.stream, q.group, q.consumer, 0, msg.receipt,
+			"IDLE", idle.Milliseconds(), "RETRYCOUNT", deliveries-1, "JUSTID").Err()
+	}
+}
+
+// deadLetter moves a failed entry to the dead-letter queue once it has been
+// delivered MaxAttempts times, and reports whether it did
+func (q *RedisQueue) deadLetter(ctx context.Context, id string, msg *Message, cause error) (bool, error) {
+	if q.opts.DeadLetterQueue == "" || q.opts.MaxAttempts <= 0 {
+		return false, nil
+	}
+
+	attempts, err := q.deliveries(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if attempts < int64(q.opts.MaxAttempts) {
+		return false, nil
+	}
+	return true, q.moveToDeadLetter(ctx, id, msg, cause, attempts)
+}
+
+// expire drops an expired entry, or moves it to the dead-letter queue with
+// DeadLetterExpired, after calling OnExpired
+func (q *RedisQueue) expire(ctx context.Context, id string, msg *Message) error {
+	if q.opts.OnExpired != nil {
+		q.opts.OnExpired(ctx, msg)
+	}
+	if !q.opts.DeadLetterExpired || q.opts.DeadLetterQueue == "" {
+		return q.ack(ctx, id)
+	}
+
+	attempts, err := q.deliveries(ctx, id)
+	if err != nil {
+		return err
+	}
+	return q.moveToDeadLetter(ctx, id, msg, ErrMessageExpired, attempts)
+}
+
+// moveToDeadLetter adds the entry to the dead-letter queue with the failure
+// in its metadata and acks it
+func (q *RedisQueue) moveToDeadLetter(ctx context.Context, id string, msg *Message, cause error, attempts int64) error {
+	dead := *msg
+	dead.Metadata = make(map[string]string, len(msg.Metadata)+4)
+	for k, v := range msg.Metadata {
+		dead.Metadata[k] = v
+	}
+	dead.Metadata[MetadataDeadLetterError] = cause.Error()
+	dead.Metadata[MetadataDeadLetterAttempts] = strconv.FormatInt(attempts, 10)
+	dead.Metadata[MetadataDeadLetterSource] = q.stream
+	dead.Metadata[MetadataDeadLetterFailedAt] = time.Now().UTC().Format(time.RFC3339Nano)
+
+	values, err := q.encode(&dead)
+	if err != nil {
+		return err
+	}
+
+	// Add before acking, a failure in between duplicates rather than loses the message
+	err = q.client.XAdd(ctx, &redis.XAddArgs{Stream: q.opts.DeadLetterQueue, Values: values}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to move message to dead-letter queue: %w", err)
+	}
+	if q.opts.OnDeadLetter != nil {
+		q.opts.OnDeadLetter(&dead, cause)
+	}
+	return q.ack(ctx, id)
+}
+
+// RedisConsumer consumes a RedisQueue as a member of its consumer group.
+// Messages whose handler fails stay pending and are claimed again once they
+// have been idle for ClaimMinIdle, by this or any other member of the group,
+// until they are moved to the DeadLetterQueue after MaxAttempts deliveries.
+type RedisConsumer struct {
+	queue        *RedisQueue
+	handler      func(ctx context.Context, msg *Message) error
+	batchHandler func(ctx context.Context, msgs []*Message) error
+
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	pauser pauser
+	locks  partitionLocks
+}
+
+// NewRedisConsumer creates a new consumer reading from the queue's consumer group
+func NewRedisConsumer(q *RedisQueue) *RedisConsumer {
+	return &RedisConsumer{queue: q, locks: partitionLocks{less: streamIDLess}}
+}
+
+// OnMessage sets the message handler
+func (c *RedisConsumer) OnMessage(handler func(ctx context.Context, msg *Message) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handler = handler
+}
+
+// OnBatch sets a handler called with up to BatchSize messages at a time,
+// replacing OnMessage. When it fails, every message of the batch stays pending.
+func (c *RedisConsumer) OnBatch(handler func(ctx context.Context, msgs []*Message) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.batchHandler = handler
+}
+
+// Start starts the read and claim loops in the background
+func (c *RedisConsumer) Start(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.handler == nil && c.batchHandler == nil {
+		return errors.New("message handler is not set")
+	}
+	if c.cancel != nil {
+		return errors.New("consumer already started")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	c.ctx, c.cancel = ctx, cancel
+
+	c.wg.Add(1)
+	go c.readLoop(ctx)
+
+	if c.queue.opts.ClaimMinIdle > 0 {
+		c.wg.Add(1)
+		go c.claimLoop(ctx)
+	}
+
+	return nil
+}
+
+// Stop stops consuming, waits for in-flight handlers to return and leaves
+// the group, handing unacknowledged messages over to another member
+func (c *RedisConsumer) Stop(ctx context.Context) error {
+	c.mu.Lock()
+	cancel := c.cancel
+	c.ctx, c.cancel = nil, nil
+	c.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return c.queue.leave(ctx)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Pause stops reading and claiming messages until Resume, waiting for
+// in-flight handlers. The group keeps the consumer's position.
+func (c *RedisConsumer) Pause(ctx context.Context) error {
+	return c.pauser.pause(ctx)
+}
+
+// Resume continues reading and claiming messages after Pause
+func (c *RedisConsumer) Resume(ctx context.Context) error {
+	c.pauser.resume()
+	return nil
+}
+
+// Health reports whether the read and claim loops are running. They stop once
+// Stop is called or the context given to Start is done.
+func (c *RedisConsumer) Health() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ctx != nil && c.ctx.Err() == nil
+}
+
+// Paused reports whether the consumer is paused
+func (c *RedisConsumer) Paused() bool {
+	return c.pauser.isPaused()
+}
+
+func (c *RedisConsumer) readLoop(ctx context.Context) {
+	defer c.wg.Done()
+
+	for ctx.Err() == nil {
+		if err := c.pauser.acquire(ctx); err != nil {
+			return
+		}
+		entries, err := c.queue.read(ctx, int64(c.queue.opts.BatchSize), c.queue.opts.PollInterval)
+		if err != nil {
+			c.pauser.release()
+			if ctx.Err() != nil {
+				return
+			}
+			c.wait(ctx, c.queue.opts.RetryDelay)
+			continue
+		}
+		c.process(ctx, entries)
+		c.pauser.release()
+	}
+}
+
+func (c *RedisConsumer) claimLoop(ctx context.Context) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.queue.opts.ClaimMinIdle)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		_ = c.queue.pruneMembers(ctx)
+
+		if c.pauser.isPaused() || c.pauser.acquire(ctx) != nil {
+			continue
+		}
+		start := "0-0"
+		for ctx.Err() == nil {
+			entries, next, err := c.queue.claim(ctx, start, int64(c.queue.opts.BatchSize))
+			if err != nil {
+				break
+			}
+			c.process(ctx, entries)
+			if next == "0-0" {
+				break
+			}
+			start = next
+		}
+		c.pauser.release()
+	}
+}
+
+func (c *RedisConsumer) process(ctx context.Context, entries []redis.XMessage) {
+	c.mu.Lock()
+	handler := c.handler
+	batchHandler := c.batchHandler
+	c.mu.Unlock()
+
+	if batchHandler != nil {
+		c.processBatch(ctx, entries, batchHandler)
+		return
+	}
+
+	msgs := make([]*Message, 0, len(entries))
+	for _, entry := range entries {
+		msg, err := decodeMessage(entry)
+		if err != nil {
+			// Malformed entries can never succeed, drop them
+			_ = c.queue.ack(ctx, entry.ID)
+			continue
+		}
+		msg.receipt = entry.ID
+		msgs = append(msgs, msg)
+	}
+
+	runPartitions(c.queue.opts.Workers, partitions(msgs, partitionKey), func(group []*Message) {
+		// Without redelivery a failed message never comes back, so it does
+		// not hold its key
+		key := partitionKey(group[0])
+		if c.queue.opts.ClaimMinIdle <= 0 {
+			key = ""
+		}
+		if key != "" {
+			if !c.locks.acquire(key) {
+				c.holdBack(ctx, key, "", group)
+				return
+			}
+			defer c.locks.release(key)
+		}
+
+		for i, msg := range group {
+			if ctx.Err() != nil {
+				return
+			}
+			if key != "" && c.waitsBehind(ctx, key, group[i:]) {
+				return
+			}
+			if msg.Expired() {
+				_ = c.queue.expire(ctx, msg.receipt, msg)
+				if key != "" {
+					c.locks.done(key, msg.receipt)
+				}
+				continue
+			}
+			err := safeCall(c.queue.opts, c.queue.stream, func() error { return handler(ctx, msg) })
+			if err != nil {
+				// Leave the entry pending so it can be claimed again, unless
+				// it has run out of attempts. The rest of the partition is
+				// held back too, so it is not handled ahead of this one.
+				c.fail(ctx, msg.receipt, msg, err)
+				if key != "" {
+					c.locks.wait(key, msg)
+					c.holdBack(ctx, key, msg.receipt, group[i+1:])
+				}
+				return
+			}
+			_ = c.queue.ack(ctx, msg.receipt)
+			if key != "" {
+				c.locks.done(key, msg.receipt)
+			}
+		}
+	})
+}
+
+// waitsBehind holds msgs back while an older message of key waits to be
+// handled again, and reports whether it did
+func (c *RedisConsumer) waitsBehind(ctx context.Context, key string, msgs []*Message) bool {
+	for {
+		blocker, ok := c.locks.ahead(key, msgs[0].receipt)
+		if !ok {
+			return false
+		}
+		pending, err := c.queue.pendingEntry(ctx, blocker)
+		if err == nil && pending == nil {
+			// Acked or dead-lettered in the meantime
+			c.locks.done(key, blocker)
+			continue
+		}
+		c.holdBack(ctx, key, blocker, msgs)
+		return true
+	}
+}
+
+// holdBack leaves msgs pending behind blocker as waiting messages of key
+func (c *RedisConsumer) holdBack(ctx context.Context, key, blocker string, msgs []*Message) {
+	c.queue.holdBack(ctx, blocker, msgs)
+	c.locks.wait(key, msgs...)
+}
+
+// processBatch hands the decoded entries to handler at once and acks them together
+func (c *RedisConsumer) processBatch(ctx context.Context, entries []redis.XMessage, handler func(ctx context.Context, msgs []*Message) error) {
+	if len(entries) == 0 || ctx.Err() != nil {
+		return
+	}
+
+	msgs := make([]*Message, 0, len(entries))
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		msg, err := decodeMessage(entry)
+		if err != nil {
+			// Malformed entries can never succeed, drop them
+			_ = c.queue.ack(ctx, entry.ID)
+			continue
+		}
+		if msg.Expired() {
+			_ = c.queue.expire(ctx, entry.ID, msg)
+			continue
+		}
+		msgs = append(msgs, msg)
+		ids = append(ids, entry.ID)
+	}
+	if len(msgs) == 0 {
+		return
+	}
+
+	err := safeCall(c.queue.opts, c.queue.stream, func() error { return handler(ctx, msgs) })
+	if err != nil {
+		for i, msg := range msgs {
+			c.fail(ctx, ids[i], msg, err)
+		}
+		return
+	}
+	_ = c.queue.ack(ctx, ids...)
+}
+
+// fail leaves a failed entry pending for the claim loop, requeued after the
+// delay of a RequeueError, or dead-letters it once it has run out of attempts
+func (c *RedisConsumer) fail(ctx context.Context, id string, msg *Message, err error) {
+	if delay := requeueDelay(err); delay > 0 {
+		_ = c.queue.requeue(ctx, id, msg, delay, err)
+		return
+	}
+	_, _ = c.queue.deadLetter(ctx, id, msg, err)
+}
+
+func (c *RedisConsumer) wait(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// encode compresses msg as configured and encodes it into stream fields
+func (q *RedisQueue) encode(msg *Message) (map[string]any, error) {
+	msg, err := compress(q.opts, msg)
+	if err != nil {
+		return nil, err
+	}
+	return encodeMessage(msg)
+}
+
+func encodeMessage(msg *Message) (map[string]any, error) {
+	metadata, err := json.Marshal(msg.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata: %w", err)
+	}
+
+	timestamp := msg.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	return map[string]any{
+		fieldID:        msg.ID,
+		fieldBody:      msg.Body,
+		fieldMetadata:  metadata,
+		fieldTimestamp: timestamp.UnixNano(),
+	}, nil
+}
+
+func decodeMessage(entry redis.XMessage) (*Message, error) {
+	msg := &Message{ID: entry.ID}
+
+	if id, _ := entry.Values[fieldID].(string); id != "" {
+		msg.ID = id
+	}
+	if body, ok := entry.Values[fieldBody].(string); ok {
+		msg.Body = []byte(body)
+	}
+	if metadata, ok := entry.Values[fieldMetadata].(string); ok && metadata != "" {
+		if err := json.Unmarshal([]byte(metadata), &msg.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to decode metadata: %w", err)
+		}
+	}
+	if ts, ok := entry.Values[fieldTimestamp].(string); ok {
+		nanos, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode timestamp: %w", err)
+		}
+		msg.Timestamp = time.Unix(0, nanos)
+	}
+	if err := decompress(msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func isBusyGroup(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP")
+}
+
+func defaultConsumerName() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "consumer"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
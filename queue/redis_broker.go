@@ -0,0 +1,151 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var _ Broker = (*RedisBroker)(nil)
+
+// RedisBroker is a Broker on Redis pub/sub, for events between services.
+// Like Redis pub/sub, messages are only delivered to subscribers connected
+// when they are published.
+type RedisBroker struct {
+	client redis.UniversalClient
+	opts   *Options
+
+	mu     sync.Mutex
+	subs   map[*subscription]struct{}
+	closed bool
+}
+
+// NewRedisBroker creates a new Redis pub/sub broker
+func NewRedisBroker(opts *Options) (*RedisBroker, error) {
+	if opts == nil {
+		opts = NewOptions()
+	}
+
+	redisOptions := opts.RedisOptions
+	if redisOptions == nil {
+		redisOptions = &redis.Options{Addr: "localhost:6379"}
+	}
+
+	client := redis.NewClient(redisOptions)
+	// Test connection
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, err
+	}
+
+	return NewRedisBrokerWithClient(client, opts), nil
+}
+
+// NewRedisBrokerWithClient creates a new Redis pub/sub broker using an existing client
+func NewRedisBrokerWithClient(client redis.UniversalClient, opts *Options) *RedisBroker {
+	if opts == nil {
+		opts = NewOptions()
+	}
+	return &RedisBroker{
+		client: client,
+		opts:   opts,
+		subs:   make(map[*subscription]struct{}),
+	}
+}
+
+// Publish sends msg to the subscribers of topic
+func (b *RedisBroker) Publish(ctx context.Context, topic string, msg *Message) error {
+	if msg == nil {
+		return errors.New("message is nil")
+	}
+
+	b.mu.Lock()
+	closed := b.closed
+	b.mu.Unlock()
+	if closed {
+		return ErrBrokerClosed
+	}
+
+	msg, err := compress(b.opts, msg)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+	if err := b.client.Publish(ctx, topic, payload).Err(); err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+	return nil
+}
+
+// Subscribe returns a consumer of topic, it subscribes to Redis when started
+// and unsubscribes when stopped
+func (b *RedisBroker) Subscribe(topic string) (Consumer, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrBrokerClosed
+	}
+
+	s := newSubscription(topic, b.opts)
+	var pubsub *redis.PubSub
+
+	s.onStart = func(ctx context.Context) error {
+		pubsub = b.client.Subscribe(ctx, topic)
+		// Wait for the subscription to be confirmed
+		if _, err := pubsub.Receive(ctx); err != nil {
+			_ = pubsub.Close()
+			return fmt.Errorf("failed to subscribe: %w", err)
+		}
+
+		s.wg.Add(1)
+		go func(ch <-chan *redis.Message) {
+			defer s.wg.Done()
+			for m := range ch {
+				var msg Message
+				if err := json.Unmarshal([]byte(m.Payload), &msg); err != nil {
+					// Malformed payloads can never succeed, drop them
+					continue
+				}
+				if err := decompress(&msg); err != nil {
+					continue
+				}
+				if err := s.deliver(ctx, &msg); err != nil {
+					return
+				}
+			}
+		}(pubsub.Channel())
+		return nil
+	}
+	s.onStop = func() error {
+		return pubsub.Close()
+	}
+
+	b.subs[s] = struct{}{}
+	return s, nil
+}
+
+// Close stops every subscription, the client is not closed
+func (b *RedisBroker) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	subs := b.subs
+	b.subs = nil
+	b.mu.Unlock()
+
+	var errs []error
+	for s := range subs {
+		errs = append(errs, s.Stop(context.Background()))
+	}
+	return errors.Join(errs...)
+}
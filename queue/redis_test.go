@@ -0,0 +1,518 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestRedisQueue returns a queue on a fresh miniredis server, consuming as consumer
+func newTestRedisQueue(t *testing.T, opts *Options, consumer string) (*RedisQueue, *redis.Client) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	opts.ConsumerName = consumer
+	q, err := NewRedisQueueWithClient(client, "jobs", opts)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return q, client
+}
+
+// member returns another member of the group of q
+func member(t *testing.T, q *RedisQueue, client *redis.Client, consumer string) *RedisQueue {
+	t.Helper()
+	opts := *q.opts
+	opts.ConsumerName = consumer
+	other, err := NewRedisQueueWithClient(client, q.stream, &opts)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return other
+}
+
+// join registers consumer as a member of the group of q, seen now. Unlike
+// Redis, miniredis records neither on an empty XREADGROUP.
+func join(t *testing.T, q *RedisQueue, client *redis.Client, consumer string) {
+	t.Helper()
+	err := client.XClaimJustID(context.Background(), &redis.XClaimArgs{
+		Stream:   q.stream,
+		Group:    q.group,
+		Consumer: consumer,
+		Messages: []string{"0-1"},
+	}).Err()
+	assert.NoError(t, err)
+}
+
+// stringEntry returns the stream entry Redis returns for values, every field as a string
+func stringEntry(id string, values map[string]any) redis.XMessage {
+	entry := redis.XMessage{ID: id, Values: make(map[string]any, len(values))}
+	for k, v := range values {
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		entry.Values[k] = fmt.Sprint(v)
+	}
+	return entry
+}
+
+func TestEncodeDecodeMessage(t *testing.T) {
+	sent := &Message{
+		ID:        "order-1",
+		Body:      []byte(`{"total":42}`),
+		Metadata:  map[string]string{"tenant": "acme"},
+		Timestamp: time.Unix(1700000000, 123),
+	}
+	values, err := encodeMessage(sent)
+	assert.NoError(t, err)
+
+	msg, err := decodeMessage(stringEntry("1-0", values))
+	assert.NoError(t, err)
+	assert.Equal(t, sent.ID, msg.ID)
+	assert.Equal(t, sent.Body, msg.Body)
+	assert.Equal(t, sent.Metadata, msg.Metadata)
+	assert.True(t, sent.Timestamp.Equal(msg.Timestamp))
+
+	// Messages without an ID take the one of the entry, and get a timestamp
+	values, err = encodeMessage(&Message{Body: []byte("a")})
+	assert.NoError(t, err)
+	msg, err = decodeMessage(stringEntry("2-0", values))
+	assert.NoError(t, err)
+	assert.Equal(t, "2-0", msg.ID)
+	assert.WithinDuration(t, time.Now(), msg.Timestamp, time.Second)
+
+	_, err = decodeMessage(redis.XMessage{ID: "3-0", Values: map[string]any{fieldMetadata: "{"}})
+	assert.ErrorContains(t, err, "failed to decode metadata")
+	_, err = decodeMessage(redis.XMessage{ID: "4-0", Values: map[string]any{fieldTimestamp: "yesterday"}})
+	assert.ErrorContains(t, err, "failed to decode timestamp")
+}
+
+func TestRedisQueue(t *testing.T) {
+	ctx := context.Background()
+	q, _ := newTestRedisQueue(t, NewOptions(), "a")
+
+	msg := &Message{Body: []byte("a"), Metadata: map[string]string{"k": "v"}}
+	assert.NoError(t, q.Push(ctx, msg))
+	assert.NotEmpty(t, msg.ID)
+	assert.NoError(t, q.PushBatch(ctx, []*Message{{Body: []byte("b")}, {Body: []byte("c")}}))
+	assert.Error(t, q.Push(ctx, nil))
+
+	length, err := q.Length(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), length)
+
+	peeked, err := q.Peek(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(peeked.Body))
+
+	popped, err := q.Pop(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, msg.ID, popped.ID)
+	assert.Equal(t, map[string]string{"k": "v"}, popped.Metadata)
+	assert.ErrorIs(t, q.Ack(ctx, popped), ErrNotReceived)
+
+	// Received messages stay pending until acked
+	b, err := q.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(b.Body))
+	pending, err := q.PendingEntries(ctx, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, pending, 1) {
+		assert.Equal(t, "a", pending[0].Consumer)
+		assert.Equal(t, int64(1), pending[0].Deliveries)
+	}
+	assert.NoError(t, q.Ack(ctx, b))
+
+	stats, err := q.Stats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "jobs", stats.Group)
+	assert.Equal(t, int64(1), stats.Length)
+	assert.Equal(t, int64(0), stats.Pending)
+	if assert.Len(t, stats.Consumers, 1) {
+		assert.Equal(t, "a", stats.Consumers[0].Name)
+	}
+
+	assert.NoError(t, q.Clear(ctx))
+	_, err = q.Receive(ctx)
+	assert.ErrorIs(t, err, ErrQueueEmpty)
+	_, err = q.Peek(ctx)
+	assert.ErrorIs(t, err, ErrQueueEmpty)
+}
+
+func TestRedisQueue_Backpressure(t *testing.T) {
+	ctx := context.Background()
+	opts := NewOptions()
+	opts.MaxSize = 2
+	opts.PollInterval = 10 * time.Millisecond
+	q, _ := newTestRedisQueue(t, opts, "a")
+
+	// The bounded add is all or nothing
+	assert.NoError(t, q.Push(ctx, &Message{Body: []byte("a")}))
+	assert.ErrorIs(t, q.PushBatch(ctx, []*Message{{Body: []byte("b")}, {Body: []byte("c")}}), ErrQueueFull)
+	assert.ErrorIs(t, q.PushBatch(ctx, []*Message{{}, {}, {}}), ErrQueueFull)
+	assert.NoError(t, q.Push(ctx, &Message{Body: []byte("b")}))
+	assert.ErrorIs(t, q.Push(ctx, &Message{Body: []byte("c")}), ErrQueueFull)
+	length, err := q.Length(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), length)
+
+	// Blocking pushes wait for room
+	opts.Backpressure = BackpressureBlock
+	shortCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.Push(shortCtx, &Message{Body: []byte("c")}), context.DeadlineExceeded)
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		_, _ = q.Pop(ctx)
+	}()
+	assert.NoError(t, q.Push(ctx, &Message{Body: []byte("c")}))
+	length, err = q.Length(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), length)
+}
+
+func TestRedisQueue_Redelivery(t *testing.T) {
+	ctx := context.Background()
+	opts := NewOptions()
+	opts.ClaimMinIdle = 50 * time.Millisecond
+	a, client := newTestRedisQueue(t, opts, "a")
+	b := member(t, a, client, "b")
+
+	assert.NoError(t, a.Push(ctx, &Message{Body: []byte("job")}))
+	first, err := a.Receive(ctx)
+	assert.NoError(t, err)
+
+	// Unacknowledged messages are claimed by any member after ClaimMinIdle
+	_, err = b.Receive(ctx)
+	assert.ErrorIs(t, err, ErrQueueEmpty)
+	time.Sleep(60 * time.Millisecond)
+	claimed, err := b.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, claimed.ID)
+
+	// Nacked messages are claimable right away
+	assert.NoError(t, b.Nack(ctx, claimed))
+	again, err := a.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, again.ID)
+	assert.NoError(t, a.Ack(ctx, again))
+
+	length, err := a.Length(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), length)
+	assert.ErrorIs(t, a.Nack(ctx, &Message{}), ErrNotReceived)
+}
+
+func TestRedisQueue_DeadLetter(t *testing.T) {
+	ctx := context.Background()
+	opts := NewOptions()
+	opts.ClaimMinIdle = 50 * time.Millisecond
+	opts.MaxAttempts = 2
+	opts.DeadLetterQueue = "jobs-dead"
+	var deadLettered []*Message
+	var causes []error
+	opts.OnDeadLetter = func(msg *Message, cause error) {
+		deadLettered = append(deadLettered, msg)
+		causes = append(causes, cause)
+	}
+	q, client := newTestRedisQueue(t, opts, "a")
+	errDown := errors.New("smtp down")
+
+	assert.NoError(t, q.Push(ctx, &Message{Body: []byte("job"), Metadata: map[string]string{"k": "v"}}))
+	for range opts.MaxAttempts {
+		msg, err := q.Receive(ctx)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.NoError(t, q.NackWithError(ctx, msg, 0, errDown))
+	}
+
+	length, err := q.Length(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), length)
+	assert.Len(t, deadLettered, 1)
+	assert.Equal(t, []error{errDown}, causes)
+
+	dlq := member(t, q, client, "a")
+	dlq.stream, dlq.group = opts.DeadLetterQueue, opts.DeadLetterQueue
+	assert.NoError(t, dlq.ensureGroup(ctx))
+	dead, err := dlq.Pop(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "job", string(dead.Body))
+	assert.Equal(t, "v", dead.Metadata["k"])
+	assert.Equal(t, "smtp down", dead.Metadata[MetadataDeadLetterError])
+	// Unlike Redis, miniredis counts the JUSTID claims of Nack as deliveries
+	assert.NotEmpty(t, dead.Metadata[MetadataDeadLetterAttempts])
+	assert.Equal(t, "jobs", dead.Metadata[MetadataDeadLetterSource])
+	assert.NotEmpty(t, dead.Metadata[MetadataDeadLetterFailedAt])
+}
+
+func TestRedisConsumer(t *testing.T) {
+	ctx := context.Background()
+	opts := NewOptions()
+	opts.ClaimMinIdle = 50 * time.Millisecond
+	opts.PollInterval = 10 * time.Millisecond
+	opts.MaxAttempts = 3
+	opts.DeadLetterQueue = "jobs-dead"
+	q, _ := newTestRedisQueue(t, opts, "a")
+
+	var (
+		mu       sync.Mutex
+		attempts = make(map[string]int)
+		handled  = make(chan string, 10)
+	)
+	consumer := NewRedisConsumer(q)
+	assert.Error(t, consumer.Start(ctx))
+	consumer.OnMessage(func(ctx context.Context, msg *Message) error {
+		mu.Lock()
+		attempts[string(msg.Body)]++
+		n := attempts[string(msg.Body)]
+		mu.Unlock()
+
+		// The first attempt fails and is claimed again by the claim loop
+		if n == 1 {
+			return errors.New("transient")
+		}
+		handled <- string(msg.Body)
+		return nil
+	})
+	assert.NoError(t, consumer.Start(ctx))
+	assert.Error(t, consumer.Start(ctx))
+
+	assert.NoError(t, q.Push(ctx, &Message{Body: []byte("job")}))
+	select {
+	case body := <-handled:
+		assert.Equal(t, "job", body)
+	case <-time.After(2 * time.Second):
+		t.Fatal("message not redelivered")
+	}
+
+	assert.Eventually(t, func() bool {
+		length, err := q.Length(ctx)
+		return err == nil && length == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.NoError(t, consumer.Stop(ctx))
+	assert.NoError(t, consumer.Stop(ctx))
+}
+
+func TestRedisConsumer_PartitionsAcrossBatches(t *testing.T) {
+	ctx := context.Background()
+	opts := NewOptions()
+	opts.BatchSize = 1
+	opts.ClaimMinIdle = 50 * time.Millisecond
+	opts.PollInterval = 10 * time.Millisecond
+	opts.MaxAttempts = 3
+	opts.DeadLetterQueue = "jobs-dead"
+	q, _ := newTestRedisQueue(t, opts, "a")
+
+	var (
+		mu      sync.Mutex
+		handled []string
+		failed  bool
+	)
+	consumer := NewRedisConsumer(q)
+	consumer.OnMessage(func(ctx context.Context, msg *Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		// Every read holds a single message, the later ones are read while
+		// the failed one waits for the claim loop
+		if string(msg.Body) == "1" && !failed {
+			failed = true
+			return errors.New("transient")
+		}
+		handled = append(handled, string(msg.Body))
+		return nil
+	})
+
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, q.Push(ctx, &Message{
+			Body:     []byte(fmt.Sprint(i)),
+			Metadata: map[string]string{MetadataPartitionKey: "a"},
+		}))
+	}
+	assert.NoError(t, consumer.Start(ctx))
+
+	assert.Eventually(t, func() bool {
+		length, err := q.Length(ctx)
+		return err == nil && length == 0
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.NoError(t, consumer.Stop(ctx))
+
+	// Held back messages are not dead-lettered for waiting
+	dead, err := q.client.XLen(ctx, "jobs-dead").Result()
+	assert.NoError(t, err)
+	assert.Zero(t, dead)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"0", "1", "2", "3", "4"}, handled)
+}
+
+func TestRedisConsumer_Batch(t *testing.T) {
+	ctx := context.Background()
+	opts := NewOptions()
+	opts.ClaimMinIdle = 0
+	opts.PollInterval = 10 * time.Millisecond
+	q, _ := newTestRedisQueue(t, opts, "a")
+
+	assert.NoError(t, q.PushBatch(ctx, []*Message{{Body: []byte("a")}, {Body: []byte("b")}}))
+
+	batches := make(chan []string, 1)
+	consumer := NewRedisConsumer(q)
+	consumer.OnBatch(func(ctx context.Context, msgs []*Message) error {
+		var bodies []string
+		for _, msg := range msgs {
+			bodies = append(bodies, string(msg.Body))
+		}
+		batches <- bodies
+		return nil
+	})
+	assert.NoError(t, consumer.Start(ctx))
+	defer consumer.Stop(ctx)
+
+	select {
+	case bodies := <-batches:
+		assert.Equal(t, []string{"a", "b"}, bodies)
+	case <-time.After(time.Second):
+		t.Fatal("batch not handled")
+	}
+}
+
+func TestRedisQueue_Leave(t *testing.T) {
+	ctx := context.Background()
+	opts := NewOptions()
+	opts.ClaimMinIdle = time.Minute
+	a, client := newTestRedisQueue(t, opts, "a")
+	b := member(t, a, client, "b")
+
+	assert.NoError(t, a.PushBatch(ctx, []*Message{{Body: []byte("1")}, {Body: []byte("2")}}))
+	_, err := a.Receive(ctx)
+	assert.NoError(t, err)
+	_, err = a.Receive(ctx)
+	assert.NoError(t, err)
+	join(t, a, client, "b")
+
+	// Leaving hands the pending messages over, claimable right away
+	assert.NoError(t, a.leave(ctx))
+	pending, err := b.PendingEntries(ctx, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, pending, 2) {
+		assert.Equal(t, "b", pending[0].Consumer)
+		assert.Equal(t, "b", pending[1].Consumer)
+	}
+	stats, err := b.Stats(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, stats.Consumers, 1) {
+		assert.Equal(t, "b", stats.Consumers[0].Name)
+	}
+
+	claimed, err := b.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", string(claimed.Body))
+}
+
+func TestRedisQueue_PruneMembers(t *testing.T) {
+	ctx := context.Background()
+	opts := NewOptions()
+	opts.MemberTimeout = 50 * time.Millisecond
+	a, client := newTestRedisQueue(t, opts, "a")
+	busy := member(t, a, client, "busy")
+
+	assert.NoError(t, a.Push(ctx, &Message{Body: []byte("job")}))
+	_, err := busy.Receive(ctx)
+	assert.NoError(t, err)
+	join(t, a, client, "idle")
+
+	// Members idle with nothing pending are removed, others are kept
+	time.Sleep(60 * time.Millisecond)
+	assert.NoError(t, a.pruneMembers(ctx))
+	stats, err := a.Stats(ctx)
+	assert.NoError(t, err)
+	var names []string
+	for _, c := range stats.Consumers {
+		names = append(names, c.Name)
+	}
+	assert.ElementsMatch(t, []string{"busy"}, names)
+}
+
+func TestRedisQueue_DropOldest(t *testing.T) {
+	ctx := context.Background()
+	opts := NewOptions()
+	opts.MaxSize = 2
+	opts.Backpressure = BackpressureDropOldest
+	q, _ := newTestRedisQueue(t, opts, "a")
+
+	for _, body := range []string{"a", "b", "c"} {
+		assert.NoError(t, q.Push(ctx, &Message{Body: []byte(body)}))
+	}
+	length, err := q.Length(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), length)
+
+	msg, err := q.Pop(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(msg.Body))
+}
+
+func TestRedisQueue_NackAfter(t *testing.T) {
+	ctx := context.Background()
+	opts := NewOptions()
+	opts.ClaimMinIdle = 200 * time.Millisecond
+	q, _ := newTestRedisQueue(t, opts, "a")
+
+	assert.NoError(t, q.Push(ctx, &Message{Body: []byte("job")}))
+	msg, err := q.Receive(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, q.NackAfter(ctx, msg, 100*time.Millisecond))
+
+	// The message is claimable once the delay has passed, not before
+	_, err = q.Receive(ctx)
+	assert.ErrorIs(t, err, ErrQueueEmpty)
+	time.Sleep(120 * time.Millisecond)
+	again, err := q.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, msg.ID, again.ID)
+}
+
+func TestRedisQueue_Subscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opts := NewOptions()
+	opts.PollInterval = 10 * time.Millisecond
+	opts.DeadLetterQueue = "jobs-dead"
+	opts.DeadLetterExpired = true
+	q, client := newTestRedisQueue(t, opts, "a")
+
+	deliveries, err := q.Subscribe(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, q.Push(ctx, (&Message{Body: []byte("stale")}).WithTTL(-time.Second)))
+	assert.NoError(t, q.Push(ctx, &Message{Body: []byte("fresh")}))
+
+	// Expired messages are moved to the dead-letter queue instead of delivered
+	select {
+	case d := <-deliveries:
+		assert.Equal(t, "fresh", string(d.Body))
+		assert.NoError(t, d.Ack(ctx))
+	case <-time.After(time.Second):
+		t.Fatal("no delivery")
+	}
+
+	dead, err := client.XRange(ctx, opts.DeadLetterQueue, "-", "+").Result()
+	assert.NoError(t, err)
+	if assert.Len(t, dead, 1) {
+		msg, err := decodeMessage(dead[0])
+		assert.NoError(t, err)
+		assert.Equal(t, "stale", string(msg.Body))
+		assert.Equal(t, ErrMessageExpired.Error(), msg.Metadata[MetadataDeadLetterError])
+	}
+}
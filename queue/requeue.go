@@ -0,0 +1,49 @@
+package queue
+
+import (
+	"errors"
+	"time"
+)
+
+// RequeueError is returned by a handler to have its message delivered again
+// after a delay, see RequeueAfter
+type RequeueError struct {
+	Err   error
+	After time.Duration
+}
+
+// RequeueAfter wraps err so the consumer delivers the message again once
+// after has passed, instead of right away. The attempt still counts toward
+// MaxAttempts, err is recorded when the message is dead-lettered. A
+// RedisConsumer caps after at ClaimMinIdle, see RedisQueue.NackAfter.
+func RequeueAfter(after time.Duration, err error) error {
+	if err == nil {
+		err = errors.New("requeued")
+	}
+	return &RequeueError{Err: err, After: after}
+}
+
+func (e *RequeueError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RequeueError) Unwrap() error {
+	return e.Err
+}
+
+// requeueDelay returns the delay requested by a handler error, zero if none
+func requeueDelay(err error) time.Duration {
+	var re *RequeueError
+	if errors.As(err, &re) && re.After > 0 {
+		return re.After
+	}
+	return 0
+}
+
+// requeueAt returns when a message failed with err is due again, zero for right away
+func requeueAt(err error) time.Time {
+	if delay := requeueDelay(err); delay > 0 {
+		return time.Now().Add(delay)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,97 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryQueueNackAfter(t *testing.T) {
+	ctx := context.Background()
+	opts := NewOptions()
+	opts.ClaimMinIdle = 0
+
+	q, err := NewMemoryQueue("jobs", opts)
+	assert.NoError(t, err)
+	assert.NoError(t, q.Push(ctx, &Message{Body: []byte("a")}))
+
+	msg, err := q.Receive(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, q.NackAfter(ctx, msg, 30*time.Millisecond))
+
+	_, err = q.Receive(ctx)
+	assert.ErrorIs(t, err, ErrQueueEmpty)
+
+	time.Sleep(40 * time.Millisecond)
+	again, err := q.Receive(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, msg.ID, again.ID)
+}
+
+func TestMemoryQueueNackWithError(t *testing.T) {
+	ctx := context.Background()
+	opts := NewOptions()
+	opts.ClaimMinIdle = 0
+	opts.MaxAttempts = 1
+	var cause error
+	opts.OnDeadLetter = func(msg *Message, err error) {
+		cause = err
+	}
+
+	q, err := NewMemoryQueue("jobs", opts)
+	assert.NoError(t, err)
+	assert.NoError(t, q.Push(ctx, &Message{Body: []byte("a")}))
+
+	// The last attempt is dropped with the given cause
+	errDown := errors.New("smtp down")
+	msg, err := q.Receive(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, q.NackWithError(ctx, msg, time.Minute, errDown))
+	assert.Equal(t, errDown, cause)
+
+	length, err := q.Length(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), length)
+}
+
+func TestRequeueAfter(t *testing.T) {
+	ctx := context.Background()
+	opts := NewOptions()
+	opts.PollInterval = 5 * time.Millisecond
+	opts.MaxAttempts = 3
+
+	causes := make(chan error, 1)
+	opts.OnDeadLetter = func(msg *Message, cause error) {
+		causes <- cause
+	}
+
+	q, err := NewMemoryQueue("jobs", opts)
+	assert.NoError(t, err)
+	assert.NoError(t, q.Push(ctx, &Message{Body: []byte("a")}))
+
+	var attempts []time.Time
+	c := NewMemoryConsumer(q)
+	c.OnMessage(func(ctx context.Context, msg *Message) error {
+		attempts = append(attempts, time.Now())
+		return RequeueAfter(30*time.Millisecond, errors.New("downstream busy"))
+	})
+	assert.NoError(t, c.Start(ctx))
+	defer c.Stop(ctx)
+
+	select {
+	case cause := <-causes:
+		assert.EqualError(t, cause, "downstream busy")
+	case <-time.After(time.Second):
+		t.Fatal("not dead-lettered")
+	}
+
+	assert.Len(t, attempts, 3)
+	for i := 1; i < len(attempts); i++ {
+		assert.GreaterOrEqual(t, attempts[i].Sub(attempts[i-1]), 30*time.Millisecond)
+	}
+	assert.Equal(t, 30*time.Millisecond, requeueDelay(RequeueAfter(30*time.Millisecond, nil)))
+	assert.Zero(t, requeueDelay(errors.New("boom")))
+}
@@ -0,0 +1,169 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// MetadataScheduledAt is the metadata key holding the activation time of a scheduled message (RFC 3339)
+const MetadataScheduledAt = "scheduled_at"
+
+// MessageFactory builds the message published for the activation at, nil skips it
+type MessageFactory func(ctx context.Context, at time.Time) (*Message, error)
+
+var _ Producer = (*Scheduler)(nil)
+
+// Scheduler is a Producer publishing messages to a queue on cron schedules,
+// so periodic jobs flow through the same workers as other messages. Each
+// replica running a Scheduler publishes, run it on a single instance.
+type Scheduler struct {
+	queue Queue
+	opts  *Options
+
+	mu      sync.Mutex
+	entries []*scheduleEntry
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+type scheduleEntry struct {
+	spec     string
+	schedule cron.Schedule
+	factory  MessageFactory
+}
+
+// NewScheduler creates a scheduler publishing to q. Failed pushes are
+// retried RetryCount times, RetryDelay apart.
+func NewScheduler(q Queue, opts *Options) *Scheduler {
+	if opts == nil {
+		opts = NewOptions()
+	}
+	return &Scheduler{queue: q, opts: opts}
+}
+
+// ScheduleSend publishes the message built by factory at every activation of
+// spec, a standard cron expression ("0 * * * *") or a descriptor ("@hourly",
+// "@every 5m"). The activation time is stored in the scheduled_at metadata.
+func (s *Scheduler) ScheduleSend(spec string, factory MessageFactory) error {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return fmt.Errorf("failed to parse schedule %q: %w", spec, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := &scheduleEntry{spec: spec, schedule: schedule, factory: factory}
+	s.entries = append(s.entries, e)
+	if s.ctx != nil {
+		s.wg.Add(1)
+		go s.run(s.ctx, e)
+	}
+	return nil
+}
+
+// Start starts publishing on the schedules
+func (s *Scheduler) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
+		return errors.New("scheduler already started")
+	}
+
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	for _, e := range s.entries {
+		s.wg.Add(1)
+		go s.run(s.ctx, e)
+	}
+	return nil
+}
+
+// Stop stops publishing and waits for running activations
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.cancel = nil
+	s.ctx = nil
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Send pushes msg to the queue right away
+func (s *Scheduler) Send(ctx context.Context, msg *Message) error {
+	return s.queue.Push(ctx, msg)
+}
+
+func (s *Scheduler) run(ctx context.Context, e *scheduleEntry) {
+	defer s.wg.Done()
+
+	for {
+		at := e.schedule.Next(time.Now())
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		// Activations missed while publishing are skipped, not caught up
+		_ = s.publish(ctx, e, at)
+	}
+}
+
+// publish builds and pushes the message of an activation, retrying failed pushes
+func (s *Scheduler) publish(ctx context.Context, e *scheduleEntry, at time.Time) error {
+	msg, err := e.factory(ctx, at)
+	if err != nil {
+		return fmt.Errorf("failed to build scheduled message: %w", err)
+	}
+	if msg == nil {
+		return nil
+	}
+	if msg.Metadata == nil {
+		msg.Metadata = make(map[string]string)
+	}
+	msg.Metadata[MetadataScheduledAt] = at.UTC().Format(time.RFC3339Nano)
+
+	for attempt := 0; ; attempt++ {
+		err = s.queue.Push(ctx, msg)
+		if err == nil || attempt >= s.opts.RetryCount {
+			break
+		}
+		timer := time.NewTimer(s.opts.RetryDelay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to publish scheduled message %q: %w", e.spec, err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler(t *testing.T) {
+	ctx := context.Background()
+	q, err := NewMemoryQueue("jobs", nil)
+	assert.NoError(t, err)
+
+	s := NewScheduler(q, nil)
+	assert.Error(t, s.ScheduleSend("not a cron", nil))
+
+	err = s.ScheduleSend("@every 1s", func(ctx context.Context, at time.Time) (*Message, error) {
+		return &Message{Body: []byte("cleanup")}, nil
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, s.Start(ctx))
+
+	assert.Eventually(t, func() bool {
+		length, _ := q.Length(ctx)
+		return length >= 1
+	}, 3*time.Second, 10*time.Millisecond)
+	assert.NoError(t, s.Stop(ctx))
+
+	msg, err := q.Pop(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "cleanup", string(msg.Body))
+	_, err = time.Parse(time.RFC3339Nano, msg.Metadata[MetadataScheduledAt])
+	assert.NoError(t, err)
+
+	// Nothing is published once stopped
+	length, _ := q.Length(ctx)
+	time.Sleep(50 * time.Millisecond)
+	after, _ := q.Length(ctx)
+	assert.Equal(t, length, after)
+}
@@ -0,0 +1,97 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/ducconit/gocore/service"
+)
+
+var _ service.Service = (*ConsumerService)(nil)
+
+// ConsumerService runs a Consumer as a service.Service, so consumers share
+// the lifecycle of the other services registered in a service.Manager
+type ConsumerService struct {
+	name     string
+	consumer Consumer
+	hooks    service.Hooks
+	running  atomic.Bool
+}
+
+// NewService wraps c into a service named name
+func NewService(name string, c Consumer) *ConsumerService {
+	return &ConsumerService{name: name, consumer: c}
+}
+
+// Name returns the name of the service
+func (s *ConsumerService) Name() string {
+	return s.name
+}
+
+// Consumer returns the wrapped consumer
+func (s *ConsumerService) Consumer() Consumer {
+	return s.consumer
+}
+
+// OnStart adds a hook run before the consumer starts, see service.Hooks
+func (s *ConsumerService) OnStart(fn service.Hook) {
+	s.hooks.OnStart(fn)
+}
+
+// OnStop adds a hook run after the consumer stops, see service.Hooks
+func (s *ConsumerService) OnStop(fn service.Hook) {
+	s.hooks.OnStop(fn)
+}
+
+// Start runs the start hooks, then starts the consumer. The consumer
+// outlives ctx and runs until Stop.
+func (s *ConsumerService) Start(ctx context.Context) error {
+	if err := s.hooks.Start(ctx); err != nil {
+		return fmt.Errorf("failed to run start hooks: %w", err)
+	}
+	if err := s.consumer.Start(context.WithoutCancel(ctx)); err != nil {
+		return errors.Join(err, s.hooks.Stop(ctx))
+	}
+	s.running.Store(true)
+	return nil
+}
+
+// Stop stops the consumer, waiting for the running handlers, then runs the
+// stop hooks
+func (s *ConsumerService) Stop(ctx context.Context) error {
+	s.running.Store(false)
+	err := s.consumer.Stop(ctx)
+	if hookErr := s.hooks.Stop(ctx); hookErr != nil {
+		err = errors.Join(err, fmt.Errorf("failed to run stop hooks: %w", hookErr))
+	}
+	return err
+}
+
+// Health reports whether the consumer is running. Consumers with their own
+// Health method, as RedisConsumer and MemoryConsumer, are asked whether
+// their loops are still alive.
+func (s *ConsumerService) Health() bool {
+	if !s.running.Load() {
+		return false
+	}
+	if h, ok := s.consumer.(interface{ Health() bool }); ok {
+		return h.Health()
+	}
+	return true
+}
+
+// DepthChecker reports q as unhealthy once it holds more than max messages
+func DepthChecker(q Queue, max int64) service.HealthChecker {
+	return service.HealthCheckerFunc(func(ctx context.Context) error {
+		length, err := q.Length(ctx)
+		if err != nil {
+			return err
+		}
+		if length > max {
+			return fmt.Errorf("queue depth %d exceeds %d", length, max)
+		}
+		return nil
+	})
+}
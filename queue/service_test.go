@@ -0,0 +1,90 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ducconit/gocore/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumerService(t *testing.T) {
+	ctx := context.Background()
+	opts := NewOptions()
+	opts.PollInterval = 10 * time.Millisecond
+
+	q, err := NewMemoryQueue("jobs", opts)
+	assert.NoError(t, err)
+
+	handled := make(chan string, 1)
+	consumer := NewMemoryConsumer(q)
+	consumer.OnMessage(func(ctx context.Context, msg *Message) error {
+		handled <- string(msg.Body)
+		return nil
+	})
+
+	svc := NewService("jobs-consumer", consumer)
+	assert.Equal(t, "jobs-consumer", svc.Name())
+	assert.False(t, svc.Health())
+
+	m := service.NewManager()
+	assert.NoError(t, m.Register(svc))
+	assert.NoError(t, m.Start(ctx))
+	assert.True(t, svc.Health())
+
+	assert.NoError(t, q.Push(ctx, &Message{Body: []byte("a")}))
+	select {
+	case body := <-handled:
+		assert.Equal(t, "a", body)
+	case <-time.After(time.Second):
+		t.Fatal("message not handled")
+	}
+
+	assert.NoError(t, m.Stop(ctx))
+	assert.False(t, svc.Health())
+
+	// The consumer outlives the context it was started with
+	startCtx, cancel := context.WithCancel(ctx)
+	assert.NoError(t, svc.Start(startCtx))
+	cancel()
+	assert.NoError(t, q.Push(ctx, &Message{Body: []byte("b")}))
+	select {
+	case body := <-handled:
+		assert.Equal(t, "b", body)
+	case <-time.After(time.Second):
+		t.Fatal("message not handled after the start context ended")
+	}
+	assert.True(t, svc.Health())
+	assert.NoError(t, svc.Stop(ctx))
+}
+
+func TestConsumerHealth(t *testing.T) {
+	q, err := NewMemoryQueue("jobs", NewOptions())
+	assert.NoError(t, err)
+	consumer := NewMemoryConsumer(q)
+	consumer.OnMessage(func(ctx context.Context, msg *Message) error { return nil })
+	assert.False(t, consumer.Health())
+
+	// A consumer started with a context that ends is no longer healthy
+	ctx, cancel := context.WithCancel(context.Background())
+	assert.NoError(t, consumer.Start(ctx))
+	assert.True(t, consumer.Health())
+	cancel()
+	assert.False(t, consumer.Health())
+	assert.NoError(t, consumer.Stop(context.Background()))
+}
+
+func TestDepthChecker(t *testing.T) {
+	ctx := context.Background()
+
+	q, err := NewMemoryQueue("jobs", NewOptions())
+	assert.NoError(t, err)
+	checker := DepthChecker(q, 1)
+
+	assert.NoError(t, q.Push(ctx, &Message{Body: []byte("a")}))
+	assert.NoError(t, checker.Check(ctx))
+
+	assert.NoError(t, q.Push(ctx, &Message{Body: []byte("b")}))
+	assert.Error(t, checker.Check(ctx))
+}
@@ -0,0 +1,213 @@
+package queue
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation name of the spans created by Tracing
+const TracerName = "github.com/ducconit/gocore/queue"
+
+// MetadataCarrier adapts Message.Metadata to a propagation.TextMapCarrier
+type MetadataCarrier map[string]string
+
+var _ propagation.TextMapCarrier = MetadataCarrier(nil)
+
+// Get returns the value of key
+func (c MetadataCarrier) Get(key string) string {
+	return c[key]
+}
+
+// Set stores key and value
+func (c MetadataCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+// Keys lists the stored keys
+func (c MetadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// TracingOption configures a Tracing
+type TracingOption func(*Tracing)
+
+// WithTracerProvider sets the provider creating spans, the global one by default
+func WithTracerProvider(tp trace.TracerProvider) TracingOption {
+	return func(t *Tracing) {
+		t.provider = tp
+	}
+}
+
+// WithPropagator sets the propagator of the trace context, the global one by default
+func WithPropagator(p propagation.TextMapPropagator) TracingOption {
+	return func(t *Tracing) {
+		t.propagator = p
+	}
+}
+
+// Tracing propagates OpenTelemetry trace context through Message.Metadata,
+// creating a producer span per published message and a consumer span per
+// handled message, so a trace continues from the publisher into the consumer.
+type Tracing struct {
+	provider   trace.TracerProvider
+	propagator propagation.TextMapPropagator
+	tracer     trace.Tracer
+}
+
+// NewTracing creates a new Tracing
+func NewTracing(opts ...TracingOption) *Tracing {
+	t := &Tracing{}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if t.provider == nil {
+		t.provider = otel.GetTracerProvider()
+	}
+	if t.propagator == nil {
+		t.propagator = otel.GetTextMapPropagator()
+	}
+	t.tracer = t.provider.Tracer(TracerName)
+	return t
+}
+
+// Inject stores the trace context of ctx in the metadata of msg
+func (t *Tracing) Inject(ctx context.Context, msg *Message) {
+	if msg.Metadata == nil {
+		msg.Metadata = make(map[string]string)
+	}
+	t.propagator.Inject(ctx, MetadataCarrier(msg.Metadata))
+}
+
+// Extract returns ctx carrying the trace context stored in the metadata of msg
+func (t *Tracing) Extract(ctx context.Context, msg *Message) context.Context {
+	return t.propagator.Extract(ctx, MetadataCarrier(msg.Metadata))
+}
+
+// Queue wraps q so pushed messages get a producer span named
+// "<name> publish" whose context they carry
+func (t *Tracing) Queue(name string, q Queue) Queue {
+	return &tracedQueue{Queue: q, name: name, tracing: t}
+}
+
+// Broker wraps b so published messages get a producer span named
+// "<topic> publish" whose context they carry
+func (t *Tracing) Broker(b Broker) Broker {
+	return &tracedBroker{Broker: b, tracing: t}
+}
+
+// Handler wraps a message handler in a consumer span named "<name> process",
+// a child of the span the message was published in
+func (t *Tracing) Handler(name string, handler func(ctx context.Context, msg *Message) error) func(ctx context.Context, msg *Message) error {
+	return func(ctx context.Context, msg *Message) error {
+		ctx = t.Extract(ctx, msg)
+		ctx, span := t.tracer.Start(ctx, name+" process",
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(
+				semconv.MessagingDestinationName(name),
+				semconv.MessagingOperationTypeDeliver,
+				semconv.MessagingMessageID(msg.ID),
+			))
+		defer span.End()
+
+		return record(span, handler(ctx, msg))
+	}
+}
+
+// BatchHandler wraps a batch handler in a consumer span named "<name> process",
+// linked to the span each message was published in
+func (t *Tracing) BatchHandler(name string, handler func(ctx context.Context, msgs []*Message) error) func(ctx context.Context, msgs []*Message) error {
+	return func(ctx context.Context, msgs []*Message) error {
+		links := make([]trace.Link, 0, len(msgs))
+		for _, msg := range msgs {
+			sc := trace.SpanContextFromContext(t.Extract(context.Background(), msg))
+			if sc.IsValid() {
+				links = append(links, trace.Link{SpanContext: sc})
+			}
+		}
+
+		ctx, span := t.tracer.Start(ctx, name+" process",
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithLinks(links...),
+			trace.WithAttributes(
+				semconv.MessagingDestinationName(name),
+				semconv.MessagingOperationTypeDeliver,
+				semconv.MessagingBatchMessageCount(len(msgs)),
+			))
+		defer span.End()
+
+		return record(span, handler(ctx, msgs))
+	}
+}
+
+// publish runs fn in a producer span, injecting its context into msgs
+func (t *Tracing) publish(ctx context.Context, name string, msgs []*Message, fn func() error) error {
+	attrs := []attribute.KeyValue{
+		semconv.MessagingDestinationName(name),
+		semconv.MessagingOperationTypePublish,
+	}
+	if len(msgs) > 1 {
+		attrs = append(attrs, semconv.MessagingBatchMessageCount(len(msgs)))
+	}
+
+	ctx, span := t.tracer.Start(ctx, name+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attrs...))
+	defer span.End()
+
+	for _, msg := range msgs {
+		if msg != nil {
+			t.Inject(ctx, msg)
+		}
+	}
+	return record(span, fn())
+}
+
+// record marks span as failed when err is set and returns err
+func record(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// tracedQueue creates producer spans for a Queue
+type tracedQueue struct {
+	Queue
+	name    string
+	tracing *Tracing
+}
+
+func (q *tracedQueue) Push(ctx context.Context, msg *Message) error {
+	return q.tracing.publish(ctx, q.name, []*Message{msg}, func() error {
+		return q.Queue.Push(ctx, msg)
+	})
+}
+
+func (q *tracedQueue) PushBatch(ctx context.Context, msgs []*Message) error {
+	return q.tracing.publish(ctx, q.name, msgs, func() error {
+		return q.Queue.PushBatch(ctx, msgs)
+	})
+}
+
+// tracedBroker creates producer spans for a Broker
+type tracedBroker struct {
+	Broker
+	tracing *Tracing
+}
+
+func (b *tracedBroker) Publish(ctx context.Context, topic string, msg *Message) error {
+	return b.tracing.publish(ctx, topic, []*Message{msg}, func() error {
+		return b.Broker.Publish(ctx, topic, msg)
+	})
+}
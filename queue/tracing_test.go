@@ -0,0 +1,58 @@
+package queue
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	ctx := context.Background()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracing := NewTracing(WithTracerProvider(tp), WithPropagator(propagation.TraceContext{}))
+
+	mq, err := NewMemoryQueue("jobs", nil)
+	assert.NoError(t, err)
+	q := tracing.Queue("jobs", mq)
+
+	// A request span in the publisher
+	reqCtx, reqSpan := tp.Tracer("test").Start(ctx, "POST /orders")
+	assert.NoError(t, q.Push(reqCtx, &Message{Body: []byte("a")}))
+	reqSpan.End()
+
+	msg, err := mq.Pop(ctx)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, msg.Metadata["traceparent"])
+
+	var handled trace.SpanContext
+	handler := tracing.Handler("jobs", func(ctx context.Context, msg *Message) error {
+		handled = trace.SpanContextFromContext(ctx)
+		return nil
+	})
+	assert.NoError(t, handler(ctx, msg))
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 3)
+	publish, process := spans[0], spans[2]
+	assert.Equal(t, "jobs publish", publish.Name())
+	assert.Equal(t, trace.SpanKindProducer, publish.SpanKind())
+	assert.Equal(t, reqSpan.SpanContext().SpanID(), publish.Parent().SpanID())
+	assert.Equal(t, "jobs process", process.Name())
+	assert.Equal(t, trace.SpanKindConsumer, process.SpanKind())
+	assert.Equal(t, publish.SpanContext().SpanID(), process.Parent().SpanID())
+	assert.Equal(t, reqSpan.SpanContext().TraceID(), handled.TraceID())
+
+	batch := tracing.BatchHandler("jobs", func(ctx context.Context, msgs []*Message) error {
+		return nil
+	})
+	assert.NoError(t, batch(ctx, []*Message{msg}))
+	spans = recorder.Ended()
+	assert.Len(t, spans[3].Links(), 1)
+	assert.Equal(t, publish.SpanContext().SpanID(), spans[3].Links()[0].SpanContext.SpanID())
+}
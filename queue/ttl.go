@@ -0,0 +1,36 @@
+package queue
+
+import "time"
+
+// MetadataExpiresAt is the metadata key holding the expiry of a message (RFC 3339)
+const MetadataExpiresAt = "expires_at"
+
+// WithTTL sets the message to expire ttl from now and returns it. Consumers
+// skip expired messages instead of handling them, see Options.OnExpired.
+func (m *Message) WithTTL(ttl time.Duration) *Message {
+	return m.WithExpiry(time.Now().Add(ttl))
+}
+
+// WithExpiry sets the message to expire at t and returns it
+func (m *Message) WithExpiry(t time.Time) *Message {
+	if m.Metadata == nil {
+		m.Metadata = make(map[string]string)
+	}
+	m.Metadata[MetadataExpiresAt] = t.UTC().Format(time.RFC3339Nano)
+	return m
+}
+
+// ExpiresAt returns when the message expires, zero if it does not
+func (m *Message) ExpiresAt() time.Time {
+	t, err := time.Parse(time.RFC3339Nano, m.Metadata[MetadataExpiresAt])
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// Expired reports whether the TTL of the message has passed
+func (m *Message) Expired() bool {
+	t := m.ExpiresAt()
+	return !t.IsZero() && time.Now().After(t)
+}
@@ -0,0 +1,61 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageTTL(t *testing.T) {
+	msg := &Message{}
+	assert.False(t, msg.Expired())
+	assert.True(t, msg.ExpiresAt().IsZero())
+
+	msg.WithTTL(time.Hour)
+	assert.False(t, msg.Expired())
+	assert.WithinDuration(t, time.Now().Add(time.Hour), msg.ExpiresAt(), time.Second)
+
+	msg.WithExpiry(time.Now().Add(-time.Second))
+	assert.True(t, msg.Expired())
+}
+
+func TestMemoryConsumerSkipsExpired(t *testing.T) {
+	ctx := context.Background()
+	opts := NewOptions()
+	opts.PollInterval = 10 * time.Millisecond
+	opts.DeadLetterExpired = true
+
+	expired := make(chan string, 10)
+	deadLettered := make(chan error, 10)
+	opts.OnExpired = func(ctx context.Context, msg *Message) {
+		expired <- string(msg.Body)
+	}
+	opts.OnDeadLetter = func(msg *Message, cause error) {
+		deadLettered <- cause
+	}
+
+	q, err := NewMemoryQueue("jobs", opts)
+	assert.NoError(t, err)
+	assert.NoError(t, q.Push(ctx, (&Message{Body: []byte("stale")}).WithTTL(-time.Second)))
+	assert.NoError(t, q.Push(ctx, (&Message{Body: []byte("fresh")}).WithTTL(time.Hour)))
+
+	handled := make(chan string, 10)
+	c := NewMemoryConsumer(q)
+	c.OnMessage(func(ctx context.Context, msg *Message) error {
+		handled <- string(msg.Body)
+		return nil
+	})
+	assert.NoError(t, c.Start(ctx))
+	defer c.Stop(ctx)
+
+	assert.Equal(t, "stale", <-expired)
+	assert.ErrorIs(t, <-deadLettered, ErrMessageExpired)
+	assert.Equal(t, "fresh", <-handled)
+
+	assert.Eventually(t, func() bool {
+		length, _ := q.Length(ctx)
+		return length == 0
+	}, time.Second, 10*time.Millisecond)
+}
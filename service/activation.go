@@ -0,0 +1,99 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrNoActivationListener is returned by ActivationListener when no socket
+// passed by the service manager is left to claim
+var ErrNoActivationListener = errors.New("no socket activation listener")
+
+// listenFDsStart is the first file descriptor passed by systemd
+const listenFDsStart = 3
+
+// activatedListener is a socket passed by the service manager
+type activatedListener struct {
+	name     string
+	listener net.Listener
+	claimed  bool
+}
+
+var activation struct {
+	once      sync.Once
+	mu        sync.Mutex
+	listeners []*activatedListener
+	err       error
+}
+
+// ActivationListener returns a socket passed by systemd socket activation
+// (LISTEN_FDS), the one named name in LISTEN_FDNAMES or the first one not
+// claimed yet when name is empty. Each socket is returned once. Services
+// bind privileged ports without root this way, and keep accepting
+// connections while the process restarts.
+func ActivationListener(name string) (net.Listener, error) {
+	activation.once.Do(func() {
+		activation.listeners, activation.err = activationListeners(os.Getenv, listenFDsStart)
+		// Child processes must not take the sockets over
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	})
+	if activation.err != nil {
+		return nil, activation.err
+	}
+
+	activation.mu.Lock()
+	defer activation.mu.Unlock()
+
+	for _, l := range activation.listeners {
+		if !l.claimed && (name == "" || l.name == name) {
+			l.claimed = true
+			return l.listener, nil
+		}
+	}
+	if name != "" {
+		return nil, fmt.Errorf("%w: %s", ErrNoActivationListener, name)
+	}
+	return nil, ErrNoActivationListener
+}
+
+// activationListeners returns the listeners of the sockets described by the
+// LISTEN_* variables of getenv, numbered from firstFD
+func activationListeners(getenv func(string) string, firstFD int) ([]*activatedListener, error) {
+	pid, err := strconv.Atoi(getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(getenv("LISTEN_FDNAMES"), ":")
+
+	listeners := make([]*activatedListener, 0, n)
+	for i := range n {
+		name := ""
+		if i < len(names) {
+			name = names[i]
+		}
+
+		// FileListener duplicates the descriptor, close the original
+		f := os.NewFile(uintptr(firstFD+i), name)
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, al := range listeners {
+				al.listener.Close()
+			}
+			return nil, fmt.Errorf("failed to use activation socket %d: %w", firstFD+i, err)
+		}
+		listeners = append(listeners, &activatedListener{name: name, listener: l})
+	}
+	return listeners, nil
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActivationListeners(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer l.Close()
+	f, err := l.(*net.TCPListener).File()
+	assert.NoError(t, err)
+
+	env := map[string]string{
+		"LISTEN_PID":     strconv.Itoa(os.Getpid()),
+		"LISTEN_FDS":     "1",
+		"LISTEN_FDNAMES": "http",
+	}
+	listeners, err := activationListeners(func(k string) string { return env[k] }, int(f.Fd()))
+	assert.NoError(t, err)
+	if assert.Len(t, listeners, 1) {
+		assert.Equal(t, "http", listeners[0].name)
+		assert.Equal(t, l.Addr().String(), listeners[0].listener.Addr().String())
+		listeners[0].listener.Close()
+	}
+
+	// Sockets passed to another process are ignored
+	env["LISTEN_PID"] = "1"
+	listeners, err = activationListeners(func(k string) string { return env[k] }, int(f.Fd()))
+	assert.NoError(t, err)
+	assert.Empty(t, listeners)
+}
+
+func TestActivationListener_None(t *testing.T) {
+	_, err := ActivationListener("")
+	assert.ErrorIs(t, err, ErrNoActivationListener)
+}
@@ -0,0 +1,315 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrJobExists is returned when a job name is added twice
+	ErrJobExists = errors.New("job already added")
+
+	// ErrJobPanic is wrapped by the error of a job that panicked
+	ErrJobPanic = errors.New("job panicked")
+)
+
+// Job is a function run on a schedule by a CronService
+type Job func(ctx context.Context) error
+
+// OverlapPolicy selects what happens when a job is due while its previous
+// run has not finished
+type OverlapPolicy int
+
+const (
+	// OverlapSkip skips the activation
+	OverlapSkip OverlapPolicy = iota
+
+	// OverlapQueue runs the activation once the previous run finishes
+	OverlapQueue
+)
+
+// CronOption represents a cron service option
+type CronOption func(*CronService)
+
+// WithCronLogger sets the logger of job failures, the global logger by default
+func WithCronLogger(l *logger.Logger) CronOption {
+	return func(s *CronService) {
+		s.logger = l
+	}
+}
+
+// JobOption represents an option applied when adding a job
+type JobOption func(*cronJob)
+
+// WithJobTimeout cancels the context of a run after d
+func WithJobTimeout(d time.Duration) JobOption {
+	return func(j *cronJob) {
+		j.timeout = d
+	}
+}
+
+// WithOverlap sets what happens when the job is due while still running,
+// OverlapSkip by default
+func WithOverlap(p OverlapPolicy) JobOption {
+	return func(j *cronJob) {
+		j.overlap = p
+	}
+}
+
+type cronJob struct {
+	name     string
+	schedule cron.Schedule
+	job      Job
+	timeout  time.Duration
+	overlap  OverlapPolicy
+
+	mu      sync.Mutex
+	running bool
+	pending int
+}
+
+// CronService runs jobs on cron schedules. Jobs recover from panics, are
+// bounded by their timeout, and follow their overlap policy when a run
+// outlasts the schedule. Each replica runs the jobs, run it on a single
+// instance or make the jobs idempotent.
+type CronService struct {
+	name       string
+	logger     *logger.Logger
+	logOnce    sync.Once
+	serviceLog *logger.Logger
+	hooks      Hooks
+
+	mu        sync.Mutex
+	jobs      []*cronJob
+	ctx       context.Context
+	cancel    context.CancelFunc
+	runCtx    context.Context
+	cancelRun context.CancelFunc
+	scheduler sync.WaitGroup
+	runs      sync.WaitGroup
+	running   atomic.Bool
+}
+
+var _ Service = (*CronService)(nil)
+
+// NewCronService creates a cron service named name
+func NewCronService(name string, opts ...CronOption) *CronService {
+	s := &CronService{name: name}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Name returns the name of the service
+func (s *CronService) Name() string {
+	return s.name
+}
+
+// AddJob runs job at every activation of spec, a standard cron expression
+// ("0 * * * *") or a descriptor ("@hourly", "@every 5m"). Jobs added while
+// the service runs are scheduled right away.
+func (s *CronService) AddJob(name, spec string, job Job, opts ...JobOption) error {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return fmt.Errorf("failed to parse schedule %q: %w", spec, err)
+	}
+
+	j := &cronJob{name: name, schedule: schedule, job: job}
+	for _, opt := range opts {
+		opt(j)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.jobs {
+		if existing.name == name {
+			return fmt.Errorf("%w: %s", ErrJobExists, name)
+		}
+	}
+	s.jobs = append(s.jobs, j)
+	if s.ctx != nil {
+		s.scheduler.Add(1)
+		go s.schedule(s.ctx, s.runCtx, j)
+	}
+	return nil
+}
+
+// OnStart adds a hook run before the jobs are scheduled, see Hooks
+func (s *CronService) OnStart(fn Hook) {
+	s.hooks.OnStart(fn)
+}
+
+// OnStop adds a hook run after the running jobs finish, see Hooks
+func (s *CronService) OnStop(fn Hook) {
+	s.hooks.OnStop(fn)
+}
+
+// Start runs the start hooks, then schedules the jobs
+func (s *CronService) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx != nil {
+		return nil
+	}
+	if err := s.hooks.Start(ctx); err != nil {
+		return fmt.Errorf("failed to run start hooks: %w", err)
+	}
+
+	// Runs outlive the scheduling until Stop gives up waiting for them
+	s.runCtx, s.cancelRun = context.WithCancel(context.WithoutCancel(ctx))
+	s.ctx, s.cancel = context.WithCancel(s.runCtx)
+	for _, j := range s.jobs {
+		s.scheduler.Add(1)
+		go s.schedule(s.ctx, s.runCtx, j)
+	}
+	s.running.Store(true)
+	return nil
+}
+
+// Stop stops scheduling and waits for the running jobs, then runs the stop
+// hooks. Jobs still running when ctx is done are cancelled.
+func (s *CronService) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx == nil {
+		return nil
+	}
+	s.running.Store(false)
+	s.cancel()
+	s.scheduler.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		s.runs.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.cancelRun()
+		<-done
+		err = ctx.Err()
+	}
+	s.cancelRun()
+	s.ctx = nil
+	s.runCtx = nil
+
+	if hookErr := s.hooks.Stop(ctx); hookErr != nil {
+		err = errors.Join(err, fmt.Errorf("failed to run stop hooks: %w", hookErr))
+	}
+	return err
+}
+
+// Health reports whether the jobs are scheduled
+func (s *CronService) Health() bool {
+	return s.running.Load()
+}
+
+// schedule triggers the activations of j until ctx is done, running them
+// with runCtx
+func (s *CronService) schedule(ctx, runCtx context.Context, j *cronJob) {
+	defer s.scheduler.Done()
+
+	for {
+		at := j.schedule.Next(time.Now())
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		j.mu.Lock()
+		switch {
+		case !j.running:
+			j.running = true
+			s.runs.Add(1)
+			go s.run(runCtx, j)
+		case j.overlap == OverlapQueue:
+			j.pending++
+		default:
+			s.log().Warn("cron job still running, skipping activation",
+				zap.String("job", j.name), zap.Time("at", at))
+		}
+		j.mu.Unlock()
+	}
+}
+
+// run runs j, then the activations queued meanwhile. Queued activations
+// are dropped once the scheduling stops.
+func (s *CronService) run(ctx context.Context, j *cronJob) {
+	defer s.runs.Done()
+
+	for {
+		s.runOnce(ctx, j)
+
+		j.mu.Lock()
+		if j.pending == 0 || !s.running.Load() {
+			j.pending = 0
+			j.running = false
+			j.mu.Unlock()
+			return
+		}
+		j.pending--
+		j.mu.Unlock()
+	}
+}
+
+// runOnce runs j within its timeout, logging its failure or panic
+func (s *CronService) runOnce(ctx context.Context, j *cronJob) {
+	if j.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("%w: %v", ErrJobPanic, r)
+				s.log().Error("cron job panicked",
+					zap.String("job", j.name),
+					zap.Any("panic", r), zap.ByteString("stack", debug.Stack()))
+			}
+		}()
+		return j.job(ctx)
+	}()
+	if err != nil && !errors.Is(err, ErrJobPanic) {
+		s.log().Error("cron job failed",
+			zap.String("job", j.name),
+			zap.Duration("duration", time.Since(start)), zap.Error(err))
+	}
+}
+
+// log returns the logger of the service, tagged with its name, instance and
+// version by ForService
+func (s *CronService) log() *logger.Logger {
+	s.logOnce.Do(func() {
+		if s.logger != nil {
+			s.serviceLog = s.logger.ForService(s.name)
+		} else {
+			s.serviceLog = logger.ForService(s.name)
+		}
+	})
+	return s.serviceLog
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+// every is a schedule finer than the one second resolution of cron expressions
+type every time.Duration
+
+func (d every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(d))
+}
+
+// addJob adds a job activated every d
+func addJob(t *testing.T, s *CronService, name string, d time.Duration, job Job, opts ...JobOption) {
+	assert.NoError(t, s.AddJob(name, "@every 1h", job, opts...))
+	s.jobs[len(s.jobs)-1].schedule = every(d)
+}
+
+func TestCronService(t *testing.T) {
+	ctx := context.Background()
+	s := NewCronService("cron")
+	assert.Equal(t, "cron", s.Name())
+	assert.False(t, s.Health())
+
+	var runs atomic.Int64
+	addJob(t, s, "cleanup", 5*time.Millisecond, func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	})
+	assert.ErrorIs(t, s.AddJob("cleanup", "@hourly", nil), ErrJobExists)
+	assert.Error(t, s.AddJob("broken", "not a cron expression", nil))
+
+	assert.NoError(t, s.Start(ctx))
+	assert.True(t, s.Health())
+	assert.Eventually(t, func() bool { return runs.Load() >= 2 }, time.Second, time.Millisecond)
+
+	assert.NoError(t, s.Stop(ctx))
+	assert.False(t, s.Health())
+	stopped := runs.Load()
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, stopped, runs.Load())
+}
+
+func TestCronService_Overlap(t *testing.T) {
+	ctx := context.Background()
+	releaseSkip := make(chan struct{})
+	releaseQueue := make(chan struct{})
+
+	s := NewCronService("cron")
+	var skipped, queued atomic.Int64
+	addJob(t, s, "skip", 5*time.Millisecond, func(ctx context.Context) error {
+		skipped.Add(1)
+		<-releaseSkip
+		return nil
+	})
+	addJob(t, s, "queue", 5*time.Millisecond, func(ctx context.Context) error {
+		queued.Add(1)
+		<-releaseQueue
+		return nil
+	}, WithOverlap(OverlapQueue))
+
+	assert.NoError(t, s.Start(ctx))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int64(1), skipped.Load())
+	assert.Equal(t, int64(1), queued.Load())
+
+	// The activations queued meanwhile run once the first run finishes
+	releaseQueue <- struct{}{}
+	assert.Eventually(t, func() bool { return queued.Load() == 2 }, time.Second, time.Millisecond)
+
+	close(releaseSkip)
+	close(releaseQueue)
+	assert.NoError(t, s.Stop(ctx))
+}
+
+func TestCronService_TimeoutAndPanic(t *testing.T) {
+	ctx := context.Background()
+	var buf syncBuffer
+
+	s := NewCronService("cron", WithCronLogger(logger.New(logger.WithOutput(&buf))))
+	timedOut := make(chan error, 1)
+	addJob(t, s, "slow", 5*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		select {
+		case timedOut <- ctx.Err():
+		default:
+		}
+		return ctx.Err()
+	}, WithJobTimeout(10*time.Millisecond))
+	addJob(t, s, "broken", 5*time.Millisecond, func(ctx context.Context) error {
+		panic("boom")
+	})
+
+	assert.NoError(t, s.Start(ctx))
+	assert.ErrorIs(t, <-timedOut, context.DeadlineExceeded)
+	assert.Eventually(t, func() bool {
+		out := buf.String()
+		return strings.Contains(out, "cron job panicked") && strings.Contains(out, "cron job failed")
+	}, time.Second, time.Millisecond)
+	assert.NoError(t, s.Stop(ctx))
+
+	out := buf.String()
+	assert.Contains(t, out, `"msg":"cron job panicked"`)
+	assert.Contains(t, out, `"panic":"boom"`)
+	assert.Contains(t, out, `"msg":"cron job failed"`)
+	assert.Contains(t, out, `"job":"slow"`)
+}
+
+func TestCronService_StopCancelsRunningJobs(t *testing.T) {
+	s := NewCronService("cron")
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	addJob(t, s, "stuck", time.Millisecond, func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+		return errors.New("interrupted")
+	})
+
+	assert.NoError(t, s.Start(context.Background()))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, s.Stop(ctx), context.DeadlineExceeded)
+	<-cancelled
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
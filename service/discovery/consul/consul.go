@@ -0,0 +1,129 @@
+// Package consul registers gocore services in the Consul agent catalog
+//
+//	client, _ := api.NewClient(api.DefaultConfig())
+//	m.Register(discovery.Register(svc, consul.New(client), discovery.Registration{
+//		HealthPath: "/healthz",
+//		TTL:        15 * time.Second,
+//	}))
+package consul
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ducconit/gocore/service/discovery"
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	// DefaultCheckInterval is the interval between HTTP health checks by the agent
+	DefaultCheckInterval = 10 * time.Second
+
+	// DefaultDeregisterAfter is how long an instance may stay critical before
+	// the agent deregisters it
+	DefaultDeregisterAfter = time.Minute
+)
+
+// Option represents a registry option
+type Option func(*Registry)
+
+// WithCheckInterval sets the interval between HTTP health checks by the agent
+func WithCheckInterval(d time.Duration) Option {
+	return func(r *Registry) {
+		r.checkInterval = d
+	}
+}
+
+// WithDeregisterAfter sets how long an instance may stay critical before
+// the agent deregisters it, zero keeps it
+func WithDeregisterAfter(d time.Duration) Option {
+	return func(r *Registry) {
+		r.deregisterAfter = d
+	}
+}
+
+// Registry registers services with the local Consul agent. The health
+// endpoint of a registration becomes an HTTP check and its TTL a TTL check.
+type Registry struct {
+	agent           *api.Agent
+	checkInterval   time.Duration
+	deregisterAfter time.Duration
+}
+
+var _ discovery.Registry = (*Registry)(nil)
+
+// New creates a registry using the agent of client
+func New(client *api.Client, opts ...Option) *Registry {
+	r := &Registry{
+		agent:           client.Agent(),
+		checkInterval:   DefaultCheckInterval,
+		deregisterAfter: DefaultDeregisterAfter,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Register registers the instance with its checks
+func (r *Registry) Register(ctx context.Context, reg discovery.Registration) error {
+	svc := &api.AgentServiceRegistration{
+		ID:      reg.ID,
+		Name:    reg.Name,
+		Address: reg.Address,
+		Port:    reg.Port,
+		Tags:    reg.Tags,
+		Meta:    reg.Meta,
+	}
+
+	var deregisterAfter string
+	if r.deregisterAfter > 0 {
+		deregisterAfter = r.deregisterAfter.String()
+	}
+	if url := reg.HealthURL(); url != "" {
+		svc.Checks = append(svc.Checks, &api.AgentServiceCheck{
+			CheckID:                        reg.ID + ":http",
+			HTTP:                           url,
+			Interval:                       r.checkInterval.String(),
+			DeregisterCriticalServiceAfter: deregisterAfter,
+		})
+	}
+	if reg.TTL > 0 {
+		svc.Checks = append(svc.Checks, &api.AgentServiceCheck{
+			CheckID:                        ttlCheckID(reg.ID),
+			TTL:                            reg.TTL.String(),
+			DeregisterCriticalServiceAfter: deregisterAfter,
+		})
+	}
+
+	if err := r.agent.ServiceRegisterOpts(svc, api.ServiceRegisterOpts{}.WithContext(ctx)); err != nil {
+		return fmt.Errorf("failed to register with consul: %w", err)
+	}
+	return nil
+}
+
+// Deregister removes the instance and its checks
+func (r *Registry) Deregister(ctx context.Context, id string) error {
+	q := (&api.QueryOptions{}).WithContext(ctx)
+	if err := r.agent.ServiceDeregisterOpts(id, q); err != nil {
+		return fmt.Errorf("failed to deregister from consul: %w", err)
+	}
+	return nil
+}
+
+// Heartbeat marks the TTL check of the instance as passing
+func (r *Registry) Heartbeat(ctx context.Context, id string) error {
+	q := (&api.QueryOptions{}).WithContext(ctx)
+	if err := r.agent.UpdateTTLOpts(ttlCheckID(id), "", api.HealthPassing, q); err != nil {
+		return fmt.Errorf("failed to update consul ttl check: %w", err)
+	}
+	return nil
+}
+
+func ttlCheckID(id string) string {
+	return id + ":ttl"
+}
@@ -0,0 +1,90 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ducconit/gocore/service/discovery"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeAgent records the calls to the agent endpoints
+type fakeAgent struct {
+	mu       sync.Mutex
+	calls    []string
+	services map[string]api.AgentServiceRegistration
+}
+
+func (a *fakeAgent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.calls = append(a.calls, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery)
+
+	if r.URL.Path == "/v1/agent/service/register" {
+		var svc api.AgentServiceRegistration
+		_ = json.NewDecoder(r.Body).Decode(&svc)
+		a.services[svc.ID] = svc
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	agent := &fakeAgent{services: make(map[string]api.AgentServiceRegistration)}
+	srv := httptest.NewServer(agent)
+	defer srv.Close()
+
+	client, err := api.NewClient(&api.Config{Address: srv.URL})
+	assert.NoError(t, err)
+	r := New(client, WithCheckInterval(5*time.Second))
+
+	reg := discovery.Registration{
+		ID:         "api-1",
+		Name:       "api",
+		Address:    "10.0.0.5",
+		Port:       8080,
+		Tags:       []string{"v2"},
+		Meta:       map[string]string{"version": "2.1.0"},
+		HealthPath: "/healthz",
+		TTL:        15 * time.Second,
+	}
+	assert.NoError(t, r.Register(ctx, reg))
+	assert.NoError(t, r.Heartbeat(ctx, "api-1"))
+	assert.NoError(t, r.Deregister(ctx, "api-1"))
+
+	svc := agent.services["api-1"]
+	assert.Equal(t, "api", svc.Name)
+	assert.Equal(t, "10.0.0.5", svc.Address)
+	assert.Equal(t, 8080, svc.Port)
+	assert.Equal(t, []string{"v2"}, svc.Tags)
+	assert.Equal(t, "2.1.0", svc.Meta["version"])
+	if assert.Len(t, svc.Checks, 2) {
+		assert.Equal(t, "http://10.0.0.5:8080/healthz", svc.Checks[0].HTTP)
+		assert.Equal(t, "5s", svc.Checks[0].Interval)
+		assert.Equal(t, "1m0s", svc.Checks[0].DeregisterCriticalServiceAfter)
+		assert.Equal(t, "api-1:ttl", svc.Checks[1].CheckID)
+		assert.Equal(t, "15s", svc.Checks[1].TTL)
+	}
+
+	assert.Equal(t, []string{
+		"PUT /v1/agent/service/register?",
+		"PUT /v1/agent/check/update/api-1:ttl?",
+		"PUT /v1/agent/service/deregister/api-1?",
+	}, agent.calls)
+}
+
+func TestRegistry_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "CheckID does not have associated TTL", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	client, err := api.NewClient(&api.Config{Address: srv.URL})
+	assert.NoError(t, err)
+	assert.ErrorContains(t, New(client).Heartbeat(context.Background(), "api-1"), "failed to update consul ttl check")
+}
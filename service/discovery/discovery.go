@@ -0,0 +1,267 @@
+// Package discovery registers services in a registry such as Consul or etcd
+// while they run, so clients outside Kubernetes can discover them
+//
+//	api := http.NewHTTPService("api", mux, http.WithHealthEndpoint("/healthz"))
+//	m.Register(discovery.Register(api, consul.New(client), discovery.Registration{
+//		Tags:       []string{"v2"},
+//		HealthPath: "/healthz",
+//		TTL:        15 * time.Second,
+//	}))
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/ducconit/gocore/service"
+	"go.uber.org/zap"
+)
+
+// Registration describes a service instance in a registry
+type Registration struct {
+	// ID identifies the instance, defaults to name-hostname-port
+	ID string `json:"id"`
+
+	// Name is the service name clients look up, defaults to the service name
+	Name string `json:"name"`
+
+	// Address is the host clients connect to. Defaults to the IP the service
+	// listens on, or the hostname when it listens on every interface
+	Address string `json:"address"`
+
+	// Port clients connect to, defaults to the port the service listens on
+	Port int `json:"port"`
+
+	// Tags and Meta are attached to the instance for filtering
+	Tags []string          `json:"tags,omitempty"`
+	Meta map[string]string `json:"meta,omitempty"`
+
+	// HealthPath is the HTTP path the registry probes on Address:Port, e.g. "/healthz"
+	HealthPath string `json:"health_path,omitempty"`
+
+	// TTL makes the registry drop the instance unless it heartbeats within
+	// TTL. The registrar heartbeats while the service is healthy.
+	TTL time.Duration `json:"-"`
+}
+
+// HealthURL returns the URL of the health endpoint, empty without HealthPath
+func (r Registration) HealthURL() string {
+	if r.HealthPath == "" {
+		return ""
+	}
+	return "http://" + net.JoinHostPort(r.Address, strconv.Itoa(r.Port)) + r.HealthPath
+}
+
+// Registry stores service instances for discovery
+type Registry interface {
+	// Register adds or replaces the instance
+	Register(ctx context.Context, r Registration) error
+
+	// Deregister removes the instance with id
+	Deregister(ctx context.Context, id string) error
+
+	// Heartbeat renews the TTL of the instance with id
+	Heartbeat(ctx context.Context, id string) error
+}
+
+// Option represents a registrar option
+type Option func(*Registrar)
+
+// WithHeartbeatInterval sets the interval between heartbeats, a third of the TTL by default
+func WithHeartbeatInterval(d time.Duration) Option {
+	return func(r *Registrar) {
+		r.interval = d
+	}
+}
+
+// WithLogger sets the logger of heartbeat failures, the global logger by default
+func WithLogger(l *logger.Logger) Option {
+	return func(r *Registrar) {
+		r.logger = l
+	}
+}
+
+// Registrar is a service registered in a registry while it runs. It
+// registers the service once started, heartbeats while the service is
+// healthy, and deregisters it before stopping it so clients stop sending
+// traffic first.
+type Registrar struct {
+	service.Service
+	registry     Registry
+	registration Registration
+	interval     time.Duration
+	logger       *logger.Logger
+	logOnce      sync.Once
+	serviceLog   *logger.Logger
+
+	mu     sync.Mutex
+	active Registration
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+var _ service.Readier = (*Registrar)(nil)
+
+// Register registers svc in reg as described by r while it runs
+func Register(svc service.Service, reg Registry, r Registration, opts ...Option) *Registrar {
+	s := &Registrar{
+		Service:      svc,
+		registry:     reg,
+		registration: r,
+		interval:     r.TTL / 3,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Registration returns the registration of the running service, with its
+// defaults filled in
+func (s *Registrar) Registration() Registration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active
+}
+
+// Ready reports whether the service is ready, see service.IsReady
+func (s *Registrar) Ready() bool {
+	return service.IsReady(s.Service)
+}
+
+// Start starts the service, then registers it
+func (s *Registrar) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.Service.Start(ctx); err != nil {
+		return err
+	}
+
+	r, err := s.resolve()
+	if err == nil {
+		err = s.registry.Register(ctx, r)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to register service %s: %w", s.Name(), err)
+		return errors.Join(err, s.Service.Stop(ctx))
+	}
+	s.active = r
+
+	if r.TTL > 0 && s.interval > 0 {
+		hbCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		s.cancel = cancel
+		s.done = make(chan struct{})
+		go s.heartbeat(hbCtx, r, s.done)
+	}
+	return nil
+}
+
+// Stop deregisters the service, then stops it
+func (s *Registrar) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
+		s.cancel()
+		<-s.done
+		s.cancel = nil
+	}
+
+	var err error
+	if s.active.ID != "" {
+		if derr := s.registry.Deregister(ctx, s.active.ID); derr != nil {
+			err = fmt.Errorf("failed to deregister service %s: %w", s.Name(), derr)
+		}
+		s.active = Registration{}
+	}
+	return errors.Join(err, s.Service.Stop(ctx))
+}
+
+// heartbeat renews the TTL while the service is healthy, registering it
+// again when the registry lost it, until ctx is done
+func (s *Registrar) heartbeat(ctx context.Context, r Registration, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !s.Health() {
+			continue
+		}
+
+		err := s.registry.Heartbeat(ctx, r.ID)
+		if err == nil || ctx.Err() != nil {
+			continue
+		}
+		s.log().Warn("service heartbeat failed, registering again",
+			zap.String("id", r.ID), zap.Error(err))
+		if err := s.registry.Register(ctx, r); err != nil && ctx.Err() == nil {
+			s.log().Error("failed to register service",
+				zap.String("id", r.ID), zap.Error(err))
+		}
+	}
+}
+
+// resolve fills the defaults of the registration from the running service
+func (s *Registrar) resolve() (Registration, error) {
+	r := s.registration
+	if r.Name == "" {
+		r.Name = s.Name()
+	}
+
+	if l, ok := s.Service.(interface{ ListenAddr() net.Addr }); ok {
+		if addr, ok := l.ListenAddr().(*net.TCPAddr); ok {
+			if r.Port == 0 {
+				r.Port = addr.Port
+			}
+			if r.Address == "" && !addr.IP.IsUnspecified() {
+				r.Address = addr.IP.String()
+			}
+		}
+	}
+	if r.Port == 0 {
+		return r, errors.New("registration port is required")
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return r, fmt.Errorf("failed to get hostname: %w", err)
+	}
+	if r.Address == "" {
+		r.Address = hostname
+	}
+	if r.ID == "" {
+		r.ID = r.Name + "-" + hostname + "-" + strconv.Itoa(r.Port)
+	}
+	return r, nil
+}
+
+// log returns the logger of the service, tagged with its name, instance and
+// version by ForService
+func (s *Registrar) log() *logger.Logger {
+	s.logOnce.Do(func() {
+		if s.logger != nil {
+			s.serviceLog = s.logger.ForService(s.Name())
+		} else {
+			s.serviceLog = logger.ForService(s.Name())
+		}
+	})
+	return s.serviceLog
+}
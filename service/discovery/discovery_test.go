@@ -0,0 +1,157 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeService struct {
+	addr    net.Addr
+	healthy atomic.Bool
+	stopped atomic.Bool
+}
+
+func (s *fakeService) Name() string { return "api" }
+
+func (s *fakeService) Start(ctx context.Context) error {
+	s.healthy.Store(true)
+	return nil
+}
+
+func (s *fakeService) Stop(ctx context.Context) error {
+	s.healthy.Store(false)
+	s.stopped.Store(true)
+	return nil
+}
+
+func (s *fakeService) Health() bool { return s.healthy.Load() }
+
+func (s *fakeService) ListenAddr() net.Addr { return s.addr }
+
+type fakeRegistry struct {
+	mu           sync.Mutex
+	events       []string
+	registered   map[string]Registration
+	heartbeatErr error
+}
+
+func newFakeRegistry() *fakeRegistry {
+	return &fakeRegistry{registered: make(map[string]Registration)}
+}
+
+func (r *fakeRegistry) Register(ctx context.Context, reg Registration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, "register")
+	r.registered[reg.ID] = reg
+	return nil
+}
+
+func (r *fakeRegistry) Deregister(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, "deregister")
+	delete(r.registered, id)
+	return nil
+}
+
+func (r *fakeRegistry) Heartbeat(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, "heartbeat")
+	return r.heartbeatErr
+}
+
+func (r *fakeRegistry) count(event string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, e := range r.events {
+		if e == event {
+			n++
+		}
+	}
+	return n
+}
+
+func TestRegistrar(t *testing.T) {
+	ctx := context.Background()
+	hostname, _ := os.Hostname()
+
+	svc := &fakeService{addr: &net.TCPAddr{IP: net.IPv6zero, Port: 8080}}
+	reg := newFakeRegistry()
+	r := Register(svc, reg, Registration{Tags: []string{"v2"}, HealthPath: "/healthz"})
+	assert.Equal(t, "api", r.Name())
+
+	assert.NoError(t, r.Start(ctx))
+	assert.True(t, r.Health())
+	assert.True(t, r.Ready())
+
+	expected := Registration{
+		ID:         "api-" + hostname + "-8080",
+		Name:       "api",
+		Address:    hostname,
+		Port:       8080,
+		Tags:       []string{"v2"},
+		HealthPath: "/healthz",
+	}
+	assert.Equal(t, expected, r.Registration())
+	assert.Equal(t, expected, reg.registered[expected.ID])
+	assert.Equal(t, "http://"+net.JoinHostPort(hostname, "8080")+"/healthz", expected.HealthURL())
+
+	assert.NoError(t, r.Stop(ctx))
+	assert.Empty(t, reg.registered)
+	assert.True(t, svc.stopped.Load())
+	assert.Equal(t, []string{"register", "deregister"}, reg.events)
+}
+
+func TestRegistrar_ListenIP(t *testing.T) {
+	svc := &fakeService{addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.5"), Port: 9000}}
+	r := Register(svc, newFakeRegistry(), Registration{ID: "api-1"})
+
+	assert.NoError(t, r.Start(context.Background()))
+	assert.Equal(t, "10.0.0.5", r.Registration().Address)
+	assert.Equal(t, "api-1", r.Registration().ID)
+	assert.NoError(t, r.Stop(context.Background()))
+}
+
+func TestRegistrar_PortRequired(t *testing.T) {
+	svc := &fakeService{}
+	r := Register(svc, newFakeRegistry(), Registration{})
+
+	assert.ErrorContains(t, r.Start(context.Background()), "registration port is required")
+	assert.True(t, svc.stopped.Load())
+}
+
+func TestRegistrar_Heartbeat(t *testing.T) {
+	ctx := context.Background()
+	svc := &fakeService{}
+	reg := newFakeRegistry()
+	r := Register(svc, reg, Registration{Port: 8080, TTL: time.Second}, WithHeartbeatInterval(time.Millisecond))
+
+	assert.NoError(t, r.Start(ctx))
+	assert.Eventually(t, func() bool { return reg.count("heartbeat") >= 2 }, time.Second, time.Millisecond)
+
+	// A lost registration is registered again
+	reg.mu.Lock()
+	reg.heartbeatErr = errors.New("unknown check")
+	reg.mu.Unlock()
+	assert.Eventually(t, func() bool { return reg.count("register") >= 2 }, time.Second, time.Millisecond)
+
+	// An unhealthy service stops heartbeating, so the registry drops it
+	svc.healthy.Store(false)
+	time.Sleep(5 * time.Millisecond)
+	beats := reg.count("heartbeat")
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, beats, reg.count("heartbeat"))
+
+	assert.NoError(t, r.Stop(ctx))
+}
@@ -0,0 +1,151 @@
+// Package etcd registers gocore services as etcd keys kept alive by a lease
+//
+//	client, _ := clientv3.New(clientv3.Config{Endpoints: []string{"etcd:2379"}})
+//	m.Register(discovery.Register(svc, etcd.New(client), discovery.Registration{
+//		TTL: 15 * time.Second,
+//	}))
+//
+// Instances are stored as JSON under prefix/name/id, clients list them with
+// a prefix Get and watch the prefix for changes.
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/ducconit/gocore/service/discovery"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// DefaultPrefix is the key prefix of the registered instances
+const DefaultPrefix = "/services/"
+
+// Option represents a registry option
+type Option func(*Registry)
+
+// WithPrefix sets the key prefix of the registered instances
+func WithPrefix(prefix string) Option {
+	return func(r *Registry) {
+		r.prefix = prefix
+	}
+}
+
+// Registry registers services in etcd. An instance with a TTL is attached
+// to a lease, so its key disappears when the heartbeats stop.
+type Registry struct {
+	kv     clientv3.KV
+	lease  clientv3.Lease
+	prefix string
+
+	mu        sync.Mutex
+	instances map[string]instance
+}
+
+type instance struct {
+	key   string
+	lease clientv3.LeaseID
+}
+
+var _ discovery.Registry = (*Registry)(nil)
+
+// New creates a registry storing instances through client
+func New(client *clientv3.Client, opts ...Option) *Registry {
+	return newRegistry(client, client, opts...)
+}
+
+func newRegistry(kv clientv3.KV, lease clientv3.Lease, opts ...Option) *Registry {
+	r := &Registry{
+		kv:        kv,
+		lease:     lease,
+		prefix:    DefaultPrefix,
+		instances: make(map[string]instance),
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Key returns the key of an instance
+func (r *Registry) Key(name, id string) string {
+	return r.prefix + name + "/" + id
+}
+
+// Register stores the instance, under a new lease when it has a TTL
+func (r *Registry) Register(ctx context.Context, reg discovery.Registration) error {
+	value, err := json.Marshal(reg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal registration: %w", err)
+	}
+
+	inst := instance{key: r.Key(reg.Name, reg.ID)}
+	var opts []clientv3.OpOption
+	if reg.TTL > 0 {
+		// etcd leases have a one second resolution
+		ttl := max(int64(reg.TTL.Seconds()), 1)
+		grant, err := r.lease.Grant(ctx, ttl)
+		if err != nil {
+			return fmt.Errorf("failed to grant etcd lease: %w", err)
+		}
+		inst.lease = grant.ID
+		opts = append(opts, clientv3.WithLease(grant.ID))
+	}
+
+	if _, err := r.kv.Put(ctx, inst.key, string(value), opts...); err != nil {
+		return fmt.Errorf("failed to put etcd key %s: %w", inst.key, err)
+	}
+
+	r.mu.Lock()
+	previous, ok := r.instances[reg.ID]
+	r.instances[reg.ID] = inst
+	r.mu.Unlock()
+
+	if ok && previous.lease != 0 && previous.lease != inst.lease {
+		_, _ = r.lease.Revoke(ctx, previous.lease)
+	}
+	return nil
+}
+
+// Deregister deletes the instance and revokes its lease
+func (r *Registry) Deregister(ctx context.Context, id string) error {
+	r.mu.Lock()
+	inst, ok := r.instances[id]
+	delete(r.instances, id)
+	r.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	if _, err := r.kv.Delete(ctx, inst.key); err != nil {
+		return fmt.Errorf("failed to delete etcd key %s: %w", inst.key, err)
+	}
+	if inst.lease != 0 {
+		if _, err := r.lease.Revoke(ctx, inst.lease); err != nil {
+			return fmt.Errorf("failed to revoke etcd lease: %w", err)
+		}
+	}
+	return nil
+}
+
+// Heartbeat renews the lease of the instance
+func (r *Registry) Heartbeat(ctx context.Context, id string) error {
+	r.mu.Lock()
+	inst, ok := r.instances[id]
+	r.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("instance %s is not registered", id)
+	}
+	if inst.lease == 0 {
+		return nil
+	}
+	if _, err := r.lease.KeepAliveOnce(ctx, inst.lease); err != nil {
+		return fmt.Errorf("failed to renew etcd lease: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ducconit/gocore/service/discovery"
+	"github.com/stretchr/testify/assert"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type fakeKV struct {
+	clientv3.KV
+	values map[string]string
+}
+
+func (kv *fakeKV) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	kv.values[key] = val
+	return &clientv3.PutResponse{}, nil
+}
+
+func (kv *fakeKV) Delete(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.DeleteResponse, error) {
+	delete(kv.values, key)
+	return &clientv3.DeleteResponse{}, nil
+}
+
+type fakeLease struct {
+	clientv3.Lease
+	next    clientv3.LeaseID
+	ttls    map[clientv3.LeaseID]int64
+	renewed []clientv3.LeaseID
+}
+
+func (l *fakeLease) Grant(ctx context.Context, ttl int64) (*clientv3.LeaseGrantResponse, error) {
+	l.next++
+	l.ttls[l.next] = ttl
+	return &clientv3.LeaseGrantResponse{ID: l.next, TTL: ttl}, nil
+}
+
+func (l *fakeLease) Revoke(ctx context.Context, id clientv3.LeaseID) (*clientv3.LeaseRevokeResponse, error) {
+	delete(l.ttls, id)
+	return &clientv3.LeaseRevokeResponse{}, nil
+}
+
+func (l *fakeLease) KeepAliveOnce(ctx context.Context, id clientv3.LeaseID) (*clientv3.LeaseKeepAliveResponse, error) {
+	if _, ok := l.ttls[id]; !ok {
+		return nil, errors.New("requested lease not found")
+	}
+	l.renewed = append(l.renewed, id)
+	return &clientv3.LeaseKeepAliveResponse{ID: id}, nil
+}
+
+func TestRegistry(t *testing.T) {
+	ctx := context.Background()
+	kv := &fakeKV{values: make(map[string]string)}
+	lease := &fakeLease{ttls: make(map[clientv3.LeaseID]int64)}
+	r := newRegistry(kv, lease, WithPrefix("/prod/"))
+
+	reg := discovery.Registration{
+		ID:      "api-1",
+		Name:    "api",
+		Address: "10.0.0.5",
+		Port:    8080,
+		Tags:    []string{"v2"},
+		TTL:     1500 * time.Millisecond,
+	}
+	assert.NoError(t, r.Register(ctx, reg))
+	assert.Equal(t, "/prod/api/api-1", r.Key("api", "api-1"))
+
+	var stored discovery.Registration
+	assert.NoError(t, json.Unmarshal([]byte(kv.values["/prod/api/api-1"]), &stored))
+	reg.TTL = 0
+	assert.Equal(t, reg, stored)
+	assert.Equal(t, map[clientv3.LeaseID]int64{1: 1}, lease.ttls)
+
+	assert.NoError(t, r.Heartbeat(ctx, "api-1"))
+	assert.Equal(t, []clientv3.LeaseID{1}, lease.renewed)
+
+	// Registering again, e.g. after the lease expired, revokes the previous lease
+	reg.TTL = 10 * time.Second
+	assert.NoError(t, r.Register(ctx, reg))
+	assert.Equal(t, map[clientv3.LeaseID]int64{2: 10}, lease.ttls)
+
+	assert.NoError(t, r.Deregister(ctx, "api-1"))
+	assert.Empty(t, kv.values)
+	assert.Empty(t, lease.ttls)
+	assert.ErrorContains(t, r.Heartbeat(ctx, "api-1"), "not registered")
+}
+
+func TestRegistry_ExpiredLease(t *testing.T) {
+	ctx := context.Background()
+	lease := &fakeLease{ttls: make(map[clientv3.LeaseID]int64)}
+	r := newRegistry(&fakeKV{values: make(map[string]string)}, lease)
+
+	assert.NoError(t, r.Register(ctx, discovery.Registration{ID: "api-1", Name: "api", TTL: time.Second}))
+	delete(lease.ttls, 1)
+	assert.ErrorContains(t, r.Heartbeat(ctx, "api-1"), "failed to renew etcd lease")
+}
@@ -0,0 +1,437 @@
+// Package grpc runs a gRPC server as a service.Service
+//
+//	svc := grpc.NewGRPCService("api", grpc.WithAddress(":50051"))
+//	pb.RegisterGreeterServer(svc, &greeter{})
+//	manager.Register(svc)
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/ducconit/gocore/service"
+	"go.uber.org/zap"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultAddress is the address served when none is given
+const DefaultAddress = ":50051"
+
+// RequestIDMetadata is the metadata key the request ID is read from
+var RequestIDMetadata = "x-request-id"
+
+// errAlreadyStarted is returned by Start while the service is running
+var errAlreadyStarted = errors.New("grpc service already started")
+
+// Option represents a gRPC service option
+type Option func(*GRPCService)
+
+// WithAddress sets the TCP address to listen on
+func WithAddress(addr string) Option {
+	return func(s *GRPCService) {
+		s.addr = addr
+	}
+}
+
+// WithListener serves on l instead of listening on the address
+func WithListener(l net.Listener) Option {
+	return func(s *GRPCService) {
+		s.listener = l
+	}
+}
+
+// WithLogger sets the logger of the logging and recovery interceptors,
+// the global logger by default
+func WithLogger(l *logger.Logger) Option {
+	return func(s *GRPCService) {
+		s.logger = l
+	}
+}
+
+// WithServerOptions adds options to the underlying grpc.Server
+func WithServerOptions(opts ...grpclib.ServerOption) Option {
+	return func(s *GRPCService) {
+		s.serverOpts = append(s.serverOpts, opts...)
+	}
+}
+
+// WithUnaryInterceptors adds unary interceptors, run after logging and recovery
+func WithUnaryInterceptors(interceptors ...grpclib.UnaryServerInterceptor) Option {
+	return func(s *GRPCService) {
+		s.unary = append(s.unary, interceptors...)
+	}
+}
+
+// WithStreamInterceptors adds stream interceptors, run after logging and recovery
+func WithStreamInterceptors(interceptors ...grpclib.StreamServerInterceptor) Option {
+	return func(s *GRPCService) {
+		s.stream = append(s.stream, interceptors...)
+	}
+}
+
+// WithReflection enables or disables the reflection service, enabled by default
+func WithReflection(enabled bool) Option {
+	return func(s *GRPCService) {
+		s.reflection = enabled
+	}
+}
+
+// WithHealthService enables or disables the grpc.health.v1 service, enabled by default
+func WithHealthService(enabled bool) Option {
+	return func(s *GRPCService) {
+		s.healthService = enabled
+	}
+}
+
+var (
+	_ service.Service          = (*GRPCService)(nil)
+	_ grpclib.ServiceRegistrar = (*GRPCService)(nil)
+)
+
+// GRPCService is a service.Service serving gRPC. Register services on it
+// before it is started, they are registered again on the new grpc.Server of
+// each restart.
+type GRPCService struct {
+	name          string
+	addr          string
+	listener      net.Listener
+	logger        *logger.Logger
+	logOnce       sync.Once
+	serviceLog    *logger.Logger
+	serverOpts    []grpclib.ServerOption
+	unary         []grpclib.UnaryServerInterceptor
+	stream        []grpclib.StreamServerInterceptor
+	reflection    bool
+	healthService bool
+	hooks         service.Hooks
+
+	server   *grpclib.Server
+	health   *health.Server
+	services []registration
+	mu       sync.Mutex
+	done     chan struct{}
+	running  atomic.Bool
+
+	// stale is set once server was stopped, a grpc.Server cannot serve again
+	stale bool
+}
+
+// registration is a service registered on the server, replayed on restarts
+type registration struct {
+	desc *grpclib.ServiceDesc
+	impl any
+}
+
+// NewGRPCService creates a gRPC service named name
+func NewGRPCService(name string, opts ...Option) *GRPCService {
+	s := &GRPCService{
+		name:          name,
+		addr:          DefaultAddress,
+		reflection:    true,
+		healthService: true,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.healthService {
+		s.health = health.NewServer()
+		s.health.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	s.server = s.newServer()
+	return s
+}
+
+// newServer creates a grpc.Server with the interceptors, the built-in
+// services and the registered ones
+func (s *GRPCService) newServer() *grpclib.Server {
+	unary := append([]grpclib.UnaryServerInterceptor{s.unaryInterceptor}, s.unary...)
+	stream := append([]grpclib.StreamServerInterceptor{s.streamInterceptor}, s.stream...)
+	serverOpts := append([]grpclib.ServerOption{
+		grpclib.ChainUnaryInterceptor(unary...),
+		grpclib.ChainStreamInterceptor(stream...),
+	}, s.serverOpts...)
+
+	server := grpclib.NewServer(serverOpts...)
+	if s.health != nil {
+		healthpb.RegisterHealthServer(server, s.health)
+	}
+	if s.reflection {
+		reflection.Register(server)
+	}
+	for _, r := range s.services {
+		server.RegisterService(r.desc, r.impl)
+	}
+	return server
+}
+
+// Name returns the name of the service
+func (s *GRPCService) Name() string {
+	return s.name
+}
+
+// Server returns the underlying grpc.Server, a new one after each restart.
+// Register services on the GRPCService rather than on it, so they survive
+// restarts.
+func (s *GRPCService) Server() *grpclib.Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.server
+}
+
+// RegisterService registers a service implementation, see grpc.ServiceRegistrar
+func (s *GRPCService) RegisterService(desc *grpclib.ServiceDesc, impl any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.services = append(s.services, registration{desc: desc, impl: impl})
+	s.server.RegisterService(desc, impl)
+	if s.health != nil {
+		s.health.SetServingStatus(desc.ServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+}
+
+// ListenAddr returns the address the service is bound to, e.g. the port
+// picked for ":0", nil while it is stopped
+func (s *GRPCService) ListenAddr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// OnStart adds a hook run before the server listens, see service.Hooks
+func (s *GRPCService) OnStart(fn service.Hook) {
+	s.hooks.OnStart(fn)
+}
+
+// OnStop adds a hook run after the server is stopped, see service.Hooks
+func (s *GRPCService) OnStop(fn service.Hook) {
+	s.hooks.OnStop(fn)
+}
+
+// Start runs the start hooks, then listens and serves in the background. A
+// stopped service can be started again.
+func (s *GRPCService) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done != nil {
+		return errAlreadyStarted
+	}
+
+	if err := s.hooks.Start(ctx); err != nil {
+		return fmt.Errorf("failed to run start hooks: %w", err)
+	}
+
+	if s.listener == nil {
+		var lc net.ListenConfig
+		l, err := lc.Listen(ctx, "tcp", s.addr)
+		if err != nil {
+			return errors.Join(fmt.Errorf("failed to listen on %s: %w", s.addr, err), s.hooks.Stop(ctx))
+		}
+		s.listener = l
+	}
+	if s.stale {
+		s.server = s.newServer()
+		s.stale = false
+	}
+
+	done := make(chan struct{})
+	s.done = done
+	go func(server *grpclib.Server, l net.Listener) {
+		defer close(done)
+		if err := server.Serve(l); err != nil {
+			s.log().Error("grpc server failed", zap.Error(err))
+		}
+		s.running.Store(false)
+	}(s.server, s.listener)
+
+	if s.health != nil {
+		s.health.Resume()
+		s.health.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+		for name := range s.server.GetServiceInfo() {
+			s.health.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+		}
+	}
+	s.running.Store(true)
+	return nil
+}
+
+// Stop stops accepting connections and waits for the running calls to
+// finish, then runs the stop hooks. Calls still running when ctx is done are
+// cancelled.
+func (s *GRPCService) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	server, done := s.server, s.done
+	s.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+
+	s.running.Store(false)
+	if s.health != nil {
+		// Tell health checking clients before draining
+		s.health.Shutdown()
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	var err error
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		server.Stop()
+		err = ctx.Err()
+	}
+	<-done
+
+	s.mu.Lock()
+	s.listener = nil
+	s.done = nil
+	s.stale = true
+	s.mu.Unlock()
+
+	if hookErr := s.hooks.Stop(ctx); hookErr != nil {
+		err = errors.Join(err, fmt.Errorf("failed to run stop hooks: %w", hookErr))
+	}
+	return err
+}
+
+// Restart stops the service, draining the running calls, and starts it
+// again on a new listener and grpc.Server
+func (s *GRPCService) Restart(ctx context.Context) error {
+	if err := s.Stop(ctx); err != nil {
+		return fmt.Errorf("failed to stop %s: %w", s.name, err)
+	}
+	return s.Start(ctx)
+}
+
+// Run starts the service and blocks until ctx is done, then stops it, see service.Run
+func (s *GRPCService) Run(ctx context.Context) error {
+	return service.Run(ctx, s)
+}
+
+// Health reports whether the server is serving
+func (s *GRPCService) Health() bool {
+	return s.running.Load()
+}
+
+// log returns the logger of the service, tagged with its name, instance and
+// version by ForService
+func (s *GRPCService) log() *logger.Logger {
+	s.logOnce.Do(func() {
+		if s.logger != nil {
+			s.serviceLog = s.logger.ForService(s.name)
+		} else {
+			s.serviceLog = logger.ForService(s.name)
+		}
+	})
+	return s.serviceLog
+}
+
+// unaryInterceptor logs every call and recovers from panics in handlers
+func (s *GRPCService) unaryInterceptor(ctx context.Context, req any, info *grpclib.UnaryServerInfo, handler grpclib.UnaryHandler) (resp any, err error) {
+	start := time.Now()
+	ctx = s.context(ctx)
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = s.recovered(ctx, info.FullMethod, r)
+		}
+		s.logCall(ctx, info.FullMethod, start, err)
+	}()
+	return handler(ctx, req)
+}
+
+// streamInterceptor logs every stream and recovers from panics in handlers
+func (s *GRPCService) streamInterceptor(srv any, ss grpclib.ServerStream, info *grpclib.StreamServerInfo, handler grpclib.StreamHandler) (err error) {
+	start := time.Now()
+	ctx := s.context(ss.Context())
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = s.recovered(ctx, info.FullMethod, r)
+		}
+		s.logCall(ctx, info.FullMethod, start, err)
+	}()
+	return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+}
+
+// context returns ctx carrying the logger and the request ID, so handlers
+// can use logger.FromContext
+func (s *GRPCService) context(ctx context.Context) context.Context {
+	ctx = logger.ContextWithLogger(ctx, s.log())
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(RequestIDMetadata); len(ids) > 0 && ids[0] != "" {
+			ctx = logger.ContextWithRequestID(ctx, ids[0])
+		}
+	}
+	return ctx
+}
+
+func (s *GRPCService) recovered(ctx context.Context, method string, r any) error {
+	s.log().Ctx(ctx).Error("panic recovered",
+		zap.Any("panic", r),
+		zap.String("method", method),
+		zap.ByteString("stacktrace", debug.Stack()),
+	)
+	return status.Error(codes.Internal, "internal error")
+}
+
+func (s *GRPCService) logCall(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+	fields := []zap.Field{
+		zap.String("method", method),
+		zap.String("code", code.String()),
+		zap.Duration("latency", time.Since(start)),
+	}
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	s.log().Ctx(ctx).Log(codeLevel(code), "grpc request", fields...)
+}
+
+// codeLevel maps server faults to ErrorLevel and client faults to WarnLevel
+func codeLevel(code codes.Code) logger.Level {
+	switch code {
+	case codes.OK:
+		return logger.InfoLevel
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented, codes.Internal,
+		codes.Unavailable, codes.DataLoss:
+		return logger.ErrorLevel
+	default:
+		return logger.WarnLevel
+	}
+}
+
+// contextStream overrides the context of a ServerStream
+type contextStream struct {
+	grpclib.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
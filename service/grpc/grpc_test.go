@@ -0,0 +1,120 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/stretchr/testify/assert"
+	grpclib "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestGRPCService(t *testing.T) {
+	ctx := context.Background()
+
+	var buf syncBuffer
+	svc := NewGRPCService("api",
+		WithAddress("127.0.0.1:0"),
+		WithLogger(logger.New(logger.WithOutput(&buf))),
+		WithUnaryInterceptors(func(ctx context.Context, req any, info *grpclib.UnaryServerInfo, handler grpclib.UnaryHandler) (any, error) {
+			if md, _ := metadata.FromIncomingContext(ctx); len(md.Get("panic")) > 0 {
+				panic("boom")
+			}
+			return handler(ctx, req)
+		}),
+	)
+	assert.Equal(t, "api", svc.Name())
+	assert.False(t, svc.Health())
+	assert.Nil(t, svc.ListenAddr())
+
+	assert.NoError(t, svc.Start(ctx))
+	assert.True(t, svc.Health())
+	assert.ErrorIs(t, svc.Start(ctx), errAlreadyStarted)
+
+	conn, err := grpclib.NewClient(svc.ListenAddr().String(), grpclib.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	callCtx := metadata.AppendToOutgoingContext(ctx, RequestIDMetadata, "req-1")
+	resp, err := client.Check(callCtx, &healthpb.HealthCheckRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+
+	// Panics become Internal errors
+	_, err = client.Check(metadata.AppendToOutgoingContext(ctx, "panic", "1"), &healthpb.HealthCheckRequest{})
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Contains(t, lines[0], `"msg":"grpc request"`)
+	assert.Contains(t, lines[0], `"method":"/grpc.health.v1.Health/Check"`)
+	assert.Contains(t, lines[0], `"request_id":"req-1"`)
+	assert.Contains(t, lines[0], `"service":"api","instance_id":"`+logger.InstanceID+`"`)
+	assert.Contains(t, lines[1], `"msg":"panic recovered"`)
+	assert.Contains(t, lines[2], `"code":"Internal"`)
+
+	assert.NoError(t, svc.Stop(ctx))
+	assert.False(t, svc.Health())
+}
+
+func TestGRPCService_Restart(t *testing.T) {
+	ctx := context.Background()
+
+	// The health service is registered by the caller, so it must be replayed
+	svc := NewGRPCService("api", WithAddress("127.0.0.1:0"), WithHealthService(false))
+	healthpb.RegisterHealthServer(svc, health.NewServer())
+
+	check := func() {
+		conn, err := grpclib.NewClient(svc.ListenAddr().String(), grpclib.WithTransportCredentials(insecure.NewCredentials()))
+		assert.NoError(t, err)
+		defer conn.Close()
+		resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+		assert.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+	}
+
+	assert.NoError(t, svc.Start(ctx))
+	first := svc.Server()
+	check()
+
+	assert.NoError(t, svc.Stop(ctx))
+	assert.False(t, svc.Health())
+	assert.Nil(t, svc.ListenAddr())
+
+	// A stopped service starts again on a new server
+	assert.NoError(t, svc.Start(ctx))
+	assert.True(t, svc.Health())
+	assert.NotSame(t, first, svc.Server())
+	check()
+
+	assert.NoError(t, svc.Restart(ctx))
+	check()
+	assert.NoError(t, svc.Stop(ctx))
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultHealthCheckTimeout bounds each check run by CheckHealth
+var DefaultHealthCheckTimeout = 5 * time.Second
+
+// Health statuses
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// HealthChecker checks a dependency of a service, e.g. pings a database
+type HealthChecker interface {
+	Check(ctx context.Context) error
+}
+
+// HealthCheckerFunc adapts a function to a HealthChecker
+type HealthCheckerFunc func(ctx context.Context) error
+
+// Check calls f
+func (f HealthCheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Pinger is implemented by clients such as *sql.DB
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// PingChecker checks p by pinging it
+func PingChecker(p Pinger) HealthChecker {
+	return HealthCheckerFunc(p.PingContext)
+}
+
+// CheckResult is the outcome of a health check
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// HealthReport aggregates health check results, it is up when every check is
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Up reports whether every check passed
+func (r HealthReport) Up() bool {
+	return r.Status == StatusUp
+}
+
+// CheckHealth runs checks concurrently, each bounded by DefaultHealthCheckTimeout
+func CheckHealth(ctx context.Context, checks map[string]HealthChecker) HealthReport {
+	report := HealthReport{
+		Status: StatusUp,
+		Checks: make(map[string]CheckResult, len(checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, checker := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, DefaultHealthCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := checker.Check(ctx)
+			result := CheckResult{
+				Status:    StatusUp,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				result.Status = StatusDown
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			report.Checks[name] = result
+			if err != nil {
+				report.Status = StatusDown
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+// HealthChecks is a set of named health checks, safe for concurrent use
+type HealthChecks struct {
+	mu     sync.RWMutex
+	checks map[string]HealthChecker
+}
+
+// Add registers checker under name, replacing any check of that name
+func (h *HealthChecks) Add(name string, checker HealthChecker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.checks == nil {
+		h.checks = make(map[string]HealthChecker)
+	}
+	h.checks[name] = checker
+}
+
+// Check runs the registered checks
+func (h *HealthChecks) Check(ctx context.Context) HealthReport {
+	h.mu.RLock()
+	checks := make(map[string]HealthChecker, len(h.checks))
+	for name, checker := range h.checks {
+		checks[name] = checker
+	}
+	h.mu.RUnlock()
+	return CheckHealth(ctx, checks)
+}
+
+// ServeHTTP responds with the JSON health report, 200 when up and 503 when down
+func (h *HealthChecks) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	WriteHealthReport(w, h.Check(r.Context()))
+}
+
+// WriteHealthReport writes report as JSON, 200 when up and 503 when down
+func WriteHealthReport(w http.ResponseWriter, report HealthReport) {
+	status := http.StatusOK
+	if !report.Up() {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(report)
+}
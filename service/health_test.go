@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type pingFunc func(ctx context.Context) error
+
+func (f pingFunc) PingContext(ctx context.Context) error { return f(ctx) }
+
+func TestHealthChecks(t *testing.T) {
+	var checks HealthChecks
+	checks.Add("db", PingChecker(pingFunc(func(ctx context.Context) error { return nil })))
+
+	report := checks.Check(context.Background())
+	assert.True(t, report.Up())
+	assert.Equal(t, StatusUp, report.Checks["db"].Status)
+
+	w := httptest.NewRecorder()
+	checks.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	checks.Add("redis", HealthCheckerFunc(func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}))
+
+	w = httptest.NewRecorder()
+	checks.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	var body HealthReport
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, StatusDown, body.Status)
+	assert.Equal(t, StatusUp, body.Checks["db"].Status)
+	assert.Equal(t, CheckResult{Status: StatusDown, LatencyMs: body.Checks["redis"].LatencyMs, Error: "connection refused"}, body.Checks["redis"])
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Hook is a function run when a service or manager starts or stops
+type Hook func(ctx context.Context) error
+
+// Hooks holds the start and stop hooks of a service, so resources such as
+// database pools and caches are opened and closed with it. The zero value
+// is ready to use.
+type Hooks struct {
+	mu      sync.Mutex
+	onStart []Hook
+	onStop  []Hook
+}
+
+// OnStart adds a hook run before starting, in the order they are added
+func (h *Hooks) OnStart(fn Hook) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onStart = append(h.onStart, fn)
+}
+
+// OnStop adds a hook run after stopping, in the reverse order they are added
+func (h *Hooks) OnStop(fn Hook) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.onStop = append(h.onStop, fn)
+}
+
+// Start runs the start hooks, stopping at the first error
+func (h *Hooks) Start(ctx context.Context) error {
+	h.mu.Lock()
+	hooks := h.onStart
+	h.mu.Unlock()
+
+	for _, fn := range hooks {
+		if err := fn(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stop runs every stop hook, joining their errors
+func (h *Hooks) Stop(ctx context.Context) error {
+	h.mu.Lock()
+	hooks := h.onStop
+	h.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i](ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
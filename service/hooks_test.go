@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHooks(t *testing.T) {
+	ctx := context.Background()
+	log := &eventLog{}
+	hook := func(event string, err error) Hook {
+		return func(ctx context.Context) error {
+			log.add(event)
+			return err
+		}
+	}
+
+	var h Hooks
+	assert.NoError(t, h.Start(ctx))
+	assert.NoError(t, h.Stop(ctx))
+
+	h.OnStart(hook("open:db", nil))
+	h.OnStart(hook("open:cache", errors.New("unreachable")))
+	h.OnStart(hook("open:never", nil))
+	h.OnStop(hook("close:db", errors.New("busy")))
+	h.OnStop(hook("close:cache", errors.New("timeout")))
+
+	assert.EqualError(t, h.Start(ctx), "unreachable")
+	assert.EqualError(t, h.Stop(ctx), "timeout\nbusy")
+	assert.Equal(t, []string{"open:db", "open:cache", "close:cache", "close:db"}, log.all())
+}
+
+func TestManager_Hooks(t *testing.T) {
+	ctx := context.Background()
+	log := &eventLog{}
+
+	m := NewManager()
+	assert.NoError(t, m.Register(newFakeService("api", log)))
+	m.OnStart(func(ctx context.Context) error {
+		log.add("open:db")
+		return nil
+	})
+	m.OnStop(func(ctx context.Context) error {
+		log.add("close:db")
+		return nil
+	})
+
+	assert.NoError(t, m.Start(ctx))
+	assert.NoError(t, m.Stop(ctx))
+	// Stopping again does not run the hooks twice
+	assert.NoError(t, m.Stop(ctx))
+	assert.Equal(t, []string{"open:db", "start:api", "stop:api", "close:db"}, log.all())
+}
+
+func TestManager_StartHookFailure(t *testing.T) {
+	log := &eventLog{}
+
+	m := NewManager()
+	assert.NoError(t, m.Register(newFakeService("api", log)))
+	m.OnStart(func(ctx context.Context) error {
+		return errors.New("db unreachable")
+	})
+
+	assert.ErrorContains(t, m.Start(context.Background()), "failed to run start hooks: db unreachable")
+	assert.Empty(t, log.all())
+}
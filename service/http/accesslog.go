@@ -0,0 +1,96 @@
+package http
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/ducconit/gocore/service"
+	"go.uber.org/zap"
+)
+
+// AccessLog logs every request once it is handled: at InfoLevel, WarnLevel
+// for 4xx and ErrorLevel for 5xx responses. Only a sampleRate fraction of
+// the successful requests is logged, 1 logs them all; failed requests are
+// always logged.
+func AccessLog(l *logger.Logger, sampleRate float64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			r, route := withRoute(r)
+			rw := &responseWriter{ResponseWriter: w}
+
+			next.ServeHTTP(rw, r)
+
+			status := rw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if status < http.StatusBadRequest && sampleRate < 1 && rand.Float64() >= sampleRate {
+				return
+			}
+
+			fields := []zap.Field{
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.String("route", routePattern(r, route)),
+				zap.Int("status", status),
+				zap.Duration("latency", time.Since(start)),
+				zap.Int64("bytes", rw.bytes),
+				zap.String("remote_addr", r.RemoteAddr),
+			}
+			// Ctx adds the request ID carried by the context, fall back to the
+			// header when the request ID middleware is not installed
+			if service.RequestIDFromContext(r.Context()) == "" {
+				fields = append(fields, zap.String(logger.RequestIDKey, r.Header.Get(RequestIDHeader)))
+			}
+
+			l.Ctx(r.Context()).Log(statusLevel(status), "http request", fields...)
+		})
+	}
+}
+
+func statusLevel(status int) logger.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return logger.ErrorLevel
+	case status >= http.StatusBadRequest:
+		return logger.WarnLevel
+	default:
+		return logger.InfoLevel
+	}
+}
+
+// responseWriter records the status and size of a response. Unwrap lets
+// http.ResponseController reach the underlying writer.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher, for handlers streaming responses
+func (w *responseWriter) Flush() {
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
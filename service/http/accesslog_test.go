@@ -0,0 +1,89 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPService_AccessLog(t *testing.T) {
+	ctx := context.Background()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "order")
+	})
+	mux.HandleFunc("GET /fail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	get := func(svc *HTTPService, path string) {
+		req, _ := http.NewRequest(http.MethodGet, svc.url("http")+path, nil)
+		req.Header.Set(RequestIDHeader, "req-1")
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		var buf syncBuffer
+		svc := NewHTTPService("api", mux, WithAddr("127.0.0.1:0"), WithLogger(logger.New(logger.WithOutput(&buf))))
+		assert.NoError(t, svc.Start(ctx))
+		get(svc, "/orders/1")
+		assert.NoError(t, svc.Stop(ctx))
+
+		line := buf.String()
+		assert.Contains(t, line, `"msg":"http request"`)
+		assert.Contains(t, line, `"level":"info"`)
+		assert.Contains(t, line, `"path":"/orders/1"`)
+		assert.Contains(t, line, `"route":"GET /orders/{id}"`)
+		assert.Contains(t, line, `"status":200`)
+		assert.Contains(t, line, `"bytes":5`)
+		assert.Contains(t, line, `"request_id":"req-1"`)
+		assert.Equal(t, 1, strings.Count(line, `"request_id"`))
+		assert.Contains(t, line, `"remote_addr":"127.0.0.1:`)
+	})
+
+	t.Run("request_id_middleware", func(t *testing.T) {
+		var buf syncBuffer
+		svc := NewHTTPService("api", mux, WithAddr("127.0.0.1:0"),
+			WithLogger(logger.New(logger.WithOutput(&buf))), WithRequestID())
+		assert.NoError(t, svc.Start(ctx))
+		get(svc, "/orders/1")
+		assert.NoError(t, svc.Stop(ctx))
+
+		line := buf.String()
+		assert.Contains(t, line, `"request_id":"req-1"`)
+		assert.Equal(t, 1, strings.Count(line, `"request_id"`))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		var buf syncBuffer
+		svc := NewHTTPService("api", mux, WithAddr("127.0.0.1:0"),
+			WithLogger(logger.New(logger.WithOutput(&buf))), WithoutAccessLog())
+		assert.NoError(t, svc.Start(ctx))
+		get(svc, "/orders/1")
+		assert.NoError(t, svc.Stop(ctx))
+		assert.Empty(t, buf.String())
+	})
+
+	t.Run("sampled", func(t *testing.T) {
+		var buf syncBuffer
+		svc := NewHTTPService("api", mux, WithAddr("127.0.0.1:0"),
+			WithLogger(logger.New(logger.WithOutput(&buf))), WithAccessLogSampling(0))
+		assert.NoError(t, svc.Start(ctx))
+		get(svc, "/orders/1")
+		get(svc, "/fail")
+		assert.NoError(t, svc.Stop(ctx))
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Len(t, lines, 1)
+		assert.Contains(t, lines[0], `"level":"error"`)
+		assert.Contains(t, lines[0], `"status":502`)
+	})
+}
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// certReloadDelay lets both files of a rotation be written before reloading
+const certReloadDelay = 100 * time.Millisecond
+
+// WithTLSFiles serves HTTPS with the PEM certificate and key of certFile and
+// keyFile, reloaded when they change so rotations need no restart. Options
+// of WithTLS, such as MinVersion, apply too.
+func WithTLSFiles(certFile, keyFile string) Option {
+	return func(s *HTTPService) {
+		s.certFiles = &certReloader{certFile: certFile, keyFile: keyFile}
+	}
+}
+
+// WithGetCertificate serves HTTPS with the certificate returned by fn for
+// each handshake, e.g. from a secret store or an ACME client. Options of
+// WithTLS, such as MinVersion, apply too.
+func WithGetCertificate(fn func(*tls.ClientHelloInfo) (*tls.Certificate, error)) Option {
+	return func(s *HTTPService) {
+		s.getCert = fn
+	}
+}
+
+// certReloader serves the last certificate loaded from its files
+type certReloader struct {
+	certFile string
+	keyFile  string
+	cert     atomic.Pointer[tls.Certificate]
+}
+
+// load reads the certificate files, keeping the previous certificate on failure
+func (c *certReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load tls certificate: %w", err)
+	}
+	c.cert.Store(&cert)
+	return nil
+}
+
+// GetCertificate returns the last certificate loaded
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return c.cert.Load(), nil
+}
+
+// watch reloads the certificate when its files change, until ctx is done.
+// The directories are watched rather than the files, as Kubernetes swaps
+// symlinks to rotate secrets.
+func (c *certReloader) watch(ctx context.Context, l *logger.Logger) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch tls certificate: %w", err)
+	}
+	for _, dir := range []string{filepath.Dir(c.certFile), filepath.Dir(c.keyFile)} {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return fmt.Errorf("failed to watch tls certificate: %w", err)
+		}
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		timer := time.NewTimer(0)
+		<-timer.C
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				timer.Reset(certReloadDelay)
+			case <-timer.C:
+				if err := c.load(); err != nil {
+					l.Warn("failed to reload tls certificate, keeping the previous one",
+						zap.String("cert_file", c.certFile), zap.Error(err))
+					continue
+				}
+				l.Info("tls certificate reloaded", zap.String("cert_file", c.certFile))
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				l.Warn("tls certificate watcher failed", zap.Error(err))
+			}
+		}
+	}()
+	return nil
+}
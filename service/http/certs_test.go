@@ -0,0 +1,87 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPService_TLSFiles(t *testing.T) {
+	ctx := context.Background()
+
+	ca, caKey := newCert(t, "ca", nil, nil)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCert := func(cn string) {
+		cert, key := newCert(t, cn, ca, caKey)
+		der, err := x509.MarshalECPrivateKey(key)
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600))
+		assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0o600))
+	}
+	writeCert("server-1")
+
+	svc := NewHTTPService("api", http.NotFoundHandler(), WithAddr("127.0.0.1:0"), WithTLSFiles(certFile, keyFile))
+	assert.NoError(t, svc.Start(ctx))
+	defer svc.Stop(ctx)
+
+	servedCN := func() string {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: pool},
+			DisableKeepAlives: true,
+		}}
+		resp, err := client.Get(svc.url("https"))
+		if !assert.NoError(t, err) {
+			return ""
+		}
+		resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	assert.Equal(t, "server-1", servedCN())
+
+	// Rotated files are served without a restart
+	writeCert("server-2")
+	assert.Eventually(t, func() bool { return servedCN() == "server-2" }, 2*time.Second, 20*time.Millisecond)
+
+	// Invalid files keep the previous certificate
+	assert.NoError(t, os.WriteFile(certFile, []byte("invalid"), 0o600))
+	time.Sleep(3 * certReloadDelay)
+	assert.Equal(t, "server-2", servedCN())
+}
+
+func TestHTTPService_GetCertificate(t *testing.T) {
+	ctx := context.Background()
+
+	ca, caKey := newCert(t, "ca", nil, nil)
+	cert, key := newCert(t, "dynamic", ca, caKey)
+
+	svc := NewHTTPService("api", http.NotFoundHandler(), WithAddr("127.0.0.1:0"),
+		WithGetCertificate(func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return &tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key}, nil
+		}))
+	assert.NoError(t, svc.Start(ctx))
+	defer svc.Stop(ctx)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	resp, err := client.Get(svc.url("https"))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "dynamic", resp.TLS.PeerCertificates[0].Subject.CommonName)
+
+	// Missing files fail Start
+	missing := NewHTTPService("api", nil, WithAddr("127.0.0.1:0"), WithTLSFiles("missing.crt", "missing.key"))
+	assert.ErrorContains(t, missing.Start(ctx), "failed to load tls certificate")
+}
@@ -0,0 +1,277 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ducconit/gocore/config"
+	"go.uber.org/zap"
+)
+
+// ServerConfig is the configuration of an HTTP service read by NewFromConfig
+type ServerConfig struct {
+	// Name of the service, defaults to the last segment of the config key
+	Name string `mapstructure:"name"`
+
+	// Addr is the TCP address to listen on, DefaultAddr when empty
+	Addr string `mapstructure:"addr"`
+
+	// TLS serves HTTPS when it holds a certificate
+	TLS ServerTLSConfig `mapstructure:"tls"`
+
+	// Server timeouts, zero disables one
+	ReadTimeout       time.Duration `mapstructure:"read_timeout"`
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout"`
+	WriteTimeout      time.Duration `mapstructure:"write_timeout"`
+	IdleTimeout       time.Duration `mapstructure:"idle_timeout"`
+	ShutdownTimeout   time.Duration `mapstructure:"shutdown_timeout"`
+
+	// MaxBodySize and MaxHeaderBytes bound the size of requests, see
+	// WithMaxBodySize and WithMaxHeaderBytes
+	MaxBodySize    int64 `mapstructure:"max_body_size"`
+	MaxHeaderBytes int   `mapstructure:"max_header_bytes"`
+
+	// H2C also serves cleartext HTTP/2, see WithH2C
+	H2C bool `mapstructure:"h2c"`
+
+	// Paths of the built-in endpoints, empty disables one
+	HealthPath    string `mapstructure:"health_path"`
+	LivenessPath  string `mapstructure:"liveness_path"`
+	ReadinessPath string `mapstructure:"readiness_path"`
+	MetricsPath   string `mapstructure:"metrics_path"`
+
+	// Pprof serves pprof and expvar, see WithPprof
+	Pprof bool `mapstructure:"pprof"`
+
+	// LogLevelPath serves the log level endpoint, see WithLogLevelEndpoint
+	LogLevelPath string `mapstructure:"log_level_path"`
+
+	// RequestID propagates or generates X-Request-ID, see WithRequestID
+	RequestID bool `mapstructure:"request_id"`
+
+	// AccessLog logs the requests through the logger of WithLogger
+	AccessLog bool `mapstructure:"access_log"`
+
+	// AccessLogSampling is the fraction of successful requests logged
+	AccessLogSampling float64 `mapstructure:"access_log_sampling"`
+
+	// CORS is the cross-origin policy, none when it allows no origin
+	CORS CORSConfig `mapstructure:"cors"`
+
+	// Reload applies the changes of the middleware toggles, the body size
+	// limit and the shutdown timeout while the service runs. Other changes
+	// need a restart.
+	Reload bool `mapstructure:"reload"`
+}
+
+// ServerTLSConfig locates the certificates of an HTTPS service
+type ServerTLSConfig struct {
+	// CertFile and KeyFile hold the PEM server certificate and key, reloaded
+	// when they change, see WithTLSFiles
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+
+	// ClientCAFile holds the PEM CAs verifying client certificates, see WithClientCA
+	ClientCAFile string `mapstructure:"client_ca_file"`
+
+	// ClientAuth is the client certificate policy: "request", "require",
+	// "verify_if_given" or "require_and_verify", the default with ClientCAFile
+	ClientAuth string `mapstructure:"client_auth"`
+}
+
+// clientAuthTypes maps the ClientAuth values to their policy
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"request":            tls.RequestClientCert,
+	"require":            tls.RequireAnyClientCert,
+	"verify_if_given":    tls.VerifyClientCertIfGiven,
+	"require_and_verify": tls.RequireAndVerifyClientCert,
+}
+
+// reloadableKeys are the ServerConfig keys applied while the service runs
+var reloadableKeys = map[string]bool{
+	"shutdown_timeout":    true,
+	"max_body_size":       true,
+	"request_id":          true,
+	"access_log":          true,
+	"access_log_sampling": true,
+	"cors":                true,
+}
+
+// NewFromConfig creates an HTTP service serving handler as configured under
+// key, e.g.
+//
+//	http:
+//	  addr: ":8443"
+//	  tls: {cert_file: server.crt, key_file: server.key}
+//	  read_timeout: 10s
+//	  health_path: /healthz
+//	  request_id: true
+//	  access_log: true
+//	  cors: {allowed_origins: ["https://app.example.com"]}
+//	  reload: true
+//
+// Unset keys keep the defaults of NewHTTPService. opts are applied after the
+// config and override it. With reload, changes of the middleware toggles and
+// the shutdown timeout apply to the next requests; changes of other keys are
+// logged as needing a restart.
+func NewFromConfig(cfg config.Config, key string, handler http.Handler, opts ...Option) (*HTTPService, error) {
+	c, err := decodeServerConfig(cfg, key)
+	if err != nil {
+		return nil, err
+	}
+	if c.Name == "" {
+		c.Name = key[strings.LastIndex(key, ".")+1:]
+	}
+
+	cfgOpts, err := c.options()
+	if err != nil {
+		return nil, err
+	}
+	s := NewHTTPService(c.Name, handler, append(cfgOpts, opts...)...)
+
+	if c.Reload {
+		go s.reloadFrom(cfg, key, cfg.Events())
+	}
+	return s, nil
+}
+
+// decodeServerConfig reads the config under key over the defaults of NewHTTPService
+func decodeServerConfig(cfg config.Config, key string) (ServerConfig, error) {
+	c := ServerConfig{
+		Addr:              DefaultAddr,
+		ReadTimeout:       DefaultReadTimeout,
+		ReadHeaderTimeout: DefaultReadHeaderTimeout,
+		WriteTimeout:      DefaultWriteTimeout,
+		IdleTimeout:       DefaultIdleTimeout,
+		ShutdownTimeout:   DefaultShutdownTimeout,
+		AccessLog:         true,
+		AccessLogSampling: 1,
+	}
+	if err := cfg.UnmarshalKey(key, &c); err != nil {
+		return c, fmt.Errorf("failed to decode http config: %w", err)
+	}
+	return c, nil
+}
+
+// options returns the options configuring a service as c
+func (c ServerConfig) options() ([]Option, error) {
+	opts := []Option{
+		WithAddr(c.Addr),
+		WithTimeouts(c.ReadTimeout, c.ReadHeaderTimeout, c.WriteTimeout, c.IdleTimeout),
+		WithHealthEndpoint(c.HealthPath),
+		WithLivenessEndpoint(c.LivenessPath),
+		WithReadinessEndpoint(c.ReadinessPath),
+		WithMetrics(c.MetricsPath),
+		WithMaxHeaderBytes(c.MaxHeaderBytes),
+		c.reloadable(),
+	}
+	if c.H2C {
+		opts = append(opts, WithH2C())
+	}
+	if c.Pprof {
+		opts = append(opts, WithPprof())
+	}
+	if c.LogLevelPath != "" {
+		opts = append(opts, WithLogLevelEndpoint(c.LogLevelPath))
+	}
+
+	if c.TLS.CertFile != "" || c.TLS.KeyFile != "" {
+		// Fail early, the files are loaded again on Start and when they change
+		if _, err := tls.LoadX509KeyPair(c.TLS.CertFile, c.TLS.KeyFile); err != nil {
+			return nil, fmt.Errorf("failed to load tls certificate: %w", err)
+		}
+		opts = append(opts, WithTLSFiles(c.TLS.CertFile, c.TLS.KeyFile))
+	}
+	if c.TLS.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.TLS.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", c.TLS.ClientCAFile)
+		}
+
+		policy := tls.RequireAndVerifyClientCert
+		if c.TLS.ClientAuth != "" {
+			var ok bool
+			if policy, ok = clientAuthTypes[c.TLS.ClientAuth]; !ok {
+				return nil, fmt.Errorf("unknown client auth %q", c.TLS.ClientAuth)
+			}
+		}
+		opts = append(opts, WithClientCA(pool, policy))
+	}
+	return opts, nil
+}
+
+// reloadable returns the option applying the keys of c applied on reload
+func (c ServerConfig) reloadable() Option {
+	return func(s *HTTPService) {
+		s.shutdownTimeout = c.ShutdownTimeout
+		s.maxBodySize = c.MaxBodySize
+		s.requestID = c.RequestID
+		s.accessLog = c.AccessLog
+		s.sampleRate = c.AccessLogSampling
+		s.cors = nil
+		if len(c.CORS.AllowedOrigins) > 0 {
+			s.cors = CORS(c.CORS)
+		}
+	}
+}
+
+// reloadFrom applies the changes under key received from events until the
+// channel is closed
+func (s *HTTPService) reloadFrom(cfg config.Config, key string, events <-chan config.ChangeEvent) {
+	prefix := strings.ToLower(key) + "."
+	for e := range events {
+		name, ok := strings.CutPrefix(e.Key, prefix)
+		if !ok {
+			continue
+		}
+		name, _, _ = strings.Cut(name, ".")
+		if !reloadableKeys[name] {
+			s.log().Warn("http config change needs a restart", zap.String("key", e.Key))
+			continue
+		}
+
+		c, err := decodeServerConfig(cfg, key)
+		if err == nil {
+			err = s.Reconfigure(c.reloadable())
+		}
+		if err != nil {
+			s.log().Error("failed to reload http config", zap.String("key", e.Key), zap.Error(err))
+		}
+	}
+}
+
+// Reconfigure applies opts while the service runs, rebuilding the handler
+// served to the next requests. Only options changing the middleware and the
+// shutdown timeout take effect before the next Start.
+func (s *HTTPService) Reconfigure(opts ...Option) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.done == nil {
+		return nil
+	}
+
+	handler, err := s.routes()
+	if err != nil {
+		return err
+	}
+	s.routed.Store(&handler)
+	return nil
+}
+
+// serveRoutes serves the handler built by the last Start or Reconfigure
+func (s *HTTPService) serveRoutes(w http.ResponseWriter, r *http.Request) {
+	(*s.routed.Load()).ServeHTTP(w, r)
+}
@@ -0,0 +1,80 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ducconit/gocore/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFromConfig(t *testing.T) {
+	cfg := config.NewConfig()
+	assert.NoError(t, cfg.Set("servers.api", map[string]any{
+		"addr":           "127.0.0.1:0",
+		"read_timeout":   "3s",
+		"health_path":    "/healthz",
+		"request_id":     true,
+		"cors":           map[string]any{"allowed_origins": []string{"https://app.example.com"}},
+		"write_timeout":  "0s",
+		"liveness_path":  "/livez",
+		"readiness_path": "",
+	}))
+
+	svc, err := NewFromConfig(cfg, "servers.api", http.NotFoundHandler(), WithShutdownTimeout(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, "api", svc.Name())
+	assert.Equal(t, "127.0.0.1:0", svc.addr)
+	assert.Equal(t, 3*time.Second, svc.readTimeout)
+	assert.Equal(t, DefaultReadHeaderTimeout, svc.readHeaderTimeout)
+	assert.Zero(t, svc.writeTimeout)
+	assert.Equal(t, time.Second, svc.shutdownTimeout)
+	assert.Equal(t, "/healthz", svc.healthPath)
+	assert.True(t, svc.requestID)
+	assert.True(t, svc.accessLog)
+	assert.NotNil(t, svc.cors)
+	assert.Nil(t, svc.tlsConfig)
+
+	_, err = NewFromConfig(cfg, "missing", nil)
+	assert.NoError(t, err)
+
+	assert.NoError(t, cfg.Set("servers.tls.tls", map[string]any{"cert_file": "missing.crt", "key_file": "missing.key"}))
+	_, err = NewFromConfig(cfg, "servers.tls", nil)
+	assert.ErrorContains(t, err, "failed to load tls certificate")
+}
+
+func TestNewFromConfig_Reload(t *testing.T) {
+	ctx := context.Background()
+
+	cfg := config.NewConfig()
+	assert.NoError(t, cfg.Set("http", map[string]any{"addr": "127.0.0.1:0", "reload": true}))
+
+	svc, err := NewFromConfig(cfg, "http", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	assert.NoError(t, err)
+	assert.NoError(t, svc.Start(ctx))
+	defer svc.Stop(ctx)
+
+	requestID := func() string {
+		resp, err := http.Get(svc.url("http"))
+		if !assert.NoError(t, err) {
+			return ""
+		}
+		resp.Body.Close()
+		return resp.Header.Get(RequestIDHeader)
+	}
+	assert.Empty(t, requestID())
+
+	// Middleware toggles apply to the next requests
+	assert.NoError(t, cfg.Set("http.request_id", true))
+	assert.Eventually(t, func() bool { return requestID() != "" }, time.Second, 10*time.Millisecond)
+
+	// Other keys need a restart
+	addr := svc.ListenAddr().String()
+	assert.NoError(t, cfg.Set("http.addr", "127.0.0.1:1"))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, addr, svc.ListenAddr().String())
+	assert.Equal(t, "127.0.0.1:0", svc.addr)
+	assert.NotEmpty(t, requestID())
+}
@@ -0,0 +1,208 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ducconit/gocore/config"
+)
+
+// Methods and headers allowed by CORS when none are configured
+var (
+	DefaultCORSMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	DefaultCORSHeaders = []string{"Accept", "Authorization", "Content-Type", "X-Request-ID"}
+)
+
+// CORSConfig is the cross-origin resource sharing policy of a service
+type CORSConfig struct {
+	// AllowedOrigins lists the allowed origins, "*" allows any and
+	// "https://*.example.com" any subdomain
+	AllowedOrigins []string `mapstructure:"allowed_origins"`
+
+	// AllowedMethods lists the methods of allowed requests, DefaultCORSMethods when empty
+	AllowedMethods []string `mapstructure:"allowed_methods"`
+
+	// AllowedHeaders lists the headers of allowed requests, "*" allows any.
+	// DefaultCORSHeaders when empty
+	AllowedHeaders []string `mapstructure:"allowed_headers"`
+
+	// ExposedHeaders lists the response headers readable by the client
+	ExposedHeaders []string `mapstructure:"exposed_headers"`
+
+	// AllowCredentials lets requests carry cookies and authorization
+	AllowCredentials bool `mapstructure:"allow_credentials"`
+
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration `mapstructure:"max_age"`
+}
+
+// WithCORS answers cross-origin requests according to cfg, before the
+// middleware added with Use
+func WithCORS(cfg CORSConfig) Option {
+	return func(s *HTTPService) {
+		s.cors = CORS(cfg)
+	}
+}
+
+// CORSFromConfig returns the CORS middleware configured under key, e.g.
+//
+//	cors:
+//	  allowed_origins: ["https://app.example.com"]
+//	  allow_credentials: true
+//	  max_age: 10m
+func CORSFromConfig(cfg config.Config, key string) (Middleware, error) {
+	var c CORSConfig
+	if err := cfg.UnmarshalKey(key, &c); err != nil {
+		return nil, fmt.Errorf("failed to decode cors config: %w", err)
+	}
+	return CORS(c), nil
+}
+
+// CORS answers preflight requests and adds the CORS headers to the responses
+// of allowed origins. Requests from other origins get no CORS headers, so
+// browsers block them.
+func CORS(cfg CORSConfig) Middleware {
+	methods := cfg.AllowedMethods
+	if len(methods) == 0 {
+		methods = DefaultCORSMethods
+	}
+	headers := cfg.AllowedHeaders
+	if len(headers) == 0 {
+		headers = DefaultCORSHeaders
+	}
+
+	p := &corsPolicy{
+		cfg:        cfg,
+		methods:    make(map[string]bool, len(methods)),
+		methodList: strings.Join(methods, ", "),
+		headers:    make(map[string]bool, len(headers)),
+		headerList: strings.Join(headers, ", "),
+		exposed:    strings.Join(cfg.ExposedHeaders, ", "),
+	}
+	for _, m := range methods {
+		p.methods[strings.ToUpper(m)] = true
+	}
+	for _, h := range headers {
+		if h == "*" {
+			p.anyHeader = true
+		}
+		p.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	if cfg.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Add("Vary", "Origin")
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				p.preflight(w, r, origin)
+				return
+			}
+
+			if p.allowOrigin(w, origin) && p.exposed != "" {
+				w.Header().Set("Access-Control-Expose-Headers", p.exposed)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type corsPolicy struct {
+	cfg        CORSConfig
+	methods    map[string]bool
+	methodList string
+	headers    map[string]bool
+	headerList string
+	anyHeader  bool
+	exposed    string
+	maxAge     string
+}
+
+// preflight answers a preflight request, without CORS headers when the
+// request is not allowed
+func (p *corsPolicy) preflight(w http.ResponseWriter, r *http.Request, origin string) {
+	h := w.Header()
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+	defer w.WriteHeader(http.StatusNoContent)
+
+	if !p.methods[strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))] {
+		return
+	}
+	requested := r.Header.Get("Access-Control-Request-Headers")
+	if !p.anyHeader {
+		for _, name := range strings.Split(requested, ",") {
+			name = strings.TrimSpace(name)
+			if name != "" && !p.headers[http.CanonicalHeaderKey(name)] {
+				return
+			}
+		}
+	}
+	if !p.allowOrigin(w, origin) {
+		return
+	}
+
+	h.Set("Access-Control-Allow-Methods", p.methodList)
+	if p.anyHeader {
+		if requested != "" {
+			h.Set("Access-Control-Allow-Headers", requested)
+		}
+	} else {
+		h.Set("Access-Control-Allow-Headers", p.headerList)
+	}
+	if p.maxAge != "" {
+		h.Set("Access-Control-Max-Age", p.maxAge)
+	}
+}
+
+// allowOrigin sets the allowed origin and credentials headers if origin is allowed
+func (p *corsPolicy) allowOrigin(w http.ResponseWriter, origin string) bool {
+	allowed, wildcard := false, false
+	for _, o := range p.cfg.AllowedOrigins {
+		if o == "*" {
+			allowed, wildcard = true, true
+			break
+		}
+		if matchOrigin(o, origin) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return false
+	}
+
+	// Credentials cannot be used with a wildcard origin, echo the origin instead
+	if wildcard && !p.cfg.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.cfg.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+	return true
+}
+
+// matchOrigin matches origin against pattern, which may hold one "*"
+func matchOrigin(pattern, origin string) bool {
+	if strings.EqualFold(pattern, origin) {
+		return true
+	}
+	prefix, suffix, ok := strings.Cut(strings.ToLower(pattern), "*")
+	if !ok {
+		return false
+	}
+	origin = strings.ToLower(origin)
+	return len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix)
+}
@@ -0,0 +1,97 @@
+package http
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultDrainLogInterval is the interval between drain progress logs during Stop
+const DefaultDrainLogInterval = time.Second
+
+// InFlight returns the number of requests being handled
+func (s *HTTPService) InFlight() int64 {
+	return s.inFlight.Load()
+}
+
+// Conns returns the number of open client connections, idle ones included
+func (s *HTTPService) Conns() int64 {
+	return s.conns.Load()
+}
+
+// OnDrain adds fn run when Stop starts draining, once the listeners are
+// closed, e.g. to end long-lived streams the drain would wait for. It
+// applies from the next Start.
+func (s *HTTPService) OnDrain(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drainHooks = append(s.drainHooks, fn)
+}
+
+// track counts the requests in flight
+func (s *HTTPService) track(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// connState counts the open connections, hijacked ones are no longer the server's
+func (s *HTTPService) connState(_ net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		s.conns.Add(1)
+	case http.StateHijacked, http.StateClosed:
+		s.conns.Add(-1)
+	}
+}
+
+// shutdown drains the server until ctx is done, then closes the remaining
+// connections, logging the progress. An idle server logs at debug level
+func (s *HTTPService) shutdown(ctx context.Context) error {
+	start := time.Now()
+	logf := s.log().Info
+	if s.InFlight() == 0 {
+		logf = s.log().Debug
+	}
+	fields := func() []zap.Field {
+		f := []zap.Field{
+			zap.Int64("in_flight", s.InFlight()),
+			zap.Int64("conns", s.Conns()),
+			zap.Duration("elapsed", time.Since(start)),
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			f = append(f, zap.Duration("remaining", time.Until(deadline)))
+		}
+		return f
+	}
+
+	logf("draining http service", fields()...)
+
+	stopped := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(s.drainInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopped:
+				return
+			case <-ticker.C:
+				logf("draining http service", fields()...)
+			}
+		}
+	}()
+	defer close(stopped)
+
+	if err := s.server.Shutdown(ctx); err != nil {
+		s.log().Warn("drain deadline exceeded, closing connections", append(fields(), zap.Error(err))...)
+		_ = s.server.Close()
+		return err
+	}
+	logf("http service drained", fields()...)
+	return nil
+}
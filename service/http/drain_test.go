@@ -0,0 +1,85 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPService_Drain(t *testing.T) {
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var buf syncBuffer
+	svc := NewHTTPService("api", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}),
+		WithAddr("127.0.0.1:0"),
+		WithLogger(logger.New(logger.WithOutput(&buf))),
+		WithoutAccessLog(),
+		WithShutdownTimeout(time.Second),
+	)
+	svc.drainInterval = 10 * time.Millisecond
+	assert.NoError(t, svc.Start(ctx))
+
+	go func() {
+		resp, err := http.Get(svc.url("http"))
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+	assert.Equal(t, int64(1), svc.InFlight())
+	assert.Equal(t, int64(1), svc.Conns())
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+	assert.NoError(t, svc.Stop(ctx))
+	assert.Zero(t, svc.InFlight())
+
+	out := buf.String()
+	assert.Contains(t, out, `"msg":"draining http service"`)
+	assert.Contains(t, out, `"instance_id":"`+logger.InstanceID+`"`)
+	assert.Equal(t, strings.Count(out, "\n"), strings.Count(out, `"service":"api"`), "one service field per entry")
+	assert.Contains(t, out, `"in_flight":1`)
+	assert.Contains(t, out, `"msg":"http service drained"`)
+}
+
+func TestHTTPService_DrainDeadline(t *testing.T) {
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	var buf syncBuffer
+	svc := NewHTTPService("api", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}),
+		WithAddr("127.0.0.1:0"),
+		WithLogger(logger.New(logger.WithOutput(&buf))),
+		WithoutAccessLog(),
+		WithShutdownTimeout(50*time.Millisecond),
+	)
+	assert.NoError(t, svc.Start(ctx))
+
+	go func() {
+		resp, err := http.Get(svc.url("http"))
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+
+	assert.ErrorIs(t, svc.Stop(ctx), context.DeadlineExceeded)
+	assert.Contains(t, buf.String(), `"msg":"drain deadline exceeded, closing connections"`)
+}
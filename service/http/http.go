@@ -0,0 +1,703 @@
+// Package http runs an HTTP server as a service.Service
+//
+//	svc := http.NewHTTPService("api", mux, http.WithAddr(":8080"))
+//	manager.Register(svc)
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/ducconit/gocore/service"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// DefaultAddr is the address served when none is given
+const DefaultAddr = ":8080"
+
+// Server timeouts used when none are given
+const (
+	DefaultReadTimeout       = 15 * time.Second
+	DefaultReadHeaderTimeout = 5 * time.Second
+	DefaultWriteTimeout      = 15 * time.Second
+	DefaultIdleTimeout       = 30 * time.Second
+	DefaultShutdownTimeout   = 30 * time.Second
+)
+
+// errAlreadyStarted is returned by Start while the service is running
+var errAlreadyStarted = errors.New("http service already started")
+
+// Middleware wraps a handler, e.g. to authenticate or log requests
+type Middleware func(http.Handler) http.Handler
+
+// Option represents an HTTP service option
+type Option func(*HTTPService)
+
+// WithAddr sets the TCP address to listen on, ":0" picks a free port, see ListenAddr
+func WithAddr(addr string) Option {
+	return func(s *HTTPService) {
+		s.addr = addr
+	}
+}
+
+// WithListener serves l instead of listening on the address, e.g. a socket
+// inherited from the previous process of a zero-downtime restart. Stop
+// closes l, so the service cannot be started again.
+func WithListener(l net.Listener) Option {
+	return func(s *HTTPService) {
+		s.injected = l
+	}
+}
+
+// WithSocketActivation serves the socket passed by systemd named name in
+// LISTEN_FDNAMES, or the first one not claimed by another service when name
+// is empty, see service.ActivationListener
+func WithSocketActivation(name string) Option {
+	return func(s *HTTPService) {
+		s.activation = true
+		s.activationName = name
+	}
+}
+
+// WithTimeouts sets the server timeouts, zero disables one. Raise or disable
+// the write timeout for long-polling and streaming endpoints.
+func WithTimeouts(read, readHeader, write, idle time.Duration) Option {
+	return func(s *HTTPService) {
+		s.readTimeout = read
+		s.readHeaderTimeout = readHeader
+		s.writeTimeout = write
+		s.idleTimeout = idle
+	}
+}
+
+// WithShutdownTimeout bounds how long Stop waits for running requests
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(s *HTTPService) {
+		s.shutdownTimeout = d
+	}
+}
+
+// WithTLS serves HTTPS with cfg, which holds the server certificate
+func WithTLS(cfg *tls.Config) Option {
+	return func(s *HTTPService) {
+		s.tlsConfig = cfg.Clone()
+	}
+}
+
+// WithClientCA verifies client certificates against pool, requiring them
+// or not depending on policy, e.g. tls.RequireAndVerifyClientCert. Handlers
+// get the verified identity through ClientIdentityFromContext. Requires WithTLS.
+func WithClientCA(pool *x509.CertPool, policy tls.ClientAuthType) Option {
+	return func(s *HTTPService) {
+		s.clientCAs = pool
+		s.clientAuth = policy
+	}
+}
+
+// WithH2C serves cleartext HTTP/2 with prior knowledge alongside HTTP/1,
+// e.g. behind a proxy terminating TLS or for gRPC-Web
+func WithH2C() Option {
+	return func(s *HTTPService) {
+		s.h2c = true
+	}
+}
+
+// WithHTTP2 tunes the HTTP/2 server: concurrent streams, frame and window
+// sizes, ping and write timeouts
+func WithHTTP2(cfg http.HTTP2Config) Option {
+	return func(s *HTTPService) {
+		s.http2 = &cfg
+	}
+}
+
+// WithHealthEndpoint serves the JSON report of the health checks at path,
+// e.g. "/healthz", with 503 Service Unavailable when a check fails
+func WithHealthEndpoint(path string) Option {
+	return func(s *HTTPService) {
+		s.healthPath = path
+	}
+}
+
+// WithHealthCheck registers a check reported by the health endpoint
+func WithHealthCheck(name string, checker service.HealthChecker) Option {
+	return func(s *HTTPService) {
+		s.checks.Add(name, checker)
+	}
+}
+
+// WithLivenessEndpoint serves the liveness probe at path, e.g. "/livez",
+// responding 200 while the server runs
+func WithLivenessEndpoint(path string) Option {
+	return func(s *HTTPService) {
+		s.livePath = path
+	}
+}
+
+// WithReadinessEndpoint serves the readiness probe at path, e.g. "/readyz",
+// responding 200 while the service is ready and its health checks pass
+func WithReadinessEndpoint(path string) Option {
+	return func(s *HTTPService) {
+		s.readyPath = path
+	}
+}
+
+// WithoutAccessLog disables the access log of WithLogger
+func WithoutAccessLog() Option {
+	return func(s *HTTPService) {
+		s.accessLog = false
+	}
+}
+
+// WithAccessLogSampling logs only a rate fraction of the successful requests,
+// failed requests are always logged
+func WithAccessLogSampling(rate float64) Option {
+	return func(s *HTTPService) {
+		s.sampleRate = rate
+	}
+}
+
+// WithLogger sets the logger of server errors, the global logger by default.
+// Requests are also logged through it, see AccessLog and WithoutAccessLog.
+func WithLogger(l *logger.Logger) Option {
+	return func(s *HTTPService) {
+		s.logger = l
+	}
+}
+
+var _ service.Service = (*HTTPService)(nil)
+
+// HTTPService is a service.Service serving HTTP, or HTTPS with WithTLS
+type HTTPService struct {
+	name        string
+	handler     http.Handler
+	middleware  []Middleware
+	cors        Middleware
+	requestID   bool
+	addr        string
+	tlsConfig   *tls.Config
+	certFiles   *certReloader
+	getCert     func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+	clientCAs   *x509.CertPool
+	clientAuth  tls.ClientAuthType
+	h2c         bool
+	http2       *http.HTTP2Config
+	healthPath  string
+	metricsPath string
+	registry    *prometheus.Registry
+	pprof       bool
+	pprofPrefix string
+	pprofAuth   Middleware
+
+	logLevelPath string
+	livePath     string
+	readyPath    string
+	checks       service.HealthChecks
+	hooks        service.Hooks
+	drainHooks   []func()
+	logger       *logger.Logger
+	logOnce      sync.Once
+	serviceLog   *logger.Logger
+	accessLog    bool
+	sampleRate   float64
+
+	maxBodySize    int64
+	maxHeaderBytes int
+
+	injected       net.Listener
+	activation     bool
+	activationName string
+	injectedUsed   bool
+	extraListeners []ListenerConfig
+
+	openAPIFS         fs.FS
+	openAPIName       string
+	openAPIValidation bool
+
+	static       []*staticMount
+	staticMaxAge time.Duration
+
+	readTimeout       time.Duration
+	readHeaderTimeout time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	shutdownTimeout   time.Duration
+	drainInterval     time.Duration
+
+	mu        sync.Mutex
+	server    *http.Server
+	listener  net.Listener
+	extra     []net.Listener
+	routed    atomic.Pointer[http.Handler]
+	stopWatch context.CancelFunc
+	done      chan struct{}
+	running   atomic.Bool
+	notReady  atomic.Bool
+	inFlight  atomic.Int64
+	conns     atomic.Int64
+}
+
+// NewHTTPService creates an HTTP service named name serving handler
+func NewHTTPService(name string, handler http.Handler, opts ...Option) *HTTPService {
+	s := &HTTPService{
+		name:        name,
+		handler:     handler,
+		addr:        DefaultAddr,
+		accessLog:   true,
+		sampleRate:  1,
+		pprofPrefix: DefaultPprofPrefix,
+
+		staticMaxAge: DefaultStaticMaxAge,
+
+		readTimeout:       DefaultReadTimeout,
+		readHeaderTimeout: DefaultReadHeaderTimeout,
+		writeTimeout:      DefaultWriteTimeout,
+		idleTimeout:       DefaultIdleTimeout,
+		shutdownTimeout:   DefaultShutdownTimeout,
+		drainInterval:     DefaultDrainLogInterval,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Name returns the name of the service
+func (s *HTTPService) Name() string {
+	return s.name
+}
+
+// ListenAddr returns the address the service is bound to, e.g. the port
+// picked for ":0", nil while it is stopped
+func (s *HTTPService) ListenAddr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// OnStart adds a hook run before the server listens, see service.Hooks
+func (s *HTTPService) OnStart(fn service.Hook) {
+	s.hooks.OnStart(fn)
+}
+
+// OnStop adds a hook run after the server is drained, see service.Hooks
+func (s *HTTPService) OnStop(fn service.Hook) {
+	s.hooks.OnStop(fn)
+}
+
+// Start runs the start hooks, then listens and serves in the background. A
+// stopped service can be started again.
+func (s *HTTPService) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done != nil {
+		return errAlreadyStarted
+	}
+
+	tlsConfig, err := s.tls()
+	if err != nil {
+		return err
+	}
+	handler, err := s.routes()
+	if err != nil {
+		return err
+	}
+
+	if err := s.hooks.Start(ctx); err != nil {
+		return fmt.Errorf("failed to run start hooks: %w", err)
+	}
+
+	l, err := s.listen(ctx)
+	if err != nil {
+		return errors.Join(err, s.hooks.Stop(ctx))
+	}
+	if tlsConfig != nil {
+		l = tls.NewListener(l, tlsConfig)
+	}
+	extra, err := s.listenExtra(ctx, tlsConfig)
+	if err != nil {
+		l.Close()
+		return errors.Join(err, s.hooks.Stop(ctx))
+	}
+	listeners := append([]net.Listener{l}, extra...)
+
+	stopWatch := func() {}
+	if s.certFiles != nil {
+		var watchCtx context.Context
+		watchCtx, stopWatch = context.WithCancel(context.WithoutCancel(ctx))
+		if err := s.certFiles.watch(watchCtx, s.log()); err != nil {
+			stopWatch()
+			for _, l := range listeners {
+				l.Close()
+			}
+			return errors.Join(err, s.hooks.Stop(ctx))
+		}
+	}
+
+	var serverHandler http.Handler = s.track(s.wrap(http.HandlerFunc(s.serveRoutes)))
+	for _, cfg := range s.extraListeners {
+		if cfg.RedirectHTTPS {
+			_, port, _ := net.SplitHostPort(l.Addr().String())
+			serverHandler = redirectHTTPS(port, serverHandler)
+			break
+		}
+	}
+
+	server := &http.Server{
+		Handler:           serverHandler,
+		TLSConfig:         tlsConfig,
+		ReadTimeout:       s.readTimeout,
+		ReadHeaderTimeout: s.readHeaderTimeout,
+		WriteTimeout:      s.writeTimeout,
+		IdleTimeout:       s.idleTimeout,
+		MaxHeaderBytes:    s.maxHeaderBytes,
+		Protocols:         s.protocols(),
+		HTTP2:             s.http2,
+		ErrorLog:          log.New(s.log().Writer(logger.ErrorLevel), "", 0),
+		ConnState:         s.connState,
+		ConnContext:       connContext,
+	}
+
+	for _, fn := range s.drainHooks {
+		server.RegisterOnShutdown(fn)
+	}
+
+	var serving sync.WaitGroup
+	for _, l := range listeners {
+		serving.Add(1)
+		go func() {
+			defer serving.Done()
+			if err := server.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.log().Error("http server failed", zap.Stringer("addr", l.Addr()), zap.Error(err))
+			}
+			s.running.Store(false)
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		serving.Wait()
+		close(done)
+	}()
+
+	s.routed.Store(&handler)
+	s.stopWatch = stopWatch
+	s.server = server
+	s.listener = l
+	s.extra = extra
+	s.done = done
+	s.running.Store(true)
+	return nil
+}
+
+// Stop stops accepting connections and waits for the running requests to
+// finish, logging the drain progress, then runs the stop hooks. Connections
+// still open when ctx is done, or after the shutdown timeout, are closed.
+func (s *HTTPService) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done == nil {
+		return nil
+	}
+
+	drainCtx := ctx
+	if s.shutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		drainCtx, cancel = context.WithTimeout(ctx, s.shutdownTimeout)
+		defer cancel()
+	}
+
+	s.running.Store(false)
+	err := s.shutdown(drainCtx)
+	<-s.done
+	s.stopWatch()
+
+	if hookErr := s.hooks.Stop(ctx); hookErr != nil {
+		err = errors.Join(err, fmt.Errorf("failed to run stop hooks: %w", hookErr))
+	}
+
+	s.server = nil
+	s.listener = nil
+	s.extra = nil
+	s.done = nil
+	return err
+}
+
+// Restart stops the service, draining the running requests, and starts it
+// again on a new listener
+func (s *HTTPService) Restart(ctx context.Context) error {
+	if err := s.Stop(ctx); err != nil {
+		return fmt.Errorf("failed to stop %s: %w", s.name, err)
+	}
+	return s.Start(ctx)
+}
+
+// Run starts the service and blocks until ctx is done, then stops it, see service.Run
+func (s *HTTPService) Run(ctx context.Context) error {
+	return service.Run(ctx, s)
+}
+
+// Health reports whether the server is serving
+func (s *HTTPService) Health() bool {
+	return s.running.Load()
+}
+
+// Use adds middleware around the handler, the first one added being the
+// outermost. The built-in endpoints are not wrapped. Middleware added while
+// the service runs applies from its next Start.
+func (s *HTTPService) Use(mw ...Middleware) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.middleware = append(s.middleware, mw...)
+}
+
+// Ready reports whether the service takes traffic: it is running and not
+// marked as not ready by SetReady
+func (s *HTTPService) Ready() bool {
+	return s.running.Load() && !s.notReady.Load()
+}
+
+// SetReady marks the service as ready or not, e.g. false while warming caches
+// so the readiness probe holds traffic back while the liveness probe passes
+func (s *HTTPService) SetReady(ready bool) {
+	s.notReady.Store(!ready)
+}
+
+// AddHealthCheck registers a check reported by the health endpoint
+func (s *HTTPService) AddHealthCheck(name string, checker service.HealthChecker) {
+	s.checks.Add(name, checker)
+}
+
+// CheckHealth runs the registered health checks
+func (s *HTTPService) CheckHealth(ctx context.Context) service.HealthReport {
+	return s.checks.Check(ctx)
+}
+
+// routes serves the built-in endpoints in front of the handler, leaving
+// every other path to it untouched
+func (s *HTTPService) routes() (http.Handler, error) {
+	handler := s.handler
+	if handler == nil {
+		handler = http.DefaultServeMux
+	}
+	spec, validate, err := s.openAPI()
+	if err != nil {
+		return nil, err
+	}
+	// Innermost, so the route matched by the mux is known outside even when
+	// a middleware replaces the request
+	served := capturePattern(handler)
+	if validate != nil {
+		served = validate(served)
+	}
+	if len(s.static) > 0 {
+		served = s.staticHandler(handler, served)
+	}
+	handler = served
+	for i := len(s.middleware) - 1; i >= 0; i-- {
+		handler = s.middleware[i](handler)
+	}
+	if s.cors != nil {
+		handler = s.cors(handler)
+	}
+	if s.logger != nil && s.accessLog {
+		handler = AccessLog(s.log(), s.sampleRate)(handler)
+	}
+	if s.metricsPath != "" {
+		m, err := newRequestMetrics(s.registerer())
+		if err != nil {
+			return nil, fmt.Errorf("failed to register metrics: %w", err)
+		}
+		handler = m.middleware(s.name)(handler)
+	}
+	if s.requestID {
+		handler = RequestID()(handler)
+	}
+	if s.maxBodySize > 0 {
+		handler = MaxBodySize(s.maxBodySize)(handler)
+	}
+
+	endpoints := make(map[string]http.Handler)
+	if s.metricsPath != "" {
+		endpoints[s.metricsPath] = s.metricsHandler()
+	}
+	if s.healthPath != "" {
+		endpoints[s.healthPath] = &s.checks
+	}
+	if s.livePath != "" {
+		endpoints[s.livePath] = http.HandlerFunc(s.serveLiveness)
+	}
+	if s.readyPath != "" {
+		endpoints[s.readyPath] = http.HandlerFunc(s.serveReadiness)
+	}
+	if spec != nil {
+		endpoints["/"+s.openAPIName] = spec
+	}
+	if s.logLevelPath != "" {
+		var h http.Handler = logger.LevelHandler()
+		if s.pprofAuth != nil {
+			h = s.pprofAuth(h)
+		}
+		endpoints[s.logLevelPath] = h
+	}
+	var debug http.Handler
+	if s.pprof {
+		debug = s.pprofHandler()
+	}
+	if len(endpoints) == 0 && debug == nil {
+		return handler, nil
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h, ok := endpoints[r.URL.Path]; ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if debug != nil && strings.HasPrefix(r.URL.Path, s.pprofPrefix+"/") {
+			debug.ServeHTTP(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}), nil
+}
+
+// listen returns the listener to serve: the one of WithListener or of
+// socket activation, or a new one bound to the address
+func (s *HTTPService) listen(ctx context.Context) (net.Listener, error) {
+	switch {
+	case s.injected != nil:
+		if s.injectedUsed {
+			return nil, errors.New("listener already served, it was closed by Stop")
+		}
+		s.injectedUsed = true
+		return s.injected, nil
+	case s.activation:
+		l, err := service.ActivationListener(s.activationName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get activation listener: %w", err)
+		}
+		return l, nil
+	}
+
+	var lc net.ListenConfig
+	l, err := lc.Listen(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+	return l, nil
+}
+
+// tls returns the TLS configuration of the server, nil without WithTLS,
+// WithTLSFiles or WithGetCertificate
+func (s *HTTPService) tls() (*tls.Config, error) {
+	if s.tlsConfig == nil && s.certFiles == nil && s.getCert == nil {
+		if s.clientCAs != nil {
+			return nil, errors.New("client certificates require WithTLS")
+		}
+		return nil, nil
+	}
+
+	cfg := &tls.Config{}
+	if s.tlsConfig != nil {
+		cfg = s.tlsConfig.Clone()
+	}
+	switch {
+	case s.getCert != nil:
+		cfg.Certificates = nil
+		cfg.GetCertificate = s.getCert
+	case s.certFiles != nil:
+		if err := s.certFiles.load(); err != nil {
+			return nil, err
+		}
+		cfg.Certificates = nil
+		cfg.GetCertificate = s.certFiles.GetCertificate
+	}
+	if len(cfg.Certificates) == 0 && cfg.GetCertificate == nil && cfg.GetConfigForClient == nil {
+		return nil, errors.New("tls config has no server certificate")
+	}
+
+	if s.clientCAs != nil {
+		cfg.ClientCAs = s.clientCAs
+		cfg.ClientAuth = s.clientAuth
+	}
+	if len(cfg.NextProtos) == 0 {
+		// The listener negotiates the protocol, not the server
+		cfg.NextProtos = []string{"h2", "http/1.1"}
+	}
+	return cfg, nil
+}
+
+// protocols returns the protocols served, the net/http defaults unless WithH2C
+func (s *HTTPService) protocols() *http.Protocols {
+	if !s.h2c {
+		return nil
+	}
+	p := new(http.Protocols)
+	p.SetHTTP1(true)
+	p.SetHTTP2(true)
+	p.SetUnencryptedHTTP2(true)
+	return p
+}
+
+// wrap stores the verified client identity in the request context
+func (s *HTTPService) wrap(h http.Handler) http.Handler {
+	if s.clientCAs == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := service.ClientIdentityFromTLS(r.TLS); ok {
+			r = r.WithContext(service.ContextWithClientIdentity(r.Context(), id))
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// serveLiveness reports whether the server runs, without checking dependencies
+func (s *HTTPService) serveLiveness(w http.ResponseWriter, r *http.Request) {
+	report := service.HealthReport{Status: service.StatusUp}
+	if !s.Health() {
+		report.Status = service.StatusDown
+	}
+	service.WriteHealthReport(w, report)
+}
+
+// serveReadiness reports whether the service is ready and its checks pass
+func (s *HTTPService) serveReadiness(w http.ResponseWriter, r *http.Request) {
+	report := s.checks.Check(r.Context())
+	if !s.Ready() {
+		report.Status = service.StatusDown
+	}
+	service.WriteHealthReport(w, report)
+}
+
+// log returns the logger of the service, tagged with its name, instance and
+// version by ForService
+func (s *HTTPService) log() *logger.Logger {
+	s.logOnce.Do(func() {
+		if s.logger != nil {
+			s.serviceLog = s.logger.ForService(s.name)
+		} else {
+			s.serviceLog = logger.ForService(s.name)
+		}
+	})
+	return s.serviceLog
+}
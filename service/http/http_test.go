@@ -0,0 +1,428 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ducconit/gocore/service"
+	"github.com/stretchr/testify/assert"
+)
+
+// url returns the base URL the service listens on
+func (s *HTTPService) url(scheme string) string {
+	return scheme + "://" + s.ListenAddr().String()
+}
+
+func TestHTTPService(t *testing.T) {
+	ctx := context.Background()
+
+	svc := NewHTTPService("api", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}), WithAddr("127.0.0.1:0"))
+	assert.Equal(t, "api", svc.Name())
+	assert.False(t, svc.Health())
+	assert.Nil(t, svc.ListenAddr())
+
+	assert.NoError(t, svc.Start(ctx))
+	assert.True(t, svc.Health())
+	assert.ErrorIs(t, svc.Start(ctx), errAlreadyStarted)
+	assert.NotEqual(t, 0, svc.ListenAddr().(*net.TCPAddr).Port)
+
+	resp, err := http.Get(svc.url("http"))
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "ok", string(body))
+
+	assert.NoError(t, svc.Stop(ctx))
+	assert.False(t, svc.Health())
+	assert.Nil(t, svc.ListenAddr())
+}
+
+func TestHTTPService_Restart(t *testing.T) {
+	ctx := context.Background()
+
+	svc := NewHTTPService("api", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}), WithAddr("127.0.0.1:0"))
+
+	get := func() {
+		resp, err := http.Get(svc.url("http"))
+		assert.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, "ok", string(body))
+	}
+
+	assert.NoError(t, svc.Start(ctx))
+	get()
+	assert.NoError(t, svc.Stop(ctx))
+	assert.NoError(t, svc.Stop(ctx))
+
+	assert.NoError(t, svc.Start(ctx))
+	assert.True(t, svc.Health())
+	get()
+
+	assert.NoError(t, svc.Restart(ctx))
+	assert.True(t, svc.Health())
+	get()
+	assert.NoError(t, svc.Stop(ctx))
+}
+
+func TestHTTPService_Run(t *testing.T) {
+	svc := NewHTTPService("api", http.NotFoundHandler(), WithAddr("127.0.0.1:0"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- svc.Run(ctx)
+	}()
+
+	assert.Eventually(t, svc.Health, time.Second, 5*time.Millisecond)
+	cancel()
+	assert.NoError(t, <-done)
+	assert.False(t, svc.Health())
+}
+
+func TestHTTPService_ClientCA(t *testing.T) {
+	ctx := context.Background()
+
+	ca, caKey := newCert(t, "ca", nil, nil)
+	serverCert, serverKey := newCert(t, "server", ca, caKey)
+	clientCert, clientKey := newCert(t, "orders", ca, caKey)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	svc := NewHTTPService("api", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := service.ClientIdentityFromContext(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = io.WriteString(w, id.CommonName)
+	}),
+		WithAddr("127.0.0.1:0"),
+		WithTLS(&tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{serverCert.Raw}, PrivateKey: serverKey}}}),
+		WithClientCA(pool, tls.RequireAndVerifyClientCert),
+	)
+	assert.NoError(t, svc.Start(ctx))
+	defer svc.Stop(ctx)
+
+	client := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{
+			Timeout: time.Second,
+			Transport: &http.Transport{
+				ForceAttemptHTTP2: true,
+				TLSClientConfig: &tls.Config{
+					RootCAs:      pool,
+					Certificates: certs,
+				},
+			},
+		}
+	}
+
+	resp, err := client(tls.Certificate{Certificate: [][]byte{clientCert.Raw}, PrivateKey: clientKey}).Get(svc.url("https"))
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, resp.ProtoMajor)
+	assert.Equal(t, "orders", string(body))
+
+	// Clients without a certificate fail the handshake
+	_, err = client().Get(svc.url("https"))
+	assert.Error(t, err)
+}
+
+func TestHTTPService_H2C(t *testing.T) {
+	ctx := context.Background()
+
+	svc := NewHTTPService("api", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Proto)
+	}),
+		WithAddr("127.0.0.1:0"),
+		WithH2C(),
+		WithHTTP2(http.HTTP2Config{MaxConcurrentStreams: 10}),
+	)
+	assert.NoError(t, svc.Start(ctx))
+	defer svc.Stop(ctx)
+
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+
+	resp, err := client.Get(svc.url("http"))
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "HTTP/2.0", string(body))
+
+	// HTTP/1 clients are still served
+	resp, err = http.Get(svc.url("http"))
+	assert.NoError(t, err)
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "HTTP/1.1", string(body))
+}
+
+func TestHTTPService_HealthEndpoint(t *testing.T) {
+	ctx := context.Background()
+
+	svc := NewHTTPService("api", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.URL.Path)
+	}),
+		WithAddr("127.0.0.1:0"),
+		WithHealthEndpoint("/healthz"),
+		WithHealthCheck("db", service.HealthCheckerFunc(func(ctx context.Context) error { return nil })),
+	)
+	assert.NoError(t, svc.Start(ctx))
+	defer svc.Stop(ctx)
+
+	var report service.HealthReport
+	resp, err := http.Get(svc.url("http") + "/healthz")
+	assert.NoError(t, err)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, service.StatusUp, report.Checks["db"].Status)
+
+	svc.AddHealthCheck("cache", service.HealthCheckerFunc(func(ctx context.Context) error {
+		return errors.New("unreachable")
+	}))
+	resp, err = http.Get(svc.url("http") + "/healthz")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	// Other paths reach the handler
+	resp, err = http.Get(svc.url("http") + "/orders")
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "/orders", string(body))
+}
+
+func TestHTTPService_Probes(t *testing.T) {
+	ctx := context.Background()
+
+	svc := NewHTTPService("api", http.NotFoundHandler(),
+		WithAddr("127.0.0.1:0"),
+		WithLivenessEndpoint("/livez"),
+		WithReadinessEndpoint("/readyz"),
+	)
+	assert.False(t, svc.Ready())
+	assert.NoError(t, svc.Start(ctx))
+	defer svc.Stop(ctx)
+	assert.True(t, svc.Ready())
+
+	status := func(path string) int {
+		resp, err := http.Get(svc.url("http") + path)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	assert.Equal(t, http.StatusOK, status("/livez"))
+	assert.Equal(t, http.StatusOK, status("/readyz"))
+
+	// Alive but not ready
+	svc.SetReady(false)
+	assert.Equal(t, http.StatusOK, status("/livez"))
+	assert.Equal(t, http.StatusServiceUnavailable, status("/readyz"))
+
+	svc.SetReady(true)
+	svc.AddHealthCheck("db", service.HealthCheckerFunc(func(ctx context.Context) error {
+		return errors.New("unreachable")
+	}))
+	assert.Equal(t, http.StatusOK, status("/livez"))
+	assert.Equal(t, http.StatusServiceUnavailable, status("/readyz"))
+}
+
+func TestHTTPService_Use(t *testing.T) {
+	ctx := context.Background()
+
+	tag := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Chain", name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	svc := NewHTTPService("api", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("X-Chain", "handler")
+	}),
+		WithAddr("127.0.0.1:0"),
+		WithLivenessEndpoint("/livez"),
+	)
+	svc.Use(tag("outer"), tag("inner"))
+	assert.NoError(t, svc.Start(ctx))
+	defer svc.Stop(ctx)
+
+	resp, err := http.Get(svc.url("http") + "/orders")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, []string{"outer", "inner", "handler"}, resp.Header.Values("X-Chain"))
+
+	// Built-in endpoints bypass the middleware
+	resp, err = http.Get(svc.url("http") + "/livez")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Empty(t, resp.Header.Values("X-Chain"))
+}
+
+func TestHTTPService_Timeouts(t *testing.T) {
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	svc := NewHTTPService("api", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}),
+		WithAddr("127.0.0.1:0"),
+		WithTimeouts(time.Minute, time.Second, 0, 2*time.Minute),
+		WithShutdownTimeout(50*time.Millisecond),
+	)
+	assert.NoError(t, svc.Start(ctx))
+
+	assert.Equal(t, time.Minute, svc.server.ReadTimeout)
+	assert.Equal(t, time.Second, svc.server.ReadHeaderTimeout)
+	assert.Zero(t, svc.server.WriteTimeout)
+	assert.Equal(t, 2*time.Minute, svc.server.IdleTimeout)
+
+	go func() {
+		resp, err := http.Get(svc.url("http"))
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+
+	// The running request outlives the shutdown timeout
+	start := time.Now()
+	assert.ErrorIs(t, svc.Stop(ctx), context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestHTTPService_ClientCARequiresTLS(t *testing.T) {
+	svc := NewHTTPService("api", http.NotFoundHandler(),
+		WithAddr("127.0.0.1:0"),
+		WithClientCA(x509.NewCertPool(), tls.RequireAndVerifyClientCert),
+	)
+	assert.Error(t, svc.Start(context.Background()))
+}
+
+// newCert creates a certificate for 127.0.0.1 signed by parent, or a CA when parent is nil
+func newCert(t *testing.T, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return cert, key
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestHTTPService_Hooks(t *testing.T) {
+	ctx := context.Background()
+
+	var events []string
+	svc := NewHTTPService("api", http.NotFoundHandler(), WithAddr("127.0.0.1:0"))
+	svc.OnStart(func(ctx context.Context) error {
+		events = append(events, "open")
+		return nil
+	})
+	svc.OnStop(func(ctx context.Context) error {
+		events = append(events, "close")
+		return nil
+	})
+
+	assert.NoError(t, svc.Start(ctx))
+	assert.Equal(t, []string{"open"}, events)
+	assert.NoError(t, svc.Restart(ctx))
+	assert.NoError(t, svc.Stop(ctx))
+	assert.Equal(t, []string{"open", "close", "open", "close"}, events)
+
+	// A failing start hook keeps the service stopped
+	svc.OnStart(func(ctx context.Context) error {
+		return errors.New("db unreachable")
+	})
+	assert.ErrorContains(t, svc.Start(ctx), "db unreachable")
+	assert.False(t, svc.Health())
+	assert.NoError(t, svc.Stop(ctx))
+}
+
+func TestHTTPService_WithListener(t *testing.T) {
+	ctx := context.Background()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	svc := NewHTTPService("api", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}), WithListener(l))
+	assert.NoError(t, svc.Start(ctx))
+	assert.Equal(t, l.Addr(), svc.ListenAddr())
+
+	resp, err := http.Get("http://" + l.Addr().String())
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "ok", string(body))
+
+	// Stop closes the listener
+	assert.NoError(t, svc.Stop(ctx))
+	assert.Error(t, svc.Start(ctx))
+}
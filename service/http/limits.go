@@ -0,0 +1,108 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+// WithMaxBodySize rejects request bodies larger than n bytes with 413
+// Request Entity Too Large, see MaxBodySize
+func WithMaxBodySize(n int64) Option {
+	return func(s *HTTPService) {
+		s.maxBodySize = n
+	}
+}
+
+// WithMaxHeaderBytes bounds the size of request headers, the request line
+// included. Larger requests get 431 Request Header Fields Too Large.
+// http.DefaultMaxHeaderBytes (1MB) by default
+func WithMaxHeaderBytes(n int) Option {
+	return func(s *HTTPService) {
+		s.maxHeaderBytes = n
+	}
+}
+
+// MaxBodySize limits request bodies to n bytes. Requests announcing a larger
+// Content-Length get 413 Request Entity Too Large without reaching next.
+// Reading past n fails with *http.MaxBytesError, and the response is a 413
+// unless next already sent its status.
+func MaxBodySize(n int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				tooLarge(w)
+				return
+			}
+			if r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body := &limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, n)}
+			lw := &limitedWriter{ResponseWriter: w, body: body}
+			r.Body = body
+			next.ServeHTTP(lw, r)
+
+			if !lw.wroteHeader && body.exceeded {
+				tooLarge(w)
+			}
+		})
+	}
+}
+
+func tooLarge(w http.ResponseWriter) {
+	http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+}
+
+// limitedBody records whether the body exceeded its limit
+type limitedBody struct {
+	io.ReadCloser
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		b.exceeded = true
+	}
+	return n, err
+}
+
+// limitedWriter replaces the response with a 413 once the body exceeded its
+// limit, e.g. the 400 of a handler failing to decode it
+type limitedWriter struct {
+	http.ResponseWriter
+	body        *limitedBody
+	wroteHeader bool
+	rejected    bool
+}
+
+func (w *limitedWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if w.body.exceeded {
+		w.rejected = true
+		tooLarge(w.ResponseWriter)
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *limitedWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.rejected {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *limitedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,82 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxBodySize(t *testing.T) {
+	h := MaxBodySize(8)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v any
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+
+	serve := func(body io.Reader, contentLength int64) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/", body)
+		r.ContentLength = contentLength
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve(strings.NewReader(`"small"`), 7)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ok", w.Body.String())
+
+	// Announced sizes are rejected before the handler runs
+	w = serve(strings.NewReader(`"too large"`), 11)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	// Bodies of unknown size are cut, replacing the response of the handler
+	w = serve(strings.NewReader(`"too large"`), -1)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.NotContains(t, w.Body.String(), "invalid body")
+
+	// Other failures are left to the handler
+	w = serve(strings.NewReader(`{`), -1)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHTTPService_Limits(t *testing.T) {
+	ctx := context.Background()
+
+	svc := NewHTTPService("api", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.ReadAll(r.Body)
+		if err != nil {
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	}), WithAddr("127.0.0.1:0"), WithMaxBodySize(16), WithMaxHeaderBytes(1024))
+	assert.NoError(t, svc.Start(ctx))
+	defer svc.Stop(ctx)
+
+	post := func(body string) int {
+		resp, err := http.Post(svc.url("http"), "text/plain", strings.NewReader(body))
+		if !assert.NoError(t, err) {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	assert.Equal(t, http.StatusOK, post("small"))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, post(strings.Repeat("x", 32)))
+
+	r, _ := http.NewRequest(http.MethodGet, svc.url("http"), nil)
+	r.Header.Set("X-Large", strings.Repeat("x", 8192))
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	resp, err := client.Do(r)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusRequestHeaderFieldsTooLarge, resp.StatusCode)
+}
@@ -0,0 +1,148 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// ListenerConfig is an address served by a service besides the one of
+// WithAddr, sharing its handler and lifecycle
+type ListenerConfig struct {
+	// Addr is the TCP address to listen on, e.g. "[::1]:8080"
+	Addr string
+
+	// TLS serves HTTPS with this config, e.g. another certificate. Plain
+	// HTTP is served when nil, unless SameTLS
+	TLS *tls.Config
+
+	// SameTLS serves HTTPS with the TLS settings of the service, including
+	// the certificates reloaded by WithTLSFiles
+	SameTLS bool
+
+	// RedirectHTTPS answers every request with a permanent redirect to the
+	// HTTPS address of the service, e.g. ":80" next to ":443"
+	RedirectHTTPS bool
+}
+
+// WithExtraListener also serves the handler on the address of cfg. The
+// service fails to start when any of its addresses cannot be bound.
+func WithExtraListener(cfg ListenerConfig) Option {
+	return func(s *HTTPService) {
+		s.extraListeners = append(s.extraListeners, cfg)
+	}
+}
+
+// ListenAddrs returns the addresses the service is bound to, the one of
+// WithAddr first, nil while it is stopped
+func (s *HTTPService) ListenAddrs() []net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	addrs := []net.Addr{s.listener.Addr()}
+	for _, l := range s.extra {
+		addrs = append(addrs, l.Addr())
+	}
+	return addrs
+}
+
+// listenExtra binds the addresses of WithExtraListener, mainTLS being the
+// TLS configuration of the service
+func (s *HTTPService) listenExtra(ctx context.Context, mainTLS *tls.Config) ([]net.Listener, error) {
+	var listeners []net.Listener
+	closeAll := func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}
+
+	for _, cfg := range s.extraListeners {
+		tlsConfig := mainTLS
+		switch {
+		case cfg.RedirectHTTPS && (cfg.TLS != nil || cfg.SameTLS):
+			closeAll()
+			return nil, fmt.Errorf("redirect listener %s serves plain HTTP", cfg.Addr)
+		case cfg.RedirectHTTPS && mainTLS == nil:
+			closeAll()
+			return nil, fmt.Errorf("redirect listener %s requires a service with TLS", cfg.Addr)
+		case cfg.SameTLS && mainTLS == nil:
+			closeAll()
+			return nil, fmt.Errorf("listener %s shares the TLS settings of a service without TLS", cfg.Addr)
+		case cfg.TLS != nil:
+			tlsConfig = cfg.TLS.Clone()
+			if len(tlsConfig.NextProtos) == 0 {
+				tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+			}
+		case !cfg.SameTLS:
+			tlsConfig = nil
+		}
+
+		var lc net.ListenConfig
+		l, err := lc.Listen(ctx, "tcp", cfg.Addr)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("failed to listen on %s: %w", cfg.Addr, err)
+		}
+		switch {
+		case cfg.RedirectHTTPS:
+			l = redirectListener{l}
+		case tlsConfig != nil:
+			l = tls.NewListener(l, tlsConfig)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+// redirectListener marks its connections as redirected to HTTPS
+type redirectListener struct {
+	net.Listener
+}
+
+func (l redirectListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return redirectConn{c}, nil
+}
+
+type redirectConn struct {
+	net.Conn
+}
+
+type redirectKey struct{}
+
+// connContext marks the requests of redirected connections
+func connContext(ctx context.Context, c net.Conn) context.Context {
+	if _, ok := c.(redirectConn); ok {
+		return context.WithValue(ctx, redirectKey{}, true)
+	}
+	return ctx
+}
+
+// redirectHTTPS redirects the requests of redirect listeners to HTTPS on
+// port, leaving the others to next
+func redirectHTTPS(port string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Context().Value(redirectKey{}) == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,100 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPService_ExtraListeners(t *testing.T) {
+	ctx := context.Background()
+
+	ca, caKey := newCert(t, "ca", nil, nil)
+	serverCert, serverKey := newCert(t, "server", ca, caKey)
+	otherCert, otherKey := newCert(t, "other", ca, caKey)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	svc := NewHTTPService("api", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}),
+		WithAddr("127.0.0.1:0"),
+		WithTLS(&tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{serverCert.Raw}, PrivateKey: serverKey}}}),
+		WithExtraListener(ListenerConfig{Addr: "127.0.0.1:0", RedirectHTTPS: true}),
+		WithExtraListener(ListenerConfig{Addr: "127.0.0.1:0", SameTLS: true}),
+		WithExtraListener(ListenerConfig{Addr: "127.0.0.1:0", TLS: &tls.Config{
+			Certificates: []tls.Certificate{{Certificate: [][]byte{otherCert.Raw}, PrivateKey: otherKey}},
+		}}),
+		WithExtraListener(ListenerConfig{Addr: "127.0.0.1:0"}),
+	)
+	assert.Nil(t, svc.ListenAddrs())
+	assert.NoError(t, svc.Start(ctx))
+	addrs := svc.ListenAddrs()
+	assert.Len(t, addrs, 5)
+	assert.Equal(t, svc.ListenAddr(), addrs[0])
+
+	client := &http.Client{
+		Timeout: time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: pool},
+			ForceAttemptHTTP2: true,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	get := func(url string) *http.Response {
+		resp, err := client.Get(url)
+		if !assert.NoError(t, err) {
+			return &http.Response{}
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+
+	_, port, _ := net.SplitHostPort(addrs[0].String())
+	resp := get("http://" + addrs[1].String() + "/orders?id=1")
+	assert.Equal(t, http.StatusPermanentRedirect, resp.StatusCode)
+	assert.Equal(t, "https://127.0.0.1:"+port+"/orders?id=1", resp.Header.Get("Location"))
+
+	resp = get("https://" + addrs[2].String())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, resp.ProtoMajor)
+	assert.Equal(t, "server", resp.TLS.PeerCertificates[0].Subject.CommonName)
+
+	resp = get("https://" + addrs[3].String())
+	assert.Equal(t, "other", resp.TLS.PeerCertificates[0].Subject.CommonName)
+
+	resp = get("http://" + addrs[4].String())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	assert.NoError(t, svc.Stop(ctx))
+	assert.Nil(t, svc.ListenAddrs())
+	for _, addr := range addrs {
+		_, err := net.Dial("tcp", addr.String())
+		assert.Error(t, err)
+	}
+}
+
+func TestHTTPService_ExtraListenerErrors(t *testing.T) {
+	ctx := context.Background()
+
+	svc := NewHTTPService("api", nil, WithAddr("127.0.0.1:0"),
+		WithExtraListener(ListenerConfig{Addr: "127.0.0.1:0", RedirectHTTPS: true}))
+	assert.ErrorContains(t, svc.Start(ctx), "requires a service with TLS")
+	assert.False(t, svc.Health())
+
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer busy.Close()
+	svc = NewHTTPService("api", nil, WithAddr("127.0.0.1:0"),
+		WithExtraListener(ListenerConfig{Addr: busy.Addr().String()}))
+	assert.ErrorContains(t, svc.Start(ctx), "failed to listen on")
+	assert.Nil(t, svc.ListenAddrs())
+}
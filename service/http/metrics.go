@@ -0,0 +1,187 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// MetricsNamespace prefixes the names of the request metrics
+const MetricsNamespace = "http"
+
+// unmatchedRoute labels requests no route pattern matched, keeping raw
+// paths out of the label values
+const unmatchedRoute = "unmatched"
+
+// WithMetrics serves the Prometheus metrics at path, e.g. "/metrics", and
+// instruments requests: count, duration and response size by route and
+// status, and requests in flight
+func WithMetrics(path string) Option {
+	return func(s *HTTPService) {
+		s.metricsPath = path
+	}
+}
+
+// WithMetricsRegistry registers the request metrics with reg and serves its
+// metrics, instead of the default Prometheus registry
+func WithMetricsRegistry(reg *prometheus.Registry) Option {
+	return func(s *HTTPService) {
+		s.registry = reg
+	}
+}
+
+// requestMetrics are shared by the services of a registry, labelled by service name
+type requestMetrics struct {
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	size     *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+}
+
+func newRequestMetrics(reg prometheus.Registerer) (*requestMetrics, error) {
+	labels := []string{"service", "method", "route", "status"}
+	m := &requestMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: MetricsNamespace,
+			Name:      "requests_total",
+			Help:      "Number of requests handled, by service, method, route and status.",
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: MetricsNamespace,
+			Name:      "request_duration_seconds",
+			Help:      "Time spent handling requests, by service, method, route and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, labels),
+		size: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: MetricsNamespace,
+			Name:      "response_size_bytes",
+			Help:      "Size of response bodies, by service, method, route and status.",
+			Buckets:   prometheus.ExponentialBuckets(100, 10, 7),
+		}, labels),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: MetricsNamespace,
+			Name:      "requests_in_flight",
+			Help:      "Number of requests being handled, by service.",
+		}, []string{"service"}),
+	}
+
+	// Reuse the collectors of a service registered before
+	var err error
+	m.requests, err = register(reg, m.requests)
+	if err != nil {
+		return nil, err
+	}
+	m.duration, err = register(reg, m.duration)
+	if err != nil {
+		return nil, err
+	}
+	m.size, err = register(reg, m.size)
+	if err != nil {
+		return nil, err
+	}
+	m.inFlight, err = register(reg, m.inFlight)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func register[C prometheus.Collector](reg prometheus.Registerer, c C) (C, error) {
+	err := reg.Register(c)
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		if existing, ok := are.ExistingCollector.(C); ok {
+			return existing, nil
+		}
+	}
+	return c, err
+}
+
+// middleware observes the requests of the service named name
+func (m *requestMetrics) middleware(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			inFlight := m.inFlight.WithLabelValues(name)
+			inFlight.Inc()
+			defer inFlight.Dec()
+
+			r, route := withRoute(r)
+			rw := &responseWriter{ResponseWriter: w}
+			next.ServeHTTP(rw, r)
+
+			status := rw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			pattern := routePattern(r, route)
+			if pattern == "" {
+				pattern = unmatchedRoute
+			}
+
+			labels := prometheus.Labels{
+				"service": name,
+				"method":  r.Method,
+				"route":   pattern,
+				"status":  strconv.Itoa(status),
+			}
+			m.requests.With(labels).Inc()
+			m.duration.With(labels).Observe(time.Since(start).Seconds())
+			m.size.With(labels).Observe(float64(rw.bytes))
+		})
+	}
+}
+
+// routeKey is the context key of the slot receiving the route pattern
+type routeKey struct{}
+
+// withRoute returns r with a slot receiving the route pattern matched by the
+// mux, see capturePattern. The mux sets Pattern on the request it is given,
+// which is a copy once a middleware calls WithContext.
+func withRoute(r *http.Request) (*http.Request, *string) {
+	if route, ok := r.Context().Value(routeKey{}).(*string); ok {
+		return r, route
+	}
+	route := new(string)
+	return r.WithContext(context.WithValue(r.Context(), routeKey{}, route)), route
+}
+
+// capturePattern wraps the mux to copy the pattern it matched into the slot
+// of withRoute
+func capturePattern(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		if route, ok := r.Context().Value(routeKey{}).(*string); ok && r.Pattern != "" {
+			*route = r.Pattern
+		}
+	})
+}
+
+// routePattern returns the pattern captured in route, else the one of r
+func routePattern(r *http.Request, route *string) string {
+	if *route != "" {
+		return *route
+	}
+	return r.Pattern
+}
+
+// metricsHandler serves the metrics of the registry of the service
+func (s *HTTPService) metricsHandler() http.Handler {
+	if s.registry != nil {
+		return promhttp.HandlerFor(s.registry, promhttp.HandlerOpts{})
+	}
+	return promhttp.Handler()
+}
+
+// registerer returns the registry the request metrics are registered with
+func (s *HTTPService) registerer() prometheus.Registerer {
+	if s.registry != nil {
+		return s.registry
+	}
+	return prometheus.DefaultRegisterer
+}
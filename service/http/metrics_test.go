@@ -0,0 +1,93 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPService_Metrics(t *testing.T) {
+	ctx := context.Background()
+	reg := prometheus.NewRegistry()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "order")
+	})
+
+	svc := NewHTTPService("api", mux,
+		WithAddr("127.0.0.1:0"),
+		WithMetrics("/metrics"),
+		WithMetricsRegistry(reg),
+	)
+	assert.NoError(t, svc.Start(ctx))
+	defer svc.Stop(ctx)
+
+	// Services sharing a registry share the collectors
+	other := NewHTTPService("admin", mux, WithAddr("127.0.0.1:0"), WithMetrics("/metrics"), WithMetricsRegistry(reg))
+	assert.NoError(t, other.Start(ctx))
+	defer other.Stop(ctx)
+
+	for _, path := range []string{"/orders/1", "/orders/2", "/missing"} {
+		resp, err := http.Get(svc.url("http") + path)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	resp, err := http.Get(svc.url("http") + "/metrics")
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	metrics := string(body)
+	assert.Contains(t, metrics, `http_requests_total{method="GET",route="GET /orders/{id}",service="api",status="200"} 2`)
+	assert.Contains(t, metrics, `http_requests_total{method="GET",route="unmatched",service="api",status="404"} 1`)
+	assert.Contains(t, metrics, `http_response_size_bytes_sum{method="GET",route="GET /orders/{id}",service="api",status="200"} 10`)
+	assert.Contains(t, metrics, `http_request_duration_seconds_count{method="GET",route="GET /orders/{id}",service="api",status="200"} 2`)
+	assert.Contains(t, metrics, `http_requests_in_flight{service="api"} 0`)
+}
+
+func TestHTTPService_MetricsRouteWithContext(t *testing.T) {
+	ctx := context.Background()
+	reg := prometheus.NewRegistry()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "order")
+	})
+
+	// The mux sets Pattern on the copy made by WithContext
+	type tenantKey struct{}
+	tenant := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tenantKey{}, "acme")))
+		})
+	}
+
+	var buf syncBuffer
+	svc := NewHTTPService("api", mux,
+		WithAddr("127.0.0.1:0"),
+		WithMetrics("/metrics"),
+		WithMetricsRegistry(reg),
+		WithLogger(logger.New(logger.WithOutput(&buf))),
+	)
+	svc.Use(tenant)
+	assert.NoError(t, svc.Start(ctx))
+	defer svc.Stop(ctx)
+
+	resp, err := http.Get(svc.url("http") + "/orders/1")
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	resp, err = http.Get(svc.url("http") + "/metrics")
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Contains(t, string(body), `http_requests_total{method="GET",route="GET /orders/{id}",service="api",status="200"} 1`)
+	assert.Contains(t, buf.String(), `"route":"GET /orders/{id}"`)
+}
@@ -0,0 +1,130 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+// WithOpenAPI serves the OpenAPI spec named name in specFS, JSON or YAML, at
+// the same path, e.g. "/openapi.yaml" for "openapi.yaml". Start fails when
+// the spec is invalid.
+func WithOpenAPI(specFS fs.FS, name string) Option {
+	return func(s *HTTPService) {
+		s.openAPIFS = specFS
+		s.openAPIName = strings.TrimPrefix(path.Clean("/"+name), "/")
+	}
+}
+
+// WithOpenAPIValidation rejects the requests not matching the spec of
+// WithOpenAPI, see OpenAPIValidation
+func WithOpenAPIValidation() Option {
+	return func(s *HTTPService) {
+		s.openAPIValidation = true
+	}
+}
+
+// OpenAPIValidation checks requests against the OpenAPI spec: paths not in
+// the spec get 404 Not Found, methods not in the spec 405 Method Not
+// Allowed, and requests with invalid parameters or bodies 400 Bad Request.
+// Paths are matched as written in the spec, whatever its servers. Security
+// requirements are left to the handlers.
+func OpenAPIValidation(spec []byte) (Middleware, error) {
+	doc, err := loadOpenAPI(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	doc.Servers = nil
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to route openapi spec: %w", err)
+	}
+	opts := &openapi3filter.Options{
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		MultiError:         true,
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route, params, err := router.FindRoute(r)
+			switch {
+			case errors.Is(err, routers.ErrMethodNotAllowed):
+				http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+				return
+			case err != nil:
+				http.NotFound(w, r)
+				return
+			}
+
+			err = openapi3filter.ValidateRequest(r.Context(), &openapi3filter.RequestValidationInput{
+				Request:    r,
+				PathParams: params,
+				Route:      route,
+				Options:    opts,
+			})
+			if err != nil {
+				http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// loadOpenAPI parses and validates a JSON or YAML spec
+func loadOpenAPI(spec []byte) (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load openapi spec: %w", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid openapi spec: %w", err)
+	}
+	return doc, nil
+}
+
+// openAPI returns the spec endpoint of WithOpenAPI and the validation
+// middleware of WithOpenAPIValidation, nil without them
+func (s *HTTPService) openAPI() (http.Handler, Middleware, error) {
+	if s.openAPIFS == nil {
+		if s.openAPIValidation {
+			return nil, nil, errors.New("openapi validation requires WithOpenAPI")
+		}
+		return nil, nil, nil
+	}
+
+	spec, err := fs.ReadFile(s.openAPIFS, s.openAPIName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read openapi spec: %w", err)
+	}
+	if _, err := loadOpenAPI(spec); err != nil {
+		return nil, nil, err
+	}
+
+	contentType := "application/json"
+	if ext := path.Ext(s.openAPIName); ext == ".yaml" || ext == ".yml" {
+		contentType = "application/yaml"
+	}
+	endpoint := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		_, _ = w.Write(spec)
+	})
+
+	var validate Middleware
+	if s.openAPIValidation {
+		if validate, err = OpenAPIValidation(spec); err != nil {
+			return nil, nil, err
+		}
+	}
+	return endpoint, validate, nil
+}
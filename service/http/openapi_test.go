@@ -0,0 +1,113 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSpec = `
+openapi: 3.0.3
+info: {title: orders, version: "1.0"}
+servers:
+  - url: https://api.example.com
+paths:
+  /orders:
+    post:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [name]
+              properties:
+                name: {type: string}
+      responses:
+        "200": {description: created}
+  /orders/{id}:
+    get:
+      parameters:
+        - {name: id, in: path, required: true, schema: {type: integer}}
+      responses:
+        "200": {description: order}
+`
+
+func TestHTTPService_OpenAPI(t *testing.T) {
+	ctx := context.Background()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /orders", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	})
+	mux.HandleFunc("GET /orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /internal", func(w http.ResponseWriter, r *http.Request) {})
+
+	specs := fstest.MapFS{"api/openapi.yaml": {Data: []byte(testSpec)}}
+	svc := NewHTTPService("api", mux, WithAddr("127.0.0.1:0"),
+		WithOpenAPI(specs, "api/openapi.yaml"), WithOpenAPIValidation(), WithHealthEndpoint("/healthz"))
+	assert.NoError(t, svc.Start(ctx))
+	defer svc.Stop(ctx)
+
+	do := func(method, path, body string) (int, string) {
+		r, _ := http.NewRequest(method, svc.url("http")+path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(r)
+		if !assert.NoError(t, err) {
+			return 0, ""
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp.StatusCode, string(b)
+	}
+
+	status, body := do(http.MethodGet, "/api/openapi.yaml", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, testSpec, body)
+
+	status, body = do(http.MethodGet, "/orders/42", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "42", body)
+
+	status, _ = do(http.MethodGet, "/orders/abc", "")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, body = do(http.MethodPost, "/orders", `{"name":"book"}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, `{"name":"book"}`, body)
+
+	status, body = do(http.MethodPost, "/orders", `{"title":"book"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, body, "name")
+
+	status, _ = do(http.MethodDelete, "/orders/42", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, status)
+
+	// Routes missing from the spec are rejected, built-in endpoints are not
+	status, _ = do(http.MethodGet, "/internal", "")
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = do(http.MethodGet, "/healthz", "")
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestHTTPService_OpenAPIErrors(t *testing.T) {
+	ctx := context.Background()
+
+	svc := NewHTTPService("api", nil, WithAddr("127.0.0.1:0"),
+		WithOpenAPI(fstest.MapFS{"openapi.json": {Data: []byte(`{"openapi": "3.0.3"}`)}}, "openapi.json"))
+	assert.ErrorContains(t, svc.Start(ctx), "invalid openapi spec")
+
+	svc = NewHTTPService("api", nil, WithAddr("127.0.0.1:0"), WithOpenAPI(fstest.MapFS{}, "openapi.json"))
+	assert.ErrorContains(t, svc.Start(ctx), "failed to read openapi spec")
+
+	svc = NewHTTPService("api", nil, WithAddr("127.0.0.1:0"), WithOpenAPIValidation())
+	assert.ErrorContains(t, svc.Start(ctx), "requires WithOpenAPI")
+}
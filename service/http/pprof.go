@@ -0,0 +1,98 @@
+package http
+
+import (
+	"crypto/subtle"
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"github.com/ducconit/gocore/logger"
+)
+
+// DefaultPprofPrefix is the prefix of the debug endpoints when none is given
+const DefaultPprofPrefix = "/debug"
+
+// WithPprof serves the net/http/pprof profiles under <prefix>/pprof/ and the
+// expvar variables at <prefix>/vars, see WithPprofPrefix and WithPprofAuth
+func WithPprof() Option {
+	return func(s *HTTPService) {
+		s.pprof = true
+	}
+}
+
+// WithLogLevelEndpoint serves logger.LevelHandler at path, reading and
+// changing log levels at runtime. An empty path is logger.LevelHandlerPath.
+// WithPprofAuth protects it like the other debug endpoints.
+func WithLogLevelEndpoint(path string) Option {
+	return func(s *HTTPService) {
+		if path == "" {
+			path = logger.LevelHandlerPath
+		}
+		s.logLevelPath = path
+	}
+}
+
+// WithPprofPrefix sets the prefix of the debug endpoints, DefaultPprofPrefix by
+// default. An empty prefix or "/" would capture every request, so it keeps the
+// default.
+func WithPprofPrefix(prefix string) Option {
+	return func(s *HTTPService) {
+		prefix = strings.TrimRight(prefix, "/")
+		if prefix == "" {
+			prefix = DefaultPprofPrefix
+		}
+		s.pprofPrefix = prefix
+	}
+}
+
+// WithPprofAuth protects the debug endpoints with auth, e.g. BasicAuth,
+// including the one of WithLogLevelEndpoint
+func WithPprofAuth(auth Middleware) Option {
+	return func(s *HTTPService) {
+		s.pprofAuth = auth
+	}
+}
+
+// BasicAuth rejects requests without the given basic auth credentials
+func BasicAuth(username, password string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			u, p, ok := r.BasicAuth()
+			if !ok ||
+				subtle.ConstantTimeCompare([]byte(u), []byte(username)) != 1 ||
+				subtle.ConstantTimeCompare([]byte(p), []byte(password)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="restricted"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// pprofHandler serves the debug endpoints under the prefix of the service
+func (s *HTTPService) pprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	// pprof.Index expects the standard /debug/pprof/ paths
+	var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r2 := new(http.Request)
+		*r2 = *r
+		u := *r.URL
+		u.Path = "/debug" + strings.TrimPrefix(r.URL.Path, s.pprofPrefix)
+		u.RawPath = ""
+		r2.URL = &u
+		mux.ServeHTTP(w, r2)
+	})
+	if s.pprofAuth != nil {
+		h = s.pprofAuth(h)
+	}
+	return h
+}
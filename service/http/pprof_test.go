@@ -0,0 +1,127 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPService_Pprof(t *testing.T) {
+	ctx := context.Background()
+
+	svc := NewHTTPService("api", http.NotFoundHandler(),
+		WithAddr("127.0.0.1:0"),
+		WithPprof(),
+		WithPprofPrefix("/internal/debug/"),
+		WithPprofAuth(BasicAuth("ops", "secret")),
+	)
+	assert.NoError(t, svc.Start(ctx))
+	defer svc.Stop(ctx)
+
+	get := func(path string, auth bool) (int, string) {
+		req, _ := http.NewRequest(http.MethodGet, svc.url("http")+path, nil)
+		if auth {
+			req.SetBasicAuth("ops", "secret")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp.StatusCode, string(body)
+	}
+
+	status, _ := get("/internal/debug/pprof/", false)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, body := get("/internal/debug/pprof/", true)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "goroutine")
+
+	status, body = get("/internal/debug/pprof/goroutine?debug=1", true)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "goroutine profile")
+
+	status, body = get("/internal/debug/vars", true)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "memstats")
+
+	// The default prefix is left to the handler
+	status, _ = get("/debug/pprof/", true)
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestHTTPService_PprofRootPrefix(t *testing.T) {
+	ctx := context.Background()
+
+	app := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "app")
+	})
+	svc := NewHTTPService("api", app,
+		WithAddr("127.0.0.1:0"),
+		WithPprof(),
+		WithPprofPrefix("/"),
+	)
+	assert.NoError(t, svc.Start(ctx))
+	defer svc.Stop(ctx)
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(svc.url("http") + path)
+		assert.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp.StatusCode, string(body)
+	}
+
+	// "/" falls back to the default prefix instead of capturing every request
+	status, body := get("/users")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "app", body)
+
+	status, body = get(DefaultPprofPrefix + "/pprof/")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "goroutine")
+}
+
+func TestHTTPService_LogLevelEndpoint(t *testing.T) {
+	ctx := context.Background()
+
+	defer logger.SetDefault(logger.Instance())
+	log := logger.New(logger.WithLevel(logger.InfoLevel), logger.WithGlobal())
+
+	svc := NewHTTPService("api", http.NotFoundHandler(),
+		WithAddr("127.0.0.1:0"),
+		WithLogLevelEndpoint(""),
+		WithPprofAuth(BasicAuth("ops", "secret")),
+	)
+	assert.NoError(t, svc.Start(ctx))
+	defer svc.Stop(ctx)
+
+	do := func(method, body string, auth bool) (int, string) {
+		req, _ := http.NewRequest(method, svc.url("http")+logger.LevelHandlerPath, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if auth {
+			req.SetBasicAuth("ops", "secret")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp.StatusCode, string(b)
+	}
+
+	status, _ := do(http.MethodGet, "", false)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	status, body := do(http.MethodGet, "", true)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `"level":"info"`)
+
+	status, _ = do(http.MethodPut, "level=debug", true)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, logger.DebugLevel, log.GetLevel())
+}
@@ -0,0 +1,69 @@
+package http
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"github.com/ducconit/gocore/errors"
+	"github.com/ducconit/gocore/logger"
+	"github.com/ducconit/gocore/service"
+	"go.uber.org/zap"
+)
+
+// RequestIDHeader is the header carrying the request ID, which also serves
+// as the correlation ID of errors
+var RequestIDHeader = "X-Request-ID"
+
+// Recovery recovers from panics in handlers: the panic is logged with its
+// stack trace through l, the global logger when nil, and the client gets a
+// 500 Internal Server Error, as errors.WriteJSON writes it, whose request ID
+// is the correlation ID to find the log entry. http.ErrAbortHandler panics
+// are let through to abort the response.
+func Recovery(l *logger.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				id := service.RequestIDFromContext(r.Context())
+				if id == "" {
+					id = newRequestID()
+				}
+
+				err := errors.Internal(fmt.Sprintf("panic: %v", rec))
+
+				log := l
+				if log == nil {
+					log = logger.Instance()
+				}
+				log.Ctx(r.Context()).Error("panic recovered",
+					zap.Error(err),
+					zap.String("correlation_id", id),
+					zap.String("method", r.Method),
+					zap.String("path", r.URL.Path),
+					zap.String("stacktrace", err.StackTrace()),
+				)
+
+				w.Header().Set(RequestIDHeader, id)
+				errors.WriteJSON(w, err)
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// newRequestID returns a random 128-bit hex ID
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,50 @@
+package http
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecovery(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(logger.WithOutput(&buf))
+
+	h := Recovery(l)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders", nil))
+
+	id := w.Header().Get(RequestIDHeader)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Len(t, id, 32)
+	assert.JSONEq(t, `{"error":{"code":"internal","message":"Internal Server Error","request_id":"`+id+`"}}`, w.Body.String())
+
+	line := buf.String()
+	assert.Contains(t, line, `"msg":"panic recovered"`)
+	assert.Contains(t, line, `"correlation_id":"`+id+`"`)
+	assert.Contains(t, line, `"error":"panic: boom"`)
+	assert.Contains(t, line, "recovery_test.go")
+
+	// The request ID of the context is used as correlation ID
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	h.ServeHTTP(w, r.WithContext(logger.ContextWithRequestID(r.Context(), "req-1")))
+	assert.Equal(t, "req-1", w.Header().Get(RequestIDHeader))
+	assert.Contains(t, w.Body.String(), `"request_id":"req-1"`)
+}
+
+func TestRecovery_AbortHandler(t *testing.T) {
+	h := Recovery(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
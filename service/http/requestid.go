@@ -0,0 +1,48 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/ducconit/gocore/service"
+)
+
+// maxRequestIDLength bounds the request IDs accepted from clients
+const maxRequestIDLength = 128
+
+// WithRequestID runs the RequestID middleware in front of every other one
+func WithRequestID() Option {
+	return func(s *HTTPService) {
+		s.requestID = true
+	}
+}
+
+// RequestID propagates the X-Request-ID header of requests, or generates one,
+// into the request context and the response header. Handlers read it with
+// service.RequestIDFromContext and loggers attach it to their entries.
+func RequestID() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = newRequestID()
+			}
+
+			w.Header().Set(RequestIDHeader, id)
+			next.ServeHTTP(w, r.WithContext(service.ContextWithRequestID(r.Context(), id)))
+		})
+	}
+}
+
+// validRequestID rejects empty, oversized and non-printable IDs, which would
+// pollute the logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
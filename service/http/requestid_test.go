@@ -0,0 +1,51 @@
+package http
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/ducconit/gocore/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.New(logger.WithOutput(&buf))
+
+	var seen string
+	h := RequestID()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = service.RequestIDFromContext(r.Context())
+		l.Ctx(r.Context()).Info("in handler")
+	}))
+
+	serve := func(id string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if id != "" {
+			r.Header.Set(RequestIDHeader, id)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// Propagated
+	w := serve("req-1")
+	assert.Equal(t, "req-1", seen)
+	assert.Equal(t, "req-1", w.Header().Get(RequestIDHeader))
+	assert.Contains(t, buf.String(), `"request_id":"req-1"`)
+
+	// Generated
+	w = serve("")
+	assert.Len(t, seen, 32)
+	assert.Equal(t, seen, w.Header().Get(RequestIDHeader))
+
+	// Invalid IDs are replaced
+	serve("bad id\n")
+	assert.Len(t, seen, 32)
+	serve(strings.Repeat("a", maxRequestIDLength+1))
+	assert.Len(t, seen, 32)
+}
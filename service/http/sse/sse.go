@@ -0,0 +1,321 @@
+// Package sse streams Server-Sent Events published to topics
+//
+//	broker := sse.NewBroker()
+//	mux.Handle("/events", broker) // GET /events?topic=orders&topic=stock
+//	svc := http.NewHTTPService("api", mux)
+//	svc.OnDrain(broker.CloseStreams)
+//
+//	broker.Publish("orders", sse.Event{Event: "created", Data: `{"id":1}`})
+package sse
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults used when no option is given
+const (
+	DefaultHeartbeat  = 15 * time.Second
+	DefaultReplay     = 100
+	DefaultBufferSize = 64
+)
+
+// Event is a Server-Sent Event
+type Event struct {
+	// ID lets clients resume after it, assigned by Publish when empty
+	ID string
+
+	// Event is the event type, "message" for clients when empty
+	Event string
+
+	// Data is the payload, sent as one data line per line
+	Data string
+
+	// Retry asks clients to wait this long before reconnecting, when set
+	Retry time.Duration
+}
+
+// Option represents a broker option
+type Option func(*Broker)
+
+// WithHeartbeat sets the interval of the comments keeping idle streams open
+// through proxies, DefaultHeartbeat by default, zero disables them
+func WithHeartbeat(d time.Duration) Option {
+	return func(b *Broker) {
+		b.heartbeat = d
+	}
+}
+
+// WithReplay sets how many events of each topic are kept for clients
+// reconnecting with a Last-Event-ID, DefaultReplay by default
+func WithReplay(n int) Option {
+	return func(b *Broker) {
+		b.replay = n
+	}
+}
+
+// WithBufferSize sets how many events a stream buffers. A client falling
+// further behind is disconnected, and catches up when it reconnects.
+// DefaultBufferSize by default
+func WithBufferSize(n int) Option {
+	return func(b *Broker) {
+		b.bufferSize = n
+	}
+}
+
+// WithTopics sets how the topics of a request are picked, the "topic" query
+// parameters by default. A request without topics gets 400 Bad Request.
+func WithTopics(fn func(r *http.Request) []string) Option {
+	return func(b *Broker) {
+		b.topics = fn
+	}
+}
+
+// Broker streams the events published to topics to the clients subscribed
+// to them. It is an http.Handler serving the streams.
+type Broker struct {
+	heartbeat  time.Duration
+	replay     int
+	bufferSize int
+	topics     func(r *http.Request) []string
+
+	mu          sync.Mutex
+	seq         uint64
+	history     map[string][]published
+	subscribers map[*subscriber]struct{}
+}
+
+// published is an event with its position in the publishing order
+type published struct {
+	seq   uint64
+	event Event
+}
+
+type subscriber struct {
+	topics map[string]bool
+	events chan published
+
+	// closed is closed when the stream must end
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (s *subscriber) close() {
+	s.closeOnce.Do(func() { close(s.closed) })
+}
+
+// NewBroker creates a broker
+func NewBroker(opts ...Option) *Broker {
+	b := &Broker{
+		heartbeat:   DefaultHeartbeat,
+		replay:      DefaultReplay,
+		bufferSize:  DefaultBufferSize,
+		topics:      queryTopics,
+		history:     make(map[string][]published),
+		subscribers: make(map[*subscriber]struct{}),
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+func queryTopics(r *http.Request) []string {
+	return r.URL.Query()["topic"]
+}
+
+// Publish sends e to the clients subscribed to topic and keeps it for
+// replay, assigning it the next ID when it has none
+func (b *Broker) Publish(topic string, e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	if e.ID == "" {
+		e.ID = strconv.FormatUint(b.seq, 10)
+	}
+	p := published{seq: b.seq, event: e}
+
+	if b.replay > 0 {
+		h := append(b.history[topic], p)
+		if len(h) > b.replay {
+			h = slices.Clone(h[len(h)-b.replay:])
+		}
+		b.history[topic] = h
+	}
+
+	for s := range b.subscribers {
+		if !s.topics[topic] {
+			continue
+		}
+		select {
+		case s.events <- p:
+		default:
+			// The client reconnects and replays what it missed
+			s.close()
+			delete(b.subscribers, s)
+		}
+	}
+}
+
+// Subscribers returns the number of open streams
+func (b *Broker) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+// CloseStreams ends the open streams, so a draining server does not wait
+// for them. Clients reconnect and resume from their last event ID, to
+// another instance or once the server is back.
+func (b *Broker) CloseStreams() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.subscribers {
+		s.close()
+		delete(b.subscribers, s)
+	}
+}
+
+// ServeHTTP streams the events of the topics of r until the client goes
+// away, replaying the events after its Last-Event-ID first
+func (b *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	topics := b.topics(r)
+	if len(topics) == 0 {
+		http.Error(w, "no topic", http.StatusBadRequest)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// Streams outlive the write timeout of the server
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	s := &subscriber{
+		topics: make(map[string]bool, len(topics)),
+		events: make(chan published, b.bufferSize),
+		closed: make(chan struct{}),
+	}
+	for _, t := range topics {
+		s.topics[t] = true
+	}
+	missed := b.subscribe(s, r.Header.Get("Last-Event-ID"))
+	defer b.unsubscribe(s)
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	// Keep proxies such as nginx from buffering the stream
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	for _, p := range missed {
+		if err := writeEvent(w, p.event); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	var heartbeat <-chan time.Time
+	if b.heartbeat > 0 {
+		ticker := time.NewTicker(b.heartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.closed:
+			return
+		case p := <-s.events:
+			err = writeEvent(w, p.event)
+		case <-heartbeat:
+			_, err = w.Write([]byte(": heartbeat\n\n"))
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// subscribe adds s and returns the retained events of its topics published
+// after lastID, all of them when lastID is no longer retained
+func (b *Broker) subscribe(s *subscriber, lastID string) []published {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[s] = struct{}{}
+
+	if lastID == "" {
+		return nil
+	}
+
+	var (
+		missed []published
+		after  uint64
+	)
+	for t := range s.topics {
+		for _, p := range b.history[t] {
+			if p.event.ID == lastID {
+				after = p.seq
+			}
+		}
+		missed = append(missed, b.history[t]...)
+	}
+	missed = slices.DeleteFunc(missed, func(p published) bool { return p.seq <= after })
+	slices.SortFunc(missed, func(a, b published) int { return cmp.Compare(a.seq, b.seq) })
+	return missed
+}
+
+func (b *Broker) unsubscribe(s *subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscribers, s)
+}
+
+// writeEvent writes e in the text/event-stream format
+func writeEvent(w http.ResponseWriter, e Event) error {
+	var sb strings.Builder
+	if e.ID != "" {
+		sb.WriteString("id: " + stripNewlines(e.ID) + "\n")
+	}
+	if e.Event != "" {
+		sb.WriteString("event: " + stripNewlines(e.Event) + "\n")
+	}
+	if e.Retry > 0 {
+		sb.WriteString("retry: " + strconv.FormatInt(e.Retry.Milliseconds(), 10) + "\n")
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(e.Data, "\r\n", "\n"), "\n") {
+		sb.WriteString("data: " + line + "\n")
+	}
+	sb.WriteString("\n")
+
+	_, err := w.Write([]byte(sb.String()))
+	return err
+}
+
+// stripNewlines keeps a field from breaking out of its line
+func stripNewlines(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
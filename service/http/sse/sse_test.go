@@ -0,0 +1,112 @@
+package sse
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gocorehttp "github.com/ducconit/gocore/service/http"
+	"github.com/stretchr/testify/assert"
+)
+
+// stream reads the events of an open stream
+type stream struct {
+	resp   *http.Response
+	reader *bufio.Reader
+}
+
+func subscribe(t *testing.T, url, lastID string) *stream {
+	r, _ := http.NewRequest(http.MethodGet, url, nil)
+	if lastID != "" {
+		r.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := http.DefaultClient.Do(r)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return &stream{resp: resp, reader: bufio.NewReader(resp.Body)}
+}
+
+// next returns the lines of the next event or comment
+func (s *stream) next(t *testing.T) []string {
+	var lines []string
+	for {
+		line, err := s.reader.ReadString('\n')
+		if !assert.NoError(t, err) {
+			return lines
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return lines
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestBroker(t *testing.T) {
+	ctx := context.Background()
+
+	broker := NewBroker(WithHeartbeat(50 * time.Millisecond))
+	svc := gocorehttp.NewHTTPService("api", broker, gocorehttp.WithAddr("127.0.0.1:0"),
+		gocorehttp.WithTimeouts(0, 0, 100*time.Millisecond, 0), gocorehttp.WithShutdownTimeout(5*time.Second))
+	svc.OnDrain(broker.CloseStreams)
+	assert.NoError(t, svc.Start(ctx))
+	url := "http://" + svc.ListenAddr().String() + "/?topic=orders&topic=stock"
+
+	s := subscribe(t, url, "")
+	assert.Equal(t, "text/event-stream", s.resp.Header.Get("Content-Type"))
+	assert.Eventually(t, func() bool { return broker.Subscribers() == 1 }, time.Second, 10*time.Millisecond)
+
+	broker.Publish("orders", Event{Event: "created", Data: "{\"id\":1}\nsecond line"})
+	broker.Publish("users", Event{Data: "ignored"})
+	broker.Publish("stock", Event{ID: "s-1", Data: "low", Retry: time.Second})
+	assert.Equal(t, []string{"id: 1", "event: created", `data: {"id":1}`, "data: second line"}, s.next(t))
+	assert.Equal(t, []string{"id: s-1", "retry: 1000", "data: low"}, s.next(t))
+
+	// Heartbeats keep the stream open past the write timeout
+	time.Sleep(150 * time.Millisecond)
+	assert.Equal(t, []string{": heartbeat"}, s.next(t))
+	s.resp.Body.Close()
+	assert.Eventually(t, func() bool { return broker.Subscribers() == 0 }, time.Second, 10*time.Millisecond)
+
+	// Reconnecting clients get the events they missed
+	broker.Publish("orders", Event{Data: "missed"})
+	s = subscribe(t, url, "1")
+	assert.Equal(t, []string{"id: s-1", "retry: 1000", "data: low"}, s.next(t))
+	assert.Equal(t, []string{"id: 4", "data: missed"}, s.next(t))
+
+	// Draining ends the streams rather than waiting for them
+	start := time.Now()
+	assert.NoError(t, svc.Stop(ctx))
+	assert.Less(t, time.Since(start), time.Second)
+	s.resp.Body.Close()
+}
+
+func TestBroker_SlowSubscriber(t *testing.T) {
+	broker := NewBroker(WithBufferSize(1), WithReplay(0))
+	s := &subscriber{topics: map[string]bool{"orders": true}, events: make(chan published, 1), closed: make(chan struct{})}
+	broker.subscribe(s, "")
+
+	broker.Publish("orders", Event{Data: "1"})
+	assert.Equal(t, 1, broker.Subscribers())
+	broker.Publish("orders", Event{Data: "2"})
+	assert.Equal(t, 0, broker.Subscribers())
+	<-s.closed
+	assert.Empty(t, broker.history)
+}
+
+func TestBroker_BadRequests(t *testing.T) {
+	broker := NewBroker()
+
+	w := httptest.NewRecorder()
+	broker.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	broker.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/?topic=orders", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
@@ -0,0 +1,197 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultStaticMaxAge is how long clients cache static assets other than HTML
+const DefaultStaticMaxAge = time.Hour
+
+// WithStaticDir serves the files of dir under prefix, see WithStaticFS
+func WithStaticDir(prefix, dir string, spaFallback bool) Option {
+	return WithStaticFS(prefix, os.DirFS(dir), spaFallback)
+}
+
+// WithStaticFS serves the files of fsys under prefix, e.g. assets embedded
+// with embed.FS. Directories serve their index.html and dotfiles are hidden.
+// With spaFallback, paths without an extension that match no file serve the
+// root index.html, so a single page app handles its own routes. Requests
+// matching a route of an *http.ServeMux handler go to the handler instead.
+func WithStaticFS(prefix string, fsys fs.FS, spaFallback bool) Option {
+	return func(s *HTTPService) {
+		prefix = "/" + strings.Trim(prefix, "/")
+		s.static = append(s.static, &staticMount{prefix: prefix, fsys: fsys, spa: spaFallback})
+	}
+}
+
+// WithStaticMaxAge sets how long clients cache static assets other than
+// HTML, which they always revalidate. DefaultStaticMaxAge by default
+func WithStaticMaxAge(d time.Duration) Option {
+	return func(s *HTTPService) {
+		s.staticMaxAge = d
+	}
+}
+
+type staticMount struct {
+	prefix string
+	fsys   fs.FS
+	spa    bool
+	maxAge time.Duration
+
+	// etags caches the ETag of each file until it changes
+	etags sync.Map
+}
+
+type etagEntry struct {
+	modTime time.Time
+	size    int64
+	etag    string
+}
+
+// staticHandler serves the static mounts, the longest prefix first, and
+// next for the other requests and the routes of handler
+func (s *HTTPService) staticHandler(handler, next http.Handler) http.Handler {
+	mounts := append([]*staticMount(nil), s.static...)
+	sort.SliceStable(mounts, func(i, j int) bool {
+		return len(mounts[i].prefix) > len(mounts[j].prefix)
+	})
+	for _, m := range mounts {
+		m.maxAge = s.staticMaxAge
+	}
+	mux, _ := handler.(*http.ServeMux)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if mux != nil {
+			if _, pattern := mux.Handler(r); pattern != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		for _, m := range mounts {
+			if m.match(r.URL.Path) {
+				m.ServeHTTP(w, r)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (m *staticMount) match(p string) bool {
+	return m.prefix == "/" || p == m.prefix || strings.HasPrefix(p, m.prefix+"/")
+}
+
+func (m *staticMount) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, m.prefix)
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "."
+	}
+	if hidden(name) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if m.serveFile(w, r, name) {
+		return
+	}
+	if m.spa && path.Ext(name) == "" && m.serveFile(w, r, "index.html") {
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// serveFile serves name, or the index.html of a directory, with its cache
+// headers. It reports false when there is no such file.
+func (m *staticMount) serveFile(w http.ResponseWriter, r *http.Request, name string) bool {
+	f, err := m.fsys.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	if info.IsDir() {
+		return m.serveFile(w, r, path.Join(name, "index.html"))
+	}
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return true
+		}
+		content = bytes.NewReader(data)
+	}
+
+	etag, err := m.etag(name, info, content)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return true
+	}
+
+	w.Header().Set("ETag", etag)
+	if path.Ext(name) == ".html" {
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(m.maxAge.Seconds())))
+	}
+	// ServeContent answers If-None-Match with 304 Not Modified
+	http.ServeContent(w, r, info.Name(), info.ModTime(), content)
+	return true
+}
+
+// etag returns the hash of the content of name, computed again when the
+// file changes
+func (m *staticMount) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	if v, ok := m.etags.Load(name); ok {
+		e := v.(etagEntry)
+		if e.modTime.Equal(info.ModTime()) && e.size == info.Size() {
+			return e.etag, nil
+		}
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	etag := `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+	m.etags.Store(name, etagEntry{modTime: info.ModTime(), size: info.Size(), etag: etag})
+	return etag, nil
+}
+
+// hidden reports whether a path has a dotfile segment, e.g. ".env" or ".git/config"
+func hidden(name string) bool {
+	for _, segment := range strings.Split(name, "/") {
+		if segment != "." && strings.HasPrefix(segment, ".") {
+			return true
+		}
+	}
+	return false
+}
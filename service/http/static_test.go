@@ -0,0 +1,128 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPService_StaticFS(t *testing.T) {
+	ctx := context.Background()
+
+	assets := fstest.MapFS{
+		"index.html":     {Data: []byte("<h1>app</h1>")},
+		"assets/main.js": {Data: []byte("console.log('app')")},
+		".env":           {Data: []byte("SECRET=1")},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/orders", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "orders")
+	})
+
+	svc := NewHTTPService("web", mux,
+		WithAddr("127.0.0.1:0"),
+		WithStaticFS("/", assets, true),
+	)
+	assert.NoError(t, svc.Start(ctx))
+	defer svc.Stop(ctx)
+
+	do := func(method, path string, header http.Header) (*http.Response, string) {
+		req, _ := http.NewRequest(method, svc.url("http")+path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp, string(body)
+	}
+
+	resp, body := do(http.MethodGet, "/", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "<h1>app</h1>", body)
+	assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+	etag := resp.Header.Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	resp, _ = do(http.MethodGet, "/", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	resp, body = do(http.MethodGet, "/assets/main.js", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "console.log('app')", body)
+	assert.Equal(t, "public, max-age=3600", resp.Header.Get("Cache-Control"))
+	assert.Contains(t, resp.Header.Get("Content-Type"), "javascript")
+
+	// Client side routes fall back to the index, missing assets do not
+	resp, body = do(http.MethodGet, "/settings/profile", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "<h1>app</h1>", body)
+	resp, _ = do(http.MethodGet, "/assets/missing.js", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, _ = do(http.MethodGet, "/.env", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, _ = do(http.MethodPost, "/assets/main.js", nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	assert.Equal(t, "GET, HEAD", resp.Header.Get("Allow"))
+
+	// Routes of the mux win over the files
+	resp, body = do(http.MethodGet, "/api/orders", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "orders", body)
+}
+
+func TestHTTPService_StaticDir(t *testing.T) {
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "docs"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "docs", "index.html"), []byte("docs"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "logo.svg"), []byte("<svg/>"), 0o644))
+
+	svc := NewHTTPService("web", http.NotFoundHandler(),
+		WithAddr("127.0.0.1:0"),
+		WithStaticDir("/static/", dir, false),
+		WithStaticMaxAge(0),
+	)
+	assert.NoError(t, svc.Start(ctx))
+	defer svc.Stop(ctx)
+
+	get := func(path string) (*http.Response, string) {
+		resp, err := http.Get(svc.url("http") + path)
+		assert.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return resp, string(body)
+	}
+
+	resp, body := get("/static/docs")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "docs", body)
+
+	resp, body = get("/static/logo.svg")
+	assert.Equal(t, "<svg/>", body)
+	assert.Equal(t, "public, max-age=0", resp.Header.Get("Cache-Control"))
+	etag := resp.Header.Get("ETag")
+
+	// The ETag follows the content of the file
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "logo.svg"), []byte("<svg></svg>"), 0o644))
+	resp, _ = get("/static/logo.svg")
+	assert.NotEqual(t, etag, resp.Header.Get("ETag"))
+
+	// Without the fallback, and outside the prefix
+	resp, _ = get("/static/settings")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = get("/static/../../etc/passwd")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = get("/logo.svg")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
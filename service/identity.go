@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/url"
+)
+
+// ClientIdentity is the identity of a client authenticated by a verified
+// TLS certificate
+type ClientIdentity struct {
+	// CommonName is the subject common name of the certificate
+	CommonName string
+
+	// DNSNames, EmailAddresses and URIs are the subject alternative names,
+	// URIs holding e.g. SPIFFE IDs
+	DNSNames       []string
+	EmailAddresses []string
+	URIs           []*url.URL
+
+	// Certificate is the verified client certificate
+	Certificate *x509.Certificate
+}
+
+type clientIdentityKey struct{}
+
+// ClientIdentityFromTLS returns the identity of the client of state, only
+// if its certificate was verified
+func ClientIdentityFromTLS(state *tls.ConnectionState) (*ClientIdentity, bool) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+
+	cert := state.VerifiedChains[0][0]
+	return &ClientIdentity{
+		CommonName:     cert.Subject.CommonName,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		URIs:           cert.URIs,
+		Certificate:    cert,
+	}, true
+}
+
+// ContextWithClientIdentity returns ctx carrying id
+func ContextWithClientIdentity(ctx context.Context, id *ClientIdentity) context.Context {
+	return context.WithValue(ctx, clientIdentityKey{}, id)
+}
+
+// ClientIdentityFromContext returns the client identity carried by ctx
+func ClientIdentityFromContext(ctx context.Context) (*ClientIdentity, bool) {
+	id, ok := ctx.Value(clientIdentityKey{}).(*ClientIdentity)
+	return id, ok
+}
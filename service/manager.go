@@ -0,0 +1,408 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ducconit/gocore/logger"
+)
+
+var (
+	// ErrServiceExists is returned when a service name is registered twice
+	ErrServiceExists = errors.New("service already registered")
+
+	// ErrUnknownDependency is returned when a service depends on an unregistered service
+	ErrUnknownDependency = errors.New("unknown service dependency")
+
+	// ErrDependencyCycle is returned when service dependencies form a cycle
+	ErrDependencyCycle = errors.New("service dependency cycle")
+
+	// ErrManagerRunning is returned by Start while the services are running
+	ErrManagerRunning = errors.New("service manager already running")
+
+	// DefaultHealthTimeout is how long to wait for a dependency to become ready
+	DefaultHealthTimeout = 30 * time.Second
+
+	// DefaultHealthInterval is the interval between dependency health checks
+	DefaultHealthInterval = 100 * time.Millisecond
+
+	// DefaultShutdownTimeout is how long Run waits for the services to stop
+	DefaultShutdownTimeout = 30 * time.Second
+)
+
+// Option represents a manager option
+type Option func(*Manager)
+
+// WithHealthTimeout sets how long to wait for a dependency to become ready
+func WithHealthTimeout(d time.Duration) Option {
+	return func(m *Manager) {
+		m.healthTimeout = d
+	}
+}
+
+// WithHealthInterval sets the interval between dependency health checks
+func WithHealthInterval(d time.Duration) Option {
+	return func(m *Manager) {
+		m.healthInterval = d
+	}
+}
+
+// WithShutdownTimeout sets how long Run waits for the services to stop
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(m *Manager) {
+		m.shutdownTimeout = d
+	}
+}
+
+// WithSignals sets the signals Run stops the services on, DefaultSignals by
+// default. Another signal received while stopping forces the exit, see
+// WithForceExit.
+func WithSignals(signals ...os.Signal) Option {
+	return func(m *Manager) {
+		m.signals = signals
+	}
+}
+
+// RegisterOption represents an option applied when registering a service
+type RegisterOption func(*registration)
+
+// WithDependsOn declares services that must be started and ready first
+func WithDependsOn(names ...string) RegisterOption {
+	return func(r *registration) {
+		r.dependsOn = append(r.dependsOn, names...)
+	}
+}
+
+type registration struct {
+	service   Service
+	dependsOn []string
+}
+
+// Manager starts services in dependency order and stops them in reverse
+type Manager struct {
+	mu             sync.Mutex
+	services       map[string]*registration
+	names          []string
+	healthTimeout  time.Duration
+	healthInterval time.Duration
+
+	shutdownTimeout time.Duration
+	signals         []os.Signal
+	forceExit       func()
+	logger          *logger.Logger
+
+	// stateMu guards the lifecycle state, so the registry stays readable
+	// while services start and stop. stopping lists the services left to
+	// stop, read when forcing the exit
+	stateMu      sync.Mutex
+	running      bool
+	started      []Service
+	stopping     []Service
+	hooksStarted bool
+
+	hooks Hooks
+}
+
+// NewManager creates a new service manager
+func NewManager(opts ...Option) *Manager {
+	m := &Manager{
+		services:        make(map[string]*registration),
+		healthTimeout:   DefaultHealthTimeout,
+		healthInterval:  DefaultHealthInterval,
+		shutdownTimeout: DefaultShutdownTimeout,
+		signals:         DefaultSignals,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// Register adds a service to the manager
+func (m *Manager) Register(svc Service, opts ...RegisterOption) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name := svc.Name()
+	if _, ok := m.services[name]; ok {
+		return fmt.Errorf("%w: %s", ErrServiceExists, name)
+	}
+
+	r := &registration{service: svc}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	m.services[name] = r
+	m.names = append(m.names, name)
+	return nil
+}
+
+// Get returns a registered service by name
+func (m *Manager) Get(name string) (Service, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.services[name]
+	if !ok {
+		return nil, false
+	}
+	return r.service, true
+}
+
+// Order returns the service names in the order they will be started
+func (m *Manager) Order() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order()
+}
+
+// OnStart adds a hook run before the services start, e.g. to open resources
+// shared by several services
+func (m *Manager) OnStart(fn Hook) {
+	m.hooks.OnStart(fn)
+}
+
+// OnStop adds a hook run after the services stop, in the reverse order they
+// are added
+func (m *Manager) OnStop(fn Hook) {
+	m.hooks.OnStop(fn)
+}
+
+// Start runs the start hooks, then starts every service after its
+// dependencies are started and ready, see Readier. If a service fails to
+// start, the services already started are stopped within the shutdown
+// timeout, even once ctx is done, and the stop hooks run. Start returns
+// ErrManagerRunning until Stop is called.
+func (m *Manager) Start(ctx context.Context) error {
+	m.stateMu.Lock()
+	if m.running {
+		m.stateMu.Unlock()
+		return ErrManagerRunning
+	}
+	m.running = true
+	m.stateMu.Unlock()
+
+	// Work on a snapshot, Get and Health are not blocked while waiting for
+	// dependencies
+	m.mu.Lock()
+	order, err := m.order()
+	services := maps.Clone(m.services)
+	m.mu.Unlock()
+	if err != nil {
+		m.setRunning(false)
+		return err
+	}
+
+	if err := m.hooks.Start(ctx); err != nil {
+		m.setRunning(false)
+		return fmt.Errorf("failed to run start hooks: %w", err)
+	}
+	m.stateMu.Lock()
+	m.hooksStarted = true
+	m.stateMu.Unlock()
+
+	for _, name := range order {
+		r := services[name]
+
+		for _, dep := range r.dependsOn {
+			if err := m.waitReady(ctx, services[dep].service); err != nil {
+				return errors.Join(
+					fmt.Errorf("service %s: dependency %s: %w", name, dep, err),
+					m.rollback(ctx),
+				)
+			}
+		}
+
+		if err := r.service.Start(ctx); err != nil {
+			return errors.Join(
+				fmt.Errorf("failed to start service %s: %w", name, err),
+				m.rollback(ctx),
+			)
+		}
+		m.stateMu.Lock()
+		m.started = append(m.started, r.service)
+		m.stateMu.Unlock()
+	}
+
+	return nil
+}
+
+// Stop stops the started services in reverse start order, then runs the
+// stop hooks
+func (m *Manager) Stop(ctx context.Context) error {
+	return m.stopStarted(ctx)
+}
+
+// Run starts the services, blocks until ctx is done or a signal is received,
+// then stops them within the shutdown timeout. Another signal received
+// while stopping logs the services left and exits right away.
+func (m *Manager) Run(ctx context.Context) error {
+	// Listen first, a signal received while starting aborts the startup
+	ctx, stop := NotifyShutdown(ctx, m.force, m.signals...)
+	defer stop()
+
+	if err := m.Start(ctx); err != nil {
+		return err
+	}
+	<-ctx.Done()
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), m.shutdownTimeout)
+	defer cancel()
+	return m.Stop(stopCtx)
+}
+
+// Health returns the health of every registered service by name
+func (m *Manager) Health() map[string]bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	health := make(map[string]bool, len(m.services))
+	for name, r := range m.services {
+		health[name] = r.service.Health()
+	}
+	return health
+}
+
+// Healthy reports whether every registered service is healthy
+func (m *Manager) Healthy() bool {
+	for _, ok := range m.Health() {
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Ready reports whether every registered service is ready, see Readier
+func (m *Manager) Ready() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, r := range m.services {
+		if !IsReady(r.service) {
+			return false
+		}
+	}
+	return true
+}
+
+// rollback stops the services started by a failed Start. The start ctx may
+// be done already, e.g. by a signal, so they get a shutdown timeout of their own.
+func (m *Manager) rollback(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), m.shutdownTimeout)
+	defer cancel()
+	return m.stopStarted(ctx)
+}
+
+func (m *Manager) setRunning(running bool) {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+	m.running = running
+}
+
+func (m *Manager) stopStarted(ctx context.Context) error {
+	m.stateMu.Lock()
+	stopping := slices.Clone(m.started)
+	slices.Reverse(stopping)
+	m.stopping = stopping
+	m.started = nil
+	hooksStarted := m.hooksStarted
+	m.hooksStarted = false
+	m.stateMu.Unlock()
+
+	// Start is rejected until every service is stopped
+	defer m.setRunning(false)
+
+	var errs []error
+	for _, svc := range stopping {
+		if err := svc.Stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop service %s: %w", svc.Name(), err))
+		}
+
+		m.stateMu.Lock()
+		m.stopping = m.stopping[1:]
+		m.stateMu.Unlock()
+	}
+
+	if hooksStarted {
+		if err := m.hooks.Stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to run stop hooks: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Manager) waitReady(ctx context.Context, svc Service) error {
+	if IsReady(svc) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.healthTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(m.healthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("not ready: %w", ctx.Err())
+		case <-ticker.C:
+			if IsReady(svc) {
+				return nil
+			}
+		}
+	}
+}
+
+// order sorts services topologically, keeping registration order among independent services
+func (m *Manager) order() ([]string, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[string]int, len(m.services))
+	order := make([]string, 0, len(m.services))
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("%w: %v", ErrDependencyCycle, append(path, name))
+		}
+
+		state[name] = visiting
+		for _, dep := range m.services[name].dependsOn {
+			if _, ok := m.services[dep]; !ok {
+				return fmt.Errorf("%w: %s depends on %s", ErrUnknownDependency, name, dep)
+			}
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		order = append(order, name)
+		return nil
+	}
+
+	for _, name := range m.names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
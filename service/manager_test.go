@@ -0,0 +1,295 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeService struct {
+	name     string
+	log      *eventLog
+	healthy  atomic.Bool
+	delay    time.Duration
+	startErr error
+	stopErr  error
+}
+
+type eventLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *eventLog) add(event string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *eventLog) all() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.events...)
+}
+
+func newFakeService(name string, log *eventLog) *fakeService {
+	return &fakeService{name: name, log: log}
+}
+
+func (s *fakeService) Name() string {
+	return s.name
+}
+
+func (s *fakeService) Start(ctx context.Context) error {
+	if s.startErr != nil {
+		return s.startErr
+	}
+	s.log.add("start:" + s.name)
+	if s.delay > 0 {
+		time.AfterFunc(s.delay, func() { s.healthy.Store(true) })
+	} else {
+		s.healthy.Store(true)
+	}
+	return nil
+}
+
+func (s *fakeService) Stop(ctx context.Context) error {
+	s.stopErr = ctx.Err()
+	s.log.add("stop:" + s.name)
+	s.healthy.Store(false)
+	return nil
+}
+
+func (s *fakeService) Health() bool {
+	return s.healthy.Load()
+}
+
+func TestManager_DependencyOrder(t *testing.T) {
+	log := &eventLog{}
+	m := NewManager(WithHealthInterval(10 * time.Millisecond))
+
+	db := newFakeService("db", log)
+	db.delay = 50 * time.Millisecond
+
+	assert.NoError(t, m.Register(newFakeService("api", log), WithDependsOn("db", "cache")))
+	assert.NoError(t, m.Register(db))
+	assert.NoError(t, m.Register(newFakeService("cache", log)))
+
+	order, err := m.Order()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db", "cache", "api"}, order)
+
+	ctx := context.Background()
+	assert.NoError(t, m.Start(ctx))
+	assert.True(t, db.Health())
+
+	assert.NoError(t, m.Stop(ctx))
+	assert.Equal(t, []string{
+		"start:db", "start:cache", "start:api",
+		"stop:api", "stop:cache", "stop:db",
+	}, log.all())
+}
+
+func TestManager_InvalidDependencies(t *testing.T) {
+	log := &eventLog{}
+
+	t.Run("unknown", func(t *testing.T) {
+		m := NewManager()
+		assert.NoError(t, m.Register(newFakeService("api", log), WithDependsOn("db")))
+		assert.ErrorIs(t, m.Start(context.Background()), ErrUnknownDependency)
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		m := NewManager()
+		assert.NoError(t, m.Register(newFakeService("a", log), WithDependsOn("b")))
+		assert.NoError(t, m.Register(newFakeService("b", log), WithDependsOn("a")))
+		assert.ErrorIs(t, m.Start(context.Background()), ErrDependencyCycle)
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		m := NewManager()
+		assert.NoError(t, m.Register(newFakeService("a", log)))
+		assert.ErrorIs(t, m.Register(newFakeService("a", log)), ErrServiceExists)
+	})
+}
+
+func TestManager_StartFailureStopsStarted(t *testing.T) {
+	log := &eventLog{}
+	m := NewManager()
+
+	broken := newFakeService("api", log)
+	broken.startErr = errors.New("bind failed")
+
+	assert.NoError(t, m.Register(newFakeService("db", log)))
+	assert.NoError(t, m.Register(broken, WithDependsOn("db")))
+
+	err := m.Start(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, []string{"start:db", "stop:db"}, log.all())
+}
+
+func TestManager_StartFailureAfterCancel(t *testing.T) {
+	log := &eventLog{}
+	m := NewManager()
+
+	db := newFakeService("db", log)
+	broken := newFakeService("api", log)
+	broken.startErr = errors.New("bind failed")
+	assert.NoError(t, m.Register(db))
+	assert.NoError(t, m.Register(broken, WithDependsOn("db")))
+
+	// A signal cancelled the start, the started services still stop gracefully
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, m.Start(ctx))
+	assert.Equal(t, []string{"start:db", "stop:db"}, log.all())
+	assert.NoError(t, db.stopErr)
+}
+
+func TestManager_StartWhileRunning(t *testing.T) {
+	ctx := context.Background()
+	log := &eventLog{}
+	m := NewManager()
+	assert.NoError(t, m.Register(newFakeService("db", log)))
+
+	assert.NoError(t, m.Start(ctx))
+	assert.ErrorIs(t, m.Start(ctx), ErrManagerRunning)
+	assert.NoError(t, m.Stop(ctx))
+
+	// Stopped managers start again
+	assert.NoError(t, m.Start(ctx))
+	assert.NoError(t, m.Stop(ctx))
+	assert.Equal(t, []string{"start:db", "stop:db", "start:db", "stop:db"}, log.all())
+}
+
+func TestManager_DependencyNeverHealthy(t *testing.T) {
+	log := &eventLog{}
+	m := NewManager(
+		WithHealthTimeout(50*time.Millisecond),
+		WithHealthInterval(10*time.Millisecond),
+	)
+
+	db := newFakeService("db", log)
+	db.delay = time.Hour
+
+	assert.NoError(t, m.Register(db))
+	assert.NoError(t, m.Register(newFakeService("api", log), WithDependsOn("db")))
+
+	err := m.Start(context.Background())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, []string{"start:db", "stop:db"}, log.all())
+}
+
+func TestManager_HealthWhileStarting(t *testing.T) {
+	log := &eventLog{}
+	m := NewManager(WithHealthInterval(10 * time.Millisecond))
+
+	db := newFakeService("db", log)
+	db.delay = 100 * time.Millisecond
+	assert.NoError(t, m.Register(db))
+	assert.NoError(t, m.Register(newFakeService("api", log), WithDependsOn("db")))
+
+	ctx := context.Background()
+	started := make(chan error, 1)
+	go func() {
+		started <- m.Start(ctx)
+	}()
+
+	// The registry is not locked while api waits for db
+	assert.Eventually(t, func() bool { return len(log.all()) == 1 }, time.Second, 5*time.Millisecond)
+	health := make(chan map[string]bool, 1)
+	go func() {
+		health <- m.Health()
+	}()
+	select {
+	case h := <-health:
+		assert.Equal(t, map[string]bool{"db": false, "api": false}, h)
+	case <-time.After(50 * time.Millisecond):
+		t.Fatal("Health blocked while starting")
+	}
+
+	assert.NoError(t, <-started)
+	assert.NoError(t, m.Stop(ctx))
+}
+
+func TestManager_Health(t *testing.T) {
+	log := &eventLog{}
+	m := NewManager()
+
+	assert.NoError(t, m.Register(newFakeService("db", log)))
+	assert.NoError(t, m.Register(newFakeService("api", log), WithDependsOn("db")))
+	assert.Equal(t, map[string]bool{"db": false, "api": false}, m.Health())
+	assert.False(t, m.Healthy())
+
+	ctx := context.Background()
+	assert.NoError(t, m.Start(ctx))
+	assert.Equal(t, map[string]bool{"db": true, "api": true}, m.Health())
+	assert.True(t, m.Healthy())
+
+	assert.NoError(t, m.Stop(ctx))
+	assert.False(t, m.Healthy())
+}
+
+func TestManager_Run(t *testing.T) {
+	log := &eventLog{}
+	m := NewManager(WithSignals(syscall.SIGUSR1))
+
+	assert.NoError(t, m.Register(newFakeService("db", log)))
+	assert.NoError(t, m.Register(newFakeService("api", log), WithDependsOn("db")))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run(context.Background())
+	}()
+
+	assert.Eventually(t, m.Healthy, time.Second, 10*time.Millisecond)
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("manager did not stop on signal")
+	}
+	assert.Equal(t, []string{"start:db", "start:api", "stop:api", "stop:db"}, log.all())
+}
+
+type warmingService struct {
+	*fakeService
+	ready atomic.Bool
+}
+
+func (s *warmingService) Ready() bool {
+	return s.ready.Load()
+}
+
+func TestManager_Readiness(t *testing.T) {
+	log := &eventLog{}
+	m := NewManager(WithHealthInterval(10 * time.Millisecond))
+
+	cache := &warmingService{fakeService: newFakeService("cache", log)}
+	assert.NoError(t, m.Register(cache))
+	assert.NoError(t, m.Register(newFakeService("api", log), WithDependsOn("cache")))
+
+	// Alive but warming, the dependent waits
+	time.AfterFunc(50*time.Millisecond, func() {
+		log.add("ready:cache")
+		cache.ready.Store(true)
+	})
+
+	ctx := context.Background()
+	assert.NoError(t, m.Start(ctx))
+	assert.True(t, m.Ready())
+	assert.Equal(t, []string{"start:cache", "ready:cache", "start:api"}, log.all())
+
+	cache.ready.Store(false)
+	assert.True(t, m.Healthy())
+	assert.False(t, m.Ready())
+	assert.NoError(t, m.Stop(ctx))
+}
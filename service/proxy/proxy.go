@@ -0,0 +1,451 @@
+// Package proxy runs a reverse proxy as a service.Service, for lightweight
+// API gateways: routes by path prefix to upstreams, rewrites headers,
+// retries dial failures on another upstream and checks upstream health.
+//
+//	svc, _ := proxy.NewProxyService("gateway",
+//		proxy.WithRoute(proxy.Route{
+//			Prefix:     "/users",
+//			Upstreams:  []string{"http://users-1:8080", "http://users-2:8080"},
+//			HealthPath: "/healthz",
+//		}),
+//		proxy.WithHTTPOptions(gocorehttp.WithAddr(":8000")),
+//	)
+//	manager.Register(svc)
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/ducconit/gocore/service"
+	gocorehttp "github.com/ducconit/gocore/service/http"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultRetries is how many other upstreams a request is sent to when
+	// dialing its upstream fails
+	DefaultRetries = 2
+
+	// DefaultHealthCheckInterval is the interval between upstream health checks
+	DefaultHealthCheckInterval = 10 * time.Second
+
+	// DefaultHealthCheckTimeout bounds each upstream health check
+	DefaultHealthCheckTimeout = 2 * time.Second
+)
+
+// ErrNoUpstream is the error of requests whose route has no healthy upstream
+var ErrNoUpstream = errors.New("no healthy upstream")
+
+// Route sends the requests under a path prefix to a pool of upstreams
+type Route struct {
+	// Prefix is the path prefix of the route, the longest matching one wins
+	Prefix string
+
+	// Upstreams are the base URLs of the pool, e.g. "http://users:8080",
+	// load balanced round robin
+	Upstreams []string
+
+	// StripPrefix removes Prefix from the path sent upstream
+	StripPrefix bool
+
+	// PreserveHost sends the Host header of the client instead of the upstream host
+	PreserveHost bool
+
+	// SetRequestHeaders and RemoveRequestHeaders rewrite the headers sent upstream
+	SetRequestHeaders    map[string]string
+	RemoveRequestHeaders []string
+
+	// SetResponseHeaders and RemoveResponseHeaders rewrite the headers sent back
+	SetResponseHeaders    map[string]string
+	RemoveResponseHeaders []string
+
+	// HealthPath is checked on every upstream with a GET, e.g. "/healthz".
+	// Upstreams answering an error or a 5xx status get no traffic until they
+	// recover. Empty only takes upstreams out after a dial failure.
+	HealthPath string
+}
+
+// Option represents a proxy service option
+type Option func(*ProxyService)
+
+// WithRoute adds a route
+func WithRoute(r Route) Option {
+	return func(s *ProxyService) {
+		s.routes = append(s.routes, r)
+	}
+}
+
+// WithRetries sets how many other upstreams a request is sent to when
+// dialing its upstream fails. Requests are only retried when nothing was
+// sent, so non-idempotent requests are safe.
+func WithRetries(n int) Option {
+	return func(s *ProxyService) {
+		s.retries = n
+	}
+}
+
+// WithHealthCheckInterval sets the interval between upstream health checks,
+// and how long an upstream that failed to dial gets no traffic
+func WithHealthCheckInterval(d time.Duration) Option {
+	return func(s *ProxyService) {
+		s.interval = d
+	}
+}
+
+// WithTransport sets the transport of the requests sent upstream,
+// http.DefaultTransport by default
+func WithTransport(rt http.RoundTripper) Option {
+	return func(s *ProxyService) {
+		s.transport = rt
+	}
+}
+
+// WithLogger sets the logger of upstream failures, the global logger by default
+func WithLogger(l *logger.Logger) Option {
+	return func(s *ProxyService) {
+		s.logger = l
+	}
+}
+
+// WithHTTPOptions configures the HTTP server of the proxy: address, TLS,
+// timeouts, middleware, access log, metrics...
+func WithHTTPOptions(opts ...gocorehttp.Option) Option {
+	return func(s *ProxyService) {
+		s.httpOpts = append(s.httpOpts, opts...)
+	}
+}
+
+// ProxyService is an HTTP service proxying requests to upstreams. The
+// health endpoint of the server reports, for each route, whether it has a
+// healthy upstream.
+type ProxyService struct {
+	*gocorehttp.HTTPService
+
+	routes    []Route
+	retries   int
+	interval  time.Duration
+	transport http.RoundTripper
+	logger    *logger.Logger
+	httpOpts  []gocorehttp.Option
+
+	logOnce    sync.Once
+	serviceLog *logger.Logger
+
+	pools  []*pool
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// pool is a route with its upstreams
+type pool struct {
+	Route
+	upstreams []*upstream
+	next      atomic.Uint64
+	proxy     *httputil.ReverseProxy
+}
+
+// upstream is a backend of a pool, available while healthy and not down
+// after a dial failure
+type upstream struct {
+	url       *url.URL
+	healthy   atomic.Bool
+	downUntil atomic.Int64
+}
+
+func (u *upstream) available() bool {
+	return u.healthy.Load() && time.Now().UnixNano() >= u.downUntil.Load()
+}
+
+// NewProxyService creates a proxy service named name
+func NewProxyService(name string, opts ...Option) (*ProxyService, error) {
+	s := &ProxyService{
+		retries:   DefaultRetries,
+		interval:  DefaultHealthCheckInterval,
+		transport: http.DefaultTransport,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	for _, r := range s.routes {
+		p, err := s.newPool(r)
+		if err != nil {
+			return nil, err
+		}
+		s.pools = append(s.pools, p)
+	}
+	// Longest prefix first
+	sort.SliceStable(s.pools, func(i, j int) bool {
+		return len(s.pools[i].Prefix) > len(s.pools[j].Prefix)
+	})
+
+	s.HTTPService = gocorehttp.NewHTTPService(name, http.HandlerFunc(s.serveHTTP), s.httpOpts...)
+	for _, p := range s.pools {
+		s.AddHealthCheck("route:"+p.Prefix, service.HealthCheckerFunc(p.check))
+	}
+	s.OnStart(s.startHealthChecks)
+	s.OnStop(s.stopHealthChecks)
+	return s, nil
+}
+
+func (s *ProxyService) newPool(r Route) (*pool, error) {
+	if len(r.Upstreams) == 0 {
+		return nil, fmt.Errorf("route %s has no upstream", r.Prefix)
+	}
+	r.Prefix = "/" + strings.Trim(r.Prefix, "/")
+
+	p := &pool{Route: r}
+	for _, raw := range r.Upstreams {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse upstream %q: %w", raw, err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("upstream %q must be an absolute URL", raw)
+		}
+		up := &upstream{url: u}
+		up.healthy.Store(true)
+		p.upstreams = append(p.upstreams, up)
+	}
+
+	p.proxy = &httputil.ReverseProxy{
+		Rewrite:        p.rewrite,
+		Transport:      &retryTransport{pool: p, base: s.transport, retries: s.retries, down: s.interval},
+		ModifyResponse: p.modifyResponse,
+		ErrorHandler:   s.errorHandler,
+	}
+	return p, nil
+}
+
+func (s *ProxyService) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	for _, p := range s.pools {
+		if p.match(r.URL.Path) {
+			p.proxy.ServeHTTP(w, r)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+func (s *ProxyService) errorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, context.Canceled) {
+		// The client went away
+		return
+	}
+	status := http.StatusBadGateway
+	if errors.Is(err, ErrNoUpstream) {
+		status = http.StatusServiceUnavailable
+	}
+	s.log().Warn("proxy request failed",
+		zap.String("path", r.URL.Path), zap.Int("status", status), zap.Error(err))
+	http.Error(w, http.StatusText(status), status)
+}
+
+// log returns the logger of the service, tagged with its name, instance and
+// version by ForService
+func (s *ProxyService) log() *logger.Logger {
+	s.logOnce.Do(func() {
+		if s.logger != nil {
+			s.serviceLog = s.logger.ForService(s.Name())
+		} else {
+			s.serviceLog = logger.ForService(s.Name())
+		}
+	})
+	return s.serviceLog
+}
+
+func (p *pool) match(path string) bool {
+	return p.Prefix == "/" || path == p.Prefix || strings.HasPrefix(path, p.Prefix+"/")
+}
+
+// rewrite prepares the request sent upstream, the transport picks the upstream
+func (p *pool) rewrite(pr *httputil.ProxyRequest) {
+	pr.SetXForwarded()
+	if p.StripPrefix && p.Prefix != "/" {
+		pr.Out.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(pr.Out.URL.Path, p.Prefix), "/")
+		pr.Out.URL.RawPath = ""
+	}
+	if p.PreserveHost {
+		pr.Out.Host = pr.In.Host
+	} else {
+		pr.Out.Host = ""
+	}
+	for _, name := range p.RemoveRequestHeaders {
+		pr.Out.Header.Del(name)
+	}
+	for name, value := range p.SetRequestHeaders {
+		pr.Out.Header.Set(name, value)
+	}
+}
+
+func (p *pool) modifyResponse(resp *http.Response) error {
+	for _, name := range p.RemoveResponseHeaders {
+		resp.Header.Del(name)
+	}
+	for name, value := range p.SetResponseHeaders {
+		resp.Header.Set(name, value)
+	}
+	return nil
+}
+
+// pick returns the next available upstream round robin, skipping tried ones
+func (p *pool) pick(tried map[*upstream]bool) *upstream {
+	n := uint64(len(p.upstreams))
+	start := p.next.Add(1) - 1
+	for i := uint64(0); i < n; i++ {
+		u := p.upstreams[(start+i)%n]
+		if !tried[u] && u.available() {
+			return u
+		}
+	}
+	return nil
+}
+
+// check reports whether the route has an available upstream
+func (p *pool) check(ctx context.Context) error {
+	for _, u := range p.upstreams {
+		if u.available() {
+			return nil
+		}
+	}
+	return ErrNoUpstream
+}
+
+// retryTransport sends a request to an upstream of its pool, and to the
+// next ones when dialing fails
+type retryTransport struct {
+	pool    *pool
+	base    http.RoundTripper
+	retries int
+	down    time.Duration
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tried := make(map[*upstream]bool)
+	var lastErr error
+	for attempt := 0; attempt <= t.retries; attempt++ {
+		u := t.pool.pick(tried)
+		if u == nil {
+			break
+		}
+		tried[u] = true
+
+		out := req.Clone(req.Context())
+		out.URL.Scheme = u.url.Scheme
+		out.URL.Host = u.url.Host
+		out.URL.Path = singleJoiningSlash(u.url.Path, req.URL.Path)
+		out.URL.RawPath = ""
+
+		resp, err := t.base.RoundTrip(out)
+		if err == nil || !isDialError(err) {
+			return resp, err
+		}
+		// Nothing was sent, take the upstream out and try another one
+		u.downUntil.Store(time.Now().Add(t.down).UnixNano())
+		lastErr = err
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, ErrNoUpstream
+}
+
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func singleJoiningSlash(a, b string) string {
+	switch aslash, bslash := strings.HasSuffix(a, "/"), strings.HasPrefix(b, "/"); {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
+
+// startHealthChecks checks the upstreams of the routes with a HealthPath
+// until the service stops
+func (s *ProxyService) startHealthChecks(ctx context.Context) error {
+	ctx, s.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	client := &http.Client{Transport: s.transport, Timeout: DefaultHealthCheckTimeout}
+	for _, p := range s.pools {
+		if p.HealthPath == "" {
+			continue
+		}
+		for _, u := range p.upstreams {
+			s.wg.Add(1)
+			go s.checkUpstream(ctx, client, p, u)
+		}
+	}
+	return nil
+}
+
+func (s *ProxyService) stopHealthChecks(ctx context.Context) error {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+	return nil
+}
+
+func (s *ProxyService) checkUpstream(ctx context.Context, client *http.Client, p *pool, u *upstream) {
+	defer s.wg.Done()
+
+	target := singleJoiningSlash(u.url.String(), p.HealthPath)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		err := probe(ctx, client, target)
+		if ctx.Err() != nil {
+			return
+		}
+		healthy := err == nil
+		if u.healthy.Swap(healthy) != healthy {
+			if healthy {
+				s.log().Info("upstream recovered", zap.String("upstream", u.url.String()))
+			} else {
+				s.log().Warn("upstream unhealthy", zap.String("upstream", u.url.String()), zap.Error(err))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probe sends a health check request, failing on 5xx statuses
+func probe(ctx context.Context, client *http.Client, target string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("health check returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gocorehttp "github.com/ducconit/gocore/service/http"
+	"github.com/stretchr/testify/assert"
+)
+
+// closedURL returns the URL of a port nothing listens on
+func closedURL(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+	return "http://" + addr
+}
+
+func get(t *testing.T, svc *ProxyService, path string) (*http.Response, string) {
+	resp, err := http.Get("http://" + svc.ListenAddr().String() + path)
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	return resp, string(body)
+}
+
+func TestProxyService(t *testing.T) {
+	ctx := context.Background()
+
+	users := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "users/1.0")
+		w.Header().Set("X-Upstream", "users")
+		_, _ = io.WriteString(w, r.Host+" "+r.URL.Path+" "+r.Header.Get("X-Gateway")+" "+r.Header.Get("Cookie")+" "+r.Header.Get("X-Forwarded-Host"))
+	}))
+	defer users.Close()
+	orders := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "orders "+r.URL.Path)
+	}))
+	defer orders.Close()
+
+	svc, err := NewProxyService("gateway",
+		WithRoute(Route{
+			Prefix:                "/users",
+			Upstreams:             []string{users.URL + "/v1"},
+			StripPrefix:           true,
+			SetRequestHeaders:     map[string]string{"X-Gateway": "gocore"},
+			RemoveRequestHeaders:  []string{"Cookie"},
+			RemoveResponseHeaders: []string{"Server"},
+		}),
+		WithRoute(Route{Prefix: "/", Upstreams: []string{orders.URL}}),
+		WithHTTPOptions(gocorehttp.WithAddr("127.0.0.1:0")),
+	)
+	assert.NoError(t, err)
+	assert.NoError(t, svc.Start(ctx))
+	defer svc.Stop(ctx)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://"+svc.ListenAddr().String()+"/users/42", nil)
+	req.Header.Set("Cookie", "session=1")
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, users.Listener.Addr().String()+" /v1/42 gocore  "+svc.ListenAddr().String(), string(body))
+	assert.Equal(t, "users", resp.Header.Get("X-Upstream"))
+	assert.Empty(t, resp.Header.Get("Server"))
+
+	_, body2 := get(t, svc, "/orders/7")
+	assert.Equal(t, "orders /orders/7", body2)
+}
+
+func TestProxyService_RetryOnDialFailure(t *testing.T) {
+	ctx := context.Background()
+
+	var hits atomic.Int64
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}))
+	defer up.Close()
+
+	svc, err := NewProxyService("gateway",
+		WithRoute(Route{Prefix: "/", Upstreams: []string{closedURL(t), up.URL}}),
+		WithHTTPOptions(gocorehttp.WithAddr("127.0.0.1:0")),
+	)
+	assert.NoError(t, err)
+	assert.NoError(t, svc.Start(ctx))
+	defer svc.Stop(ctx)
+
+	for i := 0; i < 4; i++ {
+		resp, body := get(t, svc, "/")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Empty(t, body)
+	}
+	assert.Equal(t, int64(4), hits.Load())
+}
+
+func TestProxyService_NoUpstream(t *testing.T) {
+	ctx := context.Background()
+
+	svc, err := NewProxyService("gateway",
+		WithRoute(Route{Prefix: "/api", Upstreams: []string{closedURL(t)}}),
+		WithRetries(0),
+		WithHTTPOptions(gocorehttp.WithAddr("127.0.0.1:0"), gocorehttp.WithHealthEndpoint("/healthz")),
+	)
+	assert.NoError(t, err)
+	assert.NoError(t, svc.Start(ctx))
+	defer svc.Stop(ctx)
+
+	resp, _ := get(t, svc, "/api/users")
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+
+	// The upstream that failed to dial is taken out
+	resp, _ = get(t, svc, "/api/users")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	resp, body := get(t, svc, "/healthz")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Contains(t, body, `"route:/api"`)
+
+	resp, _ = get(t, svc, "/other")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestProxyService_HealthChecks(t *testing.T) {
+	ctx := context.Background()
+
+	var healthy atomic.Bool
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" && !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer up.Close()
+
+	svc, err := NewProxyService("gateway",
+		WithRoute(Route{Prefix: "/", Upstreams: []string{up.URL}, HealthPath: "/healthz"}),
+		WithHealthCheckInterval(5*time.Millisecond),
+		WithHTTPOptions(gocorehttp.WithAddr("127.0.0.1:0")),
+	)
+	assert.NoError(t, err)
+	assert.NoError(t, svc.Start(ctx))
+	defer svc.Stop(ctx)
+
+	assert.Eventually(t, func() bool { return !svc.CheckHealth(ctx).Up() }, time.Second, time.Millisecond)
+	resp, _ := get(t, svc, "/")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	healthy.Store(true)
+	assert.Eventually(t, func() bool { return svc.CheckHealth(ctx).Up() }, time.Second, time.Millisecond)
+	resp, body := get(t, svc, "/")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "ok", body)
+}
+
+func TestNewProxyService_InvalidRoute(t *testing.T) {
+	_, err := NewProxyService("gateway", WithRoute(Route{Prefix: "/api"}))
+	assert.ErrorContains(t, err, "route /api has no upstream")
+
+	_, err = NewProxyService("gateway", WithRoute(Route{Prefix: "/api", Upstreams: []string{"users:8080"}}))
+	assert.ErrorContains(t, err, "must be an absolute URL")
+}
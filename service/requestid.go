@@ -0,0 +1,18 @@
+package service
+
+import (
+	"context"
+
+	"github.com/ducconit/gocore/logger"
+)
+
+// ContextWithRequestID returns ctx carrying a request ID, which loggers
+// attach to entries logged with Ctx or FromContext
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return logger.ContextWithRequestID(ctx, id)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, if any
+func RequestIDFromContext(ctx context.Context) string {
+	return logger.RequestIDFromContext(ctx)
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"fmt"
+)
+
+// Service represents a long-running component with a managed lifecycle
+type Service interface {
+	// Name returns the unique name of the service
+	Name() string
+
+	// Start starts the service
+	Start(ctx context.Context) error
+
+	// Stop stops the service
+	Stop(ctx context.Context) error
+
+	// Health reports whether the service is healthy
+	Health() bool
+}
+
+// Readier is implemented by services that can be alive but not ready to
+// serve, e.g. while warming caches or draining
+type Readier interface {
+	// Ready reports whether the service can take traffic
+	Ready() bool
+}
+
+// IsReady reports whether svc is ready, falling back to its health for
+// services that do not implement Readier
+func IsReady(svc Service) bool {
+	if r, ok := svc.(Readier); ok {
+		return svc.Health() && r.Ready()
+	}
+	return svc.Health()
+}
+
+// Run starts svc, blocks until ctx is done, then stops svc within
+// DefaultShutdownTimeout. Start is non-blocking for every service; Run is
+// the blocking form, e.g. for a process running a single service.
+func Run(ctx context.Context, svc Service) error {
+	if err := svc.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start service %s: %w", svc.Name(), err)
+	}
+	<-ctx.Done()
+
+	stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), DefaultShutdownTimeout)
+	defer cancel()
+	if err := svc.Stop(stopCtx); err != nil {
+		return fmt.Errorf("failed to stop service %s: %w", svc.Name(), err)
+	}
+	return nil
+}
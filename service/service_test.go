@@ -0,0 +1,30 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	log := &eventLog{}
+	svc := newFakeService("worker", log)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(ctx, svc)
+	}()
+
+	assert.Eventually(t, svc.Health, time.Second, 5*time.Millisecond)
+	cancel()
+	assert.NoError(t, <-done)
+	assert.Equal(t, []string{"start:worker", "stop:worker"}, log.all())
+
+	broken := newFakeService("broken", log)
+	broken.startErr = errors.New("bind failed")
+	assert.ErrorIs(t, Run(context.Background(), broken), broken.startErr)
+}
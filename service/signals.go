@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/ducconit/gocore/logger"
+	"go.uber.org/zap"
+)
+
+// DefaultSignals are the signals Run stops the services on when none are given
+var DefaultSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// NotifyShutdown returns a copy of ctx cancelled at the first of signals,
+// DefaultSignals when none are given. force is called at the next signal,
+// e.g. a second Ctrl-C while stopping, unless nil. The returned function stops
+// listening for the signals.
+func NotifyShutdown(ctx context.Context, force func(os.Signal), signals ...os.Signal) (context.Context, context.CancelFunc) {
+	if len(signals) == 0 {
+		signals = DefaultSignals
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	var once sync.Once
+
+	go func() {
+		select {
+		case <-ch:
+			cancel()
+		case <-done:
+			return
+		}
+		select {
+		case sig := <-ch:
+			if force != nil {
+				force(sig)
+			}
+		case <-done:
+		}
+	}()
+
+	return ctx, func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			cancel()
+			close(done)
+		})
+	}
+}
+
+// WithLogger sets the logger of forced exits, the global logger by default
+func WithLogger(l *logger.Logger) Option {
+	return func(m *Manager) {
+		m.logger = l
+	}
+}
+
+// WithForceExit sets what a signal received while Run stops the services
+// does once the services still stopping are logged, os.Exit(1) by default
+func WithForceExit(fn func()) Option {
+	return func(m *Manager) {
+		m.forceExit = fn
+	}
+}
+
+// Stopping returns the names of the services being stopped, in stop order
+func (m *Manager) Stopping() []string {
+	m.stateMu.Lock()
+	defer m.stateMu.Unlock()
+
+	names := make([]string, len(m.stopping))
+	for i, svc := range m.stopping {
+		names[i] = svc.Name()
+	}
+	return names
+}
+
+// force logs the services still stopping and the requests they are
+// handling, then exits without waiting for them
+func (m *Manager) force(sig os.Signal) {
+	m.stateMu.Lock()
+	var inFlight int64
+	for _, svc := range m.stopping {
+		if d, ok := svc.(interface{ InFlight() int64 }); ok {
+			inFlight += d.InFlight()
+		}
+	}
+	m.stateMu.Unlock()
+
+	m.log().Warn("forced exit, services did not finish stopping",
+		zap.Stringer("signal", sig), zap.Strings("stopping", m.Stopping()), zap.Int64("in_flight", inFlight))
+
+	if m.forceExit != nil {
+		m.forceExit()
+		return
+	}
+	os.Exit(1)
+}
+
+func (m *Manager) log() *logger.Logger {
+	if m.logger != nil {
+		return m.logger
+	}
+	return logger.With()
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+// stuckService does not finish stopping until released
+type stuckService struct {
+	*fakeService
+	release chan struct{}
+}
+
+func (s *stuckService) Stop(ctx context.Context) error {
+	<-s.release
+	return s.fakeService.Stop(ctx)
+}
+
+func (s *stuckService) InFlight() int64 {
+	return 3
+}
+
+func TestManager_ForceExit(t *testing.T) {
+	var buf syncBuffer
+	forced := make(chan struct{})
+	events := &eventLog{}
+	m := NewManager(
+		WithSignals(syscall.SIGUSR2),
+		WithLogger(logger.New(logger.WithOutput(&buf))),
+		WithForceExit(func() { close(forced) }),
+	)
+
+	stuck := &stuckService{fakeService: newFakeService("api", events), release: make(chan struct{})}
+	assert.NoError(t, m.Register(newFakeService("db", events)))
+	assert.NoError(t, m.Register(stuck, WithDependsOn("db")))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run(context.Background())
+	}()
+	assert.Eventually(t, m.Healthy, time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR2))
+	assert.Eventually(t, func() bool { return len(m.Stopping()) == 2 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"api", "db"}, m.Stopping())
+
+	// A second signal forces the exit, logging what was left
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR2))
+	select {
+	case <-forced:
+	case <-time.After(time.Second):
+		t.Fatal("second signal did not force the exit")
+	}
+	assert.Contains(t, buf.String(), "forced exit")
+	assert.Contains(t, buf.String(), `"stopping":["api","db"]`)
+	assert.Contains(t, buf.String(), `"in_flight":3`)
+
+	close(stuck.release)
+	assert.NoError(t, <-done)
+	assert.Empty(t, m.Stopping())
+}
+
+func TestNotifyShutdown(t *testing.T) {
+	forced := make(chan os.Signal, 1)
+	ctx, stop := NotifyShutdown(context.Background(), func(sig os.Signal) { forced <- sig }, syscall.SIGUSR2)
+
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR2))
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context not cancelled on signal")
+	}
+	assert.Empty(t, forced)
+
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR2))
+	assert.Equal(t, syscall.SIGUSR2, <-forced)
+	stop()
+	stop()
+}
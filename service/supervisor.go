@@ -0,0 +1,346 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// MetricsNamespace prefixes the names of the metrics exported by Supervisor
+const MetricsNamespace = "service"
+
+var (
+	// ErrUnhealthy is the failure of a supervised service that stopped reporting healthy
+	ErrUnhealthy = errors.New("service became unhealthy")
+
+	// DefaultRestartBackoff is the delay before the first restart of a supervised service
+	DefaultRestartBackoff = time.Second
+
+	// DefaultMaxRestartBackoff caps the delay between restarts, doubled after each failure
+	DefaultMaxRestartBackoff = time.Minute
+)
+
+// RestartPolicy selects when a Supervisor restarts its service
+type RestartPolicy int
+
+const (
+	// RestartOnFailure restarts the service when it becomes unhealthy, exits
+	// with an error or fails to restart
+	RestartOnFailure RestartPolicy = iota
+
+	// RestartAlways also restarts the service when it exits cleanly, see Exiter
+	RestartAlways
+
+	// RestartNever leaves the service stopped, the supervisor only reports it
+	RestartNever
+)
+
+// Exiter is implemented by services that can exit on their own, e.g. a job
+// runner. The channel returned after Start receives the exit error, nil for
+// a clean exit.
+type Exiter interface {
+	Exited() <-chan error
+}
+
+// SupervisorOption represents a supervisor option
+type SupervisorOption func(*Supervisor)
+
+// WithRestartPolicy sets when the service is restarted, RestartOnFailure by default
+func WithRestartPolicy(p RestartPolicy) SupervisorOption {
+	return func(s *Supervisor) {
+		s.policy = p
+	}
+}
+
+// WithMaxRestarts gives up after n restarts in a row, zero restarts forever.
+// The count is reset once the service stays up for the max backoff.
+func WithMaxRestarts(n int) SupervisorOption {
+	return func(s *Supervisor) {
+		s.maxRestarts = n
+	}
+}
+
+// WithRestartBackoff sets the delay before the first restart, doubled after
+// each failure in a row up to max
+func WithRestartBackoff(initial, max time.Duration) SupervisorOption {
+	return func(s *Supervisor) {
+		s.backoff = initial
+		s.maxBackoff = max
+	}
+}
+
+// WithCheckInterval sets the interval between health checks of the service
+func WithCheckInterval(d time.Duration) SupervisorOption {
+	return func(s *Supervisor) {
+		s.checkInterval = d
+	}
+}
+
+// WithSupervisorLogger sets the logger of restarts, the global logger by default
+func WithSupervisorLogger(l *logger.Logger) SupervisorOption {
+	return func(s *Supervisor) {
+		s.logger = l
+	}
+}
+
+// Supervisor restarts a service that crashes, according to a restart
+// policy with exponential backoff. It is a Service wrapping the supervised
+// one, and a prometheus.Collector of its restarts.
+type Supervisor struct {
+	svc           Service
+	policy        RestartPolicy
+	maxRestarts   int
+	backoff       time.Duration
+	maxBackoff    time.Duration
+	checkInterval time.Duration
+	logger        *logger.Logger
+
+	restarts *prometheus.Desc
+	up       *prometheus.Desc
+
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	done    chan struct{}
+	count   atomic.Int64
+	failure atomic.Pointer[error]
+	givenUp atomic.Bool
+}
+
+var (
+	_ Service              = (*Supervisor)(nil)
+	_ Readier              = (*Supervisor)(nil)
+	_ HealthChecker        = (*Supervisor)(nil)
+	_ prometheus.Collector = (*Supervisor)(nil)
+)
+
+// NewSupervisor supervises svc
+func NewSupervisor(svc Service, opts ...SupervisorOption) *Supervisor {
+	s := &Supervisor{
+		svc:           svc,
+		backoff:       DefaultRestartBackoff,
+		maxBackoff:    DefaultMaxRestartBackoff,
+		checkInterval: DefaultHealthInterval,
+		restarts: prometheus.NewDesc(prometheus.BuildFQName(MetricsNamespace, "", "restarts_total"),
+			"Number of restarts of a supervised service.", []string{"service"}, nil),
+		up: prometheus.NewDesc(prometheus.BuildFQName(MetricsNamespace, "", "up"),
+			"Whether a supervised service is healthy.", []string{"service"}, nil),
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Name returns the name of the supervised service
+func (s *Supervisor) Name() string {
+	return s.svc.Name()
+}
+
+// Service returns the supervised service
+func (s *Supervisor) Service() Service {
+	return s.svc
+}
+
+// Restarts returns the number of restarts of the service
+func (s *Supervisor) Restarts() int64 {
+	return s.count.Load()
+}
+
+// Start starts the service and supervises it in the background. A service
+// failing its first start is not restarted.
+func (s *Supervisor) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done != nil {
+		return nil
+	}
+	if err := s.svc.Start(ctx); err != nil {
+		return err
+	}
+
+	s.givenUp.Store(false)
+	s.failure.Store(nil)
+
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go s.supervise(ctx, s.done)
+	return nil
+}
+
+// Stop stops supervising the service, then stops it
+func (s *Supervisor) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done == nil {
+		return nil
+	}
+	s.cancel()
+	<-s.done
+	s.done = nil
+	return s.svc.Stop(ctx)
+}
+
+// Health reports whether the service is healthy. It is not while the
+// service is restarting or once the supervisor has given up.
+func (s *Supervisor) Health() bool {
+	return !s.givenUp.Load() && s.svc.Health()
+}
+
+// Ready reports whether the service is ready, see IsReady
+func (s *Supervisor) Ready() bool {
+	return !s.givenUp.Load() && IsReady(s.svc)
+}
+
+// Check reports the last failure and the restart count when the service is
+// not healthy, so health reports show why
+func (s *Supervisor) Check(ctx context.Context) error {
+	if s.Health() {
+		return nil
+	}
+	cause := ErrUnhealthy
+	if err := s.failure.Load(); err != nil {
+		cause = *err
+	}
+	if s.givenUp.Load() {
+		return fmt.Errorf("gave up after %d restarts: %w", s.Restarts(), cause)
+	}
+	return fmt.Errorf("restarted %d times: %w", s.Restarts(), cause)
+}
+
+// Describe implements prometheus.Collector
+func (s *Supervisor) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.restarts
+	ch <- s.up
+}
+
+// Collect implements prometheus.Collector
+func (s *Supervisor) Collect(ch chan<- prometheus.Metric) {
+	up := 0.0
+	if s.Health() {
+		up = 1
+	}
+	ch <- prometheus.MustNewConstMetric(s.restarts, prometheus.CounterValue, float64(s.Restarts()), s.Name())
+	ch <- prometheus.MustNewConstMetric(s.up, prometheus.GaugeValue, up, s.Name())
+}
+
+// supervise waits for the service to fail and restarts it until ctx is done
+func (s *Supervisor) supervise(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	attempts := 0
+	for {
+		started := time.Now()
+		err := s.wait(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) >= s.maxBackoff {
+			attempts = 0
+		}
+
+		for {
+			if err != nil {
+				failure := err
+				s.failure.Store(&failure)
+			}
+			if !s.restartable(err) {
+				s.log("supervised service stopped", zap.Int64("restarts", s.Restarts()), zap.Error(err))
+				return
+			}
+			attempts++
+			if s.maxRestarts > 0 && attempts > s.maxRestarts {
+				s.givenUp.Store(true)
+				s.log("supervised service keeps failing, giving up", zap.Int64("restarts", s.Restarts()), zap.Error(err))
+				return
+			}
+
+			delay := backoffDelay(s.backoff, s.maxBackoff, attempts)
+			s.log("restarting supervised service", zap.Duration("backoff", delay), zap.Int("attempt", attempts), zap.Error(err))
+			stopCtx, cancel := context.WithTimeout(ctx, DefaultShutdownTimeout)
+			_ = s.svc.Stop(stopCtx)
+			cancel()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+
+			s.count.Add(1)
+			if err = s.svc.Start(ctx); err == nil {
+				break
+			}
+			err = fmt.Errorf("failed to restart: %w", err)
+		}
+	}
+}
+
+// wait blocks until the service exits or becomes unhealthy, or ctx is done.
+// It returns nil for a clean exit.
+func (s *Supervisor) wait(ctx context.Context) error {
+	var exited <-chan error
+	if e, ok := s.svc.(Exiter); ok {
+		exited = e.Exited()
+	}
+
+	ticker := time.NewTicker(s.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-exited:
+			if err != nil {
+				return fmt.Errorf("service exited: %w", err)
+			}
+			return nil
+		case <-ticker.C:
+			if !s.svc.Health() {
+				return ErrUnhealthy
+			}
+		}
+	}
+}
+
+func (s *Supervisor) restartable(err error) bool {
+	switch s.policy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return err != nil
+	default:
+		return false
+	}
+}
+
+// backoffDelay returns the delay before a restart attempt, doubling from
+// initial up to max
+func backoffDelay(initial, max time.Duration, attempt int) time.Duration {
+	d := initial
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	return min(d, max)
+}
+
+func (s *Supervisor) log(msg string, fields ...zap.Field) {
+	if s.logger != nil {
+		s.logger.ForService(s.Name()).Warn(msg, fields...)
+	} else {
+		logger.ForService(s.Name()).Warn(msg, fields...)
+	}
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// crashingService can be crashed, made to fail its starts, or exit
+type crashingService struct {
+	name     string
+	starts   atomic.Int64
+	healthy  atomic.Bool
+	startErr atomic.Pointer[error]
+	exited   chan error
+}
+
+func (s *crashingService) Name() string {
+	return s.name
+}
+
+func (s *crashingService) Start(ctx context.Context) error {
+	if err := s.startErr.Load(); err != nil {
+		return *err
+	}
+	s.starts.Add(1)
+	s.exited = make(chan error, 1)
+	s.healthy.Store(true)
+	return nil
+}
+
+func (s *crashingService) Stop(ctx context.Context) error {
+	s.healthy.Store(false)
+	return nil
+}
+
+func (s *crashingService) Health() bool {
+	return s.healthy.Load()
+}
+
+func (s *crashingService) failStarts(err error) {
+	s.startErr.Store(&err)
+}
+
+// exitingService reports its exits, see Exiter
+type exitingService struct {
+	*crashingService
+}
+
+func (s exitingService) Exited() <-chan error {
+	return s.exited
+}
+
+func (s exitingService) exit(err error) {
+	s.healthy.Store(false)
+	s.exited <- err
+}
+
+func newSupervisor(svc Service, opts ...SupervisorOption) *Supervisor {
+	opts = append([]SupervisorOption{
+		WithRestartBackoff(time.Millisecond, 10*time.Millisecond),
+		WithCheckInterval(time.Millisecond),
+	}, opts...)
+	return NewSupervisor(svc, opts...)
+}
+
+func TestSupervisor_RestartOnFailure(t *testing.T) {
+	ctx := context.Background()
+	svc := &crashingService{name: "worker"}
+	s := newSupervisor(svc)
+
+	assert.NoError(t, s.Start(ctx))
+	assert.True(t, s.Health())
+	assert.NoError(t, s.Check(ctx))
+
+	svc.healthy.Store(false)
+	assert.Eventually(t, func() bool { return svc.starts.Load() == 2 }, time.Second, time.Millisecond)
+	assert.Eventually(t, s.Health, time.Second, time.Millisecond)
+	assert.Equal(t, int64(1), s.Restarts())
+
+	assert.NoError(t, s.Stop(ctx))
+	assert.False(t, s.Health())
+}
+
+func TestSupervisor_MaxRestarts(t *testing.T) {
+	ctx := context.Background()
+	svc := &crashingService{name: "worker"}
+	s := newSupervisor(svc, WithMaxRestarts(2))
+	assert.NoError(t, s.Start(ctx))
+
+	svc.failStarts(errors.New("bind failed"))
+	svc.healthy.Store(false)
+
+	assert.Eventually(t, func() bool { return s.Check(ctx) != nil && s.givenUp.Load() }, time.Second, time.Millisecond)
+	assert.EqualError(t, s.Check(ctx), "gave up after 2 restarts: failed to restart: bind failed")
+	assert.False(t, s.Health())
+	assert.False(t, s.Ready())
+	assert.NoError(t, s.Stop(ctx))
+}
+
+func TestSupervisor_Policies(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("always restarts clean exits", func(t *testing.T) {
+		svc := exitingService{&crashingService{name: "job"}}
+		s := newSupervisor(svc, WithRestartPolicy(RestartAlways))
+		assert.NoError(t, s.Start(ctx))
+
+		svc.exit(nil)
+		assert.Eventually(t, func() bool { return svc.starts.Load() == 2 }, time.Second, time.Millisecond)
+		assert.NoError(t, s.Stop(ctx))
+	})
+
+	t.Run("on failure skips clean exits", func(t *testing.T) {
+		svc := exitingService{&crashingService{name: "job"}}
+		s := newSupervisor(svc)
+		assert.NoError(t, s.Start(ctx))
+
+		svc.exit(nil)
+		time.Sleep(20 * time.Millisecond)
+		assert.Equal(t, int64(1), svc.starts.Load())
+		assert.Zero(t, s.Restarts())
+		assert.NoError(t, s.Stop(ctx))
+	})
+
+	t.Run("never restarts", func(t *testing.T) {
+		svc := &crashingService{name: "worker"}
+		s := newSupervisor(svc, WithRestartPolicy(RestartNever))
+		assert.NoError(t, s.Start(ctx))
+
+		svc.healthy.Store(false)
+		time.Sleep(20 * time.Millisecond)
+		assert.Equal(t, int64(1), svc.starts.Load())
+		assert.ErrorIs(t, s.Check(ctx), ErrUnhealthy)
+		assert.NoError(t, s.Stop(ctx))
+	})
+}
+
+func TestSupervisor_FirstStartFails(t *testing.T) {
+	svc := &crashingService{name: "worker"}
+	svc.failStarts(errors.New("bind failed"))
+	s := newSupervisor(svc)
+
+	assert.EqualError(t, s.Start(context.Background()), "bind failed")
+	assert.Zero(t, s.Restarts())
+}
+
+func TestSupervisor_Metrics(t *testing.T) {
+	ctx := context.Background()
+	svc := &crashingService{name: "worker"}
+	s := newSupervisor(svc)
+	assert.NoError(t, s.Start(ctx))
+	defer s.Stop(ctx)
+
+	svc.healthy.Store(false)
+	assert.Eventually(t, func() bool { return s.Restarts() == 1 && s.Health() }, time.Second, time.Millisecond)
+
+	expected := `
+# HELP service_restarts_total Number of restarts of a supervised service.
+# TYPE service_restarts_total counter
+service_restarts_total{service="worker"} 1
+# HELP service_up Whether a supervised service is healthy.
+# TYPE service_up gauge
+service_up{service="worker"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(s, strings.NewReader(expected)))
+}
+
+func TestBackoffDelay(t *testing.T) {
+	assert.Equal(t, time.Second, backoffDelay(time.Second, 5*time.Second, 1))
+	assert.Equal(t, 2*time.Second, backoffDelay(time.Second, 5*time.Second, 2))
+	assert.Equal(t, 4*time.Second, backoffDelay(time.Second, 5*time.Second, 3))
+	assert.Equal(t, 5*time.Second, backoffDelay(time.Second, 5*time.Second, 4))
+	assert.Equal(t, 5*time.Second, backoffDelay(time.Second, 5*time.Second, 40))
+}
@@ -0,0 +1,386 @@
+// Package tcp runs a raw TCP server as a service.Service, for protocol
+// servers that are not HTTP
+//
+//	svc := tcp.NewTCPService("echo", func(conn net.Conn) {
+//		io.Copy(conn, conn)
+//	}, tcp.WithAddress(":9000"), tcp.WithMaxConns(1000))
+//	manager.Register(svc)
+package tcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/ducconit/gocore/service"
+	"go.uber.org/zap"
+)
+
+// DefaultAddress is the address served when none is given
+const DefaultAddress = ":9000"
+
+// errAlreadyStarted is returned by Start while the service is running
+var errAlreadyStarted = errors.New("tcp service already started")
+
+// Handler serves a connection, which is closed once it returns
+type Handler func(conn net.Conn)
+
+// Option represents a TCP service option
+type Option func(*TCPService)
+
+// WithAddress sets the TCP address to listen on
+func WithAddress(addr string) Option {
+	return func(s *TCPService) {
+		s.addr = addr
+	}
+}
+
+// WithListener serves on l instead of listening on the address
+func WithListener(l net.Listener) Option {
+	return func(s *TCPService) {
+		s.listener = l
+	}
+}
+
+// WithMaxConns limits the number of connections served at once, further
+// connections wait to be accepted. Zero is unlimited
+func WithMaxConns(n int) Option {
+	return func(s *TCPService) {
+		s.maxConns = n
+	}
+}
+
+// WithReadTimeout sets how long each read may wait for data
+func WithReadTimeout(d time.Duration) Option {
+	return func(s *TCPService) {
+		s.readTimeout = d
+	}
+}
+
+// WithWriteTimeout sets how long each write may take
+func WithWriteTimeout(d time.Duration) Option {
+	return func(s *TCPService) {
+		s.writeTimeout = d
+	}
+}
+
+// WithConnTimeout sets the maximum lifetime of a connection
+func WithConnTimeout(d time.Duration) Option {
+	return func(s *TCPService) {
+		s.connTimeout = d
+	}
+}
+
+// WithLogger sets the logger of accept errors and handler panics, the
+// global logger by default
+func WithLogger(l *logger.Logger) Option {
+	return func(s *TCPService) {
+		s.logger = l
+	}
+}
+
+var _ service.Service = (*TCPService)(nil)
+
+// TCPService is a service.Service accepting TCP connections and serving
+// each in its own goroutine
+type TCPService struct {
+	name         string
+	handler      Handler
+	addr         string
+	listener     net.Listener
+	maxConns     int
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	connTimeout  time.Duration
+	logger       *logger.Logger
+	logOnce      sync.Once
+	serviceLog   *logger.Logger
+	hooks        service.Hooks
+
+	mu      sync.Mutex
+	conns   map[net.Conn]struct{}
+	done    chan struct{}
+	stop    chan struct{}
+	wg      sync.WaitGroup
+	running atomic.Bool
+}
+
+// NewTCPService creates a TCP service named name serving connections with handler
+func NewTCPService(name string, handler Handler, opts ...Option) *TCPService {
+	s := &TCPService{
+		name:    name,
+		handler: handler,
+		addr:    DefaultAddress,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Name returns the name of the service
+func (s *TCPService) Name() string {
+	return s.name
+}
+
+// ListenAddr returns the address the service is bound to, e.g. the port
+// picked for ":0", nil while it is stopped
+func (s *TCPService) ListenAddr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Conns returns the number of connections being served
+func (s *TCPService) Conns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// OnStart adds a hook run before the service listens, see service.Hooks
+func (s *TCPService) OnStart(fn service.Hook) {
+	s.hooks.OnStart(fn)
+}
+
+// OnStop adds a hook run after the connections are drained, see service.Hooks
+func (s *TCPService) OnStop(fn service.Hook) {
+	s.hooks.OnStop(fn)
+}
+
+// Start runs the start hooks, then listens and accepts connections in the
+// background. A stopped service can be started again.
+func (s *TCPService) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done != nil {
+		return errAlreadyStarted
+	}
+
+	if err := s.hooks.Start(ctx); err != nil {
+		return fmt.Errorf("failed to run start hooks: %w", err)
+	}
+
+	if s.listener == nil {
+		var lc net.ListenConfig
+		l, err := lc.Listen(ctx, "tcp", s.addr)
+		if err != nil {
+			return errors.Join(fmt.Errorf("failed to listen on %s: %w", s.addr, err), s.hooks.Stop(ctx))
+		}
+		s.listener = l
+	}
+
+	s.conns = make(map[net.Conn]struct{})
+	s.done = make(chan struct{})
+	s.stop = make(chan struct{})
+	s.running.Store(true)
+	go s.accept(s.listener, s.done, s.stop)
+	return nil
+}
+
+// Stop stops accepting connections and waits for the open ones to be
+// served, then runs the stop hooks. Connections still open when ctx is done
+// are closed.
+func (s *TCPService) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	l, done, stop := s.listener, s.done, s.stop
+	s.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+
+	s.running.Store(false)
+	// Wakes the accept loop up when it waits for a free connection slot
+	close(stop)
+	err := l.Close()
+	if errors.Is(err, net.ErrClosed) {
+		err = nil
+	}
+	<-done
+
+	drained := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		s.mu.Lock()
+		for conn := range s.conns {
+			_ = conn.Close()
+		}
+		s.mu.Unlock()
+		<-drained
+		err = errors.Join(err, ctx.Err())
+	}
+
+	s.mu.Lock()
+	s.listener = nil
+	s.done = nil
+	s.stop = nil
+	s.mu.Unlock()
+
+	if hookErr := s.hooks.Stop(ctx); hookErr != nil {
+		err = errors.Join(err, fmt.Errorf("failed to run stop hooks: %w", hookErr))
+	}
+	return err
+}
+
+// Restart stops the service, draining the running connections, and starts it
+// again on a new listener
+func (s *TCPService) Restart(ctx context.Context) error {
+	if err := s.Stop(ctx); err != nil {
+		return fmt.Errorf("failed to stop %s: %w", s.name, err)
+	}
+	return s.Start(ctx)
+}
+
+// Run starts the service and blocks until ctx is done, then stops it, see service.Run
+func (s *TCPService) Run(ctx context.Context) error {
+	return service.Run(ctx, s)
+}
+
+// Health reports whether the service is accepting connections
+func (s *TCPService) Health() bool {
+	return s.running.Load()
+}
+
+func (s *TCPService) accept(l net.Listener, done, stop chan struct{}) {
+	defer close(done)
+
+	var sem chan struct{}
+	if s.maxConns > 0 {
+		sem = make(chan struct{}, s.maxConns)
+	}
+
+	var delay time.Duration
+	for {
+		if sem != nil {
+			select {
+			case sem <- struct{}{}:
+			case <-stop:
+				return
+			}
+		}
+
+		conn, err := l.Accept()
+		if err != nil {
+			if sem != nil {
+				<-sem
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+
+			// Back off on errors such as running out of file descriptors
+			delay = min(max(2*delay, 5*time.Millisecond), time.Second)
+			s.log().Error("failed to accept connection", zap.Error(err))
+			time.Sleep(delay)
+			continue
+		}
+		delay = 0
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer func() {
+				if sem != nil {
+					<-sem
+				}
+			}()
+			s.serve(conn)
+		}()
+	}
+}
+
+// serve runs the handler on conn, then closes it
+func (s *TCPService) serve(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		_ = conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+	defer func() {
+		if r := recover(); r != nil {
+			s.log().Error("panic recovered",
+				zap.String("remote_addr", conn.RemoteAddr().String()),
+				zap.Any("panic", r),
+				zap.ByteString("stacktrace", debug.Stack()),
+			)
+		}
+	}()
+
+	var expiry time.Time
+	if s.connTimeout > 0 {
+		expiry = time.Now().Add(s.connTimeout)
+		_ = conn.SetDeadline(expiry)
+	}
+	if s.readTimeout > 0 || s.writeTimeout > 0 {
+		conn = &deadlineConn{Conn: conn, service: s, expiry: expiry}
+	}
+	s.handler(conn)
+}
+
+// log returns the logger of the service, tagged with its name, instance and
+// version by ForService
+func (s *TCPService) log() *logger.Logger {
+	s.logOnce.Do(func() {
+		if s.logger != nil {
+			s.serviceLog = s.logger.ForService(s.name)
+		} else {
+			s.serviceLog = logger.ForService(s.name)
+		}
+	})
+	return s.serviceLog
+}
+
+// deadlineConn sets the read and write deadlines before each call, never
+// past the lifetime of the connection
+type deadlineConn struct {
+	net.Conn
+	service *TCPService
+	expiry  time.Time
+}
+
+func (c *deadlineConn) Read(b []byte) (int, error) {
+	if d := c.service.readTimeout; d > 0 {
+		_ = c.Conn.SetReadDeadline(c.deadline(d))
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *deadlineConn) Write(b []byte) (int, error) {
+	if d := c.service.writeTimeout; d > 0 {
+		_ = c.Conn.SetWriteDeadline(c.deadline(d))
+	}
+	return c.Conn.Write(b)
+}
+
+func (c *deadlineConn) deadline(timeout time.Duration) time.Time {
+	t := time.Now().Add(timeout)
+	if !c.expiry.IsZero() && t.After(c.expiry) {
+		return c.expiry
+	}
+	return t
+}
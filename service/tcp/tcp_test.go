@@ -0,0 +1,168 @@
+package tcp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func echo(conn net.Conn) {
+	_, _ = io.Copy(conn, conn)
+}
+
+func TestTCPService(t *testing.T) {
+	ctx := context.Background()
+
+	svc := NewTCPService("echo", echo, WithAddress("127.0.0.1:0"))
+	assert.Equal(t, "echo", svc.Name())
+	assert.False(t, svc.Health())
+
+	assert.NoError(t, svc.Start(ctx))
+	assert.True(t, svc.Health())
+	assert.ErrorIs(t, svc.Start(ctx), errAlreadyStarted)
+
+	conn, err := net.Dial("tcp", svc.ListenAddr().String())
+	assert.NoError(t, err)
+	_, err = conn.Write([]byte("hello\n"))
+	assert.NoError(t, err)
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", line)
+
+	// Open connections are closed once the drain times out
+	stopCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, svc.Stop(stopCtx), context.DeadlineExceeded)
+	assert.False(t, svc.Health())
+	assert.Equal(t, 0, svc.Conns())
+
+	_, err = conn.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestTCPService_Drain(t *testing.T) {
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	served := make(chan struct{})
+	svc := NewTCPService("slow", func(conn net.Conn) {
+		<-release
+		_, _ = conn.Write([]byte("done"))
+		close(served)
+	}, WithAddress("127.0.0.1:0"))
+	assert.NoError(t, svc.Start(ctx))
+
+	conn, err := net.Dial("tcp", svc.ListenAddr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	assert.Eventually(t, func() bool { return svc.Conns() == 1 }, time.Second, 5*time.Millisecond)
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- svc.Stop(ctx)
+	}()
+
+	select {
+	case <-stopped:
+		t.Fatal("stop did not wait for the connection")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	assert.NoError(t, <-stopped)
+	<-served
+
+	body, err := io.ReadAll(conn)
+	assert.NoError(t, err)
+	assert.Equal(t, "done", string(body))
+}
+
+func TestTCPService_Limits(t *testing.T) {
+	ctx := context.Background()
+
+	svc := NewTCPService("echo", echo,
+		WithAddress("127.0.0.1:0"),
+		WithMaxConns(1),
+		WithReadTimeout(50*time.Millisecond),
+	)
+	assert.NoError(t, svc.Start(ctx))
+	defer svc.Stop(ctx)
+
+	first, err := net.Dial("tcp", svc.ListenAddr().String())
+	assert.NoError(t, err)
+	defer first.Close()
+	second, err := net.Dial("tcp", svc.ListenAddr().String())
+	assert.NoError(t, err)
+	defer second.Close()
+
+	// The second connection waits until the first one times out
+	_, err = second.Write([]byte("x"))
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return svc.Conns() == 1 }, time.Second, 5*time.Millisecond)
+
+	_ = second.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	_, err = second.Read(make([]byte, 1))
+	var netErr net.Error
+	assert.True(t, errors.As(err, &netErr) && netErr.Timeout())
+
+	_, err = first.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+
+	_ = second.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 1)
+	_, err = io.ReadFull(second, buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "x", string(buf))
+}
+
+func TestTCPService_StopSaturated(t *testing.T) {
+	ctx := context.Background()
+
+	// The handler returns once its connection is closed
+	svc := NewTCPService("sink", func(conn net.Conn) { _, _ = io.Copy(io.Discard, conn) },
+		WithAddress("127.0.0.1:0"), WithMaxConns(1))
+	assert.NoError(t, svc.Start(ctx))
+
+	conn, err := net.Dial("tcp", svc.ListenAddr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	assert.Eventually(t, func() bool { return svc.Conns() == 1 }, time.Second, 5*time.Millisecond)
+
+	// Stop gives up at the deadline even with every connection slot taken
+	stopCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	stopped := make(chan error, 1)
+	go func() { stopped <- svc.Stop(stopCtx) }()
+	select {
+	case err := <-stopped:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(time.Second):
+		t.Fatal("Stop ignored the context deadline")
+	}
+}
+
+func TestTCPService_Restart(t *testing.T) {
+	ctx := context.Background()
+
+	svc := NewTCPService("echo", echo, WithAddress("127.0.0.1:0"))
+	assert.NoError(t, svc.Start(ctx))
+	assert.NoError(t, svc.Restart(ctx))
+	assert.True(t, svc.Health())
+
+	conn, err := net.Dial("tcp", svc.ListenAddr().String())
+	assert.NoError(t, err)
+	_, err = conn.Write([]byte("hi\n"))
+	assert.NoError(t, err)
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "hi\n", line)
+	conn.Close()
+
+	assert.NoError(t, svc.Stop(ctx))
+}
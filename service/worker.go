@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ducconit/gocore/logger"
+	"go.uber.org/zap"
+)
+
+// ErrWorkerPanic is wrapped by the error of a worker loop that panicked
+var ErrWorkerPanic = errors.New("worker panicked")
+
+// WorkerOption represents a worker service option
+type WorkerOption func(*WorkerService)
+
+// WithWorkerBackoff sets the delay before restarting a failed loop, doubled
+// after each failure in a row up to max
+func WithWorkerBackoff(initial, max time.Duration) WorkerOption {
+	return func(s *WorkerService) {
+		s.backoff = initial
+		s.maxBackoff = max
+	}
+}
+
+// WithWorkerLogger sets the logger of loop failures, the global logger by default
+func WithWorkerLogger(l *logger.Logger) WorkerOption {
+	return func(s *WorkerService) {
+		s.logger = l
+	}
+}
+
+// WorkerService runs a long-running loop, such as a poller or a
+// reconciler, as a Service. The loop runs until its context is cancelled; a
+// loop returning an error or panicking is restarted with exponential
+// backoff, and a loop returning nil is done.
+type WorkerService struct {
+	name       string
+	run        func(ctx context.Context) error
+	backoff    time.Duration
+	maxBackoff time.Duration
+	logger     *logger.Logger
+	logOnce    sync.Once
+	serviceLog *logger.Logger
+	hooks      Hooks
+
+	mu       sync.Mutex
+	cancel   context.CancelFunc
+	done     chan struct{}
+	running  atomic.Bool
+	restarts atomic.Int64
+	lastErr  atomic.Pointer[error]
+}
+
+var (
+	_ Service       = (*WorkerService)(nil)
+	_ HealthChecker = (*WorkerService)(nil)
+)
+
+// NewWorkerService creates a worker service named name running run
+func NewWorkerService(name string, run func(ctx context.Context) error, opts ...WorkerOption) *WorkerService {
+	s := &WorkerService{
+		name:       name,
+		run:        run,
+		backoff:    DefaultRestartBackoff,
+		maxBackoff: DefaultMaxRestartBackoff,
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Name returns the name of the service
+func (s *WorkerService) Name() string {
+	return s.name
+}
+
+// Restarts returns the number of restarts of the loop after a failure
+func (s *WorkerService) Restarts() int64 {
+	return s.restarts.Load()
+}
+
+// OnStart adds a hook run before the loop starts, see Hooks
+func (s *WorkerService) OnStart(fn Hook) {
+	s.hooks.OnStart(fn)
+}
+
+// OnStop adds a hook run after the loop returns, see Hooks
+func (s *WorkerService) OnStop(fn Hook) {
+	s.hooks.OnStop(fn)
+}
+
+// Start runs the start hooks, then runs the loop in the background
+func (s *WorkerService) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done != nil {
+		return nil
+	}
+	if err := s.hooks.Start(ctx); err != nil {
+		return fmt.Errorf("failed to run start hooks: %w", err)
+	}
+
+	ctx, s.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	s.done = make(chan struct{})
+	s.lastErr.Store(nil)
+	s.running.Store(true)
+	go s.loop(ctx, s.done)
+	return nil
+}
+
+// Stop cancels the context of the loop and waits for it to return, then
+// runs the stop hooks
+func (s *WorkerService) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done == nil {
+		return nil
+	}
+	s.cancel()
+
+	var err error
+	select {
+	case <-s.done:
+		s.done = nil
+	case <-ctx.Done():
+		err = fmt.Errorf("worker %s did not stop: %w", s.name, ctx.Err())
+	}
+
+	if hookErr := s.hooks.Stop(ctx); hookErr != nil {
+		err = errors.Join(err, fmt.Errorf("failed to run stop hooks: %w", hookErr))
+	}
+	return err
+}
+
+// Health reports whether the loop is running. It is not while the loop
+// waits to be restarted after a failure, or once it is done.
+func (s *WorkerService) Health() bool {
+	return s.running.Load()
+}
+
+// Check reports the last failure of the loop while it is not running
+func (s *WorkerService) Check(ctx context.Context) error {
+	if s.Health() {
+		return nil
+	}
+	if err := s.lastErr.Load(); err != nil {
+		return fmt.Errorf("restarted %d times: %w", s.Restarts(), *err)
+	}
+	return errors.New("worker is not running")
+}
+
+// loop runs the loop, restarting it after failures, until ctx is done
+func (s *WorkerService) loop(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	defer s.running.Store(false)
+
+	attempts := 0
+	for {
+		started := time.Now()
+		err := s.safeRun(ctx)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+
+		s.running.Store(false)
+		s.lastErr.Store(&err)
+		if time.Since(started) >= s.maxBackoff {
+			attempts = 0
+		}
+		attempts++
+
+		delay := backoffDelay(s.backoff, s.maxBackoff, attempts)
+		s.log().Error("worker failed, restarting", zap.Duration("backoff", delay), zap.Int("attempt", attempts), zap.Error(err))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.restarts.Add(1)
+		s.running.Store(true)
+	}
+}
+
+// safeRun runs the loop, turning a panic into an error wrapping ErrWorkerPanic
+func (s *WorkerService) safeRun(ctx context.Context) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", ErrWorkerPanic, r)
+			s.log().Error("worker panicked", zap.Any("panic", r), zap.ByteString("stack", debug.Stack()))
+		}
+	}()
+	return s.run(ctx)
+}
+
+// log returns the logger of the service, tagged with its name, instance and
+// version by ForService
+func (s *WorkerService) log() *logger.Logger {
+	s.logOnce.Do(func() {
+		if s.logger != nil {
+			s.serviceLog = s.logger.ForService(s.name)
+		} else {
+			s.serviceLog = logger.ForService(s.name)
+		}
+	})
+	return s.serviceLog
+}
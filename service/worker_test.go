@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ducconit/gocore/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkerService(t *testing.T) {
+	ctx := context.Background()
+
+	var ticks atomic.Int64
+	w := NewWorkerService("poller", func(ctx context.Context) error {
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+				ticks.Add(1)
+			}
+		}
+	})
+	assert.Equal(t, "poller", w.Name())
+	assert.False(t, w.Health())
+
+	assert.NoError(t, w.Start(ctx))
+	assert.True(t, w.Health())
+	assert.NoError(t, w.Check(ctx))
+	assert.Eventually(t, func() bool { return ticks.Load() > 2 }, time.Second, time.Millisecond)
+
+	assert.NoError(t, w.Stop(ctx))
+	assert.False(t, w.Health())
+	assert.Zero(t, w.Restarts())
+}
+
+func TestWorkerService_RestartOnError(t *testing.T) {
+	ctx := context.Background()
+	var buf syncBuffer
+
+	var runs atomic.Int64
+	w := NewWorkerService("reconciler", func(ctx context.Context) error {
+		switch runs.Add(1) {
+		case 1:
+			return errors.New("connection reset")
+		case 2:
+			panic("boom")
+		}
+		<-ctx.Done()
+		return nil
+	},
+		WithWorkerBackoff(time.Millisecond, 10*time.Millisecond),
+		WithWorkerLogger(logger.New(logger.WithOutput(&buf))),
+	)
+
+	assert.NoError(t, w.Start(ctx))
+	assert.Eventually(t, func() bool { return runs.Load() == 3 && w.Health() }, time.Second, time.Millisecond)
+	assert.Equal(t, int64(2), w.Restarts())
+	assert.NoError(t, w.Stop(ctx))
+
+	out := buf.String()
+	assert.Equal(t, 2, strings.Count(out, `"msg":"worker failed, restarting"`))
+	assert.Contains(t, out, `"error":"connection reset"`)
+	assert.Contains(t, out, `"msg":"worker panicked"`)
+}
+
+func TestWorkerService_Backoff(t *testing.T) {
+	ctx := context.Background()
+	w := NewWorkerService("poller", func(ctx context.Context) error {
+		return errors.New("unreachable")
+	}, WithWorkerBackoff(time.Hour, time.Hour))
+
+	assert.NoError(t, w.Start(ctx))
+	assert.Eventually(t, func() bool { return !w.Health() }, time.Second, time.Millisecond)
+	assert.EqualError(t, w.Check(ctx), "restarted 0 times: unreachable")
+
+	// Stopping interrupts the backoff
+	assert.NoError(t, w.Stop(ctx))
+}
+
+func TestWorkerService_Done(t *testing.T) {
+	ctx := context.Background()
+	w := NewWorkerService("migration", func(ctx context.Context) error {
+		return nil
+	})
+
+	assert.NoError(t, w.Start(ctx))
+	assert.Eventually(t, func() bool { return !w.Health() }, time.Second, time.Millisecond)
+	assert.EqualError(t, w.Check(ctx), "worker is not running")
+	assert.Zero(t, w.Restarts())
+	assert.NoError(t, w.Stop(ctx))
+}
+
+func TestWorkerService_StopTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	w := NewWorkerService("stuck", func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+	assert.NoError(t, w.Start(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, w.Stop(ctx), context.DeadlineExceeded)
+}